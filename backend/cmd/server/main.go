@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 
@@ -10,8 +11,10 @@ import (
 	"github.com/user/user-management/internal/database"
 	"github.com/user/user-management/internal/handlers"
 	"github.com/user/user-management/internal/middleware"
+	"github.com/user/user-management/internal/models"
 	"github.com/user/user-management/internal/repository"
 	"github.com/user/user-management/internal/service"
+	"github.com/user/user-management/internal/storage"
 )
 
 func main() {
@@ -48,15 +51,121 @@ func main() {
 
 	// 初始化仓库
 	userRepo := repository.NewUserRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	accessTokenRepo := repository.NewAccessTokenRepository(db)
+	serviceAccountRepo := repository.NewServiceAccountRepository(db)
+	oauthClientRepo := repository.NewOAuthClientRepository(db)
+	identityRepo := repository.NewLinkedIdentityRepository(db)
+	samlConnectionRepo := repository.NewSAMLConnectionRepository(db)
+	groupRepo := repository.NewGroupRepository(db)
+	webAuthnCredentialRepo := repository.NewWebAuthnCredentialRepository(db)
+	passwordHistoryRepo := repository.NewPasswordHistoryRepository(db)
+	organizationRepo := repository.NewOrganizationRepository(db)
+	userInvitationRepo := repository.NewUserInvitationRepository(db)
+	attributeRepo := repository.NewAttributeRepository(db)
+
+	// 加载ID Token签名密钥
+	signingKey, err := service.LoadSigningKey(cfg.OIDC.SigningKeyFile)
+	if err != nil {
+		log.Fatal("Failed to load OIDC signing key:", err)
+	}
+
+	// 加载SAML服务提供方证书
+	samlKeyPair, err := service.LoadSAMLKeyPair(cfg.SAML.CertificateFile, cfg.SAML.KeyFile)
+	if err != nil {
+		log.Fatal("Failed to load SAML key pair:", err)
+	}
+
+	// 上传的头像保存在本地目录或S3兼容的对象存储中
+	blobStore, err := storage.New(cfg.Blob)
+	if err != nil {
+		log.Fatal("Failed to configure blob storage:", err)
+	}
 
 	// 初始化服务
 	sessionService := service.NewSessionService(redisClient)
-	authService := service.NewAuthService(userRepo, sessionService, cfg.JWT.Secret, cfg.JWT.AccessTokenExpiry)
-	userService := service.NewUserService(userRepo)
+	auditService := service.NewAuditService(auditRepo)
+
+	// 新密码按配置的算法哈希，旧哈希在登录时重新生成
+	passwordHasher, err := service.NewPasswordHasher(cfg.Password)
+	if err != nil {
+		log.Fatal("Failed to configure password hashing:", err)
+	}
+
+	// 新密码须满足密码策略；配置了泄露密码文件时按哈希前缀查找
+	var breachedPasswords service.BreachedPasswordSource
+	if cfg.PasswordPolicy.BreachedPasswordsFile != "" {
+		if breachedPasswords, err = service.OpenBreachedPasswordFile(cfg.PasswordPolicy.BreachedPasswordsFile); err != nil {
+			log.Fatal("Failed to open breached passwords file:", err)
+		}
+	}
+	passwordPolicy := service.NewPasswordPolicy(cfg.PasswordPolicy, passwordHasher, passwordHistoryRepo, breachedPasswords)
+
+	// 登录时先查LDAP目录，目录中没有的用户再校验本地密码
+	authenticators := []service.Authenticator{}
+	if cfg.LDAP.URL != "" {
+		ldapService, err := service.NewLDAPService(cfg.LDAP, userRepo, identityRepo, sessionService, auditService)
+		if err != nil {
+			log.Fatal("Failed to configure LDAP:", err)
+		}
+		authenticators = append(authenticators, ldapService)
+		if cfg.LDAP.SyncInterval > 0 && cfg.LDAP.BindDN != "" {
+			go ldapService.RunSync(context.Background(), cfg.LDAP.SyncInterval)
+		}
+	}
+	authenticators = append(authenticators, service.NewPasswordAuthenticator(userRepo, passwordHasher))
+
+	// 已注册安全密钥的用户密码登录后还需完成WebAuthn验证
+	webAuthnService, err := service.NewWebAuthnService(cfg.WebAuthn, webAuthnCredentialRepo, userRepo, redisClient)
+	if err != nil {
+		log.Fatal("Failed to configure WebAuthn:", err)
+	}
+
+	// 访问令牌的groups声明包含用户的有效组，包括通过子组间接所属的组
+	groupService := service.NewGroupService(cfg.Group, groupRepo, userRepo)
+	authService := service.NewAuthService(userRepo, serviceAccountRepo, organizationRepo, groupService, sessionService, authenticators, webAuthnService, passwordPolicy, cfg.JWT.Secret, cfg.JWT.AccessTokenExpiry, cfg.Impersonation.TTL)
+	mailer := service.NewMailer(cfg.SMTP)
+	magicLinkService := service.NewMagicLinkService(cfg.MagicLink, userRepo, webAuthnService, mailer, redisClient, cfg.OIDC.Issuer)
+	userService := service.NewUserService(userRepo, attributeRepo, passwordPolicy)
+	attributeService := service.NewAttributeService(attributeRepo)
+	avatarService := service.NewAvatarService(cfg.Avatar, userRepo, blobStore)
+	profileService := service.NewProfileService(cfg.StepUp, cfg.EmailChange, userService, userRepo, sessionService, passwordHasher, mailer, redisClient, cfg.OIDC.Issuer)
+	privacyService := service.NewPrivacyService(cfg.StepUp, userRepo, auditRepo, accessTokenRepo, identityRepo, webAuthnCredentialRepo, sessionService, passwordHasher, blobStore)
+	accessTokenService := service.NewAccessTokenService(accessTokenRepo, userRepo, organizationRepo)
+	organizationService := service.NewOrganizationService(cfg.Organization, organizationRepo, userRepo, mailer, cfg.OIDC.Issuer)
+	userInvitationService := service.NewUserInvitationService(cfg.Registration, userInvitationRepo, userRepo, organizationRepo, passwordPolicy, mailer, cfg.OIDC.Issuer)
+	serviceAccountService := service.NewServiceAccountService(serviceAccountRepo)
+	oauthClientService := service.NewOAuthClientService(oauthClientRepo)
+	oidcService := service.NewOIDCService(oauthClientRepo, userRepo, redisClient, signingKey, cfg.OIDC.Issuer, cfg.JWT.AccessTokenExpiry)
+	federationService := service.NewFederationService(cfg.Federation, userRepo, identityRepo, redisClient)
+	samlService := service.NewSAMLService(samlConnectionRepo, userRepo, identityRepo, redisClient, samlKeyPair, cfg.OIDC.Issuer, cfg.Registration.InvitationOnly())
+	scimService := service.NewSCIMService(userRepo, groupRepo, sessionService, passwordPolicy)
 
 	// 初始化处理器
-	authHandler := handlers.NewAuthHandler(authService)
-	userHandler := handlers.NewUserHandler(userService)
+	authHandler := handlers.NewAuthHandler(authService, auditService, cfg.Registration.InvitationOnly())
+	userHandler := handlers.NewUserHandler(userService, profileService, groupService, attributeService, auditService, cfg.Server.RequireIfMatch)
+	privacyHandler := handlers.NewPrivacyHandler(privacyService, auditService)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	accessTokenHandler := handlers.NewAccessTokenHandler(accessTokenService, auditService)
+	oauthHandler := handlers.NewOAuthHandler(authService, oidcService, auditService)
+	serviceAccountHandler := handlers.NewServiceAccountHandler(serviceAccountService, auditService)
+	oauthClientHandler := handlers.NewOAuthClientHandler(oauthClientService, auditService)
+	oidcHandler := handlers.NewOIDCHandler(oidcService, auditService)
+	federationHandler := handlers.NewFederationHandler(federationService, authService, auditService)
+	samlHandler := handlers.NewSAMLHandler(samlService, federationService, authService, auditService)
+	magicLinkHandler := handlers.NewMagicLinkHandler(magicLinkService, authService, auditService, cfg.MagicLink.TTL)
+	webAuthnHandler := handlers.NewWebAuthnHandler(webAuthnService, authService, auditService)
+	impersonationHandler := handlers.NewImpersonationHandler(authService, auditService)
+	organizationHandler := handlers.NewOrganizationHandler(organizationService, authService, auditService)
+	groupHandler := handlers.NewGroupHandler(groupService, auditService)
+	userInvitationHandler := handlers.NewUserInvitationHandler(userInvitationService, auditService)
+	attributeHandler := handlers.NewAttributeHandler(attributeService, auditService)
+	avatarHandler := handlers.NewAvatarHandler(avatarService, auditService, cfg.Avatar.MaxSize)
+	scimHandler := handlers.NewSCIMHandler(scimService, auditService, cfg.OIDC.Issuer)
+	docsHandler, err := handlers.NewDocsHandler()
+	if err != nil {
+		log.Fatal("Failed to build OpenAPI document:", err)
+	}
 
 	// 设置Gin模式
 	gin.SetMode(os.Getenv("GIN_MODE"))
@@ -65,32 +174,179 @@ func main() {
 	router := gin.Default()
 
 	// 中间件
+	router.Use(middleware.RequestID())
+	router.Use(middleware.Locale())
 	router.Use(middleware.CORS())
 	router.Use(middleware.ErrorHandler())
 
+	// OpenID Connect发现文档和签名公钥
+	router.GET("/.well-known/openid-configuration", oidcHandler.Discovery)
+	router.GET("/.well-known/jwks.json", oidcHandler.JWKS)
+
+	// 本地存储的文件由API服务直接提供访问
+	if cfg.Blob.Driver == config.BlobStoreLocal {
+		router.Static(storage.LocalPublicPath, cfg.Blob.LocalDir)
+	}
+
 	// API路由组
 	api := router.Group("/api/v1")
 	{
+		// API文档
+		api.GET("/openapi.json", docsHandler.Spec)
+		api.GET("/docs", docsHandler.UI)
+		api.GET("/docs/swagger-ui/:file", docsHandler.Assets)
+
 		// 认证路由
 		auth := api.Group("/auth")
 		{
+			// REGISTRATION_MODE=invitation时公开注册关闭，只能通过管理员发出的邀请链接注册
+			auth.GET("/registration", authHandler.Registration)
 			auth.POST("/register", authHandler.Register)
+			auth.POST("/invitations/lookup", userInvitationHandler.LookupInvitation)
+			auth.POST("/invitations/accept", userInvitationHandler.AcceptInvitation)
 			auth.POST("/login", authHandler.Login)
-			auth.POST("/logout", middleware.Auth(authService), authHandler.Logout)
+			auth.POST("/logout", middleware.Auth(authService, accessTokenService), middleware.RequireSession(), middleware.RejectImpersonation(), authHandler.Logout)
 			auth.POST("/refresh", authHandler.RefreshToken)
+
+			// 通过邮件中的一次性链接登录
+			auth.POST("/magic-link", magicLinkHandler.Request)
+			auth.POST("/magic-link/consume", magicLinkHandler.Consume)
+
+			// 确认发往新旧邮箱的修改邮箱链接
+			auth.POST("/email-change/confirm", userHandler.ConfirmEmailChange)
+
+			// 通过外部OpenID Connect身份提供方登录
+			auth.GET("/oidc", federationHandler.Provider)
+			auth.GET("/oidc/login", federationHandler.Login)
+			auth.GET("/oidc/callback", federationHandler.Callback)
+			auth.POST("/oidc/exchange", federationHandler.Exchange)
+
+			// 通过组织的SAML身份提供方登录，登录码同样由/oidc/exchange领取
+			auth.GET("/saml/:slug/metadata", samlHandler.Metadata)
+			auth.GET("/saml/:slug/login", samlHandler.Login)
+			auth.POST("/saml/:slug/acs", samlHandler.ACS)
+
+			// 通行密钥登录，或携带密码登录返回的票据完成第二因素验证
+			auth.POST("/webauthn/login/begin", webAuthnHandler.BeginLogin)
+			auth.POST("/webauthn/login/finish", webAuthnHandler.FinishLogin)
+
+			// 管理员使用模拟令牌结束模拟，模拟令牌不能退出登录
+			auth.POST("/impersonation/end", middleware.Auth(authService, accessTokenService), impersonationHandler.EndImpersonation)
 		}
 
+		// OAuth2/OIDC端点，授权同意只能由用户本人的登录会话完成
+		oauth := api.Group("/oauth")
+		{
+			oauth.POST("/token", oauthHandler.Token)
+			oauth.GET("/authorize", middleware.Auth(authService, accessTokenService), middleware.RequireSession(), middleware.RejectImpersonation(), oidcHandler.Authorize)
+			oauth.POST("/authorize", middleware.Auth(authService, accessTokenService), middleware.RequireSession(), middleware.RejectImpersonation(), oidcHandler.AuthorizeDecision)
+		}
+		api.GET("/userinfo", oidcHandler.UserInfo)
+		api.POST("/userinfo", oidcHandler.UserInfo)
+
 		// 用户路由（需要认证）
 		users := api.Group("/users")
-		users.Use(middleware.Auth(authService))
+		users.Use(middleware.Auth(authService, accessTokenService))
 		{
-			users.GET("", userHandler.GetUsers)
-			users.GET("/:id", userHandler.GetUser)
-			users.PUT("/:id", userHandler.UpdateUser)
-			users.DELETE("/:id", userHandler.DeleteUser)
-			users.GET("/profile", userHandler.GetProfile)
-			users.PUT("/profile", userHandler.UpdateProfile)
+			users.GET("", middleware.RequireScope(models.ScopeUsersRead), userHandler.GetUsers)
+			users.GET("/:id", middleware.RequireScope(models.ScopeUsersRead), userHandler.GetUser)
+			users.GET("/:id/groups", middleware.RequireScope(models.ScopeUsersRead), userHandler.GetUserGroups)
+			registerUserWriteRoutes(users, userHandler)
+			users.GET("/attributes", middleware.RequireScope(models.ScopeProfileRead), attributeHandler.ListAttributes)
+			users.GET("/profile", middleware.RequireScope(models.ScopeProfileRead), userHandler.GetProfile)
+			users.PUT("/profile", middleware.RequireScope(models.ScopeProfileWrite), userHandler.UpdateProfile)
+			users.PATCH("/profile", middleware.RequireScope(models.ScopeProfileWrite), userHandler.PatchProfile)
+			users.PUT("/profile/avatar", middleware.RequireScope(models.ScopeProfileWrite), avatarHandler.UploadAvatar)
+			users.DELETE("/profile/avatar", middleware.RequireScope(models.ScopeProfileWrite), avatarHandler.DeleteAvatar)
+			users.GET("/profile/export", middleware.RequireSession(), middleware.RejectImpersonation(), privacyHandler.ExportProfile)
+			users.POST("/profile/erase", middleware.RequireSession(), middleware.RejectImpersonation(), privacyHandler.EraseProfile)
+
+			// 个人访问令牌只能通过登录会话管理，模拟用户时只能查看
+			users.GET("/profile/tokens", middleware.RequireSession(), accessTokenHandler.ListTokens)
+			users.POST("/profile/tokens", middleware.RequireSession(), middleware.RejectImpersonation(), accessTokenHandler.CreateToken)
+			users.DELETE("/profile/tokens/:id", middleware.RequireSession(), middleware.RejectImpersonation(), accessTokenHandler.RevokeToken)
+
+			// 安全密钥同样只能通过登录会话管理
+			users.GET("/profile/webauthn", middleware.RequireSession(), webAuthnHandler.ListCredentials)
+			users.POST("/profile/webauthn/register/begin", middleware.RequireSession(), middleware.RejectImpersonation(), webAuthnHandler.BeginRegistration)
+			users.POST("/profile/webauthn/register/finish", middleware.RequireSession(), middleware.RejectImpersonation(), webAuthnHandler.FinishRegistration)
+			users.DELETE("/profile/webauthn/:id", middleware.RequireSession(), middleware.RejectImpersonation(), webAuthnHandler.DeleteCredential)
 		}
+
+		// 组织路由，切换组织会签发新的会话令牌，模拟用户期间不能访问
+		organizations := api.Group("/organizations")
+		organizations.Use(middleware.Auth(authService, accessTokenService), middleware.RequireSession(), middleware.RejectImpersonation())
+		{
+			organizations.GET("", organizationHandler.ListOrganizations)
+			organizations.POST("", organizationHandler.CreateOrganization)
+			organizations.POST("/invitations/accept", organizationHandler.AcceptInvitation)
+			organizations.POST("/:id/switch", middleware.RequireOrganizationRole(organizationService), organizationHandler.SwitchOrganization)
+			organizations.DELETE("/:id", middleware.RequireOrganizationRole(organizationService, models.OrgRoleOwner), organizationHandler.DeleteOrganization)
+			organizations.GET("/:id/members", middleware.RequireOrganizationRole(organizationService), organizationHandler.ListMembers)
+			organizations.PATCH("/:id/members/:user_id", middleware.RequireOrganizationRole(organizationService, models.OrgRoleOwner, models.OrgRoleAdmin), organizationHandler.UpdateMemberRole)
+			organizations.DELETE("/:id/members/:user_id", middleware.RequireOrganizationRole(organizationService), organizationHandler.RemoveMember)
+			organizations.GET("/:id/invitations", middleware.RequireOrganizationRole(organizationService, models.OrgRoleOwner, models.OrgRoleAdmin), organizationHandler.ListInvitations)
+			organizations.POST("/:id/invitations", middleware.RequireOrganizationRole(organizationService, models.OrgRoleOwner, models.OrgRoleAdmin), organizationHandler.CreateInvitation)
+			organizations.DELETE("/:id/invitations/:invitation_id", middleware.RequireOrganizationRole(organizationService, models.OrgRoleOwner, models.OrgRoleAdmin), organizationHandler.RevokeInvitation)
+		}
+
+		// 管理员路由，模拟用户期间不能访问
+		admin := api.Group("/admin")
+		admin.Use(middleware.Auth(authService, accessTokenService), middleware.RejectImpersonation(), middleware.RequireRole(userService, models.RoleAdmin))
+		{
+			admin.POST("/users/:id/impersonate", middleware.RequireSession(), impersonationHandler.Impersonate)
+			admin.GET("/service-accounts", middleware.RequireSession(), serviceAccountHandler.ListServiceAccounts)
+			admin.POST("/service-accounts", middleware.RequireSession(), serviceAccountHandler.CreateServiceAccount)
+			admin.DELETE("/service-accounts/:id", middleware.RequireSession(), serviceAccountHandler.DeleteServiceAccount)
+			admin.GET("/oauth-clients", middleware.RequireSession(), oauthClientHandler.ListClients)
+			admin.POST("/oauth-clients", middleware.RequireSession(), oauthClientHandler.RegisterClient)
+			admin.DELETE("/oauth-clients/:id", middleware.RequireSession(), oauthClientHandler.DeleteClient)
+			admin.GET("/saml-connections", middleware.RequireSession(), samlHandler.ListConnections)
+			admin.POST("/saml-connections", middleware.RequireSession(), samlHandler.CreateConnection)
+			admin.DELETE("/saml-connections/:id", middleware.RequireSession(), samlHandler.DeleteConnection)
+			admin.GET("/groups", middleware.RequireSession(), groupHandler.ListGroups)
+			admin.POST("/groups", middleware.RequireSession(), groupHandler.CreateGroup)
+			admin.GET("/groups/:id", middleware.RequireSession(), groupHandler.GetGroup)
+			admin.DELETE("/groups/:id", middleware.RequireSession(), groupHandler.DeleteGroup)
+			admin.PUT("/groups/:id/members/:user_id", middleware.RequireSession(), groupHandler.AddMember)
+			admin.DELETE("/groups/:id/members/:user_id", middleware.RequireSession(), groupHandler.RemoveMember)
+			admin.PUT("/groups/:id/subgroups/:child_id", middleware.RequireSession(), groupHandler.AddSubgroup)
+			admin.DELETE("/groups/:id/subgroups/:child_id", middleware.RequireSession(), groupHandler.RemoveSubgroup)
+			admin.GET("/invitations", middleware.RequireSession(), userInvitationHandler.ListInvitations)
+			admin.POST("/invitations", middleware.RequireSession(), userInvitationHandler.CreateInvitation)
+			admin.POST("/invitations/:id/resend", middleware.RequireSession(), userInvitationHandler.ResendInvitation)
+			admin.DELETE("/invitations/:id", middleware.RequireSession(), userInvitationHandler.RevokeInvitation)
+			admin.POST("/attributes", middleware.RequireSession(), attributeHandler.CreateAttribute)
+			admin.PUT("/attributes/:id", middleware.RequireSession(), attributeHandler.UpdateAttribute)
+			admin.DELETE("/attributes/:id", middleware.RequireSession(), attributeHandler.DeleteAttribute)
+		}
+
+		// 审计日志由管理员或AUDIT_LOG_GROUPS中任一组（包括子组）的成员查看，模拟用户期间不能访问
+		auditLogs := api.Group("/admin/audit-logs")
+		auditLogs.Use(middleware.Auth(authService, accessTokenService), middleware.RejectImpersonation(), middleware.RequireGroup(userService, groupService, cfg.Group.AuditLogGroups...))
+		{
+			auditLogs.GET("", middleware.RequireScope(models.ScopeAuditRead), auditHandler.ListAuditLogs)
+			auditLogs.GET("/verify", middleware.RequireScope(models.ScopeAuditRead), auditHandler.VerifyAuditLogs)
+		}
+	}
+
+	// SCIM 2.0 自动配置，由身份提供方以SCIM_TOKEN调用
+	scim := router.Group("/scim/v2")
+	scim.Use(middleware.SCIMErrorHandler(), middleware.SCIMAuth(cfg.SCIM.Token))
+	{
+		scim.GET("/ServiceProviderConfig", scimHandler.ServiceProviderConfig)
+		scim.GET("/Users", scimHandler.ListUsers)
+		scim.POST("/Users", scimHandler.CreateUser)
+		scim.GET("/Users/:id", scimHandler.GetUser)
+		scim.PUT("/Users/:id", scimHandler.ReplaceUser)
+		scim.PATCH("/Users/:id", scimHandler.PatchUser)
+		scim.DELETE("/Users/:id", scimHandler.DeleteUser)
+		scim.GET("/Groups", scimHandler.ListGroups)
+		scim.POST("/Groups", scimHandler.CreateGroup)
+		scim.GET("/Groups/:id", scimHandler.GetGroup)
+		scim.PUT("/Groups/:id", scimHandler.ReplaceGroup)
+		scim.PATCH("/Groups/:id", scimHandler.PatchGroup)
+		scim.DELETE("/Groups/:id", scimHandler.DeleteGroup)
 	}
 
 	// 健康检查
@@ -122,4 +378,12 @@ func main() {
 	if err := router.Run(":" + port); err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
-}
\ No newline at end of file
+}
+
+// registerUserWriteRoutes 注册修改和删除用户的路由，users组上已注册认证中间件。
+// 模拟期间只能查看，不能修改或删除用户
+func registerUserWriteRoutes(users *gin.RouterGroup, userHandler *handlers.UserHandler) {
+	users.PUT("/:id", middleware.RequireScope(models.ScopeUsersWrite), middleware.RejectImpersonation(), userHandler.UpdateUser)
+	users.PATCH("/:id", middleware.RequireScope(models.ScopeUsersWrite), middleware.RejectImpersonation(), userHandler.PatchUser)
+	users.DELETE("/:id", middleware.RequireScope(models.ScopeUsersWrite), middleware.RejectImpersonation(), userHandler.DeleteUser)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/handlers"
+	"github.com/user/user-management/internal/middleware"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/service"
+)
+
+var routeMethods = map[string]bool{
+	"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "HEAD": true, "OPTIONS": true,
+}
+
+type route struct {
+	method string
+	path   string
+}
+
+// registeredRoutes 解析main.go，按Group的前缀还原所有注册的路由
+func registeredRoutes(t *testing.T) []route {
+	t.Helper()
+
+	file, err := parser.ParseFile(token.NewFileSet(), "main.go", nil, 0)
+	if err != nil {
+		t.Fatalf("parse main.go: %v", err)
+	}
+
+	prefixes := map[string]string{"router": "/"}
+	var routes []route
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.AssignStmt:
+			// api := router.Group("/api/v1")
+			if len(node.Lhs) != 1 || len(node.Rhs) != 1 {
+				return true
+			}
+			name, ok := node.Lhs[0].(*ast.Ident)
+			if !ok {
+				return true
+			}
+			if parent, method, arg, ok := routerCall(node.Rhs[0]); ok && method == "Group" {
+				if base, known := prefixes[parent]; known {
+					prefixes[name.Name] = path.Join(base, arg)
+				}
+			}
+		case *ast.CallExpr:
+			// users.GET("/:id", ...)
+			if receiver, method, arg, ok := routerCall(node); ok && routeMethods[method] {
+				base, known := prefixes[receiver]
+				if !known {
+					t.Errorf("route %s %s registered on unknown group %s", method, arg, receiver)
+					return true
+				}
+				routes = append(routes, route{method: method, path: path.Join(base, arg)})
+			}
+		}
+		return true
+	})
+
+	return routes
+}
+
+func routerCall(expr ast.Expr) (receiver, method, arg string, ok bool) {
+	call, isCall := expr.(*ast.CallExpr)
+	if !isCall || len(call.Args) == 0 {
+		return "", "", "", false
+	}
+	sel, isSel := call.Fun.(*ast.SelectorExpr)
+	if !isSel {
+		return "", "", "", false
+	}
+	ident, isIdent := sel.X.(*ast.Ident)
+	if !isIdent {
+		return "", "", "", false
+	}
+	lit, isLit := call.Args[0].(*ast.BasicLit)
+	if !isLit || lit.Kind != token.STRING {
+		return "", "", "", false
+	}
+	value, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return "", "", "", false
+	}
+	return ident.Name, sel.Sel.Name, value, true
+}
+
+func TestRoutesDocumentedInOpenAPISpec(t *testing.T) {
+	routes := registeredRoutes(t)
+	if len(routes) == 0 {
+		t.Fatal("no routes found in main.go")
+	}
+
+	spec := handlers.APISpec()
+	for _, r := range routes {
+		if !spec.Has(r.method, r.path) {
+			t.Errorf("route %s %s is registered in main.go but missing from the OpenAPI spec", r.method, r.path)
+		}
+	}
+}
+
+type stubAuthService struct {
+	service.AuthService
+	principals map[string]*service.Principal
+}
+
+func (s *stubAuthService) ValidateToken(token string) (*service.Principal, error) {
+	if principal, ok := s.principals[token]; ok {
+		return principal, nil
+	}
+	return nil, service.ErrInvalidToken
+}
+
+type stubUserService struct {
+	service.UserService
+	users map[uint]*models.User
+	// writes 记录到达服务层的修改和删除
+	writes []uint
+}
+
+func (s *stubUserService) GetByID(ctx context.Context, id uint) (*models.User, error) {
+	user, ok := s.users[id]
+	if !ok {
+		return nil, service.ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (s *stubUserService) UpdateUser(ctx context.Context, id uint, updates map[string]interface{}, version uint) (*models.User, error) {
+	s.writes = append(s.writes, id)
+	return s.GetByID(ctx, id)
+}
+
+func (s *stubUserService) DeleteUser(ctx context.Context, id uint) error {
+	s.writes = append(s.writes, id)
+	return nil
+}
+
+type stubAttributeService struct {
+	service.AttributeService
+}
+
+func (stubAttributeService) Redact(viewerID uint, admin bool, users ...*models.User) error {
+	return nil
+}
+
+type stubAuditService struct {
+	service.AuditService
+}
+
+func (stubAuditService) Record(event service.AuditEvent) error {
+	return nil
+}
+
+func TestUserWritesRejectImpersonation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	users := &stubUserService{users: map[uint]*models.User{
+		1: {ID: 1, Username: "admin", Email: "admin@example.com", Role: models.RoleAdmin, IsActive: true, Version: 1},
+		3: {ID: 3, Username: "bob", Email: "bob@example.com", Role: models.RoleUser, IsActive: true, Version: 1},
+	}}
+	auth := &stubAuthService{principals: map[string]*service.Principal{
+		"admin-jwt": {Type: service.PrincipalUser, ID: 1, SessionID: "s1"},
+		// 管理员5模拟管理员1登录
+		"impersonation-jwt": {Type: service.PrincipalUser, ID: 1, SessionID: "s2", ActorID: 5},
+	}}
+
+	// 与main相同的users组和中间件链
+	router := gin.New()
+	router.Use(middleware.ErrorHandler())
+	group := router.Group("/api/v1/users")
+	group.Use(middleware.Auth(auth, nil))
+	registerUserWriteRoutes(group, handlers.NewUserHandler(users, nil, nil, stubAttributeService{}, stubAuditService{}, false))
+
+	writes := []struct {
+		method, contentType, body string
+	}{
+		{http.MethodPut, "application/json", `{"email":"bob2@example.com"}`},
+		{http.MethodPatch, "application/merge-patch+json", `{"email":"bob2@example.com"}`},
+		{http.MethodDelete, "", ""},
+	}
+	request := func(token, method, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/users/3", bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, w := range writes {
+		rec := request("impersonation-jwt", w.method, w.contentType, w.body)
+		var problem middleware.Problem
+		json.Unmarshal(rec.Body.Bytes(), &problem)
+		if rec.Code != http.StatusForbidden || !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/problem+json") || problem.Code != "impersonation_forbidden" {
+			t.Fatalf("expected %s while impersonating to return 403 impersonation_forbidden, got %d %s", w.method, rec.Code, rec.Body.String())
+		}
+	}
+	if len(users.writes) != 0 {
+		t.Fatalf("expected no writes while impersonating, got %v", users.writes)
+	}
+
+	for _, w := range writes {
+		if rec := request("admin-jwt", w.method, w.contentType, w.body); rec.Code != http.StatusOK {
+			t.Fatalf("expected admin %s to succeed, got %d %s", w.method, rec.Code, rec.Body.String())
+		}
+	}
+	if len(users.writes) != len(writes) {
+		t.Fatalf("expected every admin write to reach the service, got %v", users.writes)
+	}
+}
@@ -2,18 +2,40 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	JWT      JWTConfig
+	Server         ServerConfig
+	Database       DatabaseConfig
+	Redis          RedisConfig
+	JWT            JWTConfig
+	OIDC           OIDCConfig
+	Federation     FederationConfig
+	SAML           SAMLConfig
+	LDAP           LDAPConfig
+	SCIM           SCIMConfig
+	WebAuthn       WebAuthnConfig
+	SMTP           SMTPConfig
+	MagicLink      MagicLinkConfig
+	Password       PasswordConfig
+	PasswordPolicy PasswordPolicyConfig
+	StepUp         StepUpConfig
+	EmailChange    EmailChangeConfig
+	Impersonation  ImpersonationConfig
+	Organization   OrganizationConfig
+	Group          GroupConfig
+	Registration   RegistrationConfig
+	Blob           BlobConfig
+	Avatar         AvatarConfig
 }
 
 type ServerConfig struct {
 	Port string
+	// 为true时，更新用户必须携带If-Match请求头
+	RequireIfMatch bool
 }
 
 type DatabaseConfig struct {
@@ -37,10 +59,195 @@ type JWTConfig struct {
 	RefreshTokenExpiry time.Duration
 }
 
+type OIDCConfig struct {
+	// Issuer 是对外可访问的站点地址，ID Token的iss和发现文档中的地址都基于它
+	Issuer string
+	// SigningKeyFile 为PEM格式的RSA私钥；为空时启动时临时生成，重启后已签发的ID Token无法再校验
+	SigningKeyFile string
+}
+
+// FederationConfig 是外部OpenID Connect身份提供方（如企业IdP）的配置，Issuer为空时不启用
+type FederationConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL 是在身份提供方登记的回调地址，指向/api/v1/auth/oidc/callback
+	RedirectURL string
+	Scopes      []string
+	// InvitationOnly 为true时不为首次登录的外部身份创建新用户，只能关联邮箱已存在的用户
+	InvitationOnly bool
+}
+
+// SAMLConfig 是作为SAML服务提供方（SP）的证书和私钥，均为PEM格式；为空时启动时临时生成，
+// 重启后需要重新把SP元数据导入身份提供方。SP地址基于OIDCConfig.Issuer
+type SAMLConfig struct {
+	CertificateFile string
+	KeyFile         string
+}
+
+// LDAPConfig 是LDAP/Active Directory认证配置，URL为空时只使用本地密码登录
+type LDAPConfig struct {
+	// URL 为ldap://或ldaps://地址；StartTLS只对ldap://生效
+	URL        string
+	StartTLS   bool
+	CACertFile string
+	// UserDNTemplate 不为空时直接以用户身份绑定（%s为登录邮箱，如AD的UPN），否则先用BindDN搜索用户再绑定
+	UserDNTemplate string
+	BindDN         string
+	BindPassword   string
+	UserBaseDN     string
+	UserFilter     string
+	// LoginAttribute 是与登录邮箱比对的属性，EmailAttribute和UsernameAttribute同步到本地用户
+	LoginAttribute    string
+	EmailAttribute    string
+	UsernameAttribute string
+	// GroupBaseDN 不为空时按GroupFilter搜索用户所属的组，此外也读取用户的memberOf属性
+	GroupBaseDN string
+	GroupFilter string
+	// AdminGroups 中任一组（DN，以分号分隔）的成员同步为管理员，其余为普通用户；为空时不同步角色
+	AdminGroups []string
+	// SyncInterval 为0时不运行定期同步
+	SyncInterval time.Duration
+}
+
+// SCIMConfig 是身份提供方调用/scim/v2时使用的Bearer令牌，为空时SCIM接口拒绝所有请求
+type SCIMConfig struct {
+	Token string
+}
+
+// WebAuthnConfig 是WebAuthn依赖方（RP）配置，RPID必须是浏览器访问的域名或其上级域名，
+// Origins为允许发起认证的站点地址，为空时使用OIDCConfig.Issuer
+type WebAuthnConfig struct {
+	RPID    string
+	RPName  string
+	Origins []string
+}
+
+// SMTPConfig 是发送登录链接等邮件的SMTP服务器，Host为空时邮件只写入日志（用于本地开发）
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// MagicLinkConfig 控制邮件登录链接的有效期，以及每个邮箱在RateWindow内最多可请求的次数
+type MagicLinkConfig struct {
+	TTL        time.Duration
+	RateLimit  int
+	RateWindow time.Duration
+}
+
+// PasswordConfig 是新密码哈希使用的算法（argon2id或bcrypt）和参数。
+// 调整后已有的哈希仍可校验，并在用户下次登录时按新参数重新生成
+type PasswordConfig struct {
+	Algorithm string
+	// Argon2Memory 单位为KiB
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+	BcryptCost        int
+}
+
+// PasswordPolicyConfig 是注册、修改密码和SCIM设置密码时共用的校验规则，值为0的规则不启用
+type PasswordPolicyConfig struct {
+	MinLength int
+	// MinClasses 是至少包含的字符类别数：小写字母、大写字母、数字和其他字符
+	MinClasses int
+	// MinScore 是zxcvbn强度评分（0-4）的下限
+	MinScore int
+	// BannedWords 不能出现在密码中（不区分大小写），用户名和邮箱@之前的部分总是禁止使用
+	BannedWords []string
+	// History 是不能重复使用的最近密码个数
+	History int
+	// BreachedPasswordsFile 是按哈希排序、每行为"SHA1:次数"的泄露密码文件（Have I Been Pwned格式），为空时不检查
+	BreachedPasswordsFile string
+}
+
+// StepUpConfig 控制修改邮箱或密码时的再次认证：登录不超过MaxAge的会话无需再输入当前密码
+type StepUpConfig struct {
+	MaxAge time.Duration
+}
+
+// EmailChangeConfig 是发往新旧邮箱的确认链接的有效期
+type EmailChangeConfig struct {
+	TTL time.Duration
+}
+
+// ImpersonationConfig 是管理员模拟用户时签发的令牌的有效期，模拟令牌不能刷新
+type ImpersonationConfig struct {
+	TTL time.Duration
+}
+
+// OrganizationConfig 是组织邀请链接的有效期
+type OrganizationConfig struct {
+	InvitationTTL time.Duration
+}
+
+// GroupConfig 限制组的嵌套层数和访问令牌中groups声明的组数；
+// AuditLogGroups 中任一组（组名，以逗号分隔，包括子组）的成员可以查看审计日志
+type GroupConfig struct {
+	MaxDepth       int
+	ClaimLimit     int
+	AuditLogGroups []string
+}
+
+// 注册方式：open允许公开注册；invitation关闭/auth/register，只能通过管理员发出的邀请注册
+const (
+	RegistrationOpen       = "open"
+	RegistrationInvitation = "invitation"
+)
+
+// RegistrationConfig 是注册方式和注册邀请链接的有效期
+type RegistrationConfig struct {
+	Mode          string
+	InvitationTTL time.Duration
+}
+
+// InvitationOnly 判断是否只能通过邀请注册
+func (c RegistrationConfig) InvitationOnly() bool {
+	return c.Mode == RegistrationInvitation
+}
+
+// 上传文件的存储：local保存在本地目录并由API服务提供访问，s3使用S3兼容的对象存储（如MinIO）
+const (
+	BlobStoreLocal = "local"
+	BlobStoreS3    = "s3"
+)
+
+// BlobConfig 的PublicURL是对象公开访问地址的前缀，为空时local使用/api/v1/blobs，
+// s3使用Endpoint下的存储桶地址；S3存储桶需要允许匿名读取
+type BlobConfig struct {
+	Driver    string
+	LocalDir  string
+	PublicURL string
+	S3        S3Config
+}
+
+// S3Config 的PathStyle为true时以Endpoint/Bucket/Key访问对象，MinIO需要使用这种方式
+type S3Config struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	PathStyle       bool
+}
+
+// AvatarConfig 限制上传头像的大小（字节），Size和ThumbnailSize是重新编码后头像和缩略图的边长（像素）
+type AvatarConfig struct {
+	MaxSize       int64
+	Size          int
+	ThumbnailSize int
+}
+
 func Load() *Config {
-	return &Config{
+	cfg := &Config{
 		Server: ServerConfig{
-			Port: getEnv("API_PORT", "8080"),
+			Port:           getEnv("API_PORT", "8080"),
+			RequireIfMatch: getEnv("REQUIRE_IF_MATCH", "false") == "true",
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -60,7 +267,119 @@ func Load() *Config {
 			AccessTokenExpiry:  15 * time.Minute,
 			RefreshTokenExpiry: 7 * 24 * time.Hour,
 		},
+		OIDC: OIDCConfig{
+			Issuer:         getEnv("OIDC_ISSUER", "http://localhost"),
+			SigningKeyFile: getEnv("OIDC_SIGNING_KEY_FILE", ""),
+		},
+		Federation: FederationConfig{
+			Name:         getEnv("FEDERATION_OIDC_NAME", "SSO"),
+			Issuer:       getEnv("FEDERATION_OIDC_ISSUER", ""),
+			ClientID:     getEnv("FEDERATION_OIDC_CLIENT_ID", ""),
+			ClientSecret: getEnv("FEDERATION_OIDC_CLIENT_SECRET", ""),
+			RedirectURL:  getEnv("FEDERATION_OIDC_REDIRECT_URL", "http://localhost/api/v1/auth/oidc/callback"),
+			Scopes:       strings.Fields(getEnv("FEDERATION_OIDC_SCOPES", "openid profile email")),
+		},
+		SAML: SAMLConfig{
+			CertificateFile: getEnv("SAML_CERTIFICATE_FILE", ""),
+			KeyFile:         getEnv("SAML_KEY_FILE", ""),
+		},
+		LDAP: LDAPConfig{
+			URL:               getEnv("LDAP_URL", ""),
+			StartTLS:          getEnv("LDAP_START_TLS", "false") == "true",
+			CACertFile:        getEnv("LDAP_CA_CERT_FILE", ""),
+			UserDNTemplate:    getEnv("LDAP_USER_DN_TEMPLATE", ""),
+			BindDN:            getEnv("LDAP_BIND_DN", ""),
+			BindPassword:      getEnv("LDAP_BIND_PASSWORD", ""),
+			UserBaseDN:        getEnv("LDAP_USER_BASE_DN", ""),
+			UserFilter:        getEnv("LDAP_USER_FILTER", "(objectClass=person)"),
+			LoginAttribute:    getEnv("LDAP_LOGIN_ATTRIBUTE", "mail"),
+			EmailAttribute:    getEnv("LDAP_EMAIL_ATTRIBUTE", "mail"),
+			UsernameAttribute: getEnv("LDAP_USERNAME_ATTRIBUTE", "uid"),
+			GroupBaseDN:       getEnv("LDAP_GROUP_BASE_DN", ""),
+			GroupFilter:       getEnv("LDAP_GROUP_FILTER", "(member=%s)"),
+			AdminGroups:       getList("LDAP_ADMIN_GROUPS", ";"),
+			SyncInterval:      getDuration("LDAP_SYNC_INTERVAL", time.Hour),
+		},
+		SCIM: SCIMConfig{
+			Token: getEnv("SCIM_TOKEN", ""),
+		},
+		WebAuthn: WebAuthnConfig{
+			RPID:    getEnv("WEBAUTHN_RP_ID", "localhost"),
+			RPName:  getEnv("WEBAUTHN_RP_NAME", "User Management"),
+			Origins: getList("WEBAUTHN_ORIGINS", ","),
+		},
+		SMTP: SMTPConfig{
+			Host:     getEnv("SMTP_HOST", ""),
+			Port:     getEnv("SMTP_PORT", "587"),
+			Username: getEnv("SMTP_USERNAME", ""),
+			Password: getEnv("SMTP_PASSWORD", ""),
+			From:     getEnv("SMTP_FROM", "no-reply@localhost"),
+		},
+		MagicLink: MagicLinkConfig{
+			TTL:        getDuration("MAGIC_LINK_TTL", 15*time.Minute),
+			RateLimit:  getInt("MAGIC_LINK_RATE_LIMIT", 3),
+			RateWindow: getDuration("MAGIC_LINK_RATE_WINDOW", time.Hour),
+		},
+		Password: PasswordConfig{
+			Algorithm:         getEnv("PASSWORD_HASH_ALGORITHM", "argon2id"),
+			Argon2Memory:      uint32(getInt("ARGON2_MEMORY", 64*1024)),
+			Argon2Iterations:  uint32(getInt("ARGON2_ITERATIONS", 3)),
+			Argon2Parallelism: uint8(getInt("ARGON2_PARALLELISM", 2)),
+			BcryptCost:        getInt("BCRYPT_COST", 12),
+		},
+		PasswordPolicy: PasswordPolicyConfig{
+			MinLength:             getInt("PASSWORD_MIN_LENGTH", 8),
+			MinClasses:            getInt("PASSWORD_MIN_CLASSES", 2),
+			MinScore:              getInt("PASSWORD_MIN_SCORE", 2),
+			BannedWords:           getList("PASSWORD_BANNED_WORDS", ","),
+			History:               getInt("PASSWORD_HISTORY", 5),
+			BreachedPasswordsFile: getEnv("BREACHED_PASSWORDS_FILE", ""),
+		},
+		StepUp: StepUpConfig{
+			MaxAge: getDuration("STEP_UP_MAX_AGE", 10*time.Minute),
+		},
+		EmailChange: EmailChangeConfig{
+			TTL: getDuration("EMAIL_CHANGE_TTL", 24*time.Hour),
+		},
+		Impersonation: ImpersonationConfig{
+			TTL: getDuration("IMPERSONATION_TTL", 30*time.Minute),
+		},
+		Organization: OrganizationConfig{
+			InvitationTTL: getDuration("ORGANIZATION_INVITATION_TTL", 7*24*time.Hour),
+		},
+		Group: GroupConfig{
+			MaxDepth:       getInt("GROUP_MAX_DEPTH", 5),
+			ClaimLimit:     getInt("GROUP_CLAIM_LIMIT", 50),
+			AuditLogGroups: getList("AUDIT_LOG_GROUPS", ","),
+		},
+		Registration: RegistrationConfig{
+			Mode:          getEnv("REGISTRATION_MODE", RegistrationOpen),
+			InvitationTTL: getDuration("REGISTRATION_INVITATION_TTL", 7*24*time.Hour),
+		},
+		Blob: BlobConfig{
+			Driver:    getEnv("BLOB_STORE", BlobStoreLocal),
+			LocalDir:  getEnv("BLOB_LOCAL_DIR", "./data/blobs"),
+			PublicURL: getEnv("BLOB_PUBLIC_URL", ""),
+			S3: S3Config{
+				Endpoint:        getEnv("S3_ENDPOINT", ""),
+				Region:          getEnv("S3_REGION", "us-east-1"),
+				Bucket:          getEnv("S3_BUCKET", ""),
+				AccessKeyID:     getEnv("S3_ACCESS_KEY_ID", ""),
+				SecretAccessKey: getEnv("S3_SECRET_ACCESS_KEY", ""),
+				PathStyle:       getEnv("S3_PATH_STYLE", "true") == "true",
+			},
+		},
+		Avatar: AvatarConfig{
+			MaxSize:       int64(getInt("AVATAR_MAX_SIZE", 2<<20)),
+			Size:          getInt("AVATAR_SIZE", 256),
+			ThumbnailSize: getInt("AVATAR_THUMBNAIL_SIZE", 64),
+		},
 	}
+	cfg.Federation.InvitationOnly = cfg.Registration.InvitationOnly()
+	if len(cfg.WebAuthn.Origins) == 0 {
+		cfg.WebAuthn.Origins = []string{cfg.OIDC.Issuer}
+	}
+	return cfg
 }
 
 func getEnv(key, defaultValue string) string {
@@ -68,4 +387,29 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+// getList 按分隔符拆分环境变量，忽略空白项
+func getList(key, separator string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), separator) {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
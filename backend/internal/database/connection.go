@@ -45,5 +45,24 @@ func Migrate(db *gorm.DB) error {
 		&models.User{},
 		&models.RefreshToken{},
 		&models.UserSession{},
+		&models.ErasureTombstone{},
+		&models.AuditLog{},
+		&models.AuditChainHead{},
+		&models.PersonalAccessToken{},
+		&models.ServiceAccount{},
+		&models.OAuthClient{},
+		&models.OAuthConsent{},
+		&models.LinkedIdentity{},
+		&models.SAMLConnection{},
+		&models.Group{},
+		&models.GroupMember{},
+		&models.Subgroup{},
+		&models.WebAuthnCredential{},
+		&models.PasswordHistory{},
+		&models.Organization{},
+		&models.OrganizationMember{},
+		&models.OrganizationInvitation{},
+		&models.UserInvitation{},
+		&models.AttributeDefinition{},
 	)
 }
\ No newline at end of file
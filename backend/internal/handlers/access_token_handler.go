@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/service"
+)
+
+type AccessTokenHandler struct {
+	accessTokenService service.AccessTokenService
+	auditService       service.AuditService
+}
+
+func NewAccessTokenHandler(accessTokenService service.AccessTokenService, auditService service.AuditService) *AccessTokenHandler {
+	return &AccessTokenHandler{
+		accessTokenService: accessTokenService,
+		auditService:       auditService,
+	}
+}
+
+type CreateAccessTokenRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1" doc:"可选值：users:read、users:write、profile:read、profile:write、audit:read"`
+	ExpiresAt *time.Time `json:"expires_at" doc:"过期时间（RFC3339），不填则永不过期"`
+}
+
+// AccessTokenResponse 是令牌的元数据，不包含令牌明文
+type AccessTokenResponse struct {
+	ID             uint       `json:"id"`
+	Name           string     `json:"name"`
+	TokenPrefix    string     `json:"token_prefix" doc:"令牌的前几位，用于识别令牌"`
+	OrganizationID uint       `json:"organization_id" doc:"令牌只能访问该组织的用户，0表示不属于任何组织"`
+	Scopes         []string   `json:"scopes"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	LastUsedAt     *time.Time `json:"last_used_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type CreatedAccessTokenResponse struct {
+	AccessTokenResponse
+	Token string `json:"token" doc:"令牌明文，只在创建时返回一次"`
+}
+
+type AccessTokenListResponse struct {
+	Tokens []AccessTokenResponse `json:"tokens"`
+}
+
+func (h *AccessTokenHandler) ListTokens(c *gin.Context) {
+	tokens, err := h.accessTokenService.List(c.GetUint("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := AccessTokenListResponse{Tokens: make([]AccessTokenResponse, 0, len(tokens))}
+	for i := range tokens {
+		response.Tokens = append(response.Tokens, newAccessTokenResponse(&tokens[i]))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *AccessTokenHandler) CreateToken(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req CreateAccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	token, secret, err := h.accessTokenService.Create(userID, c.GetUint("organizationID"), req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionTokenCreate, userID)
+	event.Metadata = map[string]interface{}{"token_id": token.ID, "name": token.Name, "scopes": req.Scopes}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusCreated, CreatedAccessTokenResponse{
+		AccessTokenResponse: newAccessTokenResponse(token),
+		Token:               secret,
+	})
+}
+
+func (h *AccessTokenHandler) RevokeToken(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(errInvalidTokenID)
+		return
+	}
+
+	token, err := h.accessTokenService.Revoke(userID, uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionTokenRevoke, userID)
+	event.Metadata = map[string]interface{}{"token_id": token.ID, "name": token.Name}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "Access token revoked successfully"})
+}
+
+func newAccessTokenResponse(token *models.PersonalAccessToken) AccessTokenResponse {
+	return AccessTokenResponse{
+		ID:             token.ID,
+		Name:           token.Name,
+		TokenPrefix:    token.TokenPrefix,
+		OrganizationID: token.OrganizationID,
+		Scopes:         service.TokenScopes(token),
+		ExpiresAt:      token.ExpiresAt,
+		LastUsedAt:     token.LastUsedAt,
+		CreatedAt:      token.CreatedAt,
+	}
+}
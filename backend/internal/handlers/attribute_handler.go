@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/service"
+)
+
+// AttributeHandler 供管理员定义扩展资料属性；所有登录用户都可以读取定义以显示资料表单
+type AttributeHandler struct {
+	attributeService service.AttributeService
+	auditService     service.AuditService
+}
+
+func NewAttributeHandler(attributeService service.AttributeService, auditService service.AuditService) *AttributeHandler {
+	return &AttributeHandler{
+		attributeService: attributeService,
+		auditService:     auditService,
+	}
+}
+
+// UpdateAttributeRequest 中的字段整体替换原定义，name和type创建后不能修改
+type UpdateAttributeRequest struct {
+	Label      string   `json:"label" binding:"required,max=100"`
+	Required   bool     `json:"required" doc:"修改扩展属性时必须为该属性提供值"`
+	Visibility string   `json:"visibility" binding:"omitempty,oneof=self_editable admin_only private" doc:"默认为self_editable"`
+	Pattern    string   `json:"pattern" binding:"max=255" doc:"只用于string类型，须匹配整个值"`
+	MaxLength  int      `json:"max_length" binding:"min=0" doc:"只用于string类型，0表示不限制"`
+	Options    []string `json:"options" doc:"enum类型的可选值"`
+}
+
+type CreateAttributeRequest struct {
+	Name string `json:"name" binding:"required,max=50" doc:"属性值的键，以小写字母开头，只能包含小写字母、数字和下划线"`
+	Type string `json:"type" binding:"required,oneof=string number boolean date enum" doc:"date的值格式为YYYY-MM-DD"`
+	UpdateAttributeRequest
+}
+
+type AttributeResponse struct {
+	ID         uint      `json:"id"`
+	Name       string    `json:"name"`
+	Label      string    `json:"label"`
+	Type       string    `json:"type"`
+	Required   bool      `json:"required"`
+	Visibility string    `json:"visibility"`
+	Pattern    string    `json:"pattern,omitempty"`
+	MaxLength  int       `json:"max_length,omitempty"`
+	Options    []string  `json:"options,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type AttributeListResponse struct {
+	Attributes []AttributeResponse `json:"attributes"`
+}
+
+func (h *AttributeHandler) ListAttributes(c *gin.Context) {
+	definitions, err := h.attributeService.List()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := AttributeListResponse{Attributes: make([]AttributeResponse, 0, len(definitions))}
+	for i := range definitions {
+		response.Attributes = append(response.Attributes, newAttributeResponse(&definitions[i]))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *AttributeHandler) CreateAttribute(c *gin.Context) {
+	var req CreateAttributeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	definition := req.UpdateAttributeRequest.definition()
+	definition.Name = req.Name
+	definition.Type = req.Type
+	if err := h.attributeService.Create(definition); err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionAttributeCreate, 0)
+	event.Metadata = map[string]interface{}{"attribute_id": definition.ID, "name": definition.Name, "type": definition.Type, "visibility": definition.Visibility}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusCreated, newAttributeResponse(definition))
+}
+
+func (h *AttributeHandler) UpdateAttribute(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(errInvalidAttributeID)
+		return
+	}
+
+	var req UpdateAttributeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	definition, err := h.attributeService.Update(uint(id), req.definition())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionAttributeUpdate, 0)
+	event.Metadata = map[string]interface{}{"attribute_id": definition.ID, "name": definition.Name, "required": definition.Required, "visibility": definition.Visibility}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusOK, newAttributeResponse(definition))
+}
+
+// DeleteAttribute 同时移除所有用户的该属性值
+func (h *AttributeHandler) DeleteAttribute(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(errInvalidAttributeID)
+		return
+	}
+
+	definition, err := h.attributeService.Delete(uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionAttributeDelete, 0)
+	event.Metadata = map[string]interface{}{"attribute_id": definition.ID, "name": definition.Name}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "Attribute deleted successfully"})
+}
+
+func (r *UpdateAttributeRequest) definition() *models.AttributeDefinition {
+	return &models.AttributeDefinition{
+		Label:      r.Label,
+		Required:   r.Required,
+		Visibility: r.Visibility,
+		Pattern:    r.Pattern,
+		MaxLength:  r.MaxLength,
+		Options:    strings.Join(r.Options, "\n"),
+	}
+}
+
+func newAttributeResponse(definition *models.AttributeDefinition) AttributeResponse {
+	return AttributeResponse{
+		ID:         definition.ID,
+		Name:       definition.Name,
+		Label:      definition.Label,
+		Type:       definition.Type,
+		Required:   definition.Required,
+		Visibility: definition.Visibility,
+		Pattern:    definition.Pattern,
+		MaxLength:  definition.MaxLength,
+		Options:    definition.OptionList(),
+		CreatedAt:  definition.CreatedAt,
+	}
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/service"
+)
+
+// newAuditEvent 从请求上下文中提取操作者、IP、User-Agent和请求ID；
+// 模拟登录期间操作者记为实际操作的管理员，并记录被模拟的用户
+func newAuditEvent(c *gin.Context, action string, targetID uint) service.AuditEvent {
+	event := service.AuditEvent{
+		Action:    action,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		RequestID: c.GetString("requestID"),
+	}
+	if actorID := c.GetUint("userID"); actorID != 0 {
+		event.ActorID = &actorID
+	}
+	if actorID := c.GetUint("actorID"); actorID != 0 {
+		impersonatedID := c.GetUint("userID")
+		event.ActorID = &actorID
+		event.ImpersonatedUserID = &impersonatedID
+	}
+	if targetID != 0 {
+		event.TargetID = &targetID
+	}
+	// 服务账号不是用户，记录在元数据中
+	if accountID := c.GetUint("serviceAccountID"); accountID != 0 {
+		event.Metadata = map[string]interface{}{"service_account_id": accountID}
+	}
+	return event
+}
+
+// recordAudit 审计写入失败不影响业务请求，但需要记录日志以便排查
+func recordAudit(auditService service.AuditService, event service.AuditEvent) {
+	if err := auditService.Record(event); err != nil {
+		log.Printf("Failed to record audit event %s: %v", event.Action, err)
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+	"github.com/user/user-management/internal/service"
+)
+
+type AuditHandler struct {
+	auditService service.AuditService
+}
+
+func NewAuditHandler(auditService service.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+type AuditLogListResponse struct {
+	AuditLogs []models.AuditLog `json:"audit_logs"`
+	Total     int64             `json:"total"`
+	Page      int               `json:"page"`
+	Limit     int               `json:"limit"`
+}
+
+func (h *AuditHandler) ListAuditLogs(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	filter := repository.AuditFilter{
+		Action: c.Query("action"),
+	}
+
+	var err error
+	if filter.ActorID, err = parseOptionalID(c.Query("actor_id")); err != nil {
+		c.Error(invalidQueryParam("actor_id", "integer", "actor_id must be an integer"))
+		return
+	}
+	if filter.TargetID, err = parseOptionalID(c.Query("target_id")); err != nil {
+		c.Error(invalidQueryParam("target_id", "integer", "target_id must be an integer"))
+		return
+	}
+	if filter.From, err = parseOptionalTime(c.Query("from")); err != nil {
+		c.Error(invalidQueryParam("from", "datetime", "from must be an RFC3339 time"))
+		return
+	}
+	if filter.To, err = parseOptionalTime(c.Query("to")); err != nil {
+		c.Error(invalidQueryParam("to", "datetime", "to must be an RFC3339 time"))
+		return
+	}
+
+	entries, total, err := h.auditService.List(filter, page, limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, AuditLogListResponse{
+		AuditLogs: entries,
+		Total:     total,
+		Page:      page,
+		Limit:     limit,
+	})
+}
+
+func (h *AuditHandler) VerifyAuditLogs(c *gin.Context) {
+	result, err := h.auditService.Verify()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func parseOptionalID(value string) (*uint, error) {
+	if value == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	result := uint(id)
+	return &result, nil
+}
+
+func parseOptionalTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
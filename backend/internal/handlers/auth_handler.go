@@ -1,26 +1,34 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/config"
 	"github.com/user/user-management/internal/service"
 )
 
 type AuthHandler struct {
-	authService service.AuthService
+	authService  service.AuthService
+	auditService service.AuditService
+	// 为true时关闭公开注册，只能通过管理员发出的邀请注册
+	invitationOnly bool
 }
 
-func NewAuthHandler(authService service.AuthService) *AuthHandler {
+func NewAuthHandler(authService service.AuthService, auditService service.AuditService, invitationOnly bool) *AuthHandler {
 	return &AuthHandler{
-		authService: authService,
+		authService:    authService,
+		auditService:   auditService,
+		invitationOnly: invitationOnly,
 	}
 }
 
 type RegisterRequest struct {
 	Username string `json:"username" binding:"required,min=3,max=20"`
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required" doc:"须满足密码策略"`
 }
 
 type LoginRequest struct {
@@ -32,47 +40,113 @@ type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
+type RegisterResponse struct {
+	ID        uint      `json:"id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TokenResponse 中token为JWT访问令牌，refresh_token为不透明的随机字符串
+type TokenResponse struct {
+	Token        string `json:"token" doc:"JWT访问令牌，放在Authorization: Bearer <token>请求头中"`
+	RefreshToken string `json:"refresh_token" doc:"不透明的随机刷新令牌（64位十六进制字符串，非JWT），使用一次后失效"`
+}
+
+type LoginResponse struct {
+	TokenResponse
+	User LoginUser `json:"user"`
+}
+
+type LoginUser struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// RegistrationResponse 告知前端是否显示注册入口
+type RegistrationResponse struct {
+	Mode string `json:"mode" doc:"open表示允许公开注册，invitation表示只能通过邀请链接注册"`
+}
+
+func (h *AuthHandler) Registration(c *gin.Context) {
+	mode := config.RegistrationOpen
+	if h.invitationOnly {
+		mode = config.RegistrationInvitation
+	}
+	c.JSON(http.StatusOK, RegistrationResponse{Mode: mode})
+}
+
 func (h *AuthHandler) Register(c *gin.Context) {
+	if h.invitationOnly {
+		c.Error(service.ErrRegistrationDisabled)
+		return
+	}
+
 	var req RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	user, err := h.authService.Register(req.Username, req.Email, req.Password)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"id":         user.ID,
-		"username":   user.Username,
-		"email":      user.Email,
-		"created_at": user.CreatedAt,
+	event := newAuditEvent(c, service.AuditActionRegister, user.ID)
+	event.ActorID = &user.ID
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusCreated, RegisterResponse{
+		ID:        user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		CreatedAt: user.CreatedAt,
 	})
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	user, accessToken, refreshToken, err := h.authService.Login(req.Email, req.Password)
+	user, accessToken, refreshToken, err := h.authService.Login(req.Email, req.Password, c.ClientIP(), c.Request.UserAgent())
+	// 密码正确但已注册安全密钥，需继续完成WebAuthn验证
+	var secondFactor *service.SecondFactorRequiredError
+	if errors.As(err, &secondFactor) {
+		c.JSON(http.StatusAccepted, SecondFactorResponse{
+			Method:    "webauthn",
+			Ticket:    secondFactor.Ticket,
+			ExpiresIn: int(service.WebAuthnCeremonyTTL.Seconds()),
+		})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		event := newAuditEvent(c, service.AuditActionLoginFailed, 0)
+		event.Metadata = map[string]interface{}{"email": req.Email, "reason": service.ErrorCode(err)}
+		recordAudit(h.auditService, event)
+
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token":         accessToken,
-		"refresh_token": refreshToken,
-		"user": gin.H{
-			"id":       user.ID,
-			"username": user.Username,
-			"email":    user.Email,
+	event := newAuditEvent(c, service.AuditActionLogin, user.ID)
+	event.ActorID = &user.ID
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusOK, LoginResponse{
+		TokenResponse: TokenResponse{
+			Token:        accessToken,
+			RefreshToken: refreshToken,
+		},
+		User: LoginUser{
+			ID:       user.ID,
+			Username: user.Username,
+			Email:    user.Email,
 		},
 	})
 }
@@ -82,28 +156,34 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 	token := c.GetString("token")
 	
 	if err := h.authService.Logout(token, userID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+	recordAudit(h.auditService, newAuditEvent(c, service.AuditActionLogout, userID))
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "Logged out successfully"})
 }
 
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	var req RefreshTokenRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	accessToken, refreshToken, err := h.authService.RefreshToken(req.RefreshToken)
+	userID, accessToken, refreshToken, err := h.authService.RefreshToken(req.RefreshToken)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token":         accessToken,
-		"refresh_token": refreshToken,
+	event := newAuditEvent(c, service.AuditActionRefresh, userID)
+	event.ActorID = &userID
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusOK, TokenResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
 	})
 }
\ No newline at end of file
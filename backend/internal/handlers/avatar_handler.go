@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/service"
+)
+
+// multipartOverhead 是multipart请求中除头像文件外的边界和表单头所允许的大小
+const multipartOverhead = 64 << 10
+
+type AvatarHandler struct {
+	avatarService service.AvatarService
+	auditService  service.AuditService
+	maxSize       int64
+}
+
+func NewAvatarHandler(avatarService service.AvatarService, auditService service.AuditService, maxSize int64) *AvatarHandler {
+	return &AvatarHandler{
+		avatarService: avatarService,
+		auditService:  auditService,
+		maxSize:       maxSize,
+	}
+}
+
+// UploadAvatar 从multipart表单的avatar字段读取图片，超过大小限制时不读取剩余的请求体
+func (h *AvatarHandler) UploadAvatar(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxSize+multipartOverhead)
+	header, err := c.FormFile("avatar")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			c.Error(avatarTooLarge(h.maxSize))
+		case errors.Is(err, http.ErrMissingFile), errors.Is(err, http.ErrNotMultipart):
+			c.Error(errAvatarRequired)
+		default:
+			c.Error(service.ErrInvalidRequest)
+		}
+		return
+	}
+	if header.Size > h.maxSize {
+		c.Error(avatarTooLarge(h.maxSize))
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, h.maxSize+1))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if int64(len(data)) > h.maxSize {
+		c.Error(avatarTooLarge(h.maxSize))
+		return
+	}
+
+	user, err := h.avatarService.Upload(c.Request.Context(), userID, data)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionAvatarUpdate, userID)
+	event.Metadata = map[string]interface{}{"avatar_url": user.AvatarURL, "size": len(data)}
+	recordAudit(h.auditService, event)
+
+	writeUser(c, http.StatusOK, user)
+}
+
+func (h *AvatarHandler) DeleteAvatar(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	user, err := h.avatarService.Delete(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	recordAudit(h.auditService, newAuditEvent(c, service.AuditActionAvatarDelete, userID))
+
+	writeUser(c, http.StatusOK, user)
+}
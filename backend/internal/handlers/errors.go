@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/user/user-management/internal/service"
+)
+
+// 请求格式相关的错误，与领域错误一样由middleware.ErrorHandler统一输出
+var (
+	errInvalidUserID           = service.NewError(service.KindBadRequest, "invalid_user_id", "Invalid user ID")
+	errInvalidTokenID          = service.NewError(service.KindBadRequest, "invalid_token_id", "Invalid access token ID")
+	errInvalidServiceAccountID = service.NewError(service.KindBadRequest, "invalid_service_account_id", "Invalid service account ID")
+	errInvalidOAuthClientID    = service.NewError(service.KindBadRequest, "invalid_oauth_client_id", "Invalid OAuth client ID")
+	errInvalidSAMLConnectionID = service.NewError(service.KindBadRequest, "invalid_saml_connection_id", "Invalid SAML connection ID")
+	errInvalidWebAuthnID       = service.NewError(service.KindBadRequest, "invalid_webauthn_credential_id", "Invalid security key ID")
+	errInvalidInvitationID     = service.NewError(service.KindBadRequest, "invalid_invitation_id", "Invalid invitation ID")
+	errInvalidGroupID          = service.NewError(service.KindBadRequest, "invalid_group_id", "Invalid group ID")
+	errInvalidAttributeID      = service.NewError(service.KindBadRequest, "invalid_attribute_id", "Invalid attribute ID")
+	errAvatarRequired          = service.NewError(service.KindBadRequest, "avatar_required", "Multipart form field avatar is required")
+	errCannotDeleteSelf        = service.NewError(service.KindForbidden, "cannot_delete_self", "Cannot delete your own account")
+	errRoleChangeForbidden     = service.NewError(service.KindForbidden, "role_change_forbidden", "Only administrators can change roles")
+	errUnsupportedPatch        = service.NewError(service.KindUnsupportedMediaType, "unsupported_media_type", "Content-Type must be "+mediaTypeMergePatch+" or "+mediaTypeJSONPatch)
+	errInvalidPatch            = service.NewError(service.KindBadRequest, "invalid_patch", "Invalid patch document")
+	errPatchFailed             = service.NewError(service.KindValidation, "patch_failed", "Patch could not be applied")
+	errDocsAssetNotFound       = service.NewError(service.KindNotFound, "docs_asset_not_found", "Documentation asset not found")
+)
+
+// invalidQueryParam 返回带字段详情的查询参数错误，code为期望的格式（integer、datetime）
+func invalidQueryParam(field, code, message string) *service.Error {
+	return &service.Error{
+		Kind:    service.KindBadRequest,
+		Code:    "invalid_query_parameter",
+		Message: fmt.Sprintf("Invalid query parameter %s", field),
+		Params:  map[string]string{"field": field},
+		Fields:  []service.FieldError{{Field: field, Code: code, Message: message}},
+	}
+}
+
+// fieldNotPatchable 返回字段不在补丁白名单内的错误
+func fieldNotPatchable(field string) *service.Error {
+	return &service.Error{
+		Kind:    service.KindForbidden,
+		Code:    "field_not_patchable",
+		Message: fmt.Sprintf("Field %q cannot be modified", field),
+		Params:  map[string]string{"field": field},
+		Fields:  []service.FieldError{{Field: field, Code: "not_patchable", Message: fmt.Sprintf("%s cannot be modified", field)}},
+	}
+}
+
+// avatarTooLarge 返回上传的头像超过大小限制的错误
+func avatarTooLarge(maxSize int64) *service.Error {
+	limit := fmt.Sprintf("%d KB", maxSize/1024)
+	return &service.Error{
+		Kind:    service.KindPayloadTooLarge,
+		Code:    "avatar_too_large",
+		Message: "Avatar must not be larger than " + limit,
+		Params:  map[string]string{"limit": limit},
+	}
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/service"
+)
+
+// userETag 使用版本号作为强校验ETag，任何字段变更都会递增版本号
+func userETag(user *models.User) string {
+	return fmt.Sprintf(`"%d"`, user.Version)
+}
+
+// writeUser 返回用户信息并附带ETag；If-None-Match匹配时返回304
+func writeUser(c *gin.Context, status int, user *models.User) {
+	etag := userETag(user)
+	c.Header("ETag", etag)
+
+	if status == http.StatusOK && c.Request.Method == http.MethodGet && etagListMatches(c.GetHeader("If-None-Match"), etag, true) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.JSON(status, user)
+}
+
+// checkIfMatch 校验If-Match前置条件，不满足时记录错误并返回false
+func checkIfMatch(c *gin.Context, user *models.User, required bool) bool {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		if required {
+			c.Error(service.ErrPreconditionMissing)
+			return false
+		}
+		return true
+	}
+
+	// If-Match使用强比较，弱ETag永远不匹配
+	if !etagListMatches(ifMatch, userETag(user), false) {
+		c.Header("ETag", userETag(user))
+		c.Error(service.ErrPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+func etagListMatches(header, etag string, weak bool) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/middleware"
+	"github.com/user/user-management/internal/models"
+)
+
+func TestUserETags(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newRouter := func(requireIfMatch bool) (*gin.Engine, *stubUserService) {
+		users := &stubUserService{users: map[uint]*models.User{
+			1: {ID: 1, Username: "admin", Email: "admin@example.com", Role: models.RoleAdmin, IsActive: true, Version: 3},
+			2: {ID: 2, Username: "alice", Email: "alice@example.com", Role: models.RoleUser, IsActive: true, Version: 5},
+		}}
+		handler := NewUserHandler(users, nil, nil, stubAttributeService{}, &stubAuditService{}, requireIfMatch)
+		router := gin.New()
+		router.Use(middleware.ErrorHandler())
+		router.Use(func(c *gin.Context) {
+			c.Set("userID", uint(1))
+			c.Set("authMethod", middleware.AuthMethodSession)
+		})
+		router.GET("/users/:id", handler.GetUser)
+		router.PUT("/users/:id", handler.UpdateUser)
+		return router, users
+	}
+	request := func(router *gin.Engine, method string, headers map[string]string) *httptest.ResponseRecorder {
+		var body *bytes.Buffer
+		if method == http.MethodPut {
+			body = bytes.NewBufferString(`{"username":"alice2"}`)
+		} else {
+			body = &bytes.Buffer{}
+		}
+		req := httptest.NewRequest(method, "/users/2", body)
+		req.Header.Set("Content-Type", "application/json")
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	router, users := newRouter(false)
+	rec := request(router, http.MethodGet, nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"5"` {
+		t.Fatalf("expected ETag \"5\", got %d %q", rec.Code, rec.Header().Get("ETag"))
+	}
+	for _, ifNoneMatch := range []string{`"5"`, `W/"5"`, `"4", "5"`, `*`} {
+		if rec := request(router, http.MethodGet, map[string]string{"If-None-Match": ifNoneMatch}); rec.Code != http.StatusNotModified {
+			t.Fatalf("expected If-None-Match %s to return 304, got %d", ifNoneMatch, rec.Code)
+		}
+	}
+	if rec := request(router, http.MethodGet, map[string]string{"If-None-Match": `"4"`}); rec.Code != http.StatusOK {
+		t.Fatalf("expected stale If-None-Match to return 200, got %d", rec.Code)
+	}
+
+	// If-Match使用强比较，不匹配时返回412和当前ETag
+	for _, ifMatch := range []string{`"4"`, `W/"5"`} {
+		rec := request(router, http.MethodPut, map[string]string{"If-Match": ifMatch})
+		if rec.Code != http.StatusPreconditionFailed || rec.Header().Get("ETag") != `"5"` {
+			t.Fatalf("expected If-Match %s to fail with 412, got %d %q", ifMatch, rec.Code, rec.Header().Get("ETag"))
+		}
+	}
+	if len(users.updated) != 0 {
+		t.Fatalf("expected no updates after failed preconditions, got %v", users.updated)
+	}
+	if rec := request(router, http.MethodPut, map[string]string{"If-Match": `"5"`}); rec.Code != http.StatusOK {
+		t.Fatalf("expected matching If-Match to succeed, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := request(router, http.MethodPut, nil); rec.Code != http.StatusOK {
+		t.Fatalf("expected If-Match to be optional by default, got %d", rec.Code)
+	}
+
+	required, _ := newRouter(true)
+	if rec := request(required, http.MethodPut, nil); rec.Code != http.StatusPreconditionRequired {
+		t.Fatalf("expected missing If-Match to return 428 when required, got %d", rec.Code)
+	}
+	if rec := request(required, http.MethodPut, map[string]string{"If-Match": `"5"`}); rec.Code != http.StatusOK {
+		t.Fatalf("expected matching If-Match to succeed when required, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/service"
+)
+
+const (
+	federationStateCookie = "federation_state"
+	federationCookiePath  = "/api/v1/auth/oidc"
+	// 前端登录回调页面，携带一次性登录码或错误码
+	federationCallbackPage = "/login/callback"
+)
+
+// FederationHandler 处理通过外部OpenID Connect身份提供方登录
+type FederationHandler struct {
+	federationService service.FederationService
+	authService       service.AuthService
+	auditService      service.AuditService
+}
+
+func NewFederationHandler(federationService service.FederationService, authService service.AuthService, auditService service.AuditService) *FederationHandler {
+	return &FederationHandler{
+		federationService: federationService,
+		authService:       authService,
+		auditService:      auditService,
+	}
+}
+
+type FederationProviderResponse struct {
+	Enabled bool   `json:"enabled"`
+	Name    string `json:"name" doc:"登录按钮上显示的身份提供方名称"`
+}
+
+type FederationExchangeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+func (h *FederationHandler) Provider(c *gin.Context) {
+	c.JSON(http.StatusOK, FederationProviderResponse{
+		Enabled: h.federationService.Enabled(),
+		Name:    h.federationService.Name(),
+	})
+}
+
+// Login 跳转到身份提供方，state同时写入Cookie，回调时校验请求来自发起登录的浏览器
+func (h *FederationHandler) Login(c *gin.Context) {
+	authURL, state, err := h.federationService.Begin(c.Request.Context(), safeRedirect(c.Query("redirect")))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(federationStateCookie, state, int(service.FederationStateTTL.Seconds()), federationCookiePath, "", isHTTPS(c), true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback 完成登录后创建普通会话，浏览器带着一次性登录码回到前端
+func (h *FederationHandler) Callback(c *gin.Context) {
+	state := c.Query("state")
+	cookie, _ := c.Cookie(federationStateCookie)
+	c.SetCookie(federationStateCookie, "", -1, federationCookiePath, "", isHTTPS(c), true)
+
+	if errorCode := c.Query("error"); errorCode != "" {
+		h.fail(c, service.ErrFederatedLoginFailed, map[string]interface{}{"provider_error": errorCode})
+		return
+	}
+	if state == "" || cookie != state {
+		h.fail(c, service.ErrFederationState, nil)
+		return
+	}
+
+	login, err := h.federationService.Complete(c.Request.Context(), state, c.Query("code"))
+	if err != nil {
+		h.fail(c, err, nil)
+		return
+	}
+
+	if err := completeFederatedLogin(c, h.authService, h.federationService, h.auditService, login, "oidc"); err != nil {
+		h.fail(c, err, map[string]interface{}{"issuer": login.Identity.Issuer})
+	}
+}
+
+// Exchange 用回调时的一次性登录码换取令牌，响应与密码登录相同
+func (h *FederationHandler) Exchange(c *gin.Context) {
+	var req FederationExchangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	handoff, err := h.federationService.RedeemHandoff(req.Code)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{
+		TokenResponse: TokenResponse{
+			Token:        handoff.AccessToken,
+			RefreshToken: handoff.RefreshToken,
+		},
+		User: LoginUser{
+			ID:       handoff.UserID,
+			Username: handoff.Username,
+			Email:    handoff.Email,
+		},
+	})
+}
+
+func (h *FederationHandler) fail(c *gin.Context, err error, metadata map[string]interface{}) {
+	failFederatedLogin(c, h.auditService, err, "oidc", metadata)
+}
+
+// completeFederatedLogin 为外部身份提供方登录的用户创建普通会话，浏览器带着一次性登录码回到前端
+func completeFederatedLogin(c *gin.Context, authService service.AuthService, federationService service.FederationService, auditService service.AuditService, login *service.FederatedLogin, method string) error {
+	accessToken, refreshToken, err := authService.StartSession(login.User, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		return err
+	}
+
+	code, err := federationService.CreateHandoff(&service.FederationHandoff{
+		UserID:       login.User.ID,
+		Username:     login.User.Username,
+		Email:        login.User.Email,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	})
+	if err != nil {
+		return err
+	}
+
+	if login.Linked {
+		event := newAuditEvent(c, service.AuditActionIdentityLink, login.User.ID)
+		event.ActorID = &login.User.ID
+		event.Metadata = map[string]interface{}{"issuer": login.Identity.Issuer, "subject": login.Identity.Subject}
+		recordAudit(auditService, event)
+	}
+	event := newAuditEvent(c, service.AuditActionLogin, login.User.ID)
+	event.ActorID = &login.User.ID
+	event.Metadata = map[string]interface{}{"method": method, "issuer": login.Identity.Issuer, "provisioned": login.Provisioned}
+	recordAudit(auditService, event)
+
+	query := url.Values{"code": {code}}
+	if login.Redirect != "" {
+		query.Set("redirect", login.Redirect)
+	}
+	c.Redirect(http.StatusFound, federationCallbackPage+"?"+query.Encode())
+	return nil
+}
+
+// failFederatedLogin 记录失败并带着错误码回到前端登录回调页面
+func failFederatedLogin(c *gin.Context, auditService service.AuditService, err error, method string, metadata map[string]interface{}) {
+	if _, ok := service.AsError(err); !ok {
+		log.Printf("Federated login failed: %v", err)
+	}
+
+	event := newAuditEvent(c, service.AuditActionLoginFailed, 0)
+	event.Metadata = map[string]interface{}{"method": method, "reason": service.ErrorCode(err)}
+	for key, value := range metadata {
+		event.Metadata[key] = value
+	}
+	recordAudit(auditService, event)
+
+	c.Redirect(http.StatusFound, federationCallbackPage+"?"+url.Values{"error": {service.ErrorCode(err)}}.Encode())
+}
+
+// safeRedirect 只接受站内路径，避免开放重定向
+func safeRedirect(redirect string) string {
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.HasPrefix(redirect, "/\\") {
+		return ""
+	}
+	return redirect
+}
+
+func isHTTPS(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/service"
+)
+
+// GroupHandler 供管理员维护组、直接成员和子组；组也可以由身份提供方通过SCIM维护
+type GroupHandler struct {
+	groupService service.GroupService
+	auditService service.AuditService
+}
+
+func NewGroupHandler(groupService service.GroupService, auditService service.AuditService) *GroupHandler {
+	return &GroupHandler{
+		groupService: groupService,
+		auditService: auditService,
+	}
+}
+
+type CreateGroupRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type GroupResponse struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type GroupListResponse struct {
+	Groups []GroupResponse `json:"groups"`
+	Total  int64           `json:"total"`
+	Page   int             `json:"page"`
+	Limit  int             `json:"limit"`
+}
+
+type GroupMemberResponse struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// GroupDetailResponse 只包含直接成员和直接子组，子组的成员同时属于该组
+type GroupDetailResponse struct {
+	GroupResponse
+	Members   []GroupMemberResponse `json:"members"`
+	Subgroups []GroupResponse       `json:"subgroups"`
+}
+
+type UserGroupResponse struct {
+	ID     uint   `json:"id"`
+	Name   string `json:"name"`
+	Direct bool   `json:"direct" doc:"false表示通过子组间接属于该组"`
+}
+
+type UserGroupListResponse struct {
+	Groups []UserGroupResponse `json:"groups"`
+}
+
+func (h *GroupHandler) ListGroups(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	groups, total, err := h.groupService.List(page, limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := GroupListResponse{Groups: make([]GroupResponse, 0, len(groups)), Total: total, Page: page, Limit: limit}
+	for i := range groups {
+		response.Groups = append(response.Groups, newGroupResponse(&groups[i]))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *GroupHandler) CreateGroup(c *gin.Context) {
+	var req CreateGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	group, err := h.groupService.Create(req.Name)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionGroupCreate, 0)
+	event.Metadata = map[string]interface{}{"group_id": group.ID, "name": group.Name}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusCreated, newGroupResponse(group))
+}
+
+// GetGroup 的成员只包含当前组织内的用户
+func (h *GroupHandler) GetGroup(c *gin.Context) {
+	id, ok := groupID(c, "id")
+	if !ok {
+		return
+	}
+
+	detail, err := h.groupService.Get(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := GroupDetailResponse{
+		GroupResponse: newGroupResponse(detail.Group),
+		Members:       make([]GroupMemberResponse, 0, len(detail.Members)),
+		Subgroups:     make([]GroupResponse, 0, len(detail.Subgroups)),
+	}
+	for _, member := range detail.Members {
+		response.Members = append(response.Members, GroupMemberResponse{ID: member.ID, Username: member.Username, Email: member.Email})
+	}
+	for i := range detail.Subgroups {
+		response.Subgroups = append(response.Subgroups, newGroupResponse(&detail.Subgroups[i]))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *GroupHandler) DeleteGroup(c *gin.Context) {
+	id, ok := groupID(c, "id")
+	if !ok {
+		return
+	}
+
+	group, err := h.groupService.Delete(id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionGroupDelete, 0)
+	event.Metadata = map[string]interface{}{"group_id": group.ID, "name": group.Name}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "Group deleted successfully"})
+}
+
+// AddMember 只能添加当前组织内的用户，用户已是直接成员时不做修改
+func (h *GroupHandler) AddMember(c *gin.Context) {
+	id, ok := groupID(c, "id")
+	if !ok {
+		return
+	}
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		c.Error(errInvalidUserID)
+		return
+	}
+
+	if err := h.groupService.AddMember(c.Request.Context(), id, uint(userID)); err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionGroupMemberAdd, uint(userID))
+	event.Metadata = map[string]interface{}{"group_id": id}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "Group member added successfully"})
+}
+
+func (h *GroupHandler) RemoveMember(c *gin.Context) {
+	id, ok := groupID(c, "id")
+	if !ok {
+		return
+	}
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		c.Error(errInvalidUserID)
+		return
+	}
+
+	if err := h.groupService.RemoveMember(c.Request.Context(), id, uint(userID)); err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionGroupMemberRemove, uint(userID))
+	event.Metadata = map[string]interface{}{"group_id": id}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "Group member removed successfully"})
+}
+
+// AddSubgroup 使路径参数child_id的组成为id组的子组，不能成环，嵌套层数不能超过GROUP_MAX_DEPTH
+func (h *GroupHandler) AddSubgroup(c *gin.Context) {
+	id, ok := groupID(c, "id")
+	if !ok {
+		return
+	}
+	childID, ok := groupID(c, "child_id")
+	if !ok {
+		return
+	}
+
+	if err := h.groupService.AddSubgroup(id, childID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionSubgroupAdd, 0)
+	event.Metadata = map[string]interface{}{"group_id": id, "subgroup_id": childID}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "Subgroup added successfully"})
+}
+
+func (h *GroupHandler) RemoveSubgroup(c *gin.Context) {
+	id, ok := groupID(c, "id")
+	if !ok {
+		return
+	}
+	childID, ok := groupID(c, "child_id")
+	if !ok {
+		return
+	}
+
+	if err := h.groupService.RemoveSubgroup(id, childID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionSubgroupRemove, 0)
+	event.Metadata = map[string]interface{}{"group_id": id, "subgroup_id": childID}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "Subgroup removed successfully"})
+}
+
+// groupID 解析路径参数中的组ID，失败时写入错误并返回false
+func groupID(c *gin.Context, param string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(param), 10, 32)
+	if err != nil {
+		c.Error(errInvalidGroupID)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func newGroupResponse(group *models.Group) GroupResponse {
+	return GroupResponse{
+		ID:        group.ID,
+		Name:      group.Name,
+		CreatedAt: group.CreatedAt,
+	}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/service"
+)
+
+type ImpersonationHandler struct {
+	authService  service.AuthService
+	auditService service.AuditService
+}
+
+func NewImpersonationHandler(authService service.AuthService, auditService service.AuditService) *ImpersonationHandler {
+	return &ImpersonationHandler{
+		authService:  authService,
+		auditService: auditService,
+	}
+}
+
+type ImpersonationResponse struct {
+	Token     string    `json:"token" doc:"以被模拟用户身份访问的JWT，act声明中记录管理员；不能刷新，修改密码、邮箱和安全密钥等操作会被拒绝"`
+	ExpiresIn int       `json:"expires_in" doc:"令牌有效期（秒）"`
+	User      LoginUser `json:"user"`
+}
+
+func (h *ImpersonationHandler) Impersonate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(errInvalidUserID)
+		return
+	}
+
+	token, err := h.authService.Impersonate(c.GetUint("userID"), uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionImpersonationStart, token.User.ID)
+	event.Metadata = map[string]interface{}{"expires_in": int(token.ExpiresIn.Seconds())}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusCreated, ImpersonationResponse{
+		Token:     token.AccessToken,
+		ExpiresIn: int(token.ExpiresIn.Seconds()),
+		User: LoginUser{
+			ID:       token.User.ID,
+			Username: token.User.Username,
+			Email:    token.User.Email,
+		},
+	})
+}
+
+// EndImpersonation 使用模拟令牌调用，结束后管理员继续使用自己的令牌
+func (h *ImpersonationHandler) EndImpersonation(c *gin.Context) {
+	if c.GetUint("actorID") == 0 {
+		c.Error(service.ErrNotImpersonating)
+		return
+	}
+
+	if err := h.authService.EndImpersonation(c.GetString("token")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	recordAudit(h.auditService, newAuditEvent(c, service.AuditActionImpersonationEnd, c.GetUint("userID")))
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "Impersonation ended"})
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/service"
+)
+
+const (
+	magicLinkNonceCookie = "magic_link_nonce"
+	magicLinkCookiePath  = "/api/v1/auth/magic-link"
+)
+
+// MagicLinkHandler 处理通过邮件链接无密码登录
+type MagicLinkHandler struct {
+	magicLinkService service.MagicLinkService
+	authService      service.AuthService
+	auditService     service.AuditService
+	cookieMaxAge     int
+}
+
+func NewMagicLinkHandler(magicLinkService service.MagicLinkService, authService service.AuthService, auditService service.AuditService, linkTTL time.Duration) *MagicLinkHandler {
+	return &MagicLinkHandler{
+		magicLinkService: magicLinkService,
+		authService:      authService,
+		auditService:     auditService,
+		cookieMaxAge:     int(linkTTL.Seconds()),
+	}
+}
+
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type MagicLinkConsumeRequest struct {
+	Token string `json:"token" binding:"required" doc:"邮件链接中的token参数"`
+}
+
+// Request 无论邮箱是否已注册都返回相同的响应，nonce写入Cookie把链接绑定到当前浏览器
+func (h *MagicLinkHandler) Request(c *gin.Context) {
+	var req MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	cookie, _ := c.Cookie(magicLinkNonceCookie)
+	nonce, err := h.magicLinkService.Request(req.Email, cookie)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(magicLinkNonceCookie, nonce, h.cookieMaxAge, magicLinkCookiePath, "", isHTTPS(c), true)
+	c.JSON(http.StatusAccepted, MessageResponse{Message: "If the email address is registered, a sign-in link has been sent"})
+}
+
+// Consume 校验链接后签发与密码登录相同的令牌，启用了安全密钥的账号还需完成第二步
+func (h *MagicLinkHandler) Consume(c *gin.Context) {
+	var req MagicLinkConsumeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	nonce, _ := c.Cookie(magicLinkNonceCookie)
+	user, err := h.magicLinkService.Consume(req.Token, nonce)
+	var secondFactor *service.SecondFactorRequiredError
+	if errors.As(err, &secondFactor) {
+		c.JSON(http.StatusAccepted, SecondFactorResponse{
+			Method:    "webauthn",
+			Ticket:    secondFactor.Ticket,
+			ExpiresIn: int(service.WebAuthnCeremonyTTL.Seconds()),
+		})
+		return
+	}
+	if err == nil {
+		var accessToken, refreshToken string
+		if accessToken, refreshToken, err = h.authService.StartSession(user, c.ClientIP(), c.Request.UserAgent()); err == nil {
+			event := newAuditEvent(c, service.AuditActionLogin, user.ID)
+			event.ActorID = &user.ID
+			event.Metadata = map[string]interface{}{"method": "magic_link"}
+			recordAudit(h.auditService, event)
+
+			c.JSON(http.StatusOK, LoginResponse{
+				TokenResponse: TokenResponse{
+					Token:        accessToken,
+					RefreshToken: refreshToken,
+				},
+				User: LoginUser{
+					ID:       user.ID,
+					Username: user.Username,
+					Email:    user.Email,
+				},
+			})
+			return
+		}
+	}
+
+	event := newAuditEvent(c, service.AuditActionLoginFailed, 0)
+	event.Metadata = map[string]interface{}{"method": "magic_link", "reason": service.ErrorCode(err)}
+	recordAudit(h.auditService, event)
+
+	c.Error(err)
+}
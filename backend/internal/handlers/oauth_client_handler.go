@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/service"
+)
+
+type OAuthClientHandler struct {
+	oauthClientService service.OAuthClientService
+	auditService       service.AuditService
+}
+
+func NewOAuthClientHandler(oauthClientService service.OAuthClientService, auditService service.AuditService) *OAuthClientHandler {
+	return &OAuthClientHandler{
+		oauthClientService: oauthClientService,
+		auditService:       auditService,
+	}
+}
+
+type RegisterOAuthClientRequest struct {
+	Name         string   `json:"name" binding:"required,max=100"`
+	RedirectURIs []string `json:"redirect_uris" binding:"required,min=1" doc:"授权回调地址，授权时按字符串完全匹配"`
+	Scopes       []string `json:"scopes,omitempty" doc:"可选值：openid、profile、email，不填则允许全部"`
+	Public       bool     `json:"public,omitempty" doc:"公开客户端（SPA、移动端）不签发client_secret，只能依靠PKCE"`
+}
+
+type OAuthClientResponse struct {
+	ID           uint      `json:"id"`
+	Name         string    `json:"name"`
+	ClientID     string    `json:"client_id"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Public       bool      `json:"public"`
+	CreatedBy    uint      `json:"created_by"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type RegisteredOAuthClientResponse struct {
+	OAuthClientResponse
+	ClientSecret string `json:"client_secret,omitempty" doc:"客户端密钥，只在注册时返回一次，公开客户端没有密钥"`
+}
+
+type OAuthClientListResponse struct {
+	Clients []OAuthClientResponse `json:"clients"`
+}
+
+func (h *OAuthClientHandler) ListClients(c *gin.Context) {
+	clients, err := h.oauthClientService.List()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := OAuthClientListResponse{Clients: make([]OAuthClientResponse, 0, len(clients))}
+	for i := range clients {
+		response.Clients = append(response.Clients, newOAuthClientResponse(&clients[i]))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *OAuthClientHandler) RegisterClient(c *gin.Context) {
+	var req RegisterOAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	client, secret, err := h.oauthClientService.Register(req.Name, req.RedirectURIs, req.Scopes, req.Public, c.GetUint("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionOAuthClientCreate, 0)
+	event.Metadata = map[string]interface{}{"client_id": client.ClientID, "name": client.Name, "redirect_uris": req.RedirectURIs}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusCreated, RegisteredOAuthClientResponse{
+		OAuthClientResponse: newOAuthClientResponse(client),
+		ClientSecret:        secret,
+	})
+}
+
+func (h *OAuthClientHandler) DeleteClient(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(errInvalidOAuthClientID)
+		return
+	}
+
+	client, err := h.oauthClientService.Delete(uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionOAuthClientDelete, 0)
+	event.Metadata = map[string]interface{}{"client_id": client.ClientID, "name": client.Name}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "OAuth client deleted successfully"})
+}
+
+func newOAuthClientResponse(client *models.OAuthClient) OAuthClientResponse {
+	return OAuthClientResponse{
+		ID:           client.ID,
+		Name:         client.Name,
+		ClientID:     client.ClientID,
+		RedirectURIs: service.ClientRedirectURIs(client),
+		Scopes:       service.ClientScopes(client),
+		Public:       client.Public,
+		CreatedBy:    client.CreatedBy,
+		CreatedAt:    client.CreatedAt,
+	}
+}
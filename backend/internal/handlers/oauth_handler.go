@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/service"
+)
+
+const (
+	grantTypeClientCredentials = "client_credentials"
+	grantTypeAuthorizationCode = "authorization_code"
+)
+
+type OAuthHandler struct {
+	authService  service.AuthService
+	oidcService  service.OIDCService
+	auditService service.AuditService
+}
+
+func NewOAuthHandler(authService service.AuthService, oidcService service.OIDCService, auditService service.AuditService) *OAuthHandler {
+	return &OAuthHandler{
+		authService:  authService,
+		oidcService:  oidcService,
+		auditService: auditService,
+	}
+}
+
+// ClientCredentialsRequest 使用application/x-www-form-urlencoded提交（RFC 6749 4.4）
+// 客户端凭据也可以通过HTTP Basic认证传递
+type ClientCredentialsRequest struct {
+	GrantType    string `form:"grant_type" json:"grant_type" binding:"required,oneof=client_credentials"`
+	Scope        string `form:"scope" json:"scope,omitempty" doc:"空格分隔的scope，不填则授予服务账号的全部scope"`
+	ClientID     string `form:"client_id" json:"client_id,omitempty"`
+	ClientSecret string `form:"client_secret" json:"client_secret,omitempty"`
+}
+
+// AuthorizationCodeRequest 用授权码换取令牌（RFC 6749 4.1.3，RFC 7636 4.5）
+// 公开客户端只提交client_id，机密客户端还需要client_secret或HTTP Basic认证
+type AuthorizationCodeRequest struct {
+	GrantType    string `form:"grant_type" json:"grant_type" binding:"required,oneof=authorization_code"`
+	Code         string `form:"code" json:"code" binding:"required"`
+	RedirectURI  string `form:"redirect_uri" json:"redirect_uri" binding:"required"`
+	CodeVerifier string `form:"code_verifier" json:"code_verifier" binding:"required"`
+	ClientID     string `form:"client_id" json:"client_id,omitempty"`
+	ClientSecret string `form:"client_secret" json:"client_secret,omitempty"`
+}
+
+type OAuthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+	IDToken     string `json:"id_token,omitempty" doc:"仅authorization_code授权返回"`
+}
+
+// OAuthErrorResponse 令牌端点按RFC 6749 5.2返回错误，而不是problem+json，以兼容标准OAuth2客户端
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+func (h *OAuthHandler) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	grantType := c.PostForm("grant_type")
+	if grantType == "" {
+		oauthError(c, http.StatusBadRequest, "invalid_request", "grant_type is required")
+		return
+	}
+	switch grantType {
+	case grantTypeClientCredentials:
+		h.clientCredentialsGrant(c)
+	case grantTypeAuthorizationCode:
+		h.authorizationCodeGrant(c)
+	default:
+		oauthError(c, http.StatusBadRequest, "unsupported_grant_type", "Only client_credentials and authorization_code are supported")
+	}
+}
+
+func (h *OAuthHandler) clientCredentialsGrant(c *gin.Context) {
+	clientID, clientSecret, basic := clientCredentials(c)
+	if clientID == "" || clientSecret == "" {
+		oauthError(c, http.StatusBadRequest, "invalid_request", "Client credentials are required")
+		return
+	}
+
+	token, err := h.authService.ClientCredentials(clientID, clientSecret, strings.Fields(c.PostForm("scope")))
+	if err != nil {
+		switch service.ErrorCode(err) {
+		case service.ErrInvalidClient.Code:
+			invalidClient(c, basic)
+		case "invalid_scope":
+			oauthError(c, http.StatusBadRequest, "invalid_scope", err.Error())
+		default:
+			c.Error(err)
+		}
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionClientCredentials, 0)
+	event.Metadata = map[string]interface{}{"service_account_id": token.Account.ID, "scopes": token.Scopes}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusOK, OAuthTokenResponse{
+		AccessToken: token.AccessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(token.ExpiresIn.Seconds()),
+		Scope:       strings.Join(token.Scopes, " "),
+	})
+}
+
+func (h *OAuthHandler) authorizationCodeGrant(c *gin.Context) {
+	code := c.PostForm("code")
+	redirectURI := c.PostForm("redirect_uri")
+	codeVerifier := c.PostForm("code_verifier")
+	if code == "" || redirectURI == "" || codeVerifier == "" {
+		oauthError(c, http.StatusBadRequest, "invalid_request", "code, redirect_uri and code_verifier are required")
+		return
+	}
+
+	clientID, clientSecret, basic := clientCredentials(c)
+	if clientID == "" {
+		oauthError(c, http.StatusBadRequest, "invalid_request", "client_id is required")
+		return
+	}
+
+	tokens, err := h.oidcService.ExchangeCode(clientID, clientSecret, code, redirectURI, codeVerifier)
+	if err != nil {
+		switch service.ErrorCode(err) {
+		case service.ErrInvalidClient.Code:
+			invalidClient(c, basic)
+		case service.ErrInvalidGrant.Code:
+			oauthError(c, http.StatusBadRequest, "invalid_grant", err.Error())
+		default:
+			c.Error(err)
+		}
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionOAuthCodeExchange, tokens.UserID)
+	event.Metadata = map[string]interface{}{"client_id": tokens.ClientID, "scopes": tokens.Scopes}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusOK, OAuthTokenResponse{
+		AccessToken: tokens.AccessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(tokens.ExpiresIn.Seconds()),
+		Scope:       strings.Join(tokens.Scopes, " "),
+		IDToken:     tokens.IDToken,
+	})
+}
+
+// clientCredentials 优先使用HTTP Basic认证，用户名和密码按RFC 6749 2.3.1进行了URL编码
+// 解码失败时返回空值，由调用方按缺少凭据处理
+func clientCredentials(c *gin.Context) (clientID, clientSecret string, basic bool) {
+	if username, password, hasBasic := c.Request.BasicAuth(); hasBasic {
+		id, err := url.QueryUnescape(username)
+		if err != nil {
+			return "", "", true
+		}
+		secret, err := url.QueryUnescape(password)
+		if err != nil {
+			return "", "", true
+		}
+		return id, secret, true
+	}
+
+	return c.PostForm("client_id"), c.PostForm("client_secret"), false
+}
+
+func invalidClient(c *gin.Context, basic bool) {
+	if basic {
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	oauthError(c, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+}
+
+func oauthError(c *gin.Context, status int, code, description string) {
+	c.JSON(status, OAuthErrorResponse{Error: code, ErrorDescription: description})
+}
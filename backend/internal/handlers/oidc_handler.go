@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/service"
+)
+
+// OIDCHandler 处理OpenID Connect发现文档、授权同意和userinfo
+type OIDCHandler struct {
+	oidcService  service.OIDCService
+	auditService service.AuditService
+}
+
+func NewOIDCHandler(oidcService service.OIDCService, auditService service.AuditService) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService:  oidcService,
+		auditService: auditService,
+	}
+}
+
+// OpenIDConfiguration 是发现文档（OpenID Connect Discovery 1.0）
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	AuthorizationResponseIssSupported bool     `json:"authorization_response_iss_parameter_supported"`
+}
+
+type JWKSResponse struct {
+	Keys []service.JWK `json:"keys"`
+}
+
+type OAuthClientInfo struct {
+	ClientID string `json:"client_id"`
+	Name     string `json:"name"`
+}
+
+// AuthorizationPromptResponse 是同意页面展示的内容
+type AuthorizationPromptResponse struct {
+	Client          OAuthClientInfo `json:"client"`
+	Scopes          []string        `json:"scopes"`
+	ConsentRequired bool            `json:"consent_required" doc:"为false时用户已同意过这些scope，前端可直接提交同意"`
+}
+
+// AuthorizationDecisionRequest 携带原始授权参数和用户的选择
+type AuthorizationDecisionRequest struct {
+	ResponseType        string `json:"response_type" binding:"required"`
+	ClientID            string `json:"client_id" binding:"required"`
+	RedirectURI         string `json:"redirect_uri" binding:"required"`
+	Scope               string `json:"scope" binding:"required"`
+	State               string `json:"state,omitempty"`
+	Nonce               string `json:"nonce,omitempty"`
+	CodeChallenge       string `json:"code_challenge" binding:"required"`
+	CodeChallengeMethod string `json:"code_challenge_method" binding:"required"`
+	Approve             bool   `json:"approve" doc:"true为同意授权，false为拒绝"`
+}
+
+type AuthorizationRedirectResponse struct {
+	RedirectTo string `json:"redirect_to" doc:"客户端回调地址，携带code或error参数"`
+}
+
+func (h *OIDCHandler) Discovery(c *gin.Context) {
+	issuer := h.oidcService.Issuer()
+	c.JSON(http.StatusOK, OpenIDConfiguration{
+		Issuer: issuer,
+		// 授权端点是前端的同意页面，登录状态由浏览器会话维护
+		AuthorizationEndpoint:             issuer + "/oauth/authorize",
+		TokenEndpoint:                     issuer + "/api/v1/oauth/token",
+		UserInfoEndpoint:                  issuer + "/api/v1/userinfo",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		ScopesSupported:                   models.OIDCScopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{grantTypeAuthorizationCode, grantTypeClientCredentials},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{"RS256"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   []string{"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "preferred_username", "name", "email", "email_verified", "updated_at"},
+		AuthorizationResponseIssSupported: true,
+	})
+}
+
+func (h *OIDCHandler) JWKS(c *gin.Context) {
+	c.JSON(http.StatusOK, JWKSResponse{Keys: h.oidcService.JWKS()})
+}
+
+// Authorize 校验授权请求并返回同意页面需要的信息
+func (h *OIDCHandler) Authorize(c *gin.Context) {
+	var req service.AuthorizationRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	prompt, err := h.oidcService.Authorize(req, c.GetUint("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, AuthorizationPromptResponse{
+		Client:          OAuthClientInfo{ClientID: prompt.Client.ClientID, Name: prompt.Client.Name},
+		Scopes:          prompt.Scopes,
+		ConsentRequired: prompt.ConsentRequired,
+	})
+}
+
+// AuthorizeDecision 记录用户的同意或拒绝，返回浏览器需要跳转的回调地址
+func (h *OIDCHandler) AuthorizeDecision(c *gin.Context) {
+	var req AuthorizationDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	authReq := service.AuthorizationRequest{
+		ResponseType:        req.ResponseType,
+		ClientID:            req.ClientID,
+		RedirectURI:         req.RedirectURI,
+		Scope:               req.Scope,
+		State:               req.State,
+		Nonce:               req.Nonce,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+	}
+
+	userID := c.GetUint("userID")
+	if !req.Approve {
+		redirectTo, err := h.oidcService.Deny(authReq, userID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, AuthorizationRedirectResponse{RedirectTo: redirectTo})
+		return
+	}
+
+	// auth_time取会话最初登录的时间，由Auth中间件从令牌中读取，刷新令牌不会改变
+	redirectTo, err := h.oidcService.Approve(authReq, userID, c.GetTime("authTime"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionOAuthConsent, userID)
+	event.Metadata = map[string]interface{}{"client_id": req.ClientID, "scopes": strings.Fields(req.Scope)}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusOK, AuthorizationRedirectResponse{RedirectTo: redirectTo})
+}
+
+// UserInfo 使用授权码流程签发的访问令牌读取用户声明（OIDC Core 5.3）
+func (h *OIDCHandler) UserInfo(c *gin.Context) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		c.Header("WWW-Authenticate", `Bearer realm="userinfo"`)
+		c.Error(service.ErrUnauthorized)
+		return
+	}
+
+	info, err := h.oidcService.UserInfo(token)
+	if err != nil {
+		if service.ErrorCode(err) == service.ErrInvalidToken.Code {
+			c.Header("WWW-Authenticate", `Bearer realm="userinfo", error="invalid_token"`)
+		}
+		c.Error(err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, info)
+}
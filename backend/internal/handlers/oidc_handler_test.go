@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/middleware"
+	"github.com/user/user-management/internal/service"
+)
+
+type stubOIDCService struct {
+	service.OIDCService
+	authTime time.Time
+}
+
+func (s *stubOIDCService) Approve(req service.AuthorizationRequest, userID uint, authTime time.Time) (string, error) {
+	s.authTime = authTime
+	return req.RedirectURI + "?code=abc", nil
+}
+
+// auth_time取自Auth中间件设置的登录时间，不依赖Redis中的会话
+func TestAuthorizeDecisionUsesLoginTime(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	loginTime := time.Now().Add(-3 * time.Hour)
+	oidc := &stubOIDCService{}
+	handler := NewOIDCHandler(oidc, &stubAuditService{})
+
+	router := gin.New()
+	router.Use(middleware.ErrorHandler())
+	router.POST("/oauth/authorize", func(c *gin.Context) {
+		c.Set("userID", uint(1))
+		c.Set("authMethod", middleware.AuthMethodSession)
+		c.Set("authTime", loginTime)
+	}, handler.AuthorizeDecision)
+
+	body := `{"response_type":"code","client_id":"spa","redirect_uri":"https://app.example.com/callback","scope":"openid","code_challenge":"abc","code_challenge_method":"S256","approve":true}`
+	req := httptest.NewRequest(http.MethodPost, "/oauth/authorize", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", rec.Code, rec.Body.String())
+	}
+	if !oidc.authTime.Equal(loginTime) {
+		t.Fatalf("expected auth_time %v, got %v", loginTime, oidc.authTime)
+	}
+}
@@ -0,0 +1,787 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/middleware"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/openapi"
+	"github.com/user/user-management/internal/service"
+)
+
+const (
+	apiTitle   = "User Management API"
+	apiVersion = "1.0.0"
+	bearerAuth = "bearerAuth"
+	scimAuth   = "scimToken"
+)
+
+// 以下类型只用于文档，描述没有对应处理器结构体的请求体和响应
+type userMergePatch struct {
+	Username string `json:"username,omitempty" binding:"omitempty,min=3,max=20"`
+	Email    string `json:"email,omitempty" binding:"omitempty,email"`
+	Password string `json:"password,omitempty" doc:"只写字段，须满足密码策略"`
+	IsActive *bool  `json:"is_active,omitempty" doc:"仅管理员可修改"`
+	Role     string `json:"role,omitempty" binding:"omitempty,oneof=user admin" doc:"仅管理员可修改"`
+
+	CurrentPassword string `json:"current_password,omitempty" doc:"只写字段，修改本人邮箱或密码时用于再次认证"`
+}
+
+// stepUpDescription 说明修改本人邮箱和密码时的再次认证和邮箱确认
+const stepUpDescription = "修改邮箱或密码需要提供current_password，或者当前会话在STEP_UP_MAX_AGE内登录，否则返回403 reauthentication_required。" +
+	"新邮箱不会立即生效：向新旧地址各发送确认链接并返回202，响应中仍是原邮箱；修改密码后撤销当前会话之外的所有会话。"
+
+type jsonPatchOperation struct {
+	Op    string      `json:"op" binding:"required,oneof=add remove replace move copy test"`
+	Path  string      `json:"path" binding:"required" doc:"JSON Pointer，只能指向顶层字段，如/email"`
+	From  string      `json:"from,omitempty" doc:"move和copy的来源，不能为/password"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+type healthResponse struct {
+	Status   string `json:"status" binding:"required,oneof=healthy unhealthy"`
+	Database string `json:"database,omitempty"`
+	Redis    string `json:"redis,omitempty"`
+}
+
+type DocsHandler struct {
+	spec []byte
+	ui   []byte
+}
+
+func NewDocsHandler() (*DocsHandler, error) {
+	spec, err := json.Marshal(APISpec())
+	if err != nil {
+		return nil, err
+	}
+	ui, err := openapi.RenderUI(apiTitle, "/api/v1/openapi.json", "/api/v1/docs/swagger-ui")
+	if err != nil {
+		return nil, err
+	}
+	return &DocsHandler{spec: spec, ui: ui}, nil
+}
+
+func (h *DocsHandler) Spec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", h.spec)
+}
+
+func (h *DocsHandler) UI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", h.ui)
+}
+
+// Assets 提供文档页面内嵌的Swagger UI脚本和样式
+func (h *DocsHandler) Assets(c *gin.Context) {
+	data, contentType, ok := openapi.UIAsset(c.Param("file"))
+	if !ok {
+		c.Error(errDocsAssetNotFound)
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// APISpec 由处理器的请求/响应类型生成OpenAPI文档，新增路由时需要同步在这里注册
+func APISpec() *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:       apiTitle,
+		Version:     apiVersion,
+		Description: "错误响应统一为RFC 7807 application/problem+json，detail和字段message按Accept-Language本地化（en、zh-CN）。",
+	})
+	doc.AddSecurityScheme(bearerAuth, &openapi.SecurityScheme{
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: "JWT",
+		Description:  "登录获得的JWT、服务账号通过/oauth/token获得的JWT，或以" + service.AccessTokenPrefix + "开头的个人访问令牌；后两者只能访问其scope允许的接口",
+	})
+	doc.AddSecurityScheme(scimAuth, &openapi.SecurityScheme{
+		Type:        "http",
+		Scheme:      "bearer",
+		Description: "SCIM_TOKEN配置的令牌，只能访问/scim/v2下的接口",
+	})
+
+	var (
+		secured      = []string{bearerAuth}
+		tokenIDParam = openapi.Parameter{Name: "id", In: "path", Required: true, Description: "访问令牌ID", Schema: doc.SchemaOf(uint(0))}
+		idParam      = openapi.Parameter{Name: "id", In: "path", Required: true, Description: "用户ID", Schema: doc.SchemaOf(uint(0))}
+		pageParams   = []openapi.Parameter{queryParam(doc, "page", "页码，从1开始", 0), queryParam(doc, "limit", "每页数量，最大100", 0)}
+		ifMatch      = openapi.Parameter{Name: "If-Match", In: "header", Description: "上次读取时的ETag，不一致时返回412；设置REQUIRE_IF_MATCH后必填", Schema: doc.SchemaOf("")}
+		ifNoneMatch  = openapi.Parameter{Name: "If-None-Match", In: "header", Description: "与当前ETag一致时返回304", Schema: doc.SchemaOf("")}
+		patchBody    = &openapi.Body{
+			Content: map[string]interface{}{
+				mediaTypeMergePatch: userMergePatch{},
+				mediaTypeJSONPatch:  []jsonPatchOperation{},
+			},
+		}
+	)
+
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/auth/register", Summary: "用户注册", Tags: []string{"auth"},
+		Description: "密码不满足密码策略时返回422，错误码为weak_password，errors中列出未通过的各条规则；修改密码的接口同样适用。" +
+			"REGISTRATION_MODE=invitation时返回403，错误码为registration_disabled。",
+		Request:   &openapi.Body{Value: RegisterRequest{}},
+		Responses: responses(ok(http.StatusCreated, RegisterResponse{}), problem(http.StatusForbidden), problem(http.StatusConflict), problem(http.StatusUnprocessableEntity)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/auth/registration", Summary: "查询注册方式", Tags: []string{"auth"},
+		Responses: responses(ok(http.StatusOK, RegistrationResponse{})),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/auth/invitations/lookup", Summary: "查询注册邀请", Tags: []string{"auth"},
+		Description: "token为邀请邮件链接中的token参数，返回受邀邮箱和注册后加入的组织。",
+		Request:     &openapi.Body{Value: UserInvitationTokenRequest{}},
+		Responses:   responses(ok(http.StatusOK, UserInvitationLookupResponse{}), problem(http.StatusBadRequest)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/auth/invitations/accept", Summary: "通过注册邀请注册", Tags: []string{"auth"},
+		Description: "以邀请中的邮箱和角色创建用户并加入邀请中的组织，邀请只能使用一次；注册后需要再登录。",
+		Request:     &openapi.Body{Value: AcceptUserInvitationRequest{}},
+		Responses:   responses(ok(http.StatusCreated, RegisterResponse{}), problem(http.StatusBadRequest), problem(http.StatusConflict), problem(http.StatusUnprocessableEntity)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/auth/login", Summary: "用户登录", Tags: []string{"auth"},
+		Description: "已注册安全密钥的用户返回202和第二因素票据，需再通过/auth/webauthn/login完成登录。",
+		Request:     &openapi.Body{Value: LoginRequest{}},
+		Responses:   responses(ok(http.StatusOK, LoginResponse{}), ok(http.StatusAccepted, SecondFactorResponse{}), problem(http.StatusUnauthorized), problem(http.StatusForbidden), problem(http.StatusLocked)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/auth/logout", Summary: "用户登出", Tags: []string{"auth"}, Security: secured,
+		Responses: responses(ok(http.StatusOK, MessageResponse{}), problem(http.StatusUnauthorized), problem(http.StatusForbidden)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/auth/refresh", Summary: "刷新令牌", Tags: []string{"auth"},
+		Description: "使用刷新令牌换取新的访问令牌，旧的刷新令牌立即失效。",
+		Request:     &openapi.Body{Value: RefreshTokenRequest{}},
+		Responses:   responses(ok(http.StatusOK, TokenResponse{}), problem(http.StatusUnauthorized)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/auth/magic-link", Summary: "发送邮件登录链接", Tags: []string{"auth"},
+		Description: "无论邮箱是否已注册都返回202，同时写入magic_link_nonce Cookie，链接只能在同一浏览器中使用。每个邮箱在一段时间内的请求次数有限，超过时返回429。",
+		Request:     &openapi.Body{Value: MagicLinkRequest{}},
+		Responses:   responses(openapi.Response{Status: http.StatusAccepted, Value: MessageResponse{}, Headers: []string{"Set-Cookie"}}, problem(http.StatusUnprocessableEntity), problem(http.StatusTooManyRequests)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/auth/magic-link/consume", Summary: "使用邮件登录链接登录", Tags: []string{"auth"},
+		Description: "需携带请求链接时写入的Cookie，链接只能使用一次。响应与密码登录相同，已注册安全密钥的用户返回202和第二因素票据。",
+		Request:     &openapi.Body{Value: MagicLinkConsumeRequest{}},
+		Responses:   responses(ok(http.StatusOK, LoginResponse{}), ok(http.StatusAccepted, SecondFactorResponse{}), problem(http.StatusUnauthorized), problem(http.StatusForbidden)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/auth/email-change/confirm", Summary: "确认修改邮箱", Tags: []string{"auth"},
+		Description: "修改邮箱时向新旧地址各发送一个链接，两个都确认后邮箱才会修改，同时撤销发起修改的会话之外的所有会话。",
+		Request:     &openapi.Body{Value: EmailChangeConfirmRequest{}},
+		Responses:   responses(ok(http.StatusOK, EmailChangeConfirmResponse{}), problem(http.StatusBadRequest), problem(http.StatusConflict)),
+	})
+
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/auth/oidc", Summary: "外部身份提供方登录配置", Tags: []string{"auth"},
+		Responses: responses(ok(http.StatusOK, FederationProviderResponse{})),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/auth/oidc/login", Summary: "跳转到外部身份提供方登录", Tags: []string{"auth"},
+		Description: "由浏览器直接访问，302跳转到身份提供方，同时写入state Cookie。",
+		Parameters:  []openapi.Parameter{queryParam(doc, "redirect", "登录完成后前端跳转的站内路径", "")},
+		Responses:   responses(openapi.Response{Status: http.StatusFound, Description: "跳转到身份提供方", Headers: []string{"Location"}}, problem(http.StatusNotFound)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/auth/oidc/callback", Summary: "外部身份提供方登录回调", Tags: []string{"auth"},
+		Description: "校验state、nonce、PKCE和ID Token后创建会话，302跳转到前端/login/callback并携带一次性登录码code；失败时携带error错误码。",
+		Parameters: []openapi.Parameter{
+			queryParam(doc, "code", "身份提供方返回的授权码", ""),
+			queryParam(doc, "state", "发起登录时生成的state", ""),
+		},
+		Responses: []openapi.Response{{Status: http.StatusFound, Description: "跳转到前端登录回调页面", Headers: []string{"Location"}}},
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/auth/oidc/exchange", Summary: "用一次性登录码换取令牌", Tags: []string{"auth"},
+		Description: "登录码1分钟内有效且只能使用一次，响应与密码登录相同。",
+		Request:     &openapi.Body{Value: FederationExchangeRequest{}},
+		Responses:   responses(ok(http.StatusOK, LoginResponse{}), problem(http.StatusUnauthorized)),
+	})
+
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/auth/saml/:slug/metadata", Summary: "组织的SAML服务提供方元数据", Tags: []string{"auth"},
+		Description: "导入组织的身份提供方，包含SP实体ID、ACS地址和证书。",
+		Parameters:  []openapi.Parameter{{Name: "slug", In: "path", Required: true, Description: "组织标识", Schema: doc.SchemaOf("")}},
+		Responses:   responses(openapi.Response{Status: http.StatusOK, ContentType: "application/samlmetadata+xml", Value: ""}, problem(http.StatusNotFound)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/auth/saml/:slug/login", Summary: "跳转到组织的SAML身份提供方登录", Tags: []string{"auth"},
+		Description: "由浏览器直接访问，以HTTP-Redirect绑定发送AuthnRequest。",
+		Parameters: []openapi.Parameter{
+			{Name: "slug", In: "path", Required: true, Description: "组织标识", Schema: doc.SchemaOf("")},
+			queryParam(doc, "redirect", "登录完成后前端跳转的站内路径", ""),
+		},
+		Responses: responses(openapi.Response{Status: http.StatusFound, Description: "跳转到身份提供方", Headers: []string{"Location"}}, problem(http.StatusNotFound)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/auth/saml/:slug/acs", Summary: "SAML断言消费端点（ACS）", Tags: []string{"auth"},
+		Description: "校验签名、受众、有效期、InResponseTo并拒绝重放的断言，然后创建会话，302跳转到前端/login/callback并携带一次性登录码code；失败时携带error错误码。",
+		Parameters:  []openapi.Parameter{{Name: "slug", In: "path", Required: true, Description: "组织标识", Schema: doc.SchemaOf("")}},
+		Request: &openapi.Body{Content: map[string]interface{}{
+			"application/x-www-form-urlencoded": SAMLResponseForm{},
+		}},
+		Responses: []openapi.Response{{Status: http.StatusFound, Description: "跳转到前端登录回调页面", Headers: []string{"Location"}}},
+	})
+
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/auth/webauthn/login/begin", Summary: "开始安全密钥登录", Tags: []string{"auth"},
+		Description: "不带票据时发起通行密钥无密码登录，要求用户验证；带密码登录返回的票据时只允许该用户已注册的安全密钥。",
+		Request:     &openapi.Body{Value: WebAuthnLoginBeginRequest{}},
+		Responses:   responses(ok(http.StatusOK, WebAuthnCeremonyResponse{}), problem(http.StatusUnauthorized)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/auth/webauthn/login/finish", Summary: "完成安全密钥登录", Tags: []string{"auth"},
+		Description: "校验断言签名和签名计数，响应与密码登录相同。",
+		Request:     &openapi.Body{Value: WebAuthnFinishRequest{}},
+		Responses:   responses(ok(http.StatusOK, LoginResponse{}), problem(http.StatusBadRequest), problem(http.StatusUnauthorized), problem(http.StatusForbidden)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/auth/impersonation/end", Summary: "结束模拟用户", Tags: []string{"auth"}, Security: secured,
+		Description: "使用模拟令牌调用，模拟令牌立即失效，被模拟用户自己的会话不受影响。",
+		Responses:   responses(ok(http.StatusOK, MessageResponse{}), problem(http.StatusBadRequest), problem(http.StatusUnauthorized)),
+	})
+
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/oauth/token", Summary: "获取访问令牌（OAuth2令牌端点）", Tags: []string{"oauth"},
+		Description: "支持服务账号的client_credentials和OIDC客户端的authorization_code（必须携带PKCE的code_verifier）。客户端凭据可以通过HTTP Basic认证或表单字段传递，公开客户端只提交client_id。错误按RFC 6749返回{error, error_description}。",
+		Request: &openapi.Body{Content: map[string]interface{}{
+			"application/x-www-form-urlencoded": &openapi.Schema{AnyOf: []*openapi.Schema{doc.SchemaOf(ClientCredentialsRequest{}), doc.SchemaOf(AuthorizationCodeRequest{})}},
+		}},
+		Responses: []openapi.Response{
+			ok(http.StatusOK, OAuthTokenResponse{}),
+			{Status: http.StatusBadRequest, Description: "invalid_request、unsupported_grant_type、invalid_scope或invalid_grant", Value: OAuthErrorResponse{}},
+			{Status: http.StatusUnauthorized, Description: "invalid_client", Value: OAuthErrorResponse{}},
+		},
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/oauth/authorize", Summary: "校验OIDC授权请求并获取同意页面信息", Tags: []string{"oauth"}, Security: secured,
+		Description: "由前端同意页面（/oauth/authorize）携带原始授权参数调用，只接受登录会话。要求response_type=code、已注册的redirect_uri、包含openid的scope以及S256的PKCE。",
+		Parameters: []openapi.Parameter{
+			queryParam(doc, "response_type", "固定为code", ""),
+			queryParam(doc, "client_id", "客户端ID", ""),
+			queryParam(doc, "redirect_uri", "已注册的回调地址", ""),
+			queryParam(doc, "scope", "空格分隔的scope，必须包含openid", ""),
+			queryParam(doc, "state", "原样返回给客户端", ""),
+			queryParam(doc, "nonce", "写入ID Token", ""),
+			queryParam(doc, "code_challenge", "PKCE code_challenge", ""),
+			queryParam(doc, "code_challenge_method", "固定为S256", ""),
+		},
+		Responses: responses(ok(http.StatusOK, AuthorizationPromptResponse{}), problem(http.StatusBadRequest), problem(http.StatusNotFound), problem(http.StatusUnprocessableEntity)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/oauth/authorize", Summary: "同意或拒绝OIDC授权", Tags: []string{"oauth"}, Security: secured,
+		Description: "同意时签发一次性授权码（1分钟内有效），返回浏览器需要跳转的回调地址。",
+		Request:     &openapi.Body{Value: AuthorizationDecisionRequest{}},
+		Responses:   responses(ok(http.StatusOK, AuthorizationRedirectResponse{}), problem(http.StatusBadRequest), problem(http.StatusNotFound), problem(http.StatusUnprocessableEntity)),
+	})
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		doc.Add(openapi.Operation{
+			Method: method, Path: "/api/v1/userinfo", Summary: "获取OIDC用户信息", Tags: []string{"oauth"},
+			Description: "使用authorization_code授权获得的访问令牌（Bearer）调用，返回的声明取决于授权的scope。",
+			Responses:   responses(ok(http.StatusOK, map[string]interface{}{}), problem(http.StatusUnauthorized)),
+		})
+	}
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/.well-known/openid-configuration", Summary: "OpenID Connect发现文档", Tags: []string{"oauth"},
+		Responses: []openapi.Response{ok(http.StatusOK, OpenIDConfiguration{})},
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/.well-known/jwks.json", Summary: "ID Token签名公钥（JWKS）", Tags: []string{"oauth"},
+		Responses: []openapi.Response{ok(http.StatusOK, JWKSResponse{})},
+	})
+
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/users", Summary: "获取用户列表", Tags: []string{"users"}, Security: secured,
+		Description: "用户接口只能访问访问令牌所在组织的成员；令牌不属于任何组织时只能访问不属于任何组织的用户。" +
+			"其他用户的private扩展属性只对管理员返回。",
+		Parameters: append(pageParams, openapi.Parameter{
+			Name: "attributes", In: "query", Style: "deepObject", Explode: true, Schema: doc.SchemaOf(map[string]string{}),
+			Description: "按扩展属性值过滤，例如attributes[department]=eng；非管理员不能按private属性过滤",
+		}),
+		Responses: responses(ok(http.StatusOK, UserListResponse{}), problem(http.StatusBadRequest)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/users/:id", Summary: "获取用户详情", Tags: []string{"users"}, Security: secured,
+		Parameters: []openapi.Parameter{idParam, ifNoneMatch},
+		Responses:  responses(etag(ok(http.StatusOK, models.User{})), notModified(), problem(http.StatusNotFound)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/users/:id/groups", Summary: "获取用户的有效组", Tags: []string{"users"}, Security: secured,
+		Description: "包括直接所属的组和通过子组间接所属的上级组，按组名排序。访问令牌的groups声明超过GROUP_CLAIM_LIMIT时改为groups_overage，此时通过本接口查询。",
+		Parameters:  []openapi.Parameter{idParam},
+		Responses:   responses(ok(http.StatusOK, UserGroupListResponse{}), problem(http.StatusBadRequest), problem(http.StatusNotFound)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPut, Path: "/api/v1/users/:id", Summary: "更新用户信息", Tags: []string{"users"}, Security: secured,
+		Description: "只有管理员可以修改role和admin_only扩展属性，本人可以修改自己的其他扩展属性。",
+		Parameters:  []openapi.Parameter{idParam, ifMatch},
+		Request:     &openapi.Body{Value: UpdateUserRequest{}},
+		Responses:   responses(etag(ok(http.StatusOK, models.User{})), problem(http.StatusForbidden), problem(http.StatusNotFound), problem(http.StatusConflict), problem(http.StatusPreconditionFailed), problem(http.StatusPreconditionRequired)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPatch, Path: "/api/v1/users/:id", Summary: "部分更新用户", Tags: []string{"users"}, Security: secured,
+		Description: "支持JSON Merge Patch（RFC 7396）和JSON Patch（RFC 6902）。普通用户只能修改自己的username、email、password和非admin_only的扩展属性，扩展属性可以按/attributes/<name>单独修改。",
+		Parameters:  []openapi.Parameter{idParam, ifMatch},
+		Request:     patchBody,
+		Responses:   responses(etag(ok(http.StatusOK, models.User{})), problem(http.StatusForbidden), problem(http.StatusNotFound), problem(http.StatusPreconditionFailed), problem(http.StatusUnsupportedMediaType), problem(http.StatusUnprocessableEntity)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodDelete, Path: "/api/v1/users/:id", Summary: "删除用户", Tags: []string{"users"}, Security: secured,
+		Parameters: []openapi.Parameter{idParam},
+		Responses:  responses(ok(http.StatusOK, MessageResponse{}), problem(http.StatusForbidden), problem(http.StatusNotFound)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/users/attributes", Summary: "获取扩展属性定义", Tags: []string{"profile"}, Security: secured,
+		Description: "用于显示资料表单；属性值在用户信息的attributes中。",
+		Responses:   responses(ok(http.StatusOK, AttributeListResponse{})),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/users/profile", Summary: "获取当前用户信息", Tags: []string{"profile"}, Security: secured,
+		Parameters: []openapi.Parameter{ifNoneMatch},
+		Responses:  responses(etag(ok(http.StatusOK, models.User{})), notModified()),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPut, Path: "/api/v1/users/profile", Summary: "更新当前用户信息", Tags: []string{"profile"}, Security: secured,
+		Description: stepUpDescription,
+		Parameters:  []openapi.Parameter{ifMatch},
+		Request:     &openapi.Body{Value: UpdateUserRequest{}},
+		Responses:   responses(etag(ok(http.StatusOK, models.User{})), etag(ok(http.StatusAccepted, models.User{})), problem(http.StatusForbidden), problem(http.StatusConflict), problem(http.StatusPreconditionFailed), problem(http.StatusPreconditionRequired), problem(http.StatusUnprocessableEntity)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPatch, Path: "/api/v1/users/profile", Summary: "部分更新当前用户", Tags: []string{"profile"}, Security: secured,
+		Description: stepUpDescription,
+		Parameters:  []openapi.Parameter{ifMatch},
+		Request:     patchBody,
+		Responses:   responses(etag(ok(http.StatusOK, models.User{})), etag(ok(http.StatusAccepted, models.User{})), problem(http.StatusForbidden), problem(http.StatusPreconditionFailed), problem(http.StatusUnsupportedMediaType), problem(http.StatusUnprocessableEntity)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPut, Path: "/api/v1/users/profile/avatar", Summary: "上传当前用户的头像", Tags: []string{"profile"}, Security: secured,
+		Description: "按文件内容识别JPEG、PNG和GIF（只取第一帧），居中裁剪为正方形并缩小到AVATAR_SIZE，重新编码为JPEG（去除EXIF等元数据），同时生成AVATAR_THUMBNAIL_SIZE的缩略图。超过AVATAR_MAX_SIZE时返回413。",
+		Request: &openapi.Body{Content: map[string]interface{}{
+			"multipart/form-data": &openapi.Schema{
+				Type:       "object",
+				Properties: map[string]*openapi.Schema{"avatar": {Type: "string", Format: "binary", Description: "头像图片"}},
+				Required:   []string{"avatar"},
+			},
+		}},
+		Responses: responses(etag(ok(http.StatusOK, models.User{})), problem(http.StatusBadRequest), problem(http.StatusRequestEntityTooLarge), problem(http.StatusUnsupportedMediaType), problem(http.StatusUnprocessableEntity)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodDelete, Path: "/api/v1/users/profile/avatar", Summary: "删除当前用户的头像", Tags: []string{"profile"}, Security: secured,
+		Responses: responses(etag(ok(http.StatusOK, models.User{}))),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/users/profile/export", Summary: "导出当前用户的全部个人数据（GDPR）", Tags: []string{"profile"}, Security: secured,
+		Responses: responses(ok(http.StatusOK, service.UserDataExport{})),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/users/profile/erase", Summary: "擦除当前用户的个人数据（GDPR）", Tags: []string{"profile"}, Security: secured,
+		Description: "有密码的用户需提供当前密码，错误时返回401；只能通过外部身份登录的用户须在STEP_UP_MAX_AGE内登录，否则返回403 reauthentication_required。",
+		Request:     &openapi.Body{Value: EraseAccountRequest{}},
+		Responses:   responses(ok(http.StatusOK, MessageResponse{}), problem(http.StatusUnauthorized), problem(http.StatusForbidden)),
+	})
+
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/users/profile/tokens", Summary: "列出当前用户的个人访问令牌", Tags: []string{"tokens"}, Security: secured,
+		Description: "只能通过登录会话访问，不返回令牌明文。",
+		Responses:   responses(ok(http.StatusOK, AccessTokenListResponse{}), problem(http.StatusForbidden)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/users/profile/tokens", Summary: "创建个人访问令牌", Tags: []string{"tokens"}, Security: secured,
+		Description: "只能通过登录会话访问。令牌明文只在本次响应中返回，之后无法再次获取。",
+		Request:     &openapi.Body{Value: CreateAccessTokenRequest{}},
+		Responses:   responses(ok(http.StatusCreated, CreatedAccessTokenResponse{}), problem(http.StatusForbidden), problem(http.StatusUnprocessableEntity)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodDelete, Path: "/api/v1/users/profile/tokens/:id", Summary: "撤销个人访问令牌", Tags: []string{"tokens"}, Security: secured,
+		Parameters: []openapi.Parameter{tokenIDParam},
+		Responses:  responses(ok(http.StatusOK, MessageResponse{}), problem(http.StatusForbidden), problem(http.StatusNotFound)),
+	})
+
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/users/profile/webauthn", Summary: "列出当前用户的安全密钥", Tags: []string{"webauthn"}, Security: secured,
+		Description: "只能通过登录会话访问。",
+		Responses:   responses(ok(http.StatusOK, WebAuthnCredentialListResponse{}), problem(http.StatusForbidden)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/users/profile/webauthn/register/begin", Summary: "开始注册安全密钥", Tags: []string{"webauthn"}, Security: secured,
+		Description: "返回navigator.credentials.create()的参数，已注册的密钥列入excludeCredentials。",
+		Responses:   responses(ok(http.StatusOK, WebAuthnCeremonyResponse{}), problem(http.StatusForbidden)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/users/profile/webauthn/register/finish", Summary: "完成注册安全密钥", Tags: []string{"webauthn"}, Security: secured,
+		Description: "注册后该用户的密码登录需要额外完成安全密钥验证。",
+		Request:     &openapi.Body{Value: WebAuthnRegisterRequest{}},
+		Responses:   responses(ok(http.StatusCreated, WebAuthnCredentialResponse{}), problem(http.StatusBadRequest), problem(http.StatusUnauthorized), problem(http.StatusForbidden)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodDelete, Path: "/api/v1/users/profile/webauthn/:id", Summary: "删除安全密钥", Tags: []string{"webauthn"}, Security: secured,
+		Parameters: []openapi.Parameter{{Name: "id", In: "path", Required: true, Description: "安全密钥ID", Schema: doc.SchemaOf(uint(0))}},
+		Responses:  responses(ok(http.StatusOK, MessageResponse{}), problem(http.StatusBadRequest), problem(http.StatusForbidden), problem(http.StatusNotFound)),
+	})
+
+	orgIDParam := openapi.Parameter{Name: "id", In: "path", Required: true, Description: "组织ID", Schema: doc.SchemaOf(uint(0))}
+	memberIDParam := openapi.Parameter{Name: "user_id", In: "path", Required: true, Description: "成员的用户ID", Schema: doc.SchemaOf(uint(0))}
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/organizations", Summary: "列出当前用户加入的组织", Tags: []string{"organizations"}, Security: secured,
+		Description: "组织接口只能通过登录会话访问，模拟用户期间不能访问。",
+		Responses:   responses(ok(http.StatusOK, OrganizationListResponse{}), problem(http.StatusForbidden)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/organizations", Summary: "创建组织", Tags: []string{"organizations"}, Security: secured,
+		Description: "创建者成为owner，当前会话切换到新组织：响应中是新签发的令牌，之前的访问令牌失效。",
+		Request:     &openapi.Body{Value: CreateOrganizationRequest{}},
+		Responses:   responses(ok(http.StatusCreated, OrganizationSessionResponse{}), problem(http.StatusForbidden), problem(http.StatusConflict), problem(http.StatusUnprocessableEntity)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/organizations/invitations/accept", Summary: "接受组织邀请", Tags: []string{"organizations"}, Security: secured,
+		Description: "邀请只能由邮箱与邀请一致的用户接受。加入后当前会话切换到该组织，之前的访问令牌失效。",
+		Request:     &openapi.Body{Value: AcceptInvitationRequest{}},
+		Responses:   responses(ok(http.StatusOK, OrganizationSessionResponse{}), problem(http.StatusBadRequest), problem(http.StatusForbidden), problem(http.StatusConflict)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/organizations/:id/switch", Summary: "切换到组织", Tags: []string{"organizations"}, Security: secured,
+		Description: "在同一会话中签发该组织的令牌（org_id声明），之前的访问令牌失效。",
+		Parameters:  []openapi.Parameter{orgIDParam},
+		Responses:   responses(ok(http.StatusOK, OrganizationSessionResponse{}), problem(http.StatusForbidden), problem(http.StatusNotFound)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodDelete, Path: "/api/v1/organizations/:id", Summary: "删除组织（owner）", Tags: []string{"organizations"}, Security: secured,
+		Description: "删除成员关系和未接受的邀请，成员在该组织中的令牌失效，刷新后回到其他组织。",
+		Parameters:  []openapi.Parameter{orgIDParam},
+		Responses:   responses(ok(http.StatusOK, MessageResponse{}), problem(http.StatusForbidden), problem(http.StatusNotFound)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/organizations/:id/members", Summary: "列出组织成员", Tags: []string{"organizations"}, Security: secured,
+		Parameters: []openapi.Parameter{orgIDParam},
+		Responses:  responses(ok(http.StatusOK, MemberListResponse{}), problem(http.StatusForbidden), problem(http.StatusNotFound)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPatch, Path: "/api/v1/organizations/:id/members/:user_id", Summary: "修改成员角色（owner、admin）", Tags: []string{"organizations"}, Security: secured,
+		Description: "只有owner可以授予或撤销owner角色，组织至少保留一个owner。",
+		Parameters:  []openapi.Parameter{orgIDParam, memberIDParam},
+		Request:     &openapi.Body{Value: UpdateMemberRoleRequest{}},
+		Responses:   responses(ok(http.StatusOK, MessageResponse{}), problem(http.StatusForbidden), problem(http.StatusNotFound), problem(http.StatusConflict), problem(http.StatusUnprocessableEntity)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodDelete, Path: "/api/v1/organizations/:id/members/:user_id", Summary: "移除成员或退出组织", Tags: []string{"organizations"}, Security: secured,
+		Description: "成员可以移除自己以退出组织；移除其他成员需要owner或admin，移除owner需要owner。",
+		Parameters:  []openapi.Parameter{orgIDParam, memberIDParam},
+		Responses:   responses(ok(http.StatusOK, MessageResponse{}), problem(http.StatusForbidden), problem(http.StatusNotFound), problem(http.StatusConflict)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/organizations/:id/invitations", Summary: "列出未接受的邀请（owner、admin）", Tags: []string{"organizations"}, Security: secured,
+		Parameters: []openapi.Parameter{orgIDParam},
+		Responses:  responses(ok(http.StatusOK, InvitationListResponse{}), problem(http.StatusForbidden), problem(http.StatusNotFound)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/organizations/:id/invitations", Summary: "邀请加入组织（owner、admin）", Tags: []string{"organizations"}, Security: secured,
+		Description: "向邮箱发送邀请链接，ORGANIZATION_INVITATION_TTL内有效；同一邮箱之前未接受的邀请作废。只有owner可以邀请owner。",
+		Parameters:  []openapi.Parameter{orgIDParam},
+		Request:     &openapi.Body{Value: CreateInvitationRequest{}},
+		Responses:   responses(ok(http.StatusCreated, InvitationResponse{}), problem(http.StatusForbidden), problem(http.StatusNotFound), problem(http.StatusConflict), problem(http.StatusUnprocessableEntity)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodDelete, Path: "/api/v1/organizations/:id/invitations/:invitation_id", Summary: "撤销邀请（owner、admin）", Tags: []string{"organizations"}, Security: secured,
+		Parameters: []openapi.Parameter{orgIDParam, {Name: "invitation_id", In: "path", Required: true, Description: "邀请ID", Schema: doc.SchemaOf(uint(0))}},
+		Responses:  responses(ok(http.StatusOK, MessageResponse{}), problem(http.StatusBadRequest), problem(http.StatusForbidden), problem(http.StatusNotFound)),
+	})
+
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/admin/users/:id/impersonate", Summary: "模拟用户（管理员）", Tags: []string{"admin"}, Security: secured,
+		Description: "签发以该用户身份访问的短期令牌，期间的操作在审计日志中记为管理员本人。不能模拟管理员、停用的用户或自己；模拟期间不能修改密码、邮箱、安全密钥和访问令牌，也不能访问管理员接口。",
+		Parameters:  []openapi.Parameter{idParam},
+		Responses:   responses(ok(http.StatusCreated, ImpersonationResponse{}), problem(http.StatusBadRequest), problem(http.StatusForbidden), problem(http.StatusNotFound)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/admin/audit-logs", Summary: "查询审计日志（管理员）", Tags: []string{"admin"}, Security: secured,
+		Description: "AUDIT_LOG_GROUPS中任一组（包括其子组）的成员也可以查询。",
+		Parameters: append([]openapi.Parameter{
+			queryParam(doc, "actor_id", "操作者ID", uint(0)),
+			queryParam(doc, "target_id", "目标用户ID", uint(0)),
+			queryParam(doc, "action", "操作类型，如login、user_update", ""),
+			{Name: "from", In: "query", Description: "起始时间（RFC3339）", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			{Name: "to", In: "query", Description: "结束时间（RFC3339）", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+		}, pageParams...),
+		Responses: responses(ok(http.StatusOK, AuditLogListResponse{}), problem(http.StatusBadRequest), problem(http.StatusForbidden)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/admin/audit-logs/verify", Summary: "校验审计日志哈希链（管理员）", Tags: []string{"admin"}, Security: secured,
+		Description: "AUDIT_LOG_GROUPS中任一组（包括其子组）的成员也可以校验。",
+		Responses:   responses(ok(http.StatusOK, service.AuditVerification{}), problem(http.StatusForbidden)),
+	})
+
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/admin/service-accounts", Summary: "列出服务账号（管理员）", Tags: []string{"admin"}, Security: secured,
+		Responses: responses(ok(http.StatusOK, ServiceAccountListResponse{}), problem(http.StatusForbidden)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/admin/service-accounts", Summary: "创建服务账号（管理员）", Tags: []string{"admin"}, Security: secured,
+		Description: "client_secret只在本次响应中返回。",
+		Request:     &openapi.Body{Value: CreateServiceAccountRequest{}},
+		Responses:   responses(ok(http.StatusCreated, CreatedServiceAccountResponse{}), problem(http.StatusForbidden), problem(http.StatusConflict), problem(http.StatusUnprocessableEntity)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodDelete, Path: "/api/v1/admin/service-accounts/:id", Summary: "删除服务账号（管理员）", Tags: []string{"admin"}, Security: secured,
+		Description: "已签发的令牌立即失效。",
+		Parameters:  []openapi.Parameter{{Name: "id", In: "path", Required: true, Description: "服务账号ID", Schema: doc.SchemaOf(uint(0))}},
+		Responses:   responses(ok(http.StatusOK, MessageResponse{}), problem(http.StatusForbidden), problem(http.StatusNotFound)),
+	})
+
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/admin/oauth-clients", Summary: "列出OIDC客户端（管理员）", Tags: []string{"admin"}, Security: secured,
+		Responses: responses(ok(http.StatusOK, OAuthClientListResponse{}), problem(http.StatusForbidden)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/admin/oauth-clients", Summary: "注册OIDC客户端（管理员）", Tags: []string{"admin"}, Security: secured,
+		Description: "机密客户端的client_secret只在本次响应中返回。",
+		Request:     &openapi.Body{Value: RegisterOAuthClientRequest{}},
+		Responses:   responses(ok(http.StatusCreated, RegisteredOAuthClientResponse{}), problem(http.StatusForbidden), problem(http.StatusUnprocessableEntity)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodDelete, Path: "/api/v1/admin/oauth-clients/:id", Summary: "删除OIDC客户端（管理员）", Tags: []string{"admin"}, Security: secured,
+		Description: "同时删除用户对该客户端的授权同意记录。",
+		Parameters:  []openapi.Parameter{{Name: "id", In: "path", Required: true, Description: "客户端ID", Schema: doc.SchemaOf(uint(0))}},
+		Responses:   responses(ok(http.StatusOK, MessageResponse{}), problem(http.StatusForbidden), problem(http.StatusNotFound)),
+	})
+
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/admin/saml-connections", Summary: "列出组织的SAML连接（管理员）", Tags: []string{"admin"}, Security: secured,
+		Responses: responses(ok(http.StatusOK, SAMLConnectionListResponse{}), problem(http.StatusForbidden)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/admin/saml-connections", Summary: "创建组织的SAML连接（管理员）", Tags: []string{"admin"}, Security: secured,
+		Description: "IdP元数据必须包含签名证书和HTTP-Redirect绑定的单点登录地址。",
+		Request:     &openapi.Body{Value: CreateSAMLConnectionRequest{}},
+		Responses:   responses(ok(http.StatusCreated, SAMLConnectionResponse{}), problem(http.StatusForbidden), problem(http.StatusConflict), problem(http.StatusUnprocessableEntity)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodDelete, Path: "/api/v1/admin/saml-connections/:id", Summary: "删除组织的SAML连接（管理员）", Tags: []string{"admin"}, Security: secured,
+		Description: "已关联的用户保留，但无法再通过该连接登录。",
+		Parameters:  []openapi.Parameter{{Name: "id", In: "path", Required: true, Description: "连接ID", Schema: doc.SchemaOf(uint(0))}},
+		Responses:   responses(ok(http.StatusOK, MessageResponse{}), problem(http.StatusForbidden), problem(http.StatusNotFound)),
+	})
+
+	groupIDParam := openapi.Parameter{Name: "id", In: "path", Required: true, Description: "组ID", Schema: doc.SchemaOf(uint(0))}
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/admin/groups", Summary: "列出组（管理员）", Tags: []string{"admin"}, Security: secured,
+		Parameters: pageParams,
+		Responses:  responses(ok(http.StatusOK, GroupListResponse{}), problem(http.StatusForbidden)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/admin/groups", Summary: "创建组（管理员）", Tags: []string{"admin"}, Security: secured,
+		Description: "组名唯一，与SCIM /Groups维护的组相同。",
+		Request:     &openapi.Body{Value: CreateGroupRequest{}},
+		Responses:   responses(ok(http.StatusCreated, GroupResponse{}), problem(http.StatusForbidden), problem(http.StatusConflict), problem(http.StatusUnprocessableEntity)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/admin/groups/:id", Summary: "获取组的直接成员和子组（管理员）", Tags: []string{"admin"}, Security: secured,
+		Description: "成员只包含当前组织内的用户。",
+		Parameters:  []openapi.Parameter{groupIDParam},
+		Responses:   responses(ok(http.StatusOK, GroupDetailResponse{}), problem(http.StatusBadRequest), problem(http.StatusForbidden), problem(http.StatusNotFound)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodDelete, Path: "/api/v1/admin/groups/:id", Summary: "删除组（管理员）", Tags: []string{"admin"}, Security: secured,
+		Description: "同时删除成员关系和嵌套关系，子组本身保留。",
+		Parameters:  []openapi.Parameter{groupIDParam},
+		Responses:   responses(ok(http.StatusOK, MessageResponse{}), problem(http.StatusBadRequest), problem(http.StatusForbidden), problem(http.StatusNotFound)),
+	})
+	groupMemberParam := openapi.Parameter{Name: "user_id", In: "path", Required: true, Description: "用户ID", Schema: doc.SchemaOf(uint(0))}
+	doc.Add(openapi.Operation{
+		Method: http.MethodPut, Path: "/api/v1/admin/groups/:id/members/:user_id", Summary: "添加组成员（管理员）", Tags: []string{"admin"}, Security: secured,
+		Description: "只能添加当前组织内的用户，已是直接成员时不做修改。",
+		Parameters:  []openapi.Parameter{groupIDParam, groupMemberParam},
+		Responses:   responses(ok(http.StatusOK, MessageResponse{}), problem(http.StatusBadRequest), problem(http.StatusForbidden), problem(http.StatusNotFound)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodDelete, Path: "/api/v1/admin/groups/:id/members/:user_id", Summary: "移除组成员（管理员）", Tags: []string{"admin"}, Security: secured,
+		Parameters: []openapi.Parameter{groupIDParam, groupMemberParam},
+		Responses:  responses(ok(http.StatusOK, MessageResponse{}), problem(http.StatusBadRequest), problem(http.StatusForbidden), problem(http.StatusNotFound)),
+	})
+	subgroupIDParam := openapi.Parameter{Name: "child_id", In: "path", Required: true, Description: "子组ID", Schema: doc.SchemaOf(uint(0))}
+	doc.Add(openapi.Operation{
+		Method: http.MethodPut, Path: "/api/v1/admin/groups/:id/subgroups/:child_id", Summary: "添加子组（管理员）", Tags: []string{"admin"}, Security: secured,
+		Description: "子组的成员同时属于该组及其所有上级组。不能包含自身或上级组（409），嵌套层数不能超过GROUP_MAX_DEPTH（409）。",
+		Parameters:  []openapi.Parameter{groupIDParam, subgroupIDParam},
+		Responses:   responses(ok(http.StatusOK, MessageResponse{}), problem(http.StatusBadRequest), problem(http.StatusForbidden), problem(http.StatusNotFound), problem(http.StatusConflict)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodDelete, Path: "/api/v1/admin/groups/:id/subgroups/:child_id", Summary: "移除子组（管理员）", Tags: []string{"admin"}, Security: secured,
+		Parameters: []openapi.Parameter{groupIDParam, subgroupIDParam},
+		Responses:  responses(ok(http.StatusOK, MessageResponse{}), problem(http.StatusBadRequest), problem(http.StatusForbidden), problem(http.StatusNotFound)),
+	})
+
+	userInvitationIDParam := openapi.Parameter{Name: "id", In: "path", Required: true, Description: "注册邀请ID", Schema: doc.SchemaOf(uint(0))}
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/admin/invitations", Summary: "列出未接受的注册邀请（管理员）", Tags: []string{"admin"}, Security: secured,
+		Description: "包括已过期的邀请，已过期的邀请可以重新发送。",
+		Responses:   responses(ok(http.StatusOK, UserInvitationListResponse{}), problem(http.StatusForbidden)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/admin/invitations", Summary: "发送注册邀请（管理员）", Tags: []string{"admin"}, Security: secured,
+		Description: "邀请链接在REGISTRATION_INVITATION_TTL内有效，同一邮箱之前未接受的邀请作废；邮箱已注册时返回409。",
+		Request:     &openapi.Body{Value: CreateUserInvitationRequest{}},
+		Responses:   responses(ok(http.StatusCreated, UserInvitationResponse{}), problem(http.StatusForbidden), problem(http.StatusNotFound), problem(http.StatusConflict), problem(http.StatusUnprocessableEntity)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/admin/invitations/:id/resend", Summary: "重新发送注册邀请（管理员）", Tags: []string{"admin"}, Security: secured,
+		Description: "生成新的邀请链接并重新计算有效期，之前发出的链接失效。",
+		Parameters:  []openapi.Parameter{userInvitationIDParam},
+		Responses:   responses(ok(http.StatusOK, UserInvitationResponse{}), problem(http.StatusBadRequest), problem(http.StatusForbidden), problem(http.StatusNotFound)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodDelete, Path: "/api/v1/admin/invitations/:id", Summary: "撤销注册邀请（管理员）", Tags: []string{"admin"}, Security: secured,
+		Parameters: []openapi.Parameter{userInvitationIDParam},
+		Responses:  responses(ok(http.StatusOK, MessageResponse{}), problem(http.StatusBadRequest), problem(http.StatusForbidden), problem(http.StatusNotFound)),
+	})
+
+	attributeIDParam := openapi.Parameter{Name: "id", In: "path", Required: true, Description: "扩展属性ID", Schema: doc.SchemaOf(uint(0))}
+	doc.Add(openapi.Operation{
+		Method: http.MethodPost, Path: "/api/v1/admin/attributes", Summary: "定义扩展属性（管理员）", Tags: []string{"admin"}, Security: secured,
+		Description: "self_editable属性本人可以修改；admin_only属性只有管理员可以修改；private属性只对本人和管理员可见。",
+		Request:     &openapi.Body{Value: CreateAttributeRequest{}},
+		Responses:   responses(ok(http.StatusCreated, AttributeResponse{}), problem(http.StatusForbidden), problem(http.StatusConflict), problem(http.StatusUnprocessableEntity)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodPut, Path: "/api/v1/admin/attributes/:id", Summary: "修改扩展属性定义（管理员）", Tags: []string{"admin"}, Security: secured,
+		Description: "name和type不能修改；已保存的属性值不按新的定义重新校验。",
+		Parameters:  []openapi.Parameter{attributeIDParam},
+		Request:     &openapi.Body{Value: UpdateAttributeRequest{}},
+		Responses:   responses(ok(http.StatusOK, AttributeResponse{}), problem(http.StatusBadRequest), problem(http.StatusForbidden), problem(http.StatusNotFound), problem(http.StatusUnprocessableEntity)),
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodDelete, Path: "/api/v1/admin/attributes/:id", Summary: "删除扩展属性（管理员）", Tags: []string{"admin"}, Security: secured,
+		Description: "同时移除所有用户的该属性值。",
+		Parameters:  []openapi.Parameter{attributeIDParam},
+		Responses:   responses(ok(http.StatusOK, MessageResponse{}), problem(http.StatusBadRequest), problem(http.StatusForbidden), problem(http.StatusNotFound)),
+	})
+
+	var (
+		scimSecured = []string{scimAuth}
+		scimIfMatch = openapi.Parameter{Name: "If-Match", In: "header", Description: "资源的meta.version，不一致时返回412；未携带时不校验", Schema: doc.SchemaOf("")}
+		scimQuery   = []openapi.Parameter{
+			queryParam(doc, "filter", "过滤表达式，支持以and连接的eq、ne、co、sw、ew、gt、ge、lt、le和pr", ""),
+			queryParam(doc, "startIndex", "起始位置，从1开始", 0),
+			queryParam(doc, "count", "每页数量，最大"+strconv.Itoa(service.SCIMMaxResults), 0),
+			queryParam(doc, "excludedAttributes", "不返回的属性，逗号分隔，支持groups和members", ""),
+		}
+	)
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/scim/v2/ServiceProviderConfig", Summary: "SCIM服务提供方配置", Tags: []string{"scim"}, Security: scimSecured,
+		Responses: scimResponses(scimOK(http.StatusOK, SCIMServiceProviderConfig{})),
+	})
+	for _, resource := range []struct {
+		path, name          string
+		list, item, request interface{}
+	}{
+		{path: "/scim/v2/Users", name: "用户", list: SCIMUserListResponse{}, item: SCIMUserResource{}, request: SCIMUserRequest{}},
+		{path: "/scim/v2/Groups", name: "组", list: SCIMGroupListResponse{}, item: SCIMGroupResource{}, request: SCIMGroupRequest{}},
+	} {
+		resourceID := openapi.Parameter{Name: "id", In: "path", Required: true, Description: resource.name + "ID", Schema: doc.SchemaOf("")}
+		doc.Add(openapi.Operation{
+			Method: http.MethodGet, Path: resource.path, Summary: "SCIM查询" + resource.name, Tags: []string{"scim"}, Security: scimSecured,
+			Parameters: scimQuery,
+			Responses:  scimResponses(scimOK(http.StatusOK, resource.list), scimError(http.StatusBadRequest)),
+		})
+		doc.Add(openapi.Operation{
+			Method: http.MethodPost, Path: resource.path, Summary: "SCIM创建" + resource.name, Tags: []string{"scim"}, Security: scimSecured,
+			Request:   &openapi.Body{Content: map[string]interface{}{middleware.SCIMContentType: resource.request}},
+			Responses: scimResponses(etag(scimOK(http.StatusCreated, resource.item)), scimError(http.StatusBadRequest), scimError(http.StatusConflict)),
+		})
+		doc.Add(openapi.Operation{
+			Method: http.MethodGet, Path: resource.path + "/:id", Summary: "SCIM获取" + resource.name, Tags: []string{"scim"}, Security: scimSecured,
+			Parameters: []openapi.Parameter{resourceID, ifNoneMatch},
+			Responses:  scimResponses(etag(scimOK(http.StatusOK, resource.item)), notModified(), scimError(http.StatusNotFound)),
+		})
+		doc.Add(openapi.Operation{
+			Method: http.MethodPut, Path: resource.path + "/:id", Summary: "SCIM替换" + resource.name, Tags: []string{"scim"}, Security: scimSecured,
+			Parameters: []openapi.Parameter{resourceID, scimIfMatch},
+			Request:    &openapi.Body{Content: map[string]interface{}{middleware.SCIMContentType: resource.request}},
+			Responses:  scimResponses(etag(scimOK(http.StatusOK, resource.item)), scimError(http.StatusNotFound), scimError(http.StatusConflict), scimError(http.StatusPreconditionFailed)),
+		})
+		doc.Add(openapi.Operation{
+			Method: http.MethodPatch, Path: resource.path + "/:id", Summary: "SCIM部分更新" + resource.name, Tags: []string{"scim"}, Security: scimSecured,
+			Parameters: []openapi.Parameter{resourceID, scimIfMatch},
+			Request:    &openapi.Body{Content: map[string]interface{}{middleware.SCIMContentType: SCIMPatchRequest{}}},
+			Responses:  scimResponses(etag(scimOK(http.StatusOK, resource.item)), scimError(http.StatusBadRequest), scimError(http.StatusNotFound), scimError(http.StatusPreconditionFailed)),
+		})
+		doc.Add(openapi.Operation{
+			Method: http.MethodDelete, Path: resource.path + "/:id", Summary: "SCIM删除" + resource.name, Tags: []string{"scim"}, Security: scimSecured,
+			Parameters: []openapi.Parameter{resourceID, scimIfMatch},
+			Responses:  scimResponses(openapi.Response{Status: http.StatusNoContent}, scimError(http.StatusNotFound), scimError(http.StatusPreconditionFailed)),
+		})
+	}
+
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/openapi.json", Summary: "OpenAPI文档", Tags: []string{"docs"},
+		Responses: []openapi.Response{{Status: http.StatusOK, Value: map[string]interface{}{}}},
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/docs", Summary: "API文档页面", Tags: []string{"docs"},
+		Responses: []openapi.Response{{Status: http.StatusOK, ContentType: "text/html", Value: ""}},
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/api/v1/docs/swagger-ui/:file", Summary: "API文档页面使用的Swagger UI静态文件", Tags: []string{"docs"},
+		Description: "swagger-ui-dist " + openapi.SwaggerUIVersion + "的swagger-ui.css和swagger-ui-bundle.js，随服务一起发布。",
+		Responses:   []openapi.Response{{Status: http.StatusOK, ContentType: "text/javascript", Value: ""}, problem(http.StatusNotFound)},
+	})
+	doc.Add(openapi.Operation{
+		Method: http.MethodGet, Path: "/health", Summary: "健康检查", Tags: []string{"health"},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Value: healthResponse{}},
+			{Status: http.StatusInternalServerError, Description: "数据库或Redis不可用", Value: healthResponse{}},
+		},
+	})
+
+	return doc
+}
+
+func queryParam(doc *openapi.Document, name, description string, value interface{}) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: doc.SchemaOf(value)}
+}
+
+func ok(status int, value interface{}) openapi.Response {
+	return openapi.Response{Status: status, Value: value}
+}
+
+func etag(r openapi.Response) openapi.Response {
+	r.Headers = append(r.Headers, "ETag")
+	return r
+}
+
+func notModified() openapi.Response {
+	return openapi.Response{Status: http.StatusNotModified, Headers: []string{"ETag"}}
+}
+
+func problem(status int) openapi.Response {
+	return openapi.Response{Status: status, ContentType: "application/problem+json", Value: middleware.Problem{}}
+}
+
+func scimOK(status int, value interface{}) openapi.Response {
+	return openapi.Response{Status: status, ContentType: middleware.SCIMContentType, Value: value}
+}
+
+func scimError(status int) openapi.Response {
+	return openapi.Response{Status: status, ContentType: middleware.SCIMContentType, Value: middleware.SCIMError{}}
+}
+
+// scimResponses 与responses相同，但SCIM接口的错误响应为RFC 7644格式
+func scimResponses(rs ...openapi.Response) []openapi.Response {
+	return append(rs, openapi.Response{
+		Description: "错误响应，scimType为SCIM规定的错误类型",
+		ContentType: middleware.SCIMContentType,
+		Value:       middleware.SCIMError{},
+	})
+}
+
+// responses 为每个接口补充默认的错误响应
+func responses(rs ...openapi.Response) []openapi.Response {
+	return append(rs, openapi.Response{
+		Description: "错误响应，code为稳定的机器可读错误码",
+		ContentType: "application/problem+json",
+		Value:       middleware.Problem{},
+	})
+}
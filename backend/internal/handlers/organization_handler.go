@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/service"
+)
+
+type OrganizationHandler struct {
+	organizationService service.OrganizationService
+	authService         service.AuthService
+	auditService        service.AuditService
+}
+
+func NewOrganizationHandler(organizationService service.OrganizationService, authService service.AuthService, auditService service.AuditService) *OrganizationHandler {
+	return &OrganizationHandler{
+		organizationService: organizationService,
+		authService:         authService,
+		auditService:        auditService,
+	}
+}
+
+type CreateOrganizationRequest struct {
+	Slug string `json:"slug" binding:"required,max=64" doc:"小写字母、数字和连字符，3-64个字符"`
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type UpdateMemberRoleRequest struct {
+	Role string `json:"role" binding:"required" doc:"可选值：owner、admin、member"`
+}
+
+type CreateInvitationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required" doc:"可选值：owner、admin、member"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// OrganizationResponse 的Role是当前用户在组织中的角色
+type OrganizationResponse struct {
+	ID        uint      `json:"id"`
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type OrganizationListResponse struct {
+	Organizations         []OrganizationResponse `json:"organizations"`
+	CurrentOrganizationID uint                   `json:"current_organization_id" doc:"当前访问令牌所在的组织，0表示不属于任何组织"`
+}
+
+// OrganizationSessionResponse 是切换到组织后签发的令牌，之前的访问令牌已失效
+type OrganizationSessionResponse struct {
+	TokenResponse
+	Organization OrganizationResponse `json:"organization"`
+}
+
+type MemberResponse struct {
+	UserID   uint      `json:"user_id"`
+	Username string    `json:"username"`
+	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type MemberListResponse struct {
+	Members []MemberResponse `json:"members"`
+}
+
+type InvitationResponse struct {
+	ID        uint      `json:"id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	InvitedBy uint      `json:"invited_by"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type InvitationListResponse struct {
+	Invitations []InvitationResponse `json:"invitations"`
+}
+
+func (h *OrganizationHandler) ListOrganizations(c *gin.Context) {
+	memberships, err := h.organizationService.ListByUser(c.GetUint("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := OrganizationListResponse{
+		Organizations:         make([]OrganizationResponse, 0, len(memberships)),
+		CurrentOrganizationID: c.GetUint("organizationID"),
+	}
+	for i := range memberships {
+		response.Organizations = append(response.Organizations, newOrganizationResponse(&memberships[i]))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateOrganization 创建组织后当前会话切换到新组织
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	var req CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	org, err := h.organizationService.Create(req.Slug, req.Name, c.GetUint("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionOrganizationCreate, 0)
+	event.Metadata = map[string]interface{}{"organization_id": org.ID, "slug": org.Slug}
+	recordAudit(h.auditService, event)
+
+	h.switchOrganization(c, http.StatusCreated, &models.OrganizationMember{
+		OrganizationID: org.ID,
+		UserID:         c.GetUint("userID"),
+		Role:           models.OrgRoleOwner,
+		CreatedAt:      org.CreatedAt,
+		Organization:   *org,
+	})
+}
+
+func (h *OrganizationHandler) SwitchOrganization(c *gin.Context) {
+	member := c.MustGet("membership").(*models.OrganizationMember)
+
+	event := newAuditEvent(c, service.AuditActionOrganizationSwitch, 0)
+	event.Metadata = map[string]interface{}{"organization_id": member.OrganizationID, "from_organization_id": c.GetUint("organizationID")}
+	recordAudit(h.auditService, event)
+
+	h.switchOrganization(c, http.StatusOK, member)
+}
+
+// switchOrganization 为当前会话签发member所在组织的令牌
+func (h *OrganizationHandler) switchOrganization(c *gin.Context, status int, member *models.OrganizationMember) {
+	accessToken, refreshToken, err := h.authService.SwitchOrganization(
+		c.GetString("token"), c.GetUint("userID"), c.GetString("sessionID"), c.GetTime("authTime"), member.OrganizationID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(status, OrganizationSessionResponse{
+		TokenResponse: TokenResponse{Token: accessToken, RefreshToken: refreshToken},
+		Organization:  newOrganizationResponse(member),
+	})
+}
+
+func (h *OrganizationHandler) DeleteOrganization(c *gin.Context) {
+	member := c.MustGet("membership").(*models.OrganizationMember)
+
+	org, err := h.organizationService.Delete(member.OrganizationID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionOrganizationDelete, 0)
+	event.Metadata = map[string]interface{}{"organization_id": org.ID, "slug": org.Slug}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "Organization deleted successfully"})
+}
+
+func (h *OrganizationHandler) ListMembers(c *gin.Context) {
+	member := c.MustGet("membership").(*models.OrganizationMember)
+
+	members, err := h.organizationService.ListMembers(member.OrganizationID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := MemberListResponse{Members: make([]MemberResponse, 0, len(members))}
+	for i := range members {
+		response.Members = append(response.Members, MemberResponse{
+			UserID:   members[i].UserID,
+			Username: members[i].User.Username,
+			Email:    members[i].User.Email,
+			Role:     members[i].Role,
+			JoinedAt: members[i].CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *OrganizationHandler) UpdateMemberRole(c *gin.Context) {
+	actor := c.MustGet("membership").(*models.OrganizationMember)
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		c.Error(errInvalidUserID)
+		return
+	}
+
+	var req UpdateMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	member, err := h.organizationService.UpdateMemberRole(actor.OrganizationID, actor.Role, uint(userID), req.Role)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionMemberRoleChange, member.UserID)
+	event.Metadata = map[string]interface{}{"organization_id": member.OrganizationID, "role": member.Role}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "Member role updated successfully"})
+}
+
+// RemoveMember 也用于成员自己退出组织
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	actor := c.MustGet("membership").(*models.OrganizationMember)
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		c.Error(errInvalidUserID)
+		return
+	}
+
+	if err := h.organizationService.RemoveMember(actor.OrganizationID, actor.UserID, actor.Role, uint(userID)); err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionMemberRemove, uint(userID))
+	event.Metadata = map[string]interface{}{"organization_id": actor.OrganizationID}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "Member removed successfully"})
+}
+
+func (h *OrganizationHandler) ListInvitations(c *gin.Context) {
+	member := c.MustGet("membership").(*models.OrganizationMember)
+
+	invitations, err := h.organizationService.ListInvitations(member.OrganizationID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := InvitationListResponse{Invitations: make([]InvitationResponse, 0, len(invitations))}
+	for i := range invitations {
+		response.Invitations = append(response.Invitations, newInvitationResponse(&invitations[i]))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *OrganizationHandler) CreateInvitation(c *gin.Context) {
+	actor := c.MustGet("membership").(*models.OrganizationMember)
+
+	var req CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	invitation, err := h.organizationService.Invite(actor.OrganizationID, actor.Role, req.Email, req.Role, actor.UserID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionInvitationCreate, 0)
+	event.Metadata = map[string]interface{}{"organization_id": invitation.OrganizationID, "invitation_id": invitation.ID, "email": invitation.Email, "role": invitation.Role}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusCreated, newInvitationResponse(invitation))
+}
+
+func (h *OrganizationHandler) RevokeInvitation(c *gin.Context) {
+	actor := c.MustGet("membership").(*models.OrganizationMember)
+	id, err := strconv.ParseUint(c.Param("invitation_id"), 10, 32)
+	if err != nil {
+		c.Error(errInvalidInvitationID)
+		return
+	}
+
+	invitation, err := h.organizationService.RevokeInvitation(actor.OrganizationID, uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionInvitationRevoke, 0)
+	event.Metadata = map[string]interface{}{"organization_id": invitation.OrganizationID, "invitation_id": invitation.ID, "email": invitation.Email}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "Invitation revoked successfully"})
+}
+
+// AcceptInvitation 加入组织后当前会话切换到该组织
+func (h *OrganizationHandler) AcceptInvitation(c *gin.Context) {
+	var req AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	member, err := h.organizationService.AcceptInvitation(req.Token, c.GetUint("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionInvitationAccept, 0)
+	event.Metadata = map[string]interface{}{"organization_id": member.OrganizationID, "role": member.Role}
+	recordAudit(h.auditService, event)
+
+	h.switchOrganization(c, http.StatusOK, member)
+}
+
+func newOrganizationResponse(member *models.OrganizationMember) OrganizationResponse {
+	return OrganizationResponse{
+		ID:        member.Organization.ID,
+		Slug:      member.Organization.Slug,
+		Name:      member.Organization.Name,
+		Role:      member.Role,
+		CreatedAt: member.Organization.CreatedAt,
+	}
+}
+
+func newInvitationResponse(invitation *models.OrganizationInvitation) InvitationResponse {
+	return InvitationResponse{
+		ID:        invitation.ID,
+		Email:     invitation.Email,
+		Role:      invitation.Role,
+		InvitedBy: invitation.InvitedBy,
+		ExpiresAt: invitation.ExpiresAt,
+		CreatedAt: invitation.CreatedAt,
+	}
+}
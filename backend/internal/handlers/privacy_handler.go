@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/service"
+)
+
+type PrivacyHandler struct {
+	privacyService service.PrivacyService
+	auditService   service.AuditService
+}
+
+func NewPrivacyHandler(privacyService service.PrivacyService, auditService service.AuditService) *PrivacyHandler {
+	return &PrivacyHandler{
+		privacyService: privacyService,
+		auditService:   auditService,
+	}
+}
+
+type EraseAccountRequest struct {
+	Password string `json:"password" doc:"当前密码；只能通过外部身份登录的用户不需要提供，但须在STEP_UP_MAX_AGE内登录"`
+}
+
+func (h *PrivacyHandler) ExportProfile(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	export, err := h.privacyService.ExportUserData(userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	recordAudit(h.auditService, newAuditEvent(c, service.AuditActionDataExport, userID))
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%d-export.json"`, userID))
+	c.JSON(http.StatusOK, export)
+}
+
+func (h *PrivacyHandler) EraseProfile(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req EraseAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	reauth := service.Reauthentication{
+		CurrentPassword: req.Password,
+		AuthTime:        c.GetTime("authTime"),
+		SessionID:       c.GetString("sessionID"),
+		ActorID:         c.GetUint("actorID"),
+	}
+	if err := h.privacyService.EraseUser(userID, reauth); err != nil {
+		c.Error(err)
+		return
+	}
+
+	recordAudit(h.auditService, newAuditEvent(c, service.AuditActionDataErasure, userID))
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "Account data erased successfully"})
+}
@@ -0,0 +1,6 @@
+package handlers
+
+// MessageResponse 是只返回提示信息的操作结果
+type MessageResponse struct {
+	Message string `json:"message"`
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/service"
+)
+
+// SAMLHandler 处理组织的SAML单点登录和SAML连接管理
+type SAMLHandler struct {
+	samlService       service.SAMLService
+	federationService service.FederationService
+	authService       service.AuthService
+	auditService      service.AuditService
+}
+
+func NewSAMLHandler(samlService service.SAMLService, federationService service.FederationService, authService service.AuthService, auditService service.AuditService) *SAMLHandler {
+	return &SAMLHandler{
+		samlService:       samlService,
+		federationService: federationService,
+		authService:       authService,
+		auditService:      auditService,
+	}
+}
+
+type CreateSAMLConnectionRequest struct {
+	Slug              string   `json:"slug" binding:"required" doc:"组织标识，出现在SP元数据和登录地址中，只能包含小写字母、数字和连字符"`
+	Name              string   `json:"name" binding:"required,max=100"`
+	IdPMetadata       string   `json:"idp_metadata" binding:"required" doc:"身份提供方导出的元数据XML（EntityDescriptor）"`
+	EmailDomains      []string `json:"email_domains" binding:"required,min=1" doc:"只接受这些域名下的邮箱登录"`
+	EmailAttribute    string   `json:"email_attribute,omitempty" doc:"邮箱属性名，不填则尝试email、mail等常见属性，最后使用邮箱格式的NameID"`
+	UsernameAttribute string   `json:"username_attribute,omitempty" doc:"用户名属性名，仅在首次登录创建用户时使用"`
+}
+
+// SAMLResponseForm 是身份提供方以HTTP-POST绑定提交到ACS的表单
+type SAMLResponseForm struct {
+	SAMLResponse string `form:"SAMLResponse" json:"SAMLResponse" binding:"required" doc:"Base64编码的签名Response"`
+	RelayState   string `form:"RelayState" json:"RelayState" binding:"required"`
+}
+
+type SAMLConnectionResponse struct {
+	ID                uint      `json:"id"`
+	Slug              string    `json:"slug"`
+	Name              string    `json:"name"`
+	IdPEntityID       string    `json:"idp_entity_id"`
+	EmailDomains      []string  `json:"email_domains"`
+	EmailAttribute    string    `json:"email_attribute"`
+	UsernameAttribute string    `json:"username_attribute"`
+	MetadataURL       string    `json:"metadata_url" doc:"SP元数据地址，导入身份提供方"`
+	CreatedBy         uint      `json:"created_by"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+type SAMLConnectionListResponse struct {
+	Connections []SAMLConnectionResponse `json:"connections"`
+}
+
+// Metadata 返回该组织连接的SP元数据XML
+func (h *SAMLHandler) Metadata(c *gin.Context) {
+	metadata, err := h.samlService.Metadata(c.Param("slug"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.Data(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+// Login 以HTTP-Redirect绑定把AuthnRequest发给组织的身份提供方
+func (h *SAMLHandler) Login(c *gin.Context) {
+	location, err := h.samlService.Begin(c.Request.Context(), c.Param("slug"), safeRedirect(c.Query("redirect")))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.Redirect(http.StatusFound, location)
+}
+
+// ACS 接收身份提供方以HTTP-POST绑定返回的SAMLResponse
+func (h *SAMLHandler) ACS(c *gin.Context) {
+	slug := c.Param("slug")
+	var form SAMLResponseForm
+	if err := c.ShouldBind(&form); err != nil {
+		h.fail(c, service.ErrSAMLAssertionInvalid, map[string]interface{}{"connection": slug})
+		return
+	}
+
+	login, err := h.samlService.Complete(c.Request.Context(), slug, form.SAMLResponse, form.RelayState)
+	if err != nil {
+		h.fail(c, err, map[string]interface{}{"connection": slug})
+		return
+	}
+
+	if err := completeFederatedLogin(c, h.authService, h.federationService, h.auditService, login, "saml"); err != nil {
+		h.fail(c, err, map[string]interface{}{"connection": slug})
+	}
+}
+
+func (h *SAMLHandler) ListConnections(c *gin.Context) {
+	connections, err := h.samlService.ListConnections()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := SAMLConnectionListResponse{Connections: make([]SAMLConnectionResponse, 0, len(connections))}
+	for i := range connections {
+		response.Connections = append(response.Connections, newSAMLConnectionResponse(&connections[i]))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *SAMLHandler) CreateConnection(c *gin.Context) {
+	var req CreateSAMLConnectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	connection, err := h.samlService.CreateConnection(&service.SAMLConnectionInput{
+		Slug:              req.Slug,
+		Name:              req.Name,
+		IdPMetadata:       req.IdPMetadata,
+		EmailDomains:      req.EmailDomains,
+		EmailAttribute:    req.EmailAttribute,
+		UsernameAttribute: req.UsernameAttribute,
+	}, c.GetUint("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionSAMLConnectionCreate, 0)
+	event.Metadata = map[string]interface{}{"slug": connection.Slug, "idp_entity_id": connection.IdPEntityID, "email_domains": req.EmailDomains}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusCreated, newSAMLConnectionResponse(connection))
+}
+
+func (h *SAMLHandler) DeleteConnection(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(errInvalidSAMLConnectionID)
+		return
+	}
+
+	connection, err := h.samlService.DeleteConnection(uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionSAMLConnectionDelete, 0)
+	event.Metadata = map[string]interface{}{"slug": connection.Slug, "idp_entity_id": connection.IdPEntityID}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "SAML connection deleted successfully"})
+}
+
+func (h *SAMLHandler) fail(c *gin.Context, err error, metadata map[string]interface{}) {
+	failFederatedLogin(c, h.auditService, err, "saml", metadata)
+}
+
+func newSAMLConnectionResponse(connection *models.SAMLConnection) SAMLConnectionResponse {
+	return SAMLConnectionResponse{
+		ID:                connection.ID,
+		Slug:              connection.Slug,
+		Name:              connection.Name,
+		IdPEntityID:       connection.IdPEntityID,
+		EmailDomains:      strings.Fields(connection.EmailDomains),
+		EmailAttribute:    connection.EmailAttribute,
+		UsernameAttribute: connection.UsernameAttribute,
+		MetadataURL:       "/api/v1/auth/saml/" + connection.Slug + "/metadata",
+		CreatedBy:         connection.CreatedBy,
+		CreatedAt:         connection.CreatedAt,
+	}
+}
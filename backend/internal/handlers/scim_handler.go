@@ -0,0 +1,652 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/middleware"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/service"
+)
+
+// SCIM协议中的schema URN
+const (
+	scimSchemaUser         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimSchemaGroup        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	scimSchemaListResponse = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimSchemaPatchOp      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	scimSchemaProvider     = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	scimDefaultCount       = 100
+)
+
+// SCIMHandler 实现SCIM 2.0的/Users和/Groups，供身份提供方自动创建、更新和停用账号
+type SCIMHandler struct {
+	scimService  service.SCIMService
+	auditService service.AuditService
+	// baseURL 是/scim/v2的对外地址，用于meta.location
+	baseURL string
+}
+
+func NewSCIMHandler(scimService service.SCIMService, auditService service.AuditService, baseURL string) *SCIMHandler {
+	return &SCIMHandler{
+		scimService:  scimService,
+		auditService: auditService,
+		baseURL:      strings.TrimSuffix(baseURL, "/") + "/scim/v2",
+	}
+}
+
+type SCIMMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Version      string    `json:"version" doc:"与ETag响应头相同的弱校验值"`
+	Location     string    `json:"location"`
+}
+
+type SCIMReference struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+type SCIMUserResource struct {
+	Schemas  []string                 `json:"schemas"`
+	ID       string                   `json:"id"`
+	UserName string                   `json:"userName"`
+	Emails   []service.SCIMMultiValue `json:"emails"`
+	Active   bool                     `json:"active"`
+	Groups   []SCIMReference          `json:"groups,omitempty" doc:"只读，通过/Groups维护"`
+	Meta     SCIMMeta                 `json:"meta"`
+}
+
+type SCIMUserRequest struct {
+	Schemas  []string                 `json:"schemas"`
+	UserName string                   `json:"userName" binding:"required,max=50"`
+	Emails   []service.SCIMMultiValue `json:"emails" binding:"required,min=1" doc:"只保存primary为true的一项，没有时保存第一项"`
+	Active   *bool                    `json:"active" doc:"默认为true；设为false时立即撤销该用户的会话和刷新令牌"`
+	Password string                   `json:"password,omitempty" doc:"只写，须满足密码策略；创建时不填则不能用密码登录，只能通过单点登录"`
+}
+
+type SCIMGroupResource struct {
+	Schemas     []string        `json:"schemas"`
+	ID          string          `json:"id"`
+	DisplayName string          `json:"displayName"`
+	Members     []SCIMReference `json:"members,omitempty"`
+	Meta        SCIMMeta        `json:"meta"`
+}
+
+type SCIMGroupRequest struct {
+	Schemas     []string                 `json:"schemas"`
+	DisplayName string                   `json:"displayName" binding:"required,max=100"`
+	Members     []service.SCIMMultiValue `json:"members" doc:"value为用户id"`
+}
+
+type SCIMPatchRequest struct {
+	Schemas    []string             `json:"schemas" binding:"required"`
+	Operations []SCIMPatchOperation `json:"Operations" binding:"required,min=1,dive"`
+}
+
+type SCIMPatchOperation struct {
+	Op    string      `json:"op" binding:"required" doc:"add、replace或remove，不区分大小写"`
+	Path  string      `json:"path,omitempty" doc:"如active、emails[type eq \"work\"].value、members[value eq \"12\"]；为空时value为属性对象"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+type SCIMListResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int64    `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+}
+
+type SCIMUserListResponse struct {
+	SCIMListResponse
+	Resources []SCIMUserResource `json:"Resources"`
+}
+
+type SCIMGroupListResponse struct {
+	SCIMListResponse
+	Resources []SCIMGroupResource `json:"Resources"`
+}
+
+type scimSupported struct {
+	Supported bool `json:"supported"`
+}
+
+type scimFilterSupported struct {
+	Supported  bool `json:"supported"`
+	MaxResults int  `json:"maxResults"`
+}
+
+type SCIMServiceProviderConfig struct {
+	Schemas               []string            `json:"schemas"`
+	Patch                 scimSupported       `json:"patch"`
+	Bulk                  scimSupported       `json:"bulk"`
+	Filter                scimFilterSupported `json:"filter"`
+	ChangePassword        scimSupported       `json:"changePassword"`
+	Sort                  scimSupported       `json:"sort"`
+	ETag                  scimSupported       `json:"etag"`
+	AuthenticationSchemes []scimAuthScheme    `json:"authenticationSchemes"`
+}
+
+type scimAuthScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// ServiceProviderConfig 声明支持的SCIM功能
+func (h *SCIMHandler) ServiceProviderConfig(c *gin.Context) {
+	writeSCIM(c, http.StatusOK, SCIMServiceProviderConfig{
+		Schemas:        []string{scimSchemaProvider},
+		Patch:          scimSupported{Supported: true},
+		Filter:         scimFilterSupported{Supported: true, MaxResults: service.SCIMMaxResults},
+		ChangePassword: scimSupported{Supported: true},
+		ETag:           scimSupported{Supported: true},
+		AuthenticationSchemes: []scimAuthScheme{{
+			Type:        "oauthbearertoken",
+			Name:        "Bearer Token",
+			Description: "SCIM_TOKEN配置的令牌",
+		}},
+	})
+}
+
+func (h *SCIMHandler) ListUsers(c *gin.Context) {
+	startIndex, count := scimPagination(c)
+	users, total, err := h.scimService.ListUsers(c.Query("filter"), startIndex, count)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	withGroups := !scimExcluded(c, "groups")
+	resources := make([]SCIMUserResource, 0, len(users))
+	for i := range users {
+		resource, err := h.userResource(&users[i], withGroups)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		resources = append(resources, resource)
+	}
+
+	writeSCIM(c, http.StatusOK, SCIMUserListResponse{
+		SCIMListResponse: scimList(total, startIndex, len(resources)),
+		Resources:        resources,
+	})
+}
+
+func (h *SCIMHandler) GetUser(c *gin.Context) {
+	id, ok := scimID(c, service.ErrUserNotFound)
+	if !ok {
+		return
+	}
+	user, err := h.scimService.GetUser(id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.writeUser(c, http.StatusOK, user)
+}
+
+func (h *SCIMHandler) CreateUser(c *gin.Context) {
+	var req SCIMUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	user, err := h.scimService.CreateUser(scimUserInput(&req))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := h.auditEvent(c, service.AuditActionUserCreate, user.ID)
+	event.Metadata["username"] = user.Username
+	recordAudit(h.auditService, event)
+
+	c.Header("Location", h.location("Users", user.ID))
+	h.writeUser(c, http.StatusCreated, user)
+}
+
+func (h *SCIMHandler) ReplaceUser(c *gin.Context) {
+	var req SCIMUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	h.updateUser(c, func(id, version uint) (*models.User, error) {
+		return h.scimService.ReplaceUser(id, scimUserInput(&req), version)
+	})
+}
+
+func (h *SCIMHandler) PatchUser(c *gin.Context) {
+	operations, ok := bindSCIMPatch(c)
+	if !ok {
+		return
+	}
+	h.updateUser(c, func(id, version uint) (*models.User, error) {
+		return h.scimService.PatchUser(id, operations, version)
+	})
+}
+
+// updateUser 校验If-Match后执行修改，并把字段变更记入审计日志
+func (h *SCIMHandler) updateUser(c *gin.Context, update func(id, version uint) (*models.User, error)) {
+	id, ok := scimID(c, service.ErrUserNotFound)
+	if !ok {
+		return
+	}
+	current, err := h.scimService.GetUser(id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !checkSCIMIfMatch(c, current.Version) {
+		return
+	}
+	before := *current
+
+	user, err := update(id, current.Version)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if changes := service.DiffUsers(&before, user); len(changes) > 0 {
+		event := h.auditEvent(c, service.AuditActionUserUpdate, user.ID)
+		event.Changes = changes
+		recordAudit(h.auditService, event)
+	}
+
+	h.writeUser(c, http.StatusOK, user)
+}
+
+func (h *SCIMHandler) DeleteUser(c *gin.Context) {
+	id, ok := scimID(c, service.ErrUserNotFound)
+	if !ok {
+		return
+	}
+	current, err := h.scimService.GetUser(id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !checkSCIMIfMatch(c, current.Version) {
+		return
+	}
+
+	user, err := h.scimService.DeleteUser(id, current.Version)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := h.auditEvent(c, service.AuditActionUserDelete, user.ID)
+	event.Metadata["username"] = user.Username
+	recordAudit(h.auditService, event)
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *SCIMHandler) ListGroups(c *gin.Context) {
+	startIndex, count := scimPagination(c)
+	groups, total, err := h.scimService.ListGroups(c.Query("filter"), startIndex, count, !scimExcluded(c, "members"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	resources := make([]SCIMGroupResource, 0, len(groups))
+	for i := range groups {
+		resources = append(resources, h.groupResource(&groups[i]))
+	}
+
+	writeSCIM(c, http.StatusOK, SCIMGroupListResponse{
+		SCIMListResponse: scimList(total, startIndex, len(resources)),
+		Resources:        resources,
+	})
+}
+
+func (h *SCIMHandler) GetGroup(c *gin.Context) {
+	id, ok := scimID(c, service.ErrGroupNotFound)
+	if !ok {
+		return
+	}
+	group, err := h.scimService.GetGroup(id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.writeGroup(c, http.StatusOK, group)
+}
+
+func (h *SCIMHandler) CreateGroup(c *gin.Context) {
+	var req SCIMGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	input, err := scimGroupInput(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	group, err := h.scimService.CreateGroup(input)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := h.auditEvent(c, service.AuditActionGroupCreate, 0)
+	event.Metadata["group_id"] = group.Group.ID
+	event.Metadata["name"] = group.Group.Name
+	event.Metadata["members"] = memberIDs(group.Members)
+	recordAudit(h.auditService, event)
+
+	c.Header("Location", h.location("Groups", group.Group.ID))
+	h.writeGroup(c, http.StatusCreated, group)
+}
+
+func (h *SCIMHandler) ReplaceGroup(c *gin.Context) {
+	var req SCIMGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	input, err := scimGroupInput(&req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.updateGroup(c, func(id, version uint) (*service.SCIMGroup, error) {
+		return h.scimService.ReplaceGroup(id, input, version)
+	})
+}
+
+func (h *SCIMHandler) PatchGroup(c *gin.Context) {
+	operations, ok := bindSCIMPatch(c)
+	if !ok {
+		return
+	}
+	h.updateGroup(c, func(id, version uint) (*service.SCIMGroup, error) {
+		return h.scimService.PatchGroup(id, operations, version)
+	})
+}
+
+func (h *SCIMHandler) updateGroup(c *gin.Context, update func(id, version uint) (*service.SCIMGroup, error)) {
+	id, ok := scimID(c, service.ErrGroupNotFound)
+	if !ok {
+		return
+	}
+	current, err := h.scimService.GetGroup(id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !checkSCIMIfMatch(c, current.Group.Version) {
+		return
+	}
+	beforeName, beforeMembers := current.Group.Name, memberIDs(current.Members)
+
+	group, err := update(id, current.Group.Version)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	changes := make(map[string]service.FieldChange)
+	if group.Group.Name != beforeName {
+		changes["name"] = service.FieldChange{Before: beforeName, After: group.Group.Name}
+	}
+	if afterMembers := memberIDs(group.Members); fmt.Sprint(afterMembers) != fmt.Sprint(beforeMembers) {
+		changes["members"] = service.FieldChange{Before: beforeMembers, After: afterMembers}
+	}
+	if len(changes) > 0 {
+		event := h.auditEvent(c, service.AuditActionGroupUpdate, 0)
+		event.Metadata["group_id"] = group.Group.ID
+		event.Changes = changes
+		recordAudit(h.auditService, event)
+	}
+
+	h.writeGroup(c, http.StatusOK, group)
+}
+
+func (h *SCIMHandler) DeleteGroup(c *gin.Context) {
+	id, ok := scimID(c, service.ErrGroupNotFound)
+	if !ok {
+		return
+	}
+	current, err := h.scimService.GetGroup(id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !checkSCIMIfMatch(c, current.Group.Version) {
+		return
+	}
+
+	group, err := h.scimService.DeleteGroup(id, current.Group.Version)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := h.auditEvent(c, service.AuditActionGroupDelete, 0)
+	event.Metadata["group_id"] = group.ID
+	event.Metadata["name"] = group.Name
+	recordAudit(h.auditService, event)
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *SCIMHandler) writeUser(c *gin.Context, status int, user *models.User) {
+	resource, err := h.userResource(user, true)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	writeSCIMResource(c, status, resource.Meta.Version, resource)
+}
+
+func (h *SCIMHandler) writeGroup(c *gin.Context, status int, group *service.SCIMGroup) {
+	resource := h.groupResource(group)
+	writeSCIMResource(c, status, resource.Meta.Version, resource)
+}
+
+func (h *SCIMHandler) userResource(user *models.User, withGroups bool) (SCIMUserResource, error) {
+	resource := SCIMUserResource{
+		Schemas:  []string{scimSchemaUser},
+		ID:       strconv.FormatUint(uint64(user.ID), 10),
+		UserName: user.Username,
+		Emails:   []service.SCIMMultiValue{{Value: user.Email, Type: "work", Primary: true}},
+		Active:   user.IsActive,
+		Meta:     h.meta("User", "Users", user.ID, user.Version, user.CreatedAt, user.UpdatedAt),
+	}
+	if withGroups {
+		groups, err := h.scimService.UserGroups(user.ID)
+		if err != nil {
+			return resource, err
+		}
+		for _, group := range groups {
+			resource.Groups = append(resource.Groups, SCIMReference{
+				Value:   strconv.FormatUint(uint64(group.ID), 10),
+				Display: group.Name,
+				Ref:     h.location("Groups", group.ID),
+			})
+		}
+	}
+	return resource, nil
+}
+
+func (h *SCIMHandler) groupResource(group *service.SCIMGroup) SCIMGroupResource {
+	resource := SCIMGroupResource{
+		Schemas:     []string{scimSchemaGroup},
+		ID:          strconv.FormatUint(uint64(group.Group.ID), 10),
+		DisplayName: group.Group.Name,
+		Meta:        h.meta("Group", "Groups", group.Group.ID, group.Group.Version, group.Group.CreatedAt, group.Group.UpdatedAt),
+	}
+	for _, member := range group.Members {
+		resource.Members = append(resource.Members, SCIMReference{
+			Value:   strconv.FormatUint(uint64(member.ID), 10),
+			Display: member.Username,
+			Ref:     h.location("Users", member.ID),
+		})
+	}
+	return resource
+}
+
+func (h *SCIMHandler) meta(resourceType, endpoint string, id, version uint, created, lastModified time.Time) SCIMMeta {
+	return SCIMMeta{
+		ResourceType: resourceType,
+		Created:      created,
+		LastModified: lastModified,
+		Version:      scimETag(version),
+		Location:     h.location(endpoint, id),
+	}
+}
+
+func (h *SCIMHandler) location(endpoint string, id uint) string {
+	return fmt.Sprintf("%s/%s/%d", h.baseURL, endpoint, id)
+}
+
+// auditEvent 的操作者为身份提供方，记录在元数据中
+func (h *SCIMHandler) auditEvent(c *gin.Context, action string, targetID uint) service.AuditEvent {
+	event := newAuditEvent(c, action, targetID)
+	event.Metadata = map[string]interface{}{"source": "scim"}
+	return event
+}
+
+func scimUserInput(req *SCIMUserRequest) *service.SCIMUserInput {
+	input := &service.SCIMUserInput{
+		UserName: req.UserName,
+		Email:    service.SCIMPrimaryValue(req.Emails),
+		Active:   true,
+		Password: req.Password,
+	}
+	if req.Active != nil {
+		input.Active = *req.Active
+	}
+	return input
+}
+
+func scimGroupInput(req *SCIMGroupRequest) (*service.SCIMGroupInput, error) {
+	members, err := service.ParseSCIMMembers(req.Members)
+	if err != nil {
+		return nil, err
+	}
+	return &service.SCIMGroupInput{DisplayName: req.DisplayName, MemberIDs: members}, nil
+}
+
+func bindSCIMPatch(c *gin.Context) ([]service.SCIMPatchOperation, bool) {
+	var req SCIMPatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return nil, false
+	}
+	if len(req.Schemas) != 1 || req.Schemas[0] != scimSchemaPatchOp {
+		c.Error(service.ErrInvalidRequest)
+		return nil, false
+	}
+
+	operations := make([]service.SCIMPatchOperation, 0, len(req.Operations))
+	for _, op := range req.Operations {
+		operation := service.SCIMPatchOperation{Op: op.Op, Path: op.Path}
+		if op.Value != nil {
+			value, err := json.Marshal(op.Value)
+			if err != nil {
+				c.Error(err)
+				return nil, false
+			}
+			operation.Value = value
+		}
+		operations = append(operations, operation)
+	}
+	return operations, true
+}
+
+// scimID 解析路径中的资源ID，非数字ID按资源不存在处理
+func scimID(c *gin.Context, notFound error) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(notFound)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// scimPagination 读取从1开始的startIndex和count，非法值按默认值处理，count的上限由服务层截断
+func scimPagination(c *gin.Context) (int, int) {
+	startIndex, err := strconv.Atoi(c.Query("startIndex"))
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(c.Query("count"))
+	if err != nil {
+		count = scimDefaultCount
+	}
+	return startIndex, count
+}
+
+func scimExcluded(c *gin.Context, attribute string) bool {
+	for _, excluded := range strings.Split(c.Query("excludedAttributes"), ",") {
+		if strings.EqualFold(strings.TrimSpace(excluded), attribute) {
+			return true
+		}
+	}
+	return false
+}
+
+func scimList(total int64, startIndex, itemsPerPage int) SCIMListResponse {
+	return SCIMListResponse{
+		Schemas:      []string{scimSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: itemsPerPage,
+	}
+}
+
+// scimETag SCIM的meta.version是弱校验值，与REST接口一样基于版本号
+func scimETag(version uint) string {
+	return fmt.Sprintf(`W/"%d"`, version)
+}
+
+// checkSCIMIfMatch 使用弱比较校验If-Match，未携带时不校验
+func checkSCIMIfMatch(c *gin.Context, version uint) bool {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" || etagListMatches(ifMatch, fmt.Sprintf(`"%d"`, version), true) {
+		return true
+	}
+	c.Header("ETag", scimETag(version))
+	c.Error(service.ErrPreconditionFailed)
+	return false
+}
+
+// writeSCIMResource 返回单个资源并附带ETag；If-None-Match匹配时返回304
+func writeSCIMResource(c *gin.Context, status int, etag string, resource interface{}) {
+	c.Header("ETag", etag)
+	if status == http.StatusOK && c.Request.Method == http.MethodGet && etagListMatches(c.GetHeader("If-None-Match"), strings.TrimPrefix(etag, "W/"), true) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	writeSCIM(c, status, resource)
+}
+
+func writeSCIM(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", middleware.SCIMContentType)
+	c.JSON(status, body)
+}
+
+func memberIDs(members []models.User) []uint {
+	ids := make([]uint, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.ID)
+	}
+	return ids
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/service"
+)
+
+type ServiceAccountHandler struct {
+	serviceAccountService service.ServiceAccountService
+	auditService          service.AuditService
+}
+
+func NewServiceAccountHandler(serviceAccountService service.ServiceAccountService, auditService service.AuditService) *ServiceAccountHandler {
+	return &ServiceAccountHandler{
+		serviceAccountService: serviceAccountService,
+		auditService:          auditService,
+	}
+}
+
+type CreateServiceAccountRequest struct {
+	Name        string   `json:"name" binding:"required,max=100"`
+	Description string   `json:"description" binding:"max=255"`
+	Scopes      []string `json:"scopes" binding:"required,min=1" doc:"可选值：users:read、users:write"`
+}
+
+type ServiceAccountResponse struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	ClientID    string    `json:"client_id"`
+	Scopes      []string  `json:"scopes"`
+	IsActive    bool      `json:"is_active"`
+	CreatedBy   uint      `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type CreatedServiceAccountResponse struct {
+	ServiceAccountResponse
+	ClientSecret string `json:"client_secret" doc:"客户端密钥，只在创建时返回一次"`
+}
+
+type ServiceAccountListResponse struct {
+	ServiceAccounts []ServiceAccountResponse `json:"service_accounts"`
+}
+
+func (h *ServiceAccountHandler) ListServiceAccounts(c *gin.Context) {
+	accounts, err := h.serviceAccountService.List()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := ServiceAccountListResponse{ServiceAccounts: make([]ServiceAccountResponse, 0, len(accounts))}
+	for i := range accounts {
+		response.ServiceAccounts = append(response.ServiceAccounts, newServiceAccountResponse(&accounts[i]))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *ServiceAccountHandler) CreateServiceAccount(c *gin.Context) {
+	var req CreateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	account, secret, err := h.serviceAccountService.Create(req.Name, req.Description, req.Scopes, c.GetUint("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionServiceAccountCreate, 0)
+	event.Metadata = map[string]interface{}{"service_account_id": account.ID, "name": account.Name, "scopes": req.Scopes}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusCreated, CreatedServiceAccountResponse{
+		ServiceAccountResponse: newServiceAccountResponse(account),
+		ClientSecret:           secret,
+	})
+}
+
+func (h *ServiceAccountHandler) DeleteServiceAccount(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(errInvalidServiceAccountID)
+		return
+	}
+
+	account, err := h.serviceAccountService.Delete(uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionServiceAccountDelete, 0)
+	event.Metadata = map[string]interface{}{"service_account_id": account.ID, "name": account.Name}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "Service account deleted successfully"})
+}
+
+func newServiceAccountResponse(account *models.ServiceAccount) ServiceAccountResponse {
+	return ServiceAccountResponse{
+		ID:          account.ID,
+		Name:        account.Name,
+		Description: account.Description,
+		ClientID:    account.ClientID,
+		Scopes:      service.AccountScopes(account),
+		IsActive:    account.IsActive,
+		CreatedBy:   account.CreatedBy,
+		CreatedAt:   account.CreatedAt,
+	}
+}
@@ -5,24 +5,56 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/middleware"
+	"github.com/user/user-management/internal/models"
 	"github.com/user/user-management/internal/service"
 )
 
 type UserHandler struct {
-	userService service.UserService
+	userService      service.UserService
+	profileService   service.ProfileService
+	groupService     service.GroupService
+	attributeService service.AttributeService
+	auditService     service.AuditService
+	requireIfMatch   bool
 }
 
-func NewUserHandler(userService service.UserService) *UserHandler {
+func NewUserHandler(userService service.UserService, profileService service.ProfileService, groupService service.GroupService, attributeService service.AttributeService, auditService service.AuditService, requireIfMatch bool) *UserHandler {
 	return &UserHandler{
-		userService: userService,
+		userService:      userService,
+		profileService:   profileService,
+		groupService:     groupService,
+		attributeService: attributeService,
+		auditService:     auditService,
+		requireIfMatch:   requireIfMatch,
 	}
 }
 
 type UpdateUserRequest struct {
-	Username string `json:"username,omitempty"`
-	Email    string `json:"email,omitempty"`
-	Password string `json:"password,omitempty"`
-	IsActive *bool  `json:"is_active,omitempty"`
+	Username        string `json:"username,omitempty"`
+	Email           string `json:"email,omitempty" doc:"修改本人邮箱时向新旧地址发送确认链接，都确认后才生效，此时返回202"`
+	Password        string `json:"password,omitempty"`
+	CurrentPassword string `json:"current_password,omitempty" doc:"修改本人邮箱或密码时需要，除非当前会话在STEP_UP_MAX_AGE内登录"`
+	IsActive        *bool  `json:"is_active,omitempty"`
+	Role            string `json:"role,omitempty"`
+
+	Attributes map[string]interface{} `json:"attributes,omitempty" doc:"要修改的扩展属性，值为null时移除；admin_only属性只有管理员可以修改"`
+}
+
+type EmailChangeConfirmRequest struct {
+	Token string `json:"token" binding:"required" doc:"邮件链接中的token参数"`
+}
+
+type EmailChangeConfirmResponse struct {
+	Status string `json:"status" doc:"pending表示还需确认发往另一个地址的链接，completed表示邮箱已修改"`
+	Email  string `json:"email" doc:"新邮箱"`
+}
+
+type UserListResponse struct {
+	Users []models.User `json:"users"`
+	Total int64         `json:"total"`
+	Page  int           `json:"page"`
+	Limit int           `json:"limit"`
 }
 
 func (h *UserHandler) GetUsers(c *gin.Context) {
@@ -36,46 +68,103 @@ func (h *UserHandler) GetUsers(c *gin.Context) {
 		limit = 10
 	}
 
-	users, total, err := h.userService.ListUsers(page, limit)
+	// attributes[name]=value按扩展属性过滤
+	admin := h.isAttributeAdmin(c)
+	users, total, err := h.userService.ListUsers(c.Request.Context(), page, limit, c.QueryMap("attributes"), admin)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		c.Error(err)
+		return
+	}
+
+	redacted := make([]*models.User, len(users))
+	for i := range users {
+		redacted[i] = &users[i]
+	}
+	if err := h.attributeService.Redact(c.GetUint("userID"), admin, redacted...); err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"users": users,
-		"total": total,
-		"page":  page,
-		"limit": limit,
+	c.JSON(http.StatusOK, UserListResponse{
+		Users: users,
+		Total: total,
+		Page:  page,
+		Limit: limit,
 	})
 }
 
 func (h *UserHandler) GetUser(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		c.Error(errInvalidUserID)
 		return
 	}
 
-	user, err := h.userService.GetByID(uint(id))
+	user, err := h.userService.GetByID(c.Request.Context(), uint(id))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	h.writeRedactedUser(c, http.StatusOK, user)
+}
+
+// GetUserGroups 返回用户的有效组，包括通过子组间接所属的组；可以查看的用户与GetUser一致
+func (h *UserHandler) GetUserGroups(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(errInvalidUserID)
+		return
+	}
+
+	if _, err := h.userService.GetByID(c.Request.Context(), uint(id)); err != nil {
+		c.Error(err)
+		return
+	}
+
+	groups, err := h.groupService.EffectiveGroups(uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := UserGroupListResponse{Groups: make([]UserGroupResponse, 0, len(groups))}
+	for _, group := range groups {
+		response.Groups = append(response.Groups, UserGroupResponse{
+			ID:     group.Group.ID,
+			Name:   group.Group.Name,
+			Direct: group.Direct,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 func (h *UserHandler) UpdateUser(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		c.Error(errInvalidUserID)
+		return
+	}
+
+	admin, err := h.authorizeUserWrite(c, uint(id))
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
 	var req UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
+		return
+	}
+
+	before, err := h.userService.GetByID(c.Request.Context(), uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !checkIfMatch(c, before, h.requireIfMatch) {
 		return
 	}
 
@@ -90,50 +179,124 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		updates["password"] = req.Password
 	}
 	if req.IsActive != nil {
+		// 与PATCH的白名单一致，普通用户不能停用或启用自己
+		if !admin && c.GetString("authMethod") != middleware.AuthMethodClientCredentials {
+			c.Error(fieldNotPatchable("is_active"))
+			return
+		}
 		updates["is_active"] = *req.IsActive
 	}
+	if req.Role != "" {
+		// 只有管理员可以修改角色
+		if !admin {
+			c.Error(errRoleChangeForbidden)
+			return
+		}
+		updates["role"] = req.Role
+	}
+	if len(req.Attributes) > 0 {
+		if err := h.attributeService.CheckEditable(req.Attributes, uint(id) == c.GetUint("userID"), h.isAttributeAdmin(c)); err != nil {
+			c.Error(err)
+			return
+		}
+		updates["attributes"] = req.Attributes
+	}
+
+	if uint(id) == c.GetUint("userID") {
+		h.updateOwnProfile(c, service.AuditActionUserUpdate, before, updates, req.CurrentPassword)
+		return
+	}
 
-	user, err := h.userService.UpdateUser(uint(id), updates)
+	user, err := h.userService.UpdateUser(c.Request.Context(), uint(id), updates, before.Version)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	h.recordUserChanges(c, service.AuditActionUserUpdate, before, user)
+
+	h.writeRedactedUser(c, http.StatusOK, user)
 }
 
 func (h *UserHandler) DeleteUser(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		c.Error(errInvalidUserID)
+		return
+	}
+
+	if _, err := h.authorizeUserWrite(c, uint(id)); err != nil {
+		c.Error(err)
 		return
 	}
 
 	// 防止用户删除自己
 	currentUserID := c.GetUint("userID")
 	if uint(id) == currentUserID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot delete your own account"})
+		c.Error(errCannotDeleteSelf)
 		return
 	}
 
-	if err := h.userService.DeleteUser(uint(id)); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := h.userService.DeleteUser(c.Request.Context(), uint(id)); err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+	recordAudit(h.auditService, newAuditEvent(c, service.AuditActionUserDelete, uint(id)))
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "User deleted successfully"})
+}
+
+// authorizeUserWrite 检查当前请求能否修改或删除id对应的用户：服务账号和管理员可以操作任何用户，
+// 普通用户只能操作自己。返回当前用户是否为管理员，服务账号不是管理员
+func (h *UserHandler) authorizeUserWrite(c *gin.Context, id uint) (bool, error) {
+	if c.GetString("authMethod") == middleware.AuthMethodClientCredentials {
+		return false, nil
+	}
+	currentUserID := c.GetUint("userID")
+	currentUser, err := h.userService.GetByID(c.Request.Context(), currentUserID)
+	if err != nil {
+		return false, service.ErrForbidden
+	}
+	if currentUser.Role == models.RoleAdmin {
+		return true, nil
+	}
+	if id != currentUserID {
+		return false, service.ErrForbidden
+	}
+	return false, nil
+}
+
+// isAttributeAdmin 判断当前请求能否查看和修改所有扩展属性，服务账号视为管理员
+func (h *UserHandler) isAttributeAdmin(c *gin.Context) bool {
+	if c.GetString("authMethod") == middleware.AuthMethodClientCredentials {
+		return true
+	}
+	currentUser, err := h.userService.GetByID(c.Request.Context(), c.GetUint("userID"))
+	return err == nil && currentUser.Role == models.RoleAdmin
+}
+
+// writeRedactedUser 移除当前请求不可见的扩展属性后返回用户，查看本人时不需要移除
+func (h *UserHandler) writeRedactedUser(c *gin.Context, status int, user *models.User) {
+	if user.ID != c.GetUint("userID") {
+		if err := h.attributeService.Redact(c.GetUint("userID"), h.isAttributeAdmin(c), user); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	writeUser(c, status, user)
 }
 
 func (h *UserHandler) GetProfile(c *gin.Context) {
 	userID := c.GetUint("userID")
 
-	user, err := h.userService.GetByID(userID)
+	user, err := h.userService.GetByID(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	writeUser(c, http.StatusOK, user)
 }
 
 func (h *UserHandler) UpdateProfile(c *gin.Context) {
@@ -141,7 +304,16 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 
 	var req UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
+		return
+	}
+
+	before, err := h.userService.GetByID(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !checkIfMatch(c, before, h.requireIfMatch) {
 		return
 	}
 
@@ -155,12 +327,84 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 	if req.Password != "" {
 		updates["password"] = req.Password
 	}
+	if len(req.Attributes) > 0 {
+		if err := h.attributeService.CheckEditable(req.Attributes, true, h.isAttributeAdmin(c)); err != nil {
+			c.Error(err)
+			return
+		}
+		updates["attributes"] = req.Attributes
+	}
 
-	user, err := h.userService.UpdateUser(userID, updates)
+	h.updateOwnProfile(c, service.AuditActionProfileUpdate, before, updates, req.CurrentPassword)
+}
+
+// updateOwnProfile 修改本人资料：邮箱和密码需要再次认证，新邮箱等待确认时返回202和未修改邮箱的用户
+func (h *UserHandler) updateOwnProfile(c *gin.Context, action string, before *models.User, updates map[string]interface{}, currentPassword string) {
+	reauth := service.Reauthentication{
+		CurrentPassword: currentPassword,
+		AuthTime:        c.GetTime("authTime"),
+		SessionID:       c.GetString("sessionID"),
+		ActorID:         c.GetUint("actorID"),
+	}
+	user, pendingEmail, err := h.profileService.UpdateProfile(c.Request.Context(), before.ID, updates, before.Version, reauth)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	h.recordUserChanges(c, action, before, user)
+
+	status := http.StatusOK
+	if pendingEmail != "" {
+		event := newAuditEvent(c, service.AuditActionEmailChangeRequest, user.ID)
+		event.Changes = map[string]service.FieldChange{"email": {Before: user.Email, After: pendingEmail}}
+		recordAudit(h.auditService, event)
+		status = http.StatusAccepted
+	}
+	writeUser(c, status, user)
+}
+
+// ConfirmEmailChange 由邮件链接打开的页面调用，不需要登录；两个链接都确认后修改邮箱
+func (h *UserHandler) ConfirmEmailChange(c *gin.Context) {
+	var req EmailChangeConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	confirmation, err := h.profileService.ConfirmEmailChange(req.Token)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if !confirmation.Completed {
+		c.JSON(http.StatusOK, EmailChangeConfirmResponse{Status: "pending", Email: confirmation.NewEmail})
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionEmailChange, confirmation.UserID)
+	event.ActorID = &confirmation.UserID
+	event.Changes = map[string]service.FieldChange{"email": {Before: confirmation.OldEmail, After: confirmation.NewEmail}}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusOK, EmailChangeConfirmResponse{Status: "completed", Email: confirmation.NewEmail})
+}
+
+// recordUserChanges 记录用户变更，角色变更额外记录一条独立事件便于检索
+func (h *UserHandler) recordUserChanges(c *gin.Context, action string, before, after *models.User) {
+	changes := service.DiffUsers(before, after)
+	if len(changes) == 0 {
+		return
+	}
+
+	event := newAuditEvent(c, action, after.ID)
+	event.Changes = changes
+	recordAudit(h.auditService, event)
+
+	if roleChange, ok := changes["role"]; ok {
+		event := newAuditEvent(c, service.AuditActionRoleChange, after.ID)
+		event.Changes = map[string]service.FieldChange{"role": roleChange}
+		recordAudit(h.auditService, event)
+	}
 }
\ No newline at end of file
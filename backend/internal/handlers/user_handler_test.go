@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/middleware"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/service"
+)
+
+type stubUserService struct {
+	service.UserService
+	users   map[uint]*models.User
+	deleted []uint
+	updated []uint
+	// changes 按顺序记录每次UpdateUser收到的修改
+	changes []map[string]interface{}
+}
+
+func (s *stubUserService) GetByID(ctx context.Context, id uint) (*models.User, error) {
+	user, ok := s.users[id]
+	if !ok {
+		return nil, service.ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (s *stubUserService) UpdateUser(ctx context.Context, id uint, updates map[string]interface{}, version uint) (*models.User, error) {
+	s.updated = append(s.updated, id)
+	s.changes = append(s.changes, updates)
+	return s.GetByID(ctx, id)
+}
+
+func (s *stubUserService) DeleteUser(ctx context.Context, id uint) error {
+	s.deleted = append(s.deleted, id)
+	return nil
+}
+
+type stubAuditService struct {
+	service.AuditService
+	events []service.AuditEvent
+}
+
+func (s *stubAuditService) Record(event service.AuditEvent) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+type stubAttributeService struct {
+	service.AttributeService
+}
+
+func (stubAttributeService) Redact(viewerID uint, admin bool, users ...*models.User) error {
+	return nil
+}
+
+func (stubAttributeService) CheckEditable(changes map[string]interface{}, self, admin bool) error {
+	return nil
+}
+
+func newUserTestRouter(users *stubUserService, currentUserID uint, authMethod string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	handler := NewUserHandler(users, nil, nil, stubAttributeService{}, &stubAuditService{}, false)
+
+	router := gin.New()
+	router.Use(middleware.ErrorHandler())
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", currentUserID)
+		c.Set("authMethod", authMethod)
+	})
+	router.PUT("/users/:id", handler.UpdateUser)
+	router.PATCH("/users/:id", handler.PatchUser)
+	router.DELETE("/users/:id", handler.DeleteUser)
+	return router
+}
+
+func TestUserWritesRequireSelfOrAdmin(t *testing.T) {
+	users := &stubUserService{users: map[uint]*models.User{
+		1: {ID: 1, Username: "admin", Email: "admin@example.com", Role: models.RoleAdmin, IsActive: true, Version: 1},
+		2: {ID: 2, Username: "alice", Email: "alice@example.com", Role: models.RoleUser, IsActive: true, Version: 1},
+		3: {ID: 3, Username: "bob", Email: "bob@example.com", Role: models.RoleUser, IsActive: true, Version: 1},
+	}}
+
+	request := func(router *gin.Engine, method, path, contentType, body string) int {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	alice := newUserTestRouter(users, 2, middleware.AuthMethodSession)
+	for _, tc := range []struct {
+		method, contentType, body string
+	}{
+		{http.MethodPut, "application/json", `{"email":"owned@example.com"}`},
+		{http.MethodPut, "application/json", `{"is_active":false}`},
+		{http.MethodPatch, mediaTypeMergePatch, `{"email":"owned@example.com"}`},
+		{http.MethodDelete, "", ""},
+	} {
+		if code := request(alice, tc.method, "/users/3", tc.contentType, tc.body); code != http.StatusForbidden {
+			t.Fatalf("expected %s on another user to be forbidden, got %d", tc.method, code)
+		}
+	}
+	if code := request(alice, http.MethodPut, "/users/2", "application/json", `{"is_active":false}`); code != http.StatusForbidden {
+		t.Fatalf("expected users to be unable to deactivate themselves, got %d", code)
+	}
+	if len(users.updated) != 0 || len(users.deleted) != 0 {
+		t.Fatalf("expected no writes, got updates %v and deletes %v", users.updated, users.deleted)
+	}
+
+	admin := newUserTestRouter(users, 1, middleware.AuthMethodSession)
+	if code := request(admin, http.MethodPut, "/users/3", "application/json", `{"is_active":false}`); code != http.StatusOK {
+		t.Fatalf("expected admin to update another user, got %d", code)
+	}
+	if code := request(admin, http.MethodDelete, "/users/3", "", ""); code != http.StatusOK {
+		t.Fatalf("expected admin to delete another user, got %d", code)
+	}
+
+	// 服务账号可以修改用户，但不能修改角色
+	serviceAccount := newUserTestRouter(users, 0, middleware.AuthMethodClientCredentials)
+	if code := request(serviceAccount, http.MethodPut, "/users/2", "application/json", `{"role":"admin"}`); code != http.StatusForbidden {
+		t.Fatalf("expected service accounts to be unable to change roles, got %d", code)
+	}
+	if code := request(serviceAccount, http.MethodPut, "/users/2", "application/json", `{"is_active":false}`); code != http.StatusOK {
+		t.Fatalf("expected service account to update a user, got %d", code)
+	}
+}
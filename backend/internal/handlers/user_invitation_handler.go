@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/service"
+)
+
+// UserInvitationHandler 供管理员发出注册邀请，受邀者通过邀请链接完成注册
+type UserInvitationHandler struct {
+	invitationService service.UserInvitationService
+	auditService      service.AuditService
+}
+
+func NewUserInvitationHandler(invitationService service.UserInvitationService, auditService service.AuditService) *UserInvitationHandler {
+	return &UserInvitationHandler{
+		invitationService: invitationService,
+		auditService:      auditService,
+	}
+}
+
+type CreateUserInvitationRequest struct {
+	Email            string `json:"email" binding:"required,email"`
+	Role             string `json:"role" binding:"omitempty,oneof=user admin" doc:"注册后的全局角色，默认为user"`
+	OrganizationID   uint   `json:"organization_id" doc:"注册后加入的组织，为空时不加入组织"`
+	OrganizationRole string `json:"organization_role" binding:"omitempty,oneof=owner admin member" doc:"在组织中的角色，默认为member"`
+}
+
+type UserInvitationTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type AcceptUserInvitationRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Username string `json:"username" binding:"required,min=3,max=20"`
+	Password string `json:"password" binding:"required" doc:"须满足密码策略"`
+}
+
+type UserInvitationResponse struct {
+	ID               uint      `json:"id"`
+	Email            string    `json:"email"`
+	Role             string    `json:"role"`
+	OrganizationID   *uint     `json:"organization_id"`
+	OrganizationName string    `json:"organization_name,omitempty"`
+	OrganizationRole string    `json:"organization_role,omitempty"`
+	InvitedBy        uint      `json:"invited_by"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	Expired          bool      `json:"expired" doc:"已过期的邀请可以重新发送"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+type UserInvitationListResponse struct {
+	Invitations []UserInvitationResponse `json:"invitations"`
+}
+
+// UserInvitationLookupResponse 是注册页面展示的邀请信息
+type UserInvitationLookupResponse struct {
+	Email            string    `json:"email"`
+	OrganizationName string    `json:"organization_name,omitempty"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+func (h *UserInvitationHandler) ListInvitations(c *gin.Context) {
+	invitations, err := h.invitationService.List()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := UserInvitationListResponse{Invitations: make([]UserInvitationResponse, 0, len(invitations))}
+	for i := range invitations {
+		response.Invitations = append(response.Invitations, newUserInvitationResponse(&invitations[i]))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *UserInvitationHandler) CreateInvitation(c *gin.Context) {
+	var req CreateUserInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.RoleUser
+	}
+
+	invitation, err := h.invitationService.Invite(req.Email, req.Role, req.OrganizationID, req.OrganizationRole, c.GetUint("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionUserInviteCreate, 0)
+	event.Metadata = map[string]interface{}{"invitation_id": invitation.ID, "email": invitation.Email, "role": invitation.Role, "organization_id": invitation.OrganizationID}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusCreated, newUserInvitationResponse(invitation))
+}
+
+// ResendInvitation 重新发送邀请邮件，之前发出的链接失效
+func (h *UserInvitationHandler) ResendInvitation(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(errInvalidInvitationID)
+		return
+	}
+
+	invitation, err := h.invitationService.Resend(uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionUserInviteResend, 0)
+	event.Metadata = map[string]interface{}{"invitation_id": invitation.ID, "email": invitation.Email}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusOK, newUserInvitationResponse(invitation))
+}
+
+func (h *UserInvitationHandler) RevokeInvitation(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(errInvalidInvitationID)
+		return
+	}
+
+	invitation, err := h.invitationService.Revoke(uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionUserInviteRevoke, 0)
+	event.Metadata = map[string]interface{}{"invitation_id": invitation.ID, "email": invitation.Email}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "Invitation revoked successfully"})
+}
+
+// LookupInvitation 令牌放在请求体中，避免出现在访问日志里
+func (h *UserInvitationHandler) LookupInvitation(c *gin.Context) {
+	var req UserInvitationTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	invitation, err := h.invitationService.Lookup(req.Token)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := UserInvitationLookupResponse{Email: invitation.Email, ExpiresAt: invitation.ExpiresAt}
+	if invitation.Organization != nil {
+		response.OrganizationName = invitation.Organization.Name
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// AcceptInvitation 注册后需要再登录，不直接签发令牌
+func (h *UserInvitationHandler) AcceptInvitation(c *gin.Context) {
+	var req AcceptUserInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	user, err := h.invitationService.Accept(req.Token, req.Username, req.Password)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionUserInviteAccept, user.ID)
+	event.ActorID = &user.ID
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusCreated, RegisterResponse{
+		ID:        user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		CreatedAt: user.CreatedAt,
+	})
+}
+
+func newUserInvitationResponse(invitation *models.UserInvitation) UserInvitationResponse {
+	response := UserInvitationResponse{
+		ID:               invitation.ID,
+		Email:            invitation.Email,
+		Role:             invitation.Role,
+		OrganizationID:   invitation.OrganizationID,
+		OrganizationRole: invitation.OrganizationRole,
+		InvitedBy:        invitation.InvitedBy,
+		ExpiresAt:        invitation.ExpiresAt,
+		Expired:          time.Now().After(invitation.ExpiresAt),
+		CreatedAt:        invitation.CreatedAt,
+	}
+	if invitation.Organization != nil {
+		response.OrganizationName = invitation.Organization.Name
+	}
+	return response
+}
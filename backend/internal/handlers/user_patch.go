@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/user/user-management/internal/middleware"
+	"github.com/user/user-management/internal/service"
+)
+
+const (
+	mediaTypeMergePatch = "application/merge-patch+json"
+	mediaTypeJSONPatch  = "application/json-patch+json"
+)
+
+// 本人和管理员可修改的字段不同，password只写不读；服务账号不能修改角色。
+// current_password不是用户属性，修改本人邮箱或密码时随补丁一起提交用于再次认证
+var (
+	selfPatchableFields           = []string{"username", "email", "password", "current_password", "attributes"}
+	adminPatchableFields          = []string{"username", "email", "password", "current_password", "is_active", "role", "attributes"}
+	serviceAccountPatchableFields = []string{"username", "email", "password", "is_active", "attributes"}
+)
+
+// userPatchDocument 是补丁作用的目标文档，应用补丁后只校验被修改的字段，
+// 已有的值（例如SCIM创建的超过20个字符的用户名）不妨碍修改其他字段
+type userPatchDocument struct {
+	Username string  `json:"username" binding:"required,min=3,max=20"`
+	Email    string  `json:"email" binding:"required,email"`
+	Password *string `json:"password"`
+	IsActive bool    `json:"is_active"`
+	Role     string  `json:"role" binding:"required,oneof=user admin"`
+
+	// Attributes 可以整体替换，也可以按/attributes/<name>修改单个属性
+	Attributes map[string]interface{} `json:"attributes"`
+
+	CurrentPassword *string `json:"current_password"`
+}
+
+func (h *UserHandler) PatchUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(errInvalidUserID)
+		return
+	}
+
+	if c.GetString("authMethod") == middleware.AuthMethodClientCredentials {
+		h.patchUser(c, uint(id), serviceAccountPatchableFields, service.AuditActionUserUpdate)
+		return
+	}
+
+	// 管理员使用管理员白名单，普通用户只能修改自己且使用本人白名单
+	admin, err := h.authorizeUserWrite(c, uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	allowed := selfPatchableFields
+	if admin {
+		allowed = adminPatchableFields
+	}
+
+	h.patchUser(c, uint(id), allowed, service.AuditActionUserUpdate)
+}
+
+func (h *UserHandler) PatchProfile(c *gin.Context) {
+	h.patchUser(c, c.GetUint("userID"), selfPatchableFields, service.AuditActionProfileUpdate)
+}
+
+func (h *UserHandler) patchUser(c *gin.Context, id uint, allowed []string, action string) {
+	mediaType, _, err := mime.ParseMediaType(c.ContentType())
+	if err != nil || (mediaType != mediaTypeMergePatch && mediaType != mediaTypeJSONPatch) {
+		c.Header("Accept-Patch", mediaTypeMergePatch+", "+mediaTypeJSONPatch)
+		c.Error(errUnsupportedPatch)
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.Error(service.ErrInvalidRequest)
+		return
+	}
+
+	before, err := h.userService.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !checkIfMatch(c, before, h.requireIfMatch) {
+		return
+	}
+
+	original := userPatchDocument{
+		Username: before.Username,
+		Email:    before.Email,
+		IsActive: before.IsActive,
+		Role:     before.Role,
+	}
+	// 属性为空时使用空对象，使JSON Patch可以添加单个属性
+	original.Attributes = make(map[string]interface{}, len(before.Attributes))
+	for name, value := range before.Attributes {
+		original.Attributes[name] = value
+	}
+	originalJSON, err := json.Marshal(original)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var patchedJSON []byte
+	if mediaType == mediaTypeMergePatch {
+		patchedJSON, err = applyMergePatch(originalJSON, body, allowed)
+	} else {
+		patchedJSON, err = applyJSONPatch(originalJSON, body, allowed)
+	}
+	if err != nil {
+		if _, ok := service.AsError(err); !ok {
+			// 补丁本身合法但无法应用，例如test操作失败或路径不存在
+			err = errPatchFailed
+		}
+		c.Error(err)
+		return
+	}
+
+	var patched userPatchDocument
+	if err := json.Unmarshal(patchedJSON, &patched); err != nil {
+		c.Error(err)
+		return
+	}
+
+	updates := make(map[string]interface{})
+	var changed []string
+	if patched.Username != original.Username {
+		updates["username"] = patched.Username
+		changed = append(changed, "Username")
+	}
+	if patched.Email != original.Email {
+		updates["email"] = patched.Email
+		changed = append(changed, "Email")
+	}
+	if patched.Password != nil {
+		updates["password"] = *patched.Password
+	}
+	if patched.IsActive != original.IsActive {
+		updates["is_active"] = patched.IsActive
+	}
+	if patched.Role != original.Role {
+		updates["role"] = patched.Role
+		changed = append(changed, "Role")
+	}
+	if err := validatePatchedFields(&patched, changed); err != nil {
+		c.Error(err)
+		return
+	}
+	if changes := diffAttributes(original.Attributes, patched.Attributes); len(changes) > 0 {
+		if err := h.attributeService.CheckEditable(changes, id == c.GetUint("userID"), h.isAttributeAdmin(c)); err != nil {
+			c.Error(err)
+			return
+		}
+		updates["attributes"] = changes
+	}
+
+	if id == c.GetUint("userID") {
+		var currentPassword string
+		if patched.CurrentPassword != nil {
+			currentPassword = *patched.CurrentPassword
+		}
+		h.updateOwnProfile(c, action, before, updates, currentPassword)
+		return
+	}
+
+	user, err := h.userService.UpdateUser(c.Request.Context(), id, updates, before.Version)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.recordUserChanges(c, action, before, user)
+
+	h.writeRedactedUser(c, http.StatusOK, user)
+}
+
+// applyMergePatch 按RFC 7396应用补丁，只允许修改白名单中的顶层字段
+func applyMergePatch(doc, patch []byte, allowed []string) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &fields); err != nil {
+		return nil, errInvalidPatch
+	}
+	for field := range fields {
+		if !containsField(allowed, field) {
+			return nil, fieldNotPatchable(field)
+		}
+	}
+
+	return jsonpatch.MergePatch(doc, patch)
+}
+
+// applyJSONPatch 按RFC 6902应用补丁，path和from都必须指向白名单中的顶层字段或其成员
+func applyJSONPatch(doc, patch []byte, allowed []string) ([]byte, error) {
+	operations, err := jsonpatch.DecodePatch(patch)
+	if err != nil {
+		return nil, errInvalidPatch
+	}
+
+	for _, op := range operations {
+		path, err := op.Path()
+		if err != nil {
+			return nil, errInvalidPatch
+		}
+		if field := topLevelField(path); !strings.HasPrefix(path, "/") || !containsField(allowed, field) {
+			return nil, fieldNotPatchable(field)
+		}
+
+		if op.Kind() == "move" || op.Kind() == "copy" {
+			from, err := op.From()
+			if err != nil {
+				return nil, errInvalidPatch
+			}
+			// password只写不读，不能作为move/copy的来源
+			if field := topLevelField(from); !strings.HasPrefix(from, "/") || field == "password" || !containsField(allowed, field) {
+				return nil, fieldNotPatchable(field)
+			}
+		}
+	}
+
+	return operations.Apply(doc)
+}
+
+// validatePatchedFields 按binding规则校验userPatchDocument中被修改的字段
+func validatePatchedFields(patched *userPatchDocument, fields []string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	validate, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return binding.Validator.ValidateStruct(patched)
+	}
+	return validate.StructPartial(patched, fields...)
+}
+
+// topLevelField 返回JSON Pointer的第一段，例如/attributes/department返回attributes
+func topLevelField(pointer string) string {
+	return strings.SplitN(strings.TrimPrefix(pointer, "/"), "/", 2)[0]
+}
+
+// diffAttributes 返回补丁修改的扩展属性，被移除的属性值为nil
+func diffAttributes(before, after map[string]interface{}) map[string]interface{} {
+	changes := make(map[string]interface{})
+	for name, value := range after {
+		if previous, ok := before[name]; !ok || !reflect.DeepEqual(previous, value) {
+			changes[name] = value
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			changes[name] = nil
+		}
+	}
+	return changes
+}
+
+func containsField(fields []string, field string) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
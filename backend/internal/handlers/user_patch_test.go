@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/middleware"
+	"github.com/user/user-management/internal/models"
+)
+
+func newPatchTestUsers() *stubUserService {
+	return &stubUserService{users: map[uint]*models.User{
+		1: {ID: 1, Username: "admin", Email: "admin@example.com", Role: models.RoleAdmin, IsActive: true, Version: 1},
+		2: {ID: 2, Username: "alice", Email: "alice@example.com", Role: models.RoleUser, IsActive: true, Version: 1,
+			Attributes: models.UserAttributes{"team": "core"}},
+		// SCIM允许最长50个字符的用户名
+		3: {ID: 3, Username: "provisioned.user.from.the.directory", Email: "bob@example.com", Role: models.RoleUser, IsActive: true, Version: 1},
+	}}
+}
+
+// patch 发送补丁并返回响应；失败时解析problem+json中的错误码
+func patch(router *gin.Engine, path, contentType, body string) (int, string) {
+	req := httptest.NewRequest(http.MethodPatch, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var problem middleware.Problem
+	if rec.Code >= http.StatusBadRequest {
+		json.Unmarshal(rec.Body.Bytes(), &problem)
+	}
+	return rec.Code, problem.Code
+}
+
+func TestPatchUserApplies(t *testing.T) {
+	users := newPatchTestUsers()
+	admin := newUserTestRouter(users, 1, middleware.AuthMethodSession)
+
+	tests := []struct {
+		name, contentType, body string
+		want                    map[string]interface{}
+	}{
+		{
+			name: "merge patch", contentType: mediaTypeMergePatch,
+			body: `{"username":"alice2","is_active":false,"attributes":{"team":null,"department":"eng"}}`,
+			want: map[string]interface{}{"username": "alice2", "is_active": false, "attributes": map[string]interface{}{"team": nil, "department": "eng"}},
+		},
+		{
+			name: "json patch", contentType: mediaTypeJSONPatch,
+			body: `[{"op":"test","path":"/email","value":"alice@example.com"},{"op":"replace","path":"/email","value":"new@example.com"},{"op":"add","path":"/attributes/department","value":"eng"},{"op":"replace","path":"/role","value":"admin"}]`,
+			want: map[string]interface{}{"email": "new@example.com", "role": "admin", "attributes": map[string]interface{}{"department": "eng"}},
+		},
+		{
+			name: "json patch copy", contentType: mediaTypeJSONPatch,
+			body: `[{"op":"copy","from":"/attributes/team","path":"/attributes/department"}]`,
+			want: map[string]interface{}{"attributes": map[string]interface{}{"department": "core"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users.changes = nil
+			if code, problem := patch(admin, "/users/2", tt.contentType, tt.body); code != http.StatusOK {
+				t.Fatalf("expected 200, got %d %s", code, problem)
+			}
+			if len(users.changes) != 1 || !reflect.DeepEqual(users.changes[0], tt.want) {
+				t.Fatalf("expected updates %v, got %v", tt.want, users.changes)
+			}
+		})
+	}
+
+	// 只校验被修改的字段，超过20个字符的已有用户名不影响修改邮箱
+	users.changes = nil
+	if code, problem := patch(admin, "/users/3", mediaTypeMergePatch, `{"email":"bob2@example.com"}`); code != http.StatusOK {
+		t.Fatalf("expected long existing username to be accepted, got %d %s", code, problem)
+	}
+	if len(users.changes) != 1 || !reflect.DeepEqual(users.changes[0], map[string]interface{}{"email": "bob2@example.com"}) {
+		t.Fatalf("expected only the email to change, got %v", users.changes)
+	}
+	if code, problem := patch(admin, "/users/3", mediaTypeMergePatch, `{"username":"a.new.name.longer.than.twenty"}`); code != http.StatusUnprocessableEntity || problem != "validation_failed" {
+		t.Fatalf("expected new long username to be rejected, got %d %s", code, problem)
+	}
+	if code, problem := patch(admin, "/users/2", mediaTypeJSONPatch, `[{"op":"test","path":"/email","value":"other@example.com"}]`); code != http.StatusUnprocessableEntity || problem != "patch_failed" {
+		t.Fatalf("expected failed test operation to return patch_failed, got %d %s", code, problem)
+	}
+}
+
+func TestPatchUserRejectsForbiddenPaths(t *testing.T) {
+	users := newPatchTestUsers()
+	alice := newUserTestRouter(users, 2, middleware.AuthMethodSession)
+	admin := newUserTestRouter(users, 1, middleware.AuthMethodSession)
+	serviceAccount := newUserTestRouter(users, 0, middleware.AuthMethodClientCredentials)
+
+	tests := []struct {
+		name        string
+		router      *gin.Engine
+		contentType string
+		body        string
+	}{
+		{name: "self role merge", router: alice, contentType: mediaTypeMergePatch, body: `{"role":"admin"}`},
+		{name: "self role json patch", router: alice, contentType: mediaTypeJSONPatch, body: `[{"op":"replace","path":"/role","value":"admin"}]`},
+		{name: "self is_active", router: alice, contentType: mediaTypeJSONPatch, body: `[{"op":"replace","path":"/is_active","value":false}]`},
+		{name: "unknown field", router: admin, contentType: mediaTypeMergePatch, body: `{"password_hash":"x"}`},
+		{name: "unknown path", router: admin, contentType: mediaTypeJSONPatch, body: `[{"op":"add","path":"/version","value":9}]`},
+		{name: "service account role", router: serviceAccount, contentType: mediaTypeJSONPatch, body: `[{"op":"replace","path":"/role","value":"admin"}]`},
+		{name: "copy from password", router: admin, contentType: mediaTypeJSONPatch, body: `[{"op":"copy","from":"/password","path":"/attributes/leak"}]`},
+		{name: "move from password", router: admin, contentType: mediaTypeJSONPatch, body: `[{"op":"move","from":"/password","path":"/username"}]`},
+		{name: "self move from role", router: alice, contentType: mediaTypeJSONPatch, body: `[{"op":"move","from":"/role","path":"/attributes/role"}]`},
+		{name: "self copy from is_active", router: alice, contentType: mediaTypeJSONPatch, body: `[{"op":"copy","from":"/is_active","path":"/attributes/active"}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, problem := patch(tt.router, "/users/2", tt.contentType, tt.body); code != http.StatusForbidden || problem != "field_not_patchable" {
+				t.Fatalf("expected 403 field_not_patchable, got %d %s", code, problem)
+			}
+		})
+	}
+	if len(users.updated) != 0 {
+		t.Fatalf("expected no updates, got %v", users.updated)
+	}
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/service"
+)
+
+// WebAuthnHandler 处理WebAuthn凭据的注册、管理和登录
+type WebAuthnHandler struct {
+	webAuthnService service.WebAuthnService
+	authService     service.AuthService
+	auditService    service.AuditService
+}
+
+func NewWebAuthnHandler(webAuthnService service.WebAuthnService, authService service.AuthService, auditService service.AuditService) *WebAuthnHandler {
+	return &WebAuthnHandler{
+		webAuthnService: webAuthnService,
+		authService:     authService,
+		auditService:    auditService,
+	}
+}
+
+type WebAuthnLoginBeginRequest struct {
+	Ticket string `json:"ticket" doc:"密码登录返回的第二因素票据；为空时发起无密码登录"`
+}
+
+// WebAuthnCeremonyResponse 中publicKey原样传给navigator.credentials.create()或get()，二进制字段为base64url
+type WebAuthnCeremonyResponse struct {
+	CeremonyID string      `json:"ceremony_id" doc:"完成注册或登录时原样提交，5分钟内有效且只能使用一次"`
+	PublicKey  interface{} `json:"publicKey"`
+}
+
+type WebAuthnFinishRequest struct {
+	CeremonyID string                 `json:"ceremony_id" binding:"required"`
+	Credential map[string]interface{} `json:"credential" binding:"required" doc:"navigator.credentials返回的PublicKeyCredential，二进制字段为base64url"`
+}
+
+type WebAuthnRegisterRequest struct {
+	WebAuthnFinishRequest
+	Name string `json:"name" binding:"required,max=100" doc:"便于用户识别的名称，如“工作电脑”"`
+}
+
+// SecondFactorResponse 表示密码正确但还需要第二因素，此时不签发令牌
+type SecondFactorResponse struct {
+	Method    string `json:"method" doc:"第二因素类型，目前只有webauthn"`
+	Ticket    string `json:"ticket" doc:"提交给/auth/webauthn/login/begin完成第二步登录"`
+	ExpiresIn int    `json:"expires_in" doc:"票据有效期（秒）"`
+}
+
+type WebAuthnCredentialResponse struct {
+	ID             uint       `json:"id"`
+	Name           string     `json:"name"`
+	Transports     []string   `json:"transports"`
+	BackupEligible bool       `json:"backup_eligible" doc:"是否为可同步的通行密钥"`
+	BackupState    bool       `json:"backup_state"`
+	LastUsedAt     *time.Time `json:"last_used_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type WebAuthnCredentialListResponse struct {
+	Credentials []WebAuthnCredentialResponse `json:"credentials"`
+}
+
+func (h *WebAuthnHandler) BeginLogin(c *gin.Context) {
+	var req WebAuthnLoginBeginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	ceremonyID, assertion, err := h.webAuthnService.BeginLogin(req.Ticket)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, WebAuthnCeremonyResponse{CeremonyID: ceremonyID, PublicKey: assertion.Response})
+}
+
+// FinishLogin 校验断言后签发与密码登录相同的令牌
+func (h *WebAuthnHandler) FinishLogin(c *gin.Context) {
+	var req WebAuthnFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	credential, err := json.Marshal(req.Credential)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	user, err := h.webAuthnService.FinishLogin(req.CeremonyID, credential)
+	if err == nil {
+		var accessToken, refreshToken string
+		if accessToken, refreshToken, err = h.authService.StartSession(user, c.ClientIP(), c.Request.UserAgent()); err == nil {
+			event := newAuditEvent(c, service.AuditActionLogin, user.ID)
+			event.ActorID = &user.ID
+			event.Metadata = map[string]interface{}{"method": "webauthn"}
+			recordAudit(h.auditService, event)
+
+			c.JSON(http.StatusOK, LoginResponse{
+				TokenResponse: TokenResponse{
+					Token:        accessToken,
+					RefreshToken: refreshToken,
+				},
+				User: LoginUser{
+					ID:       user.ID,
+					Username: user.Username,
+					Email:    user.Email,
+				},
+			})
+			return
+		}
+	}
+
+	event := newAuditEvent(c, service.AuditActionLoginFailed, 0)
+	event.Metadata = map[string]interface{}{"method": "webauthn", "reason": service.ErrorCode(err)}
+	recordAudit(h.auditService, event)
+
+	c.Error(err)
+}
+
+func (h *WebAuthnHandler) ListCredentials(c *gin.Context) {
+	credentials, err := h.webAuthnService.ListCredentials(c.GetUint("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := WebAuthnCredentialListResponse{Credentials: make([]WebAuthnCredentialResponse, 0, len(credentials))}
+	for i := range credentials {
+		response.Credentials = append(response.Credentials, newWebAuthnCredentialResponse(&credentials[i]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *WebAuthnHandler) BeginRegistration(c *gin.Context) {
+	ceremonyID, creation, err := h.webAuthnService.BeginRegistration(c.GetUint("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, WebAuthnCeremonyResponse{CeremonyID: ceremonyID, PublicKey: creation.Response})
+}
+
+func (h *WebAuthnHandler) FinishRegistration(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var req WebAuthnRegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+	response, err := json.Marshal(req.Credential)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	credential, err := h.webAuthnService.FinishRegistration(userID, req.CeremonyID, req.Name, response)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionWebAuthnRegister, userID)
+	event.Metadata = map[string]interface{}{"credential_id": credential.ID, "name": credential.Name}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusCreated, newWebAuthnCredentialResponse(credential))
+}
+
+func (h *WebAuthnHandler) DeleteCredential(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(errInvalidWebAuthnID)
+		return
+	}
+
+	credential, err := h.webAuthnService.DeleteCredential(userID, uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	event := newAuditEvent(c, service.AuditActionWebAuthnDelete, userID)
+	event.Metadata = map[string]interface{}{"credential_id": credential.ID, "name": credential.Name}
+	recordAudit(h.auditService, event)
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "Security key deleted successfully"})
+}
+
+func newWebAuthnCredentialResponse(credential *models.WebAuthnCredential) WebAuthnCredentialResponse {
+	transports := []string{}
+	if credential.Transports != "" {
+		transports = strings.Split(credential.Transports, ",")
+	}
+	return WebAuthnCredentialResponse{
+		ID:             credential.ID,
+		Name:           credential.Name,
+		Transports:     transports,
+		BackupEligible: credential.BackupEligible,
+		BackupState:    credential.BackupState,
+		LastUsedAt:     credential.LastUsedAt,
+		CreatedAt:      credential.CreatedAt,
+	}
+}
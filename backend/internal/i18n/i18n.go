@@ -0,0 +1,101 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	zhTranslations "github.com/go-playground/validator/v10/translations/zh"
+	"golang.org/x/text/language"
+)
+
+const (
+	English           = "en"
+	SimplifiedChinese = "zh-CN"
+	DefaultLocale     = English
+)
+
+//go:embed locales/*.json
+var localeFS embed.FS
+
+var (
+	// supportedTags与supportedLocales一一对应，第一个为默认语言
+	supportedTags    = []language.Tag{language.English, language.SimplifiedChinese}
+	supportedLocales = []string{English, SimplifiedChinese}
+	matcher          = language.NewMatcher(supportedTags)
+
+	catalogs = make(map[string]map[string]string)
+
+	// validator翻译器使用go-playground/locales的语言名
+	universal = ut.New(en.New(), en.New(), zh.New())
+	utLocales = map[string]string{English: "en", SimplifiedChinese: "zh"}
+)
+
+func init() {
+	for _, locale := range supportedLocales {
+		data, err := localeFS.ReadFile(fmt.Sprintf("locales/%s.json", locale))
+		if err != nil {
+			panic(err)
+		}
+		messages := make(map[string]string)
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("invalid message catalog %s: %v", locale, err))
+		}
+		catalogs[locale] = messages
+	}
+}
+
+// Negotiate 根据Accept-Language选择支持的语言，无法匹配时返回默认语言
+func Negotiate(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return DefaultLocale
+	}
+
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return DefaultLocale
+	}
+	return supportedLocales[index]
+}
+
+// T 返回指定语言的消息并替换{name}占位符，目录中没有时返回fallback
+func T(locale, key, fallback string, params map[string]string) string {
+	message, ok := catalogs[locale][key]
+	if !ok {
+		if message, ok = catalogs[DefaultLocale][key]; !ok {
+			return fallback
+		}
+	}
+
+	for name, value := range params {
+		message = strings.ReplaceAll(message, "{"+name+"}", value)
+	}
+	return message
+}
+
+// RegisterValidatorTranslations 为validator注册所有支持语言的默认字段错误翻译
+func RegisterValidatorTranslations(v *validator.Validate) error {
+	enTrans, _ := universal.GetTranslator(utLocales[English])
+	if err := enTranslations.RegisterDefaultTranslations(v, enTrans); err != nil {
+		return err
+	}
+
+	zhTrans, _ := universal.GetTranslator(utLocales[SimplifiedChinese])
+	return zhTranslations.RegisterDefaultTranslations(v, zhTrans)
+}
+
+// TranslateFieldError 将validator的字段错误翻译为指定语言
+func TranslateFieldError(locale string, fe validator.FieldError) string {
+	trans, found := universal.GetTranslator(utLocales[locale])
+	if !found {
+		trans, _ = universal.GetTranslator(utLocales[DefaultLocale])
+	}
+	return fe.Translate(trans)
+}
@@ -0,0 +1,54 @@
+package i18n
+
+import (
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := map[string]string{
+		"":                          English,
+		"zh-CN":                     SimplifiedChinese,
+		"zh":                        SimplifiedChinese,
+		"zh-Hans-CN":                SimplifiedChinese,
+		"fr-FR, zh-CN;q=0.8":        SimplifiedChinese,
+		"en-US,en;q=0.9,zh;q=0.8":   English,
+		"fr-FR":                     English,
+		"not a language tag;q=oops": English,
+	}
+	for header, want := range tests {
+		if got := Negotiate(header); got != want {
+			t.Errorf("Negotiate(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	if got := T(SimplifiedChinese, "user_not_found", "User not found", nil); got != "用户不存在" {
+		t.Fatalf("expected chinese message, got %q", got)
+	}
+	if got := T(SimplifiedChinese, "avatar_too_large", "", map[string]string{"limit": "512 KB"}); got != "头像不能超过512 KB" {
+		t.Fatalf("expected placeholder to be replaced, got %q", got)
+	}
+	if got := T("fr", "user_not_found", "fallback", nil); got != "User not found" {
+		t.Fatalf("expected unsupported locale to use the default catalog, got %q", got)
+	}
+	if got := T(SimplifiedChinese, "no_such_code", "fallback", nil); got != "fallback" {
+		t.Fatalf("expected fallback for unknown code, got %q", got)
+	}
+}
+
+// 各语言目录的键必须一致，避免新增错误码时漏翻译
+func TestCatalogsHaveSameKeys(t *testing.T) {
+	for _, locale := range supportedLocales {
+		for key := range catalogs[DefaultLocale] {
+			if _, ok := catalogs[locale][key]; !ok {
+				t.Errorf("%s catalog is missing %q", locale, key)
+			}
+		}
+		for key := range catalogs[locale] {
+			if _, ok := catalogs[DefaultLocale][key]; !ok {
+				t.Errorf("%s catalog has %q which is not in the default catalog", locale, key)
+			}
+		}
+	}
+}
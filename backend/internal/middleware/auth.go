@@ -1,18 +1,25 @@
 package middleware
 
 import (
-	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/repository"
 	"github.com/user/user-management/internal/service"
 )
 
-func Auth(authService service.AuthService) gin.HandlerFunc {
+// 认证方式，保存在上下文的authMethod中
+const (
+	AuthMethodSession           = "session"
+	AuthMethodAccessToken       = "access_token"
+	AuthMethodClientCredentials = "client_credentials"
+)
+
+func Auth(authService service.AuthService, accessTokenService service.AccessTokenService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
+			c.Error(service.ErrUnauthorized)
 			c.Abort()
 			return
 		}
@@ -20,25 +27,115 @@ func Auth(authService service.AuthService) gin.HandlerFunc {
 		// 检查Bearer前缀
 		parts := strings.SplitN(authHeader, " ", 2)
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
+			c.Error(service.ErrInvalidAuthHeader)
 			c.Abort()
 			return
 		}
 
 		tokenString := parts[1]
 
-		// 验证token（包括Redis session验证）
-		userID, err := authService.ValidateToken(tokenString)
+		// 个人访问令牌通过固定前缀识别，其余按JWT处理
+		if strings.HasPrefix(tokenString, service.AccessTokenPrefix) {
+			token, err := accessTokenService.Authenticate(tokenString)
+			if err != nil {
+				c.Error(service.ErrInvalidToken)
+				c.Abort()
+				return
+			}
+
+			c.Set("userID", token.UserID)
+			c.Set("authMethod", AuthMethodAccessToken)
+			c.Set("accessTokenID", token.ID)
+			c.Set("scopes", service.TokenScopes(token))
+			setTenant(c, token.OrganizationID)
+
+			c.Next()
+			return
+		}
+
+		// 验证token（用户令牌包括Redis session验证）
+		principal, err := authService.ValidateToken(tokenString)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			c.Error(service.ErrInvalidToken)
 			c.Abort()
 			return
 		}
 
-		// 设置用户ID和token到上下文
-		c.Set("userID", userID)
+		// 服务账号没有userID，只能访问其scope允许的接口
+		if principal.Type == service.PrincipalServiceAccount {
+			c.Set("serviceAccountID", principal.ID)
+			c.Set("authMethod", AuthMethodClientCredentials)
+			c.Set("scopes", principal.Scopes)
+			c.Request = c.Request.WithContext(repository.WithAllTenants(c.Request.Context()))
+
+			c.Next()
+			return
+		}
+
+		// 设置用户ID和token到上下文，sessionID和authTime用于再次认证和撤销其他会话
+		c.Set("userID", principal.ID)
 		c.Set("token", tokenString)
+		c.Set("authMethod", AuthMethodSession)
+		c.Set("sessionID", principal.SessionID)
+		c.Set("authTime", principal.AuthTime)
+		// 管理员模拟用户时userID是被模拟的用户，actorID是实际操作的管理员
+		if principal.ActorID != 0 {
+			c.Set("actorID", principal.ActorID)
+		}
+		c.Set("organizationRole", principal.OrganizationRole)
+		setTenant(c, principal.OrganizationID)
 
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// setTenant 记录令牌所在的组织，并写入请求的context，用户查询据此限定在该组织内；
+// 服务账号不属于任何组织，改为明确不限定租户（repository.WithAllTenants）
+func setTenant(c *gin.Context, organizationID uint) {
+	c.Set("organizationID", organizationID)
+	c.Request = c.Request.WithContext(repository.WithTenant(c.Request.Context(), organizationID))
+}
+
+// RequireScope 需要在Auth之后使用；登录会话拥有全部权限，访问令牌必须包含指定scope
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("authMethod") == AuthMethodSession {
+			c.Next()
+			return
+		}
+
+		for _, granted := range c.GetStringSlice("scopes") {
+			if granted == scope {
+				c.Next()
+				return
+			}
+		}
+
+		c.Error(service.ErrInsufficientScope)
+		c.Abort()
+	}
+}
+
+// RejectImpersonation 禁止管理员在模拟用户期间访问，用于修改凭据、第二因素等敏感操作
+func RejectImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetUint("actorID") != 0 {
+			c.Error(service.ErrImpersonationForbidden)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSession 限制只能通过登录会话访问，例如管理访问令牌本身
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("authMethod") != AuthMethodSession {
+			c.Error(service.ErrSessionRequired)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
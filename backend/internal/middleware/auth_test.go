@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/service"
+)
+
+type stubAccessTokenService struct {
+	service.AccessTokenService
+	tokens map[string]*models.PersonalAccessToken
+}
+
+func (s *stubAccessTokenService) Authenticate(secret string) (*models.PersonalAccessToken, error) {
+	if token, ok := s.tokens[secret]; ok {
+		return token, nil
+	}
+	return nil, service.ErrInvalidToken
+}
+
+type stubAuthService struct {
+	service.AuthService
+	principals map[string]*service.Principal
+}
+
+func (s *stubAuthService) ValidateToken(token string) (*service.Principal, error) {
+	if principal, ok := s.principals[token]; ok {
+		return principal, nil
+	}
+	return nil, service.ErrInvalidToken
+}
+
+func TestAuthScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens := &stubAccessTokenService{tokens: map[string]*models.PersonalAccessToken{
+		"ump_reader": {ID: 1, UserID: 7, Scopes: models.ScopeUsersRead},
+		"ump_writer": {ID: 2, UserID: 7, Scopes: models.ScopeUsersRead + " " + models.ScopeUsersWrite},
+	}}
+	auth := &stubAuthService{principals: map[string]*service.Principal{
+		"session-jwt": {Type: service.PrincipalUser, ID: 7, SessionID: "s1"},
+		"client-jwt":  {Type: service.PrincipalServiceAccount, ID: 3, Scopes: []string{models.ScopeUsersRead}},
+	}}
+
+	router := gin.New()
+	router.Use(ErrorHandler())
+	users := router.Group("/users")
+	users.Use(Auth(auth, tokens))
+	users.GET("", RequireScope(models.ScopeUsersRead), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetUint("userID"), "auth_method": c.GetString("authMethod")})
+	})
+	users.PUT("/:id", RequireScope(models.ScopeUsersWrite), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	users.GET("/profile/tokens", RequireSession(), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	for _, tc := range []struct {
+		method, path, authorization string
+		status                      int
+		code                        string
+	}{
+		{http.MethodGet, "/users", "", http.StatusUnauthorized, "unauthorized"},
+		{http.MethodGet, "/users", "Basic dXNlcjpwYXNz", http.StatusUnauthorized, "invalid_authorization_header"},
+		{http.MethodGet, "/users", "Bearer ump_unknown", http.StatusUnauthorized, "invalid_token"},
+		{http.MethodGet, "/users", "Bearer ump_reader", http.StatusOK, ""},
+		{http.MethodPut, "/users/1", "Bearer ump_reader", http.StatusForbidden, "insufficient_scope"},
+		{http.MethodPut, "/users/1", "Bearer ump_writer", http.StatusNoContent, ""},
+		{http.MethodGet, "/users/profile/tokens", "Bearer ump_writer", http.StatusForbidden, "session_required"},
+		// 登录会话拥有全部scope
+		{http.MethodPut, "/users/1", "Bearer session-jwt", http.StatusNoContent, ""},
+		{http.MethodGet, "/users/profile/tokens", "Bearer session-jwt", http.StatusNoContent, ""},
+		{http.MethodPut, "/users/1", "Bearer client-jwt", http.StatusForbidden, "insufficient_scope"},
+		{http.MethodGet, "/users", "Bearer client-jwt", http.StatusOK, ""},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		if tc.authorization != "" {
+			req.Header.Set("Authorization", tc.authorization)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != tc.status {
+			t.Fatalf("%s %s with %q: expected %d, got %d %s", tc.method, tc.path, tc.authorization, tc.status, rec.Code, rec.Body.String())
+		}
+		if tc.code != "" {
+			var problem struct {
+				Code string `json:"code"`
+			}
+			json.Unmarshal(rec.Body.Bytes(), &problem)
+			if problem.Code != tc.code {
+				t.Fatalf("%s %s with %q: expected code %s, got %s", tc.method, tc.path, tc.authorization, tc.code, rec.Body.String())
+			}
+		}
+	}
+
+	// 访问令牌以令牌所属的用户身份访问
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Authorization", "Bearer ump_reader")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	var body struct {
+		UserID     uint   `json:"user_id"`
+		AuthMethod string `json:"auth_method"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if body.UserID != 7 || body.AuthMethod != AuthMethodAccessToken {
+		t.Fatalf("unexpected principal %+v", body)
+	}
+}
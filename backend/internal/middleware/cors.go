@@ -8,9 +8,9 @@ import (
 func CORS() gin.HandlerFunc {
 	return cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost", "http://localhost:80", "http://localhost:5173"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-Request-ID", "If-Match", "If-None-Match"},
+		ExposeHeaders:    []string{"Content-Length", "X-Request-ID", "ETag", "Accept-Patch"},
 		AllowCredentials: true,
 		MaxAge:           12 * 3600,
 	})
@@ -1,36 +1,173 @@
 package middleware
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"reflect"
+	"strings"
+	"sync"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/user/user-management/internal/i18n"
+	"github.com/user/user-management/internal/service"
 )
 
+const problemContentType = "application/problem+json"
+
+// Problem 是RFC 7807格式的错误响应，code为稳定的机器可读错误码
+type Problem struct {
+	Type      string               `json:"type"`
+	Title     string               `json:"title"`
+	Status    int                  `json:"status"`
+	Detail    string               `json:"detail,omitempty"`
+	Instance  string               `json:"instance,omitempty"`
+	Code      string               `json:"code"`
+	RequestID string               `json:"request_id,omitempty"`
+	Errors    []service.FieldError `json:"errors,omitempty"`
+}
+
+var kindStatus = map[service.ErrorKind]int{
+	service.KindInternal:             http.StatusInternalServerError,
+	service.KindBadRequest:           http.StatusBadRequest,
+	service.KindValidation:           http.StatusUnprocessableEntity,
+	service.KindUnauthorized:         http.StatusUnauthorized,
+	service.KindInvalidCredentials:   http.StatusUnauthorized,
+	service.KindForbidden:            http.StatusForbidden,
+	service.KindDisabled:             http.StatusForbidden,
+	service.KindLocked:               http.StatusLocked,
+	service.KindNotFound:             http.StatusNotFound,
+	service.KindConflict:             http.StatusConflict,
+	service.KindPreconditionFailed:   http.StatusPreconditionFailed,
+	service.KindPreconditionRequired: http.StatusPreconditionRequired,
+	service.KindUnsupportedMediaType: http.StatusUnsupportedMediaType,
+	service.KindTooManyRequests:      http.StatusTooManyRequests,
+	service.KindPayloadTooLarge:      http.StatusRequestEntityTooLarge,
+}
+
+// registerValidator 只在全局validator上注册一次，重复注册翻译会报冲突
+var registerValidator sync.Once
+
 func ErrorHandler() gin.HandlerFunc {
+	// 校验错误中的字段名使用json标签，与请求体保持一致
+	registerValidator.Do(func() {
+		if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+			v.RegisterTagNameFunc(jsonFieldName)
+			if err := i18n.RegisterValidatorTranslations(v); err != nil {
+				log.Printf("Failed to register validator translations: %v", err)
+			}
+		}
+	})
+
 	return func(c *gin.Context) {
 		c.Next()
 
 		// 处理所有错误
-		if len(c.Errors) > 0 {
-			err := c.Errors.Last()
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		locale := c.GetString("locale")
+		if locale == "" {
+			locale = i18n.Negotiate(c.GetHeader("Accept-Language"))
+		}
+
+		err := c.Errors.Last().Err
+		domainErr := toDomainError(err, locale)
+		if domainErr.Kind == service.KindInternal {
+			// 内部错误只记录日志，不向客户端暴露原始信息
 			log.Printf("Error processing request: %v", err)
+		}
 
-			// 根据错误类型返回不同的状态码
-			switch err.Type {
-			case gin.ErrorTypeBind:
-				c.JSON(http.StatusBadRequest, gin.H{
-					"error": "Invalid request format",
-				})
-			case gin.ErrorTypePublic:
-				c.JSON(http.StatusBadRequest, gin.H{
-					"error": err.Error(),
-				})
-			default:
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": "Internal server error",
-				})
-			}
+		status, ok := kindStatus[domainErr.Kind]
+		if !ok {
+			status = http.StatusInternalServerError
 		}
+
+		problem := Problem{
+			Type:      "urn:problem-type:" + domainErr.Code,
+			Title:     http.StatusText(status),
+			Status:    status,
+			Detail:    i18n.T(locale, domainErr.Code, domainErr.Message, domainErr.Params),
+			Instance:  c.Request.URL.Path,
+			Code:      domainErr.Code,
+			RequestID: c.GetString("requestID"),
+			Errors:    localizeFields(domainErr.Fields, locale),
+		}
+
+		c.Header("Content-Type", problemContentType)
+		c.Header("Content-Language", locale)
+		c.JSON(status, problem)
+	}
+}
+
+// toDomainError 将绑定/校验错误转换为领域错误，未知错误统一视为内部错误
+func toDomainError(err error, locale string) *service.Error {
+	if domainErr, ok := service.AsError(err); ok {
+		return domainErr
+	}
+
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]service.FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			fields = append(fields, service.FieldError{
+				Field:   fe.Field(),
+				Code:    fe.Tag(),
+				Message: i18n.TranslateFieldError(locale, fe),
+				Param:   fe.Param(),
+			})
+		}
+		return service.NewValidationError(fields...)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return service.NewValidationError(service.FieldError{
+			Field:   typeErr.Field,
+			Code:    "type",
+			Message: fmt.Sprintf("%s must be of type %s", typeErr.Field, typeErr.Type.String()),
+			Param:   typeErr.Type.String(),
+		})
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return service.ErrInvalidRequest
+	}
+
+	return service.ErrInternal
+}
+
+// localizeFields 按字段错误码翻译领域层产生的字段错误，目录中没有的保留原消息
+func localizeFields(fields []service.FieldError, locale string) []service.FieldError {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	localized := make([]service.FieldError, len(fields))
+	for i, field := range fields {
+		field.Message = i18n.T(locale, "field."+field.Code, field.Message, map[string]string{
+			"field": field.Field,
+			"param": field.Param,
+		})
+		localized[i] = field
+	}
+	return localized
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
 	}
-}
\ No newline at end of file
+	return name
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/service"
+)
+
+func TestErrorHandlerProblems(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	type payload struct {
+		Username string `json:"username" binding:"required,min=3"`
+		Age      int    `json:"age"`
+	}
+
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.GET("/domain", func(c *gin.Context) {
+		c.Error(service.ErrUserNotFound)
+	})
+	router.GET("/precondition", func(c *gin.Context) {
+		c.Error(service.ErrPreconditionFailed)
+	})
+	router.GET("/wrapped", func(c *gin.Context) {
+		c.Error(errors.Join(errors.New("loading user"), service.ErrEmailTaken))
+	})
+	router.GET("/internal", func(c *gin.Context) {
+		c.Error(errors.New("dial tcp 10.0.0.1:3306: connection refused"))
+	})
+	router.POST("/bind", func(c *gin.Context) {
+		var req payload
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+		fields []service.FieldError
+	}{
+		{name: "domain error", method: http.MethodGet, path: "/domain", status: http.StatusNotFound, code: "user_not_found"},
+		{name: "precondition failed", method: http.MethodGet, path: "/precondition", status: http.StatusPreconditionFailed, code: "precondition_failed"},
+		{name: "wrapped domain error", method: http.MethodGet, path: "/wrapped", status: http.StatusConflict, code: "email_taken"},
+		{name: "unknown error", method: http.MethodGet, path: "/internal", status: http.StatusInternalServerError, code: "internal_error"},
+		{name: "malformed json", method: http.MethodPost, path: "/bind", body: `{"username":`, status: http.StatusBadRequest, code: "invalid_request"},
+		{name: "empty body", method: http.MethodPost, path: "/bind", status: http.StatusBadRequest, code: "invalid_request"},
+		{
+			name: "validation error", method: http.MethodPost, path: "/bind", body: `{"username":"ab"}`,
+			status: http.StatusUnprocessableEntity, code: "validation_failed",
+			fields: []service.FieldError{{Field: "username", Code: "min", Message: "username must be at least 3 characters in length", Param: "3"}},
+		},
+		{
+			name: "type error", method: http.MethodPost, path: "/bind", body: `{"username":"alice","age":"ten"}`,
+			status: http.StatusUnprocessableEntity, code: "validation_failed",
+			fields: []service.FieldError{{Field: "age", Code: "type", Message: "age must be of type int", Param: "int"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+			if contentType := rec.Header().Get("Content-Type"); !strings.HasPrefix(contentType, problemContentType) {
+				t.Fatalf("expected problem+json content type, got %q", contentType)
+			}
+
+			var problem Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+			if problem.Code != tt.code || problem.Status != tt.status || problem.Type != "urn:problem-type:"+tt.code {
+				t.Fatalf("unexpected problem %+v", problem)
+			}
+			if problem.Title != http.StatusText(tt.status) || problem.Instance != tt.path {
+				t.Fatalf("unexpected problem title or instance %+v", problem)
+			}
+			if !reflect.DeepEqual(problem.Errors, tt.fields) {
+				t.Fatalf("expected fields %+v, got %+v", tt.fields, problem.Errors)
+			}
+			// 内部错误不向客户端暴露原始信息
+			if strings.Contains(rec.Body.String(), "connection refused") {
+				t.Fatalf("internal error leaked to client: %s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestErrorHandlerKeepsWrittenResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.GET("/", func(c *gin.Context) {
+		c.JSON(http.StatusAccepted, gin.H{"ok": true})
+		c.Error(service.ErrInternal)
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusAccepted || rec.Body.String() != `{"ok":true}` {
+		t.Fatalf("expected handler response to be kept, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestKindStatusCoversAllKinds(t *testing.T) {
+	for kind := service.KindInternal; kind <= service.KindPayloadTooLarge; kind++ {
+		if _, ok := kindStatus[kind]; !ok {
+			t.Fatalf("error kind %d has no HTTP status", kind)
+		}
+	}
+}
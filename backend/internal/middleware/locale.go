@@ -0,0 +1,14 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/i18n"
+)
+
+// Locale 根据Accept-Language协商响应语言，供错误信息等本地化使用
+func Locale() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("locale", i18n.Negotiate(c.GetHeader("Accept-Language")))
+		c.Next()
+	}
+}
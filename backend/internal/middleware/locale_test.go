@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/service"
+)
+
+func TestLocalizedProblems(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(Locale(), ErrorHandler())
+	router.GET("/domain", func(c *gin.Context) {
+		c.Error(service.ErrUserNotFound)
+	})
+	router.GET("/fields", func(c *gin.Context) {
+		c.Error(service.ErrInvalidRole)
+	})
+	router.POST("/bind", func(c *gin.Context) {
+		var req struct {
+			Username string `json:"username" binding:"required,min=3"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(err)
+		}
+	})
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		acceptLanguage string
+		language       string
+		detail         string
+		fieldMessage   string
+	}{
+		{name: "default", method: http.MethodGet, path: "/domain", language: "en", detail: "User not found"},
+		{name: "chinese", method: http.MethodGet, path: "/domain", acceptLanguage: "zh-CN,zh;q=0.9", language: "zh-CN", detail: "用户不存在"},
+		{name: "unsupported", method: http.MethodGet, path: "/domain", acceptLanguage: "fr-FR", language: "en", detail: "User not found"},
+		// 目录中有对应field.<code>时优先使用目录，否则使用validator自带的翻译
+		{name: "domain field", method: http.MethodGet, path: "/fields", acceptLanguage: "zh-CN", language: "zh-CN", fieldMessage: "role必须是[user admin]中的一个"},
+		{name: "validator field", method: http.MethodPost, path: "/bind", acceptLanguage: "zh-CN", language: "zh-CN", detail: "请求参数校验失败", fieldMessage: "username为必填项"},
+		{name: "validator translation", method: http.MethodPost, path: "/bind", body: `{"username":"ab"}`, acceptLanguage: "zh-CN", language: "zh-CN", fieldMessage: "username长度必须至少为3个字符"},
+		{name: "english validator field", method: http.MethodPost, path: "/bind", language: "en", detail: "Request validation failed", fieldMessage: "username is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := tt.body
+			if body == "" {
+				body = `{}`
+			}
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Content-Language"); got != tt.language {
+				t.Fatalf("expected Content-Language %q, got %q", tt.language, got)
+			}
+			var problem Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+			if tt.detail != "" && problem.Detail != tt.detail {
+				t.Fatalf("expected detail %q, got %q", tt.detail, problem.Detail)
+			}
+			if tt.fieldMessage != "" && (len(problem.Errors) != 1 || problem.Errors[0].Message != tt.fieldMessage) {
+				t.Fatalf("expected field message %q, got %+v", tt.fieldMessage, problem.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 优先沿用上游（如Nginx）传入的请求ID
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" || len(requestID) > 64 {
+			bytes := make([]byte, 16)
+			if _, err := rand.Read(bytes); err == nil {
+				requestID = hex.EncodeToString(bytes)
+			}
+		}
+
+		c.Set("requestID", requestID)
+		c.Header(RequestIDHeader, requestID)
+
+		c.Next()
+	}
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/service"
+)
+
+// RequireRole 需要在Auth之后使用，每次请求都从数据库读取角色，角色变更立即生效
+func RequireRole(userService service.UserService, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := userService.GetByID(c.Request.Context(), c.GetUint("userID"))
+		if err != nil {
+			c.Error(service.ErrForbidden)
+			c.Abort()
+			return
+		}
+
+		for _, role := range roles {
+			if user.Role == role {
+				c.Set("role", user.Role)
+				c.Next()
+				return
+			}
+		}
+
+		c.Error(service.ErrForbidden)
+		c.Abort()
+	}
+}
+
+// RequireOrganizationRole 需要在Auth之后使用，要求当前用户是路径参数id对应组织的成员，
+// 并且角色属于roles（roles为空时任何成员均可）；成员关系保存在上下文的membership中
+func RequireOrganizationRole(organizationService service.OrganizationService, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		organizationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.Error(service.ErrOrganizationNotFound)
+			c.Abort()
+			return
+		}
+
+		member, err := organizationService.GetMembership(uint(organizationID), c.GetUint("userID"))
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+
+		if len(roles) == 0 {
+			c.Set("membership", member)
+			c.Next()
+			return
+		}
+		for _, role := range roles {
+			if member.Role == role {
+				c.Set("membership", member)
+				c.Next()
+				return
+			}
+		}
+
+		c.Error(service.ErrForbidden)
+		c.Abort()
+	}
+}
+
+// RequireGroup 需要在Auth之后使用，管理员或有效组（包括通过子组间接所属的组）包含groups中任一组的用户可以访问；
+// 与RequireRole一样每次请求都从数据库读取，移出组后立即生效，不依赖令牌中的groups声明
+func RequireGroup(userService service.UserService, groupService service.GroupService, groups ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := userService.GetByID(c.Request.Context(), c.GetUint("userID"))
+		if err != nil {
+			c.Error(service.ErrForbidden)
+			c.Abort()
+			return
+		}
+		if user.Role == models.RoleAdmin {
+			c.Set("role", user.Role)
+			c.Next()
+			return
+		}
+
+		member, err := groupService.InAnyGroup(user.ID, groups)
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		if !member {
+			c.Error(service.ErrForbidden)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
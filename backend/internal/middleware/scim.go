@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/user-management/internal/i18n"
+	"github.com/user/user-management/internal/service"
+)
+
+const (
+	// AuthMethodSCIM 表示请求来自持有SCIM令牌的身份提供方，没有userID
+	AuthMethodSCIM = "scim"
+
+	SCIMContentType = "application/scim+json"
+	scimErrorSchema = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// SCIMError 是RFC 7644 3.12格式的错误响应，status为字符串
+type SCIMError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	SCIMType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// scimTypes 把错误码映射为SCIM规定的scimType
+var scimTypes = map[string]string{
+	service.ErrUsernameTaken.Code:     "uniqueness",
+	service.ErrEmailTaken.Code:        "uniqueness",
+	service.ErrGroupNameTaken.Code:    "uniqueness",
+	service.ErrSCIMInvalidFilter.Code: "invalidFilter",
+	service.ErrSCIMInvalidPath.Code:   "invalidPath",
+	service.ErrSCIMInvalidValue.Code:  "invalidValue",
+	"validation_failed":               "invalidValue",
+	"weak_password":                   "invalidValue",
+	service.ErrInvalidRequest.Code:    "invalidSyntax",
+}
+
+// SCIMAuth 校验身份提供方的SCIM令牌；令牌未配置时拒绝所有请求
+func SCIMAuth(token string) gin.HandlerFunc {
+	expected := sha256.Sum256([]byte(token))
+
+	return func(c *gin.Context) {
+		bearer, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		actual := sha256.Sum256([]byte(bearer))
+		if token == "" || !ok || subtle.ConstantTimeCompare(expected[:], actual[:]) != 1 {
+			c.Error(service.ErrInvalidToken)
+			c.Abort()
+			return
+		}
+
+		c.Set("authMethod", AuthMethodSCIM)
+		c.Next()
+	}
+}
+
+// SCIMErrorHandler 以SCIM错误格式输出错误，需要在全局ErrorHandler之后注册到SCIM路由组
+func SCIMErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		locale := c.GetString("locale")
+		if locale == "" {
+			locale = i18n.Negotiate(c.GetHeader("Accept-Language"))
+		}
+
+		err := c.Errors.Last().Err
+		domainErr := toDomainError(err, locale)
+		if domainErr.Kind == service.KindInternal {
+			log.Printf("Error processing request: %v", err)
+		}
+
+		status, ok := kindStatus[domainErr.Kind]
+		if !ok {
+			status = http.StatusInternalServerError
+		}
+		detail := i18n.T(locale, domainErr.Code, domainErr.Message, domainErr.Params)
+		for _, field := range localizeFields(domainErr.Fields, locale) {
+			detail += "; " + field.Message
+		}
+
+		c.Header("Content-Type", SCIMContentType)
+		c.Header("Content-Language", locale)
+		c.JSON(status, SCIMError{
+			Schemas:  []string{scimErrorSchema},
+			Status:   strconv.Itoa(status),
+			SCIMType: scimTypes[domainErr.Code],
+			Detail:   detail,
+		})
+	}
+}
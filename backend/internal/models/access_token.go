@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// 个人访问令牌可授予的权限范围；JWT会话不受scope限制
+const (
+	ScopeUsersRead    = "users:read"
+	ScopeUsersWrite   = "users:write"
+	ScopeProfileRead  = "profile:read"
+	ScopeProfileWrite = "profile:write"
+	ScopeAuditRead    = "audit:read"
+)
+
+var Scopes = []string{ScopeUsersRead, ScopeUsersWrite, ScopeProfileRead, ScopeProfileWrite, ScopeAuditRead}
+
+// PersonalAccessToken 个人访问令牌，只保存令牌的SHA-256哈希，明文只在创建时返回一次；
+// OrganizationID是创建时所在的组织，令牌只能访问该组织的用户
+type PersonalAccessToken struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	UserID         uint       `gorm:"not null;index" json:"user_id"`
+	OrganizationID uint       `gorm:"not null;default:0" json:"organization_id"`
+	Name           string     `gorm:"size:100;not null" json:"name"`
+	TokenPrefix    string     `gorm:"size:16;not null" json:"token_prefix"`
+	TokenHash      string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Scopes         string     `gorm:"size:255;not null" json:"-"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	LastUsedAt     *time.Time `json:"last_used_at"`
+	RevokedAt      *time.Time `gorm:"index" json:"-"`
+	CreatedAt      time.Time  `json:"created_at"`
+	User           User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
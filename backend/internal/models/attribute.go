@@ -0,0 +1,94 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 扩展属性的值类型
+const (
+	AttributeTypeString  = "string"
+	AttributeTypeNumber  = "number"
+	AttributeTypeBoolean = "boolean"
+	AttributeTypeDate    = "date"
+	AttributeTypeEnum    = "enum"
+)
+
+var AttributeTypes = []string{AttributeTypeString, AttributeTypeNumber, AttributeTypeBoolean, AttributeTypeDate, AttributeTypeEnum}
+
+// 扩展属性的可见性：self_editable所有能查看该用户的人可见，本人和管理员可以修改；
+// admin_only同样可见，只有管理员可以修改；private只有本人和管理员可见和修改
+const (
+	AttributeSelfEditable = "self_editable"
+	AttributeAdminOnly    = "admin_only"
+	AttributePrivate      = "private"
+)
+
+var AttributeVisibilities = []string{AttributeSelfEditable, AttributeAdminOnly, AttributePrivate}
+
+// AttributeDefinition 是管理员定义的扩展资料属性，Name是属性在User.Attributes中的键，创建后不能修改；
+// Pattern和MaxLength只用于string类型，Options是enum类型的可选值，以换行分隔
+type AttributeDefinition struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Name       string    `gorm:"size:50;not null;uniqueIndex" json:"name"`
+	Label      string    `gorm:"size:100;not null" json:"label"`
+	Type       string    `gorm:"size:20;not null" json:"type"`
+	Required   bool      `gorm:"not null;default:false" json:"required"`
+	Visibility string    `gorm:"size:20;not null;default:self_editable" json:"visibility"`
+	Pattern    string    `gorm:"size:255" json:"pattern"`
+	MaxLength  int       `gorm:"not null;default:0" json:"max_length"`
+	Options    string    `gorm:"type:text" json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// OptionList 返回enum类型的可选值
+func (d *AttributeDefinition) OptionList() []string {
+	if d.Options == "" {
+		return nil
+	}
+	return strings.Split(d.Options, "\n")
+}
+
+// ReadableBy 判断属性值对本人（self）或管理员（admin）以外的查看者是否可见
+func (d *AttributeDefinition) ReadableBy(self, admin bool) bool {
+	return self || admin || d.Visibility != AttributePrivate
+}
+
+// EditableBy 判断查看者能否修改属性值，其他普通用户不能修改任何属性
+func (d *AttributeDefinition) EditableBy(self, admin bool) bool {
+	return admin || (self && d.Visibility != AttributeAdminOnly)
+}
+
+// UserAttributes 是扩展属性的值，以JSON保存在users.attributes列
+type UserAttributes map[string]interface{}
+
+func (a UserAttributes) Value() (driver.Value, error) {
+	if len(a) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (a *UserAttributes) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported attributes value %T", value)
+	}
+	return json.Unmarshal(data, a)
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// AuditLog 审计日志，只允许追加；每条记录的Hash包含上一条记录的Hash，形成哈希链
+type AuditLog struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ActorID   *uint     `gorm:"index" json:"actor_id"`
+	TargetID  *uint     `gorm:"index" json:"target_id"`
+	Action    string    `gorm:"size:50;not null;index" json:"action"`
+	IPAddress string    `gorm:"size:45" json:"ip_address"`
+	UserAgent string    `gorm:"size:255" json:"user_agent"`
+	RequestID string    `gorm:"size:64" json:"request_id"`
+	Changes   string    `gorm:"type:text" json:"changes,omitempty"`
+	Metadata  string    `gorm:"type:text" json:"metadata,omitempty"`
+	PrevHash  string    `gorm:"size:64;not null" json:"prev_hash"`
+	Hash      string    `gorm:"size:64;not null;uniqueIndex" json:"hash"`
+	CreatedAt time.Time `gorm:"not null;index" json:"created_at"`
+}
+
+// AuditChainHead 保存哈希链的最新位置，追加时加行锁以保证链的顺序
+type AuditChainHead struct {
+	ID       uint   `gorm:"primaryKey"`
+	LastID   uint   `gorm:"not null"`
+	LastHash string `gorm:"size:64;not null"`
+}
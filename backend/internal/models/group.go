@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Group 是用户组，由身份提供方通过SCIM或管理员创建和维护成员；Version用于ETag和乐观锁
+type Group struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	Name      string         `gorm:"size:100;not null;uniqueIndex" json:"name"`
+	Version   uint           `gorm:"not null;default:1" json:"version"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// GroupMember 是组和用户的成员关系
+type GroupMember struct {
+	GroupID   uint      `gorm:"primaryKey" json:"group_id"`
+	UserID    uint      `gorm:"primaryKey;index" json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Group     Group     `gorm:"foreignKey:GroupID;constraint:OnDelete:CASCADE" json:"-"`
+	User      User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// Subgroup 表示ChildID组是ParentID组的成员，子组的成员（包括更深层子组的成员）同时属于父组
+type Subgroup struct {
+	ParentID  uint      `gorm:"primaryKey" json:"parent_id"`
+	ChildID   uint      `gorm:"primaryKey;index" json:"child_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Parent    Group     `gorm:"foreignKey:ParentID;constraint:OnDelete:CASCADE" json:"-"`
+	Child     Group     `gorm:"foreignKey:ChildID;constraint:OnDelete:CASCADE" json:"-"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// LinkedIdentity 是用户在外部身份提供方的账号，按(issuer, subject)唯一确定
+type LinkedIdentity struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	Issuer      string     `gorm:"size:255;not null;uniqueIndex:idx_linked_identities_issuer_subject" json:"issuer"`
+	Subject     string     `gorm:"size:255;not null;uniqueIndex:idx_linked_identities_issuer_subject" json:"subject"`
+	Email       string     `gorm:"size:100" json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	User        User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
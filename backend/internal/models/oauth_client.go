@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OpenID Connect支持的scope
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+var OIDCScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+
+// OAuthClient 是接入单点登录的应用；公共客户端（如SPA）没有密钥，只能依赖PKCE
+type OAuthClient struct {
+	ID               uint           `gorm:"primaryKey" json:"id"`
+	Name             string         `gorm:"size:100;not null" json:"name"`
+	ClientID         string         `gorm:"unique;not null;size:64" json:"client_id"`
+	ClientSecretHash string         `gorm:"size:64" json:"-"`
+	RedirectURIs     string         `gorm:"type:text;not null" json:"-"`
+	Scopes           string         `gorm:"size:255;not null" json:"-"`
+	Public           bool           `gorm:"not null;default:false" json:"public"`
+	CreatedBy        uint           `gorm:"not null" json:"created_by"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// OAuthConsent 记录用户已同意授予客户端的scope，再次授权时无需重复确认
+type OAuthConsent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_oauth_consents_user_client" json:"user_id"`
+	ClientID  string    `gorm:"size:64;not null;uniqueIndex:idx_oauth_consents_user_client" json:"client_id"`
+	Scopes    string    `gorm:"size:255;not null" json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	User      User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (OAuthClient) TableName() string {
+	return "oauth_clients"
+}
+
+func (OAuthConsent) TableName() string {
+	return "oauth_consents"
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 成员在组织中的角色，与全局的User.Role相互独立
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+var OrgRoles = []string{OrgRoleOwner, OrgRoleAdmin, OrgRoleMember}
+
+// Organization 是一个租户；用户名和邮箱仍是全局唯一的登录标识，用户通过成员关系加入一个或多个组织
+type Organization struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	Slug      string         `gorm:"size:64;not null;uniqueIndex" json:"slug"`
+	Name      string         `gorm:"size:100;not null" json:"name"`
+	CreatedBy uint           `gorm:"not null" json:"created_by"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// OrganizationMember 是组织和用户的成员关系
+type OrganizationMember struct {
+	OrganizationID uint         `gorm:"primaryKey" json:"organization_id"`
+	UserID         uint         `gorm:"primaryKey;index" json:"user_id"`
+	Role           string       `gorm:"size:20;not null;default:member" json:"role"`
+	CreatedAt      time.Time    `json:"created_at"`
+	Organization   Organization `gorm:"foreignKey:OrganizationID;constraint:OnDelete:CASCADE" json:"-"`
+	User           User         `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// OrganizationInvitation 邀请一个邮箱以Role加入组织，只保存邀请令牌的SHA-256哈希
+type OrganizationInvitation struct {
+	ID             uint         `gorm:"primaryKey" json:"id"`
+	OrganizationID uint         `gorm:"not null;index" json:"organization_id"`
+	Email          string       `gorm:"size:100;not null" json:"email"`
+	Role           string       `gorm:"size:20;not null" json:"role"`
+	TokenHash      string       `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	InvitedBy      uint         `gorm:"not null" json:"invited_by"`
+	ExpiresAt      time.Time    `gorm:"not null" json:"expires_at"`
+	AcceptedAt     *time.Time   `json:"accepted_at"`
+	CreatedAt      time.Time    `json:"created_at"`
+	Organization   Organization `gorm:"foreignKey:OrganizationID;constraint:OnDelete:CASCADE" json:"-"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// PasswordHistory 保存用户先前设置过的密码哈希，用于禁止重复使用最近的密码
+type PasswordHistory struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	UserID       uint      `gorm:"not null;index" json:"user_id"`
+	PasswordHash string    `gorm:"size:255;not null" json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	User         User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
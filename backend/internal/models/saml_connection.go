@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SAMLConnection 是某个组织的SAML身份提供方配置，Slug出现在SP的元数据和ACS地址中
+type SAMLConnection struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	Slug        string `gorm:"size:64;not null;uniqueIndex" json:"slug"`
+	Name        string `gorm:"size:100;not null" json:"name"`
+	IdPEntityID string `gorm:"column:idp_entity_id;size:255;not null" json:"idp_entity_id"`
+	IdPMetadata string `gorm:"column:idp_metadata;type:text;not null" json:"-"`
+	// EmailDomains 以空格分隔，只接受这些域名下的邮箱，防止身份提供方冒用其他组织的账号
+	EmailDomains      string         `gorm:"size:255;not null" json:"-"`
+	EmailAttribute    string         `gorm:"size:255" json:"email_attribute"`
+	UsernameAttribute string         `gorm:"size:255" json:"username_attribute"`
+	IsActive          bool           `gorm:"default:true" json:"is_active"`
+	CreatedBy         uint           `gorm:"not null" json:"created_by"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (SAMLConnection) TableName() string {
+	return "saml_connections"
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 服务账号可以申请的scope，服务账号没有个人资料，也不能访问管理员接口
+var ServiceAccountScopes = []string{ScopeUsersRead, ScopeUsersWrite}
+
+// ServiceAccount 是机器对机器调用使用的非人类主体，只能通过client_credentials获取令牌
+type ServiceAccount struct {
+	ID               uint           `gorm:"primaryKey" json:"id"`
+	Name             string         `gorm:"not null;size:100" json:"name"`
+	Description      string         `gorm:"size:255" json:"description"`
+	ClientID         string         `gorm:"unique;not null;size:64" json:"client_id"`
+	ClientSecretHash string         `gorm:"size:64;not null" json:"-"`
+	Scopes           string         `gorm:"size:255;not null" json:"-"`
+	IsActive         bool           `gorm:"default:true" json:"is_active"`
+	CreatedBy        uint           `gorm:"not null" json:"created_by"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
+}
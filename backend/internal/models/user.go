@@ -6,24 +6,41 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// User 的AvatarKey是头像在BlobStore中的key，缩略图的key由其派生；头像地址在上传时保存
 type User struct {
-	ID           uint           `gorm:"primaryKey" json:"id"`
-	Username     string         `gorm:"unique;not null;size:50" json:"username"`
-	Email        string         `gorm:"unique;not null;size:100" json:"email"`
-	PasswordHash string         `gorm:"not null" json:"-"`
-	IsActive     bool           `gorm:"default:true" json:"is_active"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	ID                 uint           `gorm:"primaryKey" json:"id"`
+	Username           string         `gorm:"unique;not null;size:50" json:"username"`
+	Email              string         `gorm:"unique;not null;size:100" json:"email"`
+	PasswordHash       string         `gorm:"not null" json:"-"`
+	IsActive           bool           `gorm:"default:true" json:"is_active"`
+	Role               string         `gorm:"size:20;not null;default:user" json:"role"`
+	Attributes         UserAttributes `gorm:"type:json" json:"attributes,omitempty"`
+	AvatarKey          string         `gorm:"size:255;not null;default:''" json:"-"`
+	AvatarURL          string         `gorm:"size:500;not null;default:''" json:"avatar_url,omitempty"`
+	AvatarThumbnailURL string         `gorm:"size:500;not null;default:''" json:"avatar_thumbnail_url,omitempty"`
+	Version            uint           `gorm:"not null;default:1" json:"version"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 }
 
+// RefreshToken 的SessionID标识一次登录，刷新令牌轮换时沿用；AuthTime是该次登录完成身份验证的时间；
+// OrganizationID是访问令牌所在的组织，0表示不属于任何组织
 type RefreshToken struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `gorm:"not null" json:"user_id"`
-	Token     string    `gorm:"unique;not null" json:"token"`
-	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
-	CreatedAt time.Time `json:"created_at"`
-	User      User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	UserID         uint       `gorm:"not null" json:"user_id"`
+	Token          string     `gorm:"unique;not null" json:"token"`
+	SessionID      string     `gorm:"size:32;not null;default:'';index" json:"session_id"`
+	AuthTime       *time.Time `json:"auth_time"`
+	OrganizationID uint       `gorm:"not null;default:0" json:"organization_id"`
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	User           User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
 }
 
 type UserSession struct {
@@ -34,4 +51,12 @@ type UserSession struct {
 	LastActivity time.Time `json:"last_activity"`
 	CreatedAt    time.Time `json:"created_at"`
 	User         User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
-}
\ No newline at end of file
+}
+
+// ErasureTombstone 记录已完成的个人数据擦除，擦除后仅保留该记录
+type ErasureTombstone struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"not null;uniqueIndex" json:"user_id"`
+	RequestedBy uint      `gorm:"not null" json:"requested_by"`
+	ErasedAt    time.Time `gorm:"not null" json:"erased_at"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// UserInvitation 由管理员发出，受邀者通过邀请链接设置用户名和密码完成注册；
+// 只保存邀请令牌的SHA-256哈希，OrganizationID不为空时注册后以OrganizationRole加入该组织
+type UserInvitation struct {
+	ID               uint          `gorm:"primaryKey" json:"id"`
+	Email            string        `gorm:"size:100;not null;index" json:"email"`
+	Role             string        `gorm:"size:20;not null;default:user" json:"role"`
+	OrganizationID   *uint         `gorm:"index" json:"organization_id"`
+	OrganizationRole string        `gorm:"size:20" json:"organization_role,omitempty"`
+	TokenHash        string        `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	InvitedBy        uint          `gorm:"not null" json:"invited_by"`
+	ExpiresAt        time.Time     `gorm:"not null" json:"expires_at"`
+	AcceptedAt       *time.Time    `json:"accepted_at"`
+	UserID           *uint         `json:"user_id"`
+	CreatedAt        time.Time     `json:"created_at"`
+	UpdatedAt        time.Time     `json:"updated_at"`
+	Organization     *Organization `gorm:"foreignKey:OrganizationID;constraint:OnDelete:CASCADE" json:"-"`
+}
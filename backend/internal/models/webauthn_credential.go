@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// WebAuthnCredential 是用户注册的FIDO2凭据（通行密钥或安全密钥），CredentialID由认证器生成
+type WebAuthnCredential struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	UserID          uint       `gorm:"not null;index" json:"user_id"`
+	Name            string     `gorm:"size:100;not null" json:"name"`
+	CredentialID    []byte     `gorm:"type:varbinary(255);not null;uniqueIndex" json:"-"`
+	PublicKey       []byte     `gorm:"type:blob;not null" json:"-"`
+	AttestationType string     `gorm:"size:32" json:"-"`
+	AAGUID          []byte     `gorm:"column:aaguid;type:binary(16)" json:"-"`
+	SignCount       uint32     `gorm:"not null;default:0" json:"-"`
+	Transports      string     `gorm:"size:255" json:"transports"`
+	BackupEligible  bool       `gorm:"not null;default:false" json:"backup_eligible"`
+	BackupState     bool       `gorm:"not null;default:false" json:"backup_state"`
+	LastUsedAt      *time.Time `json:"last_used_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	User            User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
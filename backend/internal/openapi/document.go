@@ -0,0 +1,242 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const Version = "3.1.0"
+
+// Document 是OpenAPI 3.1文档，通过Add注册接口，Schema由Go类型反射生成
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Paths      map[string]map[string]*opObject `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// Operation 描述一个接口，Path使用gin的路由语法（如/users/:id）
+// Request和Responses中的Body为示例值，只用于获取类型
+type Operation struct {
+	Method      string
+	Path        string
+	Summary     string
+	Description string
+	Tags        []string
+	Security    []string
+	Parameters  []Parameter
+	Request     *Body
+	Responses   []Response
+}
+
+// Body 默认为application/json；Content不为空时按媒体类型分别描述
+type Body struct {
+	Description string
+	Value       interface{}
+	Content     map[string]interface{}
+}
+
+type Response struct {
+	Status      int
+	Description string
+	ContentType string
+	Headers     []string
+	Value       interface{}
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Style       string  `json:"style,omitempty"`
+	Explode     bool    `json:"explode,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+type opObject struct {
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId"`
+	Tags        []string              `json:"tags,omitempty"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *requestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*response  `json:"responses"`
+}
+
+type requestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required"`
+	Content     map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Headers     map[string]*header   `json:"headers,omitempty"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type header struct {
+	Schema *Schema `json:"schema"`
+}
+
+type mediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+var pathParam = regexp.MustCompile(`:([A-Za-z_][A-Za-z0-9_]*)`)
+
+func New(info Info) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]map[string]*opObject),
+		Components: Components{
+			Schemas:         make(map[string]*Schema),
+			SecuritySchemes: make(map[string]*SecurityScheme),
+		},
+	}
+}
+
+func (d *Document) AddSecurityScheme(name string, scheme *SecurityScheme) {
+	d.Components.SecuritySchemes[name] = scheme
+}
+
+// SchemaOf 返回示例值类型对应的Schema，可用于构造参数等；传入*Schema时原样返回
+func (d *Document) SchemaOf(value interface{}) *Schema {
+	if schema, ok := value.(*Schema); ok {
+		return schema
+	}
+	return d.schemaFor(reflect.TypeOf(value))
+}
+
+// Add 注册接口，路径参数未显式声明时按必填字符串补齐
+func (d *Document) Add(op Operation) {
+	path := ToOpenAPIPath(op.Path)
+	method := strings.ToLower(op.Method)
+
+	object := &opObject{
+		Summary:     op.Summary,
+		Description: op.Description,
+		OperationID: operationID(method, path),
+		Tags:        op.Tags,
+		Parameters:  op.Parameters,
+		Responses:   make(map[string]*response),
+	}
+
+	for _, scheme := range op.Security {
+		object.Security = append(object.Security, map[string][]string{scheme: {}})
+	}
+
+	for _, match := range pathParam.FindAllStringSubmatch(op.Path, -1) {
+		if !hasParameter(object.Parameters, match[1], "path") {
+			object.Parameters = append(object.Parameters, Parameter{
+				Name:     match[1],
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+	}
+
+	if op.Request != nil {
+		content := op.Request.Content
+		if len(content) == 0 {
+			content = map[string]interface{}{"application/json": op.Request.Value}
+		}
+		body := &requestBody{
+			Description: op.Request.Description,
+			Required:    true,
+			Content:     make(map[string]mediaType),
+		}
+		for contentType, value := range content {
+			body.Content[contentType] = mediaType{Schema: d.SchemaOf(value)}
+		}
+		object.RequestBody = body
+	}
+
+	for _, r := range op.Responses {
+		resp := &response{Description: r.Description}
+		if resp.Description == "" {
+			resp.Description = http.StatusText(r.Status)
+		}
+		if r.Value != nil {
+			contentType := r.ContentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			resp.Content = map[string]mediaType{contentType: {Schema: d.SchemaOf(r.Value)}}
+		}
+		for _, name := range r.Headers {
+			if resp.Headers == nil {
+				resp.Headers = make(map[string]*header)
+			}
+			resp.Headers[name] = &header{Schema: &Schema{Type: "string"}}
+		}
+
+		key := "default"
+		if r.Status != 0 {
+			key = strconv.Itoa(r.Status)
+		}
+		object.Responses[key] = resp
+	}
+
+	if d.Paths[path] == nil {
+		d.Paths[path] = make(map[string]*opObject)
+	}
+	d.Paths[path][method] = object
+}
+
+// Has 判断文档中是否包含指定的接口，path使用gin的路由语法
+func (d *Document) Has(method, path string) bool {
+	_, ok := d.Paths[ToOpenAPIPath(path)][strings.ToLower(method)]
+	return ok
+}
+
+// ToOpenAPIPath 将gin的:param路径参数转换为{param}
+func ToOpenAPIPath(path string) string {
+	return pathParam.ReplaceAllString(path, "{$1}")
+}
+
+func hasParameter(parameters []Parameter, name, in string) bool {
+	for _, p := range parameters {
+		if p.Name == name && p.In == in {
+			return true
+		}
+	}
+	return false
+}
+
+// operationID 由方法和路径生成，如 GET /api/v1/users/{id} -> getApiV1UsersId
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(method)
+	for _, segment := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '-' || r == '_'
+	}) {
+		b.WriteString(strings.ToUpper(segment[:1]) + segment[1:])
+	}
+	return b.String()
+}
@@ -0,0 +1,220 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// Schema 是OpenAPI 3.1使用的JSON Schema（2020-12）子集
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 interface{}        `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	WriteOnly            bool               `json:"writeOnly,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+)
+
+// schemaFor 根据Go类型生成Schema，具名结构体注册到components并返回引用
+func (d *Document) schemaFor(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case deletedAtType:
+		return &Schema{Type: []string{"string", "null"}, Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return nullable(d.schemaFor(t.Elem()))
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		name := componentName(t)
+		if _, ok := d.Components.Schemas[name]; !ok {
+			// 先占位，避免自引用类型无限递归
+			d.Components.Schemas[name] = &Schema{}
+			*d.Components.Schemas[name] = *d.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+
+	// interface{}等无法确定的类型接受任意值
+	return &Schema{}
+}
+
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	d.addFields(schema, t)
+	return schema
+}
+
+func (d *Document) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, omitempty, skip := jsonName(field)
+		if skip {
+			continue
+		}
+
+		// 匿名嵌入的结构体与encoding/json一样展开到外层
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				d.addFields(schema, ft)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		property := d.schemaFor(field.Type)
+		if description := field.Tag.Get("doc"); description != "" {
+			if property.Ref != "" {
+				// 3.1允许$ref旁出现其他关键字
+				property = &Schema{Ref: property.Ref}
+			}
+			property.Description = description
+		}
+
+		required := applyBinding(property, field.Tag.Get("binding"))
+		if required || (!omitempty && field.Tag.Get("binding") == "" && isResponseField(field)) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+}
+
+// applyBinding 将gin的binding规则转换为Schema约束，返回字段是否必填
+func applyBinding(schema *Schema, binding string) bool {
+	if binding == "" {
+		return false
+	}
+
+	required := false
+	for _, rule := range strings.Split(binding, ",") {
+		key, param, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			required = true
+		case "email":
+			schema.Format = "email"
+		case "url":
+			schema.Format = "uri"
+		case "oneof":
+			schema.Enum = strings.Fields(param)
+		case "min", "max", "len":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			if schema.Type == "string" {
+				if key != "max" {
+					schema.MinLength = &n
+				}
+				if key != "min" {
+					schema.MaxLength = &n
+				}
+			} else if schema.Type == "array" {
+				if key != "max" {
+					schema.MinItems = &n
+				}
+				if key != "min" {
+					schema.MaxItems = &n
+				}
+			} else if schema.Type == "integer" || schema.Type == "number" {
+				f := float64(n)
+				if key != "max" {
+					schema.Minimum = &f
+				}
+				if key != "min" {
+					schema.Maximum = &f
+				}
+			}
+		}
+	}
+	return required
+}
+
+// isResponseField 没有binding规则且不带omitempty的字段总会被序列化，视为必有字段
+// 请求结构体的字段都带binding规则或omitempty，因此不受影响
+func isResponseField(field reflect.StructField) bool {
+	return field.Type.Kind() != reflect.Ptr && field.Type != deletedAtType
+}
+
+func jsonName(field reflect.StructField) (name string, omitempty bool, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, option := range parts[1:] {
+		if option == "omitempty" {
+			omitempty = true
+		}
+	}
+	return name, omitempty, false
+}
+
+// componentName 使用类型名作为组件名，未导出的类型名首字母转为大写
+func componentName(t reflect.Type) string {
+	runes := []rune(t.Name())
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+func nullable(schema *Schema) *Schema {
+	if schema.Ref != "" {
+		return &Schema{AnyOf: []*Schema{schema, {Type: "null"}}}
+	}
+	if t, ok := schema.Type.(string); ok {
+		schema.Type = []string{t, "null"}
+	}
+	return schema
+}
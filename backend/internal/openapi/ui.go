@@ -0,0 +1,41 @@
+package openapi
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"mime"
+	"path"
+)
+
+//go:embed ui.html
+var uiTemplate string
+
+// swaggerui目录是未经修改的swagger-ui-dist文件，随二进制一起发布，文档页面不依赖外部CDN。
+// 升级时替换这两个文件、NOTICE和SwaggerUIVersion
+//
+//go:embed swaggerui/swagger-ui.css swaggerui/swagger-ui-bundle.js
+var uiAssets embed.FS
+
+// SwaggerUIVersion 是内嵌的swagger-ui-dist版本
+const SwaggerUIVersion = "5.18.2"
+
+var ui = template.Must(template.New("ui").Parse(uiTemplate))
+
+// RenderUI 生成加载指定规范地址的文档页面，assetsURL是UIAsset文件的访问路径
+func RenderUI(title, specURL, assetsURL string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := ui.Execute(&buf, map[string]string{"Title": title, "SpecURL": specURL, "AssetsURL": assetsURL}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UIAsset 返回文档页面使用的静态文件及其Content-Type，文件不存在时ok为false
+func UIAsset(name string) (data []byte, contentType string, ok bool) {
+	data, err := uiAssets.ReadFile(path.Join("swaggerui", path.Clean("/"+name)))
+	if err != nil {
+		return nil, "", false
+	}
+	return data, mime.TypeByExtension(path.Ext(name)), true
+}
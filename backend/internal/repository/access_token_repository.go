@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/user/user-management/internal/models"
+	"gorm.io/gorm"
+)
+
+type AccessTokenRepository interface {
+	Create(token *models.PersonalAccessToken) error
+	GetByHash(hash string) (*models.PersonalAccessToken, error)
+	GetByID(userID, id uint) (*models.PersonalAccessToken, error)
+	ListByUser(userID uint) ([]models.PersonalAccessToken, error)
+	Revoke(id uint, revokedAt time.Time) error
+	TouchLastUsed(id uint, usedAt time.Time) error
+}
+
+type accessTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewAccessTokenRepository(db *gorm.DB) AccessTokenRepository {
+	return &accessTokenRepository{db: db}
+}
+
+func (r *accessTokenRepository) Create(token *models.PersonalAccessToken) error {
+	return r.db.Create(token).Error
+}
+
+// GetByHash 只返回未撤销的令牌
+func (r *accessTokenRepository) GetByHash(hash string) (*models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	err := r.db.Where("token_hash = ? AND revoked_at IS NULL", hash).First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &token, err
+}
+
+// GetByID 按所属用户查询，避免操作其他用户的令牌
+func (r *accessTokenRepository) GetByID(userID, id uint) (*models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	err := r.db.Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &token, err
+}
+
+func (r *accessTokenRepository) ListByUser(userID uint) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL", userID).Order("id DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *accessTokenRepository) Revoke(id uint, revokedAt time.Time) error {
+	return r.db.Model(&models.PersonalAccessToken{}).Where("id = ?", id).Update("revoked_at", revokedAt).Error
+}
+
+func (r *accessTokenRepository) TouchLastUsed(id uint, usedAt time.Time) error {
+	return r.db.Model(&models.PersonalAccessToken{}).Where("id = ?", id).Update("last_used_at", usedAt).Error
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/user/user-management/internal/models"
+	"gorm.io/gorm"
+)
+
+type AttributeRepository interface {
+	// List 返回所有扩展属性定义，按ID排序
+	List() ([]models.AttributeDefinition, error)
+	GetByID(id uint) (*models.AttributeDefinition, error)
+	GetByName(name string) (*models.AttributeDefinition, error)
+	Create(definition *models.AttributeDefinition) error
+	Update(definition *models.AttributeDefinition) error
+	// Delete 在同一事务中删除定义并从所有用户的属性值中移除该属性，被修改的用户递增版本号
+	Delete(definition *models.AttributeDefinition) error
+}
+
+type attributeRepository struct {
+	db *gorm.DB
+}
+
+func NewAttributeRepository(db *gorm.DB) AttributeRepository {
+	return &attributeRepository{db: db}
+}
+
+func (r *attributeRepository) List() ([]models.AttributeDefinition, error) {
+	var definitions []models.AttributeDefinition
+	err := r.db.Order("id").Find(&definitions).Error
+	return definitions, err
+}
+
+func (r *attributeRepository) GetByID(id uint) (*models.AttributeDefinition, error) {
+	var definition models.AttributeDefinition
+	err := r.db.First(&definition, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &definition, err
+}
+
+func (r *attributeRepository) GetByName(name string) (*models.AttributeDefinition, error) {
+	var definition models.AttributeDefinition
+	err := r.db.Where("name = ?", name).First(&definition).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &definition, err
+}
+
+func (r *attributeRepository) Create(definition *models.AttributeDefinition) error {
+	return r.db.Create(definition).Error
+}
+
+func (r *attributeRepository) Update(definition *models.AttributeDefinition) error {
+	return r.db.Model(definition).Select("*").Omit("created_at").Updates(definition).Error
+}
+
+func (r *attributeRepository) Delete(definition *models.AttributeDefinition) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(definition).Error; err != nil {
+			return err
+		}
+		path := "$." + definition.Name
+		return tx.Model(&models.User{}).Where("JSON_CONTAINS_PATH(attributes, 'one', ?)", path).
+			UpdateColumns(map[string]interface{}{
+				"attributes": gorm.Expr("JSON_REMOVE(attributes, ?)", path),
+				"version":    gorm.Expr("version + 1"),
+			}).Error
+	})
+}
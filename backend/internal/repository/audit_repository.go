@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/user/user-management/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const auditChainHeadID = 1
+
+type AuditFilter struct {
+	ActorID  *uint
+	TargetID *uint
+	Action   string
+	From     *time.Time
+	To       *time.Time
+}
+
+type AuditRepository interface {
+	Append(entry *models.AuditLog, chain func(prevHash string) string) error
+	List(filter AuditFilter, offset, limit int) ([]models.AuditLog, int64, error)
+	ListByUser(userID uint) ([]models.AuditLog, error)
+	Walk(batchSize int, fn func(entries []models.AuditLog) error) error
+	// Head 返回哈希链头，还没有任何记录时返回nil
+	Head() (*models.AuditChainHead, error)
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+// Append 锁定链头后追加记录，chain根据上一条记录的Hash计算新记录的Hash
+func (r *auditRepository) Append(entry *models.AuditLog, chain func(prevHash string) string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var head models.AuditChainHead
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&head, auditChainHeadID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			head = models.AuditChainHead{ID: auditChainHeadID}
+			// 并发初始化时由唯一主键保证只有一条链头
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&head).Error; err != nil {
+				return err
+			}
+			err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&head, auditChainHeadID).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		entry.PrevHash = head.LastHash
+		entry.Hash = chain(head.LastHash)
+		if err := tx.Create(entry).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&head).Updates(map[string]interface{}{
+			"last_id":   entry.ID,
+			"last_hash": entry.Hash,
+		}).Error
+	})
+}
+
+func (r *auditRepository) List(filter AuditFilter, offset, limit int) ([]models.AuditLog, int64, error) {
+	var entries []models.AuditLog
+	var total int64
+
+	query := r.db.Model(&models.AuditLog{})
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.TargetID != nil {
+		query = query.Where("target_id = ?", *filter.TargetID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at <= ?", *filter.To)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&entries).Error
+	return entries, total, err
+}
+
+func (r *auditRepository) ListByUser(userID uint) ([]models.AuditLog, error) {
+	var entries []models.AuditLog
+	err := r.db.Where("actor_id = ? OR target_id = ?", userID, userID).Order("id ASC").Find(&entries).Error
+	return entries, err
+}
+
+// Walk 按ID顺序分批遍历全部审计记录
+func (r *auditRepository) Walk(batchSize int, fn func(entries []models.AuditLog) error) error {
+	var entries []models.AuditLog
+	return r.db.Order("id ASC").FindInBatches(&entries, batchSize, func(tx *gorm.DB, batch int) error {
+		return fn(entries)
+	}).Error
+}
+
+func (r *auditRepository) Head() (*models.AuditChainHead, error) {
+	var head models.AuditChainHead
+	err := r.db.First(&head, auditChainHeadID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &head, nil
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 条件运算符，与SCIM过滤表达式的比较运算符一致
+const (
+	OpEqual        = "eq"
+	OpNotEqual     = "ne"
+	OpContains     = "co"
+	OpStartsWith   = "sw"
+	OpEndsWith     = "ew"
+	OpGreater      = "gt"
+	OpGreaterEqual = "ge"
+	OpLess         = "lt"
+	OpLessEqual    = "le"
+	OpPresent      = "pr"
+)
+
+// Condition 是按列比较的查询条件；Column必须由调用方从白名单映射得到，不能直接使用客户端输入。
+// JSONPath不为空时比较JSON列中该路径的值，路径作为参数绑定
+type Condition struct {
+	Column   string
+	JSONPath string
+	Operator string
+	Value    interface{}
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// whereConditions 以AND组合所有条件
+func whereConditions(query *gorm.DB, conditions []Condition) (*gorm.DB, error) {
+	for _, condition := range conditions {
+		var column interface{} = clause.Column{Name: condition.Column}
+		if condition.JSONPath != "" {
+			column = clause.Expr{SQL: "JSON_UNQUOTE(JSON_EXTRACT(?, ?))", Vars: []interface{}{column, condition.JSONPath}}
+		}
+		var expr clause.Expression
+		switch condition.Operator {
+		case OpEqual:
+			expr = clause.Eq{Column: column, Value: condition.Value}
+		case OpNotEqual:
+			expr = clause.Neq{Column: column, Value: condition.Value}
+		case OpContains:
+			expr = clause.Like{Column: column, Value: "%" + likeEscaper.Replace(fmt.Sprint(condition.Value)) + "%"}
+		case OpStartsWith:
+			expr = clause.Like{Column: column, Value: likeEscaper.Replace(fmt.Sprint(condition.Value)) + "%"}
+		case OpEndsWith:
+			expr = clause.Like{Column: column, Value: "%" + likeEscaper.Replace(fmt.Sprint(condition.Value))}
+		case OpGreater:
+			expr = clause.Gt{Column: column, Value: condition.Value}
+		case OpGreaterEqual:
+			expr = clause.Gte{Column: column, Value: condition.Value}
+		case OpLess:
+			expr = clause.Lt{Column: column, Value: condition.Value}
+		case OpLessEqual:
+			expr = clause.Lte{Column: column, Value: condition.Value}
+		case OpPresent:
+			expr = clause.Neq{Column: column, Value: nil}
+		default:
+			return nil, fmt.Errorf("unsupported operator %q", condition.Operator)
+		}
+		query = query.Where(expr)
+	}
+	return query, nil
+}
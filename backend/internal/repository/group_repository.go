@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/user/user-management/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GroupRepository 的组本身不属于任何组织；通过WithContext得到的仓库按context中的租户限定ListMembers返回的用户，
+// context没有租户时不返回任何用户
+type GroupRepository interface {
+	WithContext(ctx context.Context) GroupRepository
+	Create(group *models.Group, memberIDs []uint) error
+	GetByID(id uint) (*models.Group, error)
+	GetByName(name string) (*models.Group, error)
+	Search(conditions []Condition, offset, limit int) ([]models.Group, int64, error)
+	Update(group *models.Group, memberIDs []uint) error
+	Delete(id uint) error
+	ListMembers(groupID uint) ([]models.User, error)
+	ListByUser(userID uint) ([]models.Group, error)
+	// AddMember 和RemoveMember 增删单个直接成员，成员关系有变化时递增组的版本号
+	AddMember(groupID, userID uint) error
+	RemoveMember(groupID, userID uint) error
+	ListSubgroups(groupID uint) ([]models.Group, error)
+	// ListParents 返回直接包含groupIDs中任一组的嵌套关系，Parent已预加载
+	ListParents(groupIDs []uint) ([]models.Subgroup, error)
+	// ListChildren 返回groupIDs中各组的直接子组关系
+	ListChildren(groupIDs []uint) ([]models.Subgroup, error)
+	AddSubgroup(parentID, childID uint) error
+	RemoveSubgroup(parentID, childID uint) error
+}
+
+type groupRepository struct {
+	db *gorm.DB
+	// scope 不为nil时ListMembers只返回范围内的用户，由WithContext按context中的租户设置
+	scope func(*gorm.DB) *gorm.DB
+}
+
+func NewGroupRepository(db *gorm.DB) GroupRepository {
+	return &groupRepository{db: db}
+}
+
+func (r *groupRepository) WithContext(ctx context.Context) GroupRepository {
+	return &groupRepository{db: r.db.WithContext(ctx), scope: contextScope(ctx)}
+}
+
+// Create 在同一事务中创建组和成员关系
+func (r *groupRepository) Create(group *models.Group, memberIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(group).Error; err != nil {
+			return err
+		}
+		return createGroupMembers(tx, group.ID, memberIDs)
+	})
+}
+
+func (r *groupRepository) GetByID(id uint) (*models.Group, error) {
+	var group models.Group
+	err := r.db.First(&group, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &group, err
+}
+
+func (r *groupRepository) GetByName(name string) (*models.Group, error) {
+	var group models.Group
+	err := r.db.Where("name = ?", name).First(&group).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &group, err
+}
+
+func (r *groupRepository) Search(conditions []Condition, offset, limit int) ([]models.Group, int64, error) {
+	var groups []models.Group
+	var total int64
+
+	query, err := whereConditions(r.db.Model(&models.Group{}), conditions)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err = query.Order("id").Offset(offset).Limit(limit).Find(&groups).Error
+	return groups, total, err
+}
+
+// Update 与UserRepository.Update一样以版本号作为乐观锁，并在同一事务中把成员替换为memberIDs
+func (r *groupRepository) Update(group *models.Group, memberIDs []uint) error {
+	version := group.Version
+	group.Version = version + 1
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(group).Where("version = ?", version).Select("*").Omit("created_at").Updates(group)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+
+		if err := tx.Where("group_id = ?", group.ID).Delete(&models.GroupMember{}).Error; err != nil {
+			return err
+		}
+		return createGroupMembers(tx, group.ID, memberIDs)
+	})
+	if err != nil {
+		group.Version = version
+	}
+	return err
+}
+
+// Delete 同时删除组的成员关系和它作为父组、子组的嵌套关系
+func (r *groupRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", id).Delete(&models.GroupMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("parent_id = ? OR child_id = ?", id, id).Delete(&models.Subgroup{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Group{}, id).Error
+	})
+}
+
+func (r *groupRepository) ListMembers(groupID uint) ([]models.User, error) {
+	var users []models.User
+	query := r.db
+	if r.scope != nil {
+		query = query.Scopes(r.scope)
+	}
+	err := query.Joins("JOIN group_members ON group_members.user_id = users.id").
+		Where("group_members.group_id = ?", groupID).
+		Order("users.id").
+		Find(&users).Error
+	return users, err
+}
+
+func (r *groupRepository) ListByUser(userID uint) ([]models.Group, error) {
+	var groups []models.Group
+	err := r.db.Joins("JOIN group_members ON group_members.group_id = groups.id").
+		Where("group_members.user_id = ?", userID).
+		Order("groups.id").
+		Find(&groups).Error
+	return groups, err
+}
+
+func (r *groupRepository) AddMember(groupID, userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.GroupMember{GroupID: groupID, UserID: userID})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return incrementGroupVersion(tx, groupID)
+	})
+}
+
+func (r *groupRepository) RemoveMember(groupID, userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("group_id = ? AND user_id = ?", groupID, userID).Delete(&models.GroupMember{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return incrementGroupVersion(tx, groupID)
+	})
+}
+
+func (r *groupRepository) ListSubgroups(groupID uint) ([]models.Group, error) {
+	var groups []models.Group
+	err := r.db.Joins("JOIN subgroups ON subgroups.child_id = `groups`.id").
+		Where("subgroups.parent_id = ?", groupID).
+		Order("`groups`.id").
+		Find(&groups).Error
+	return groups, err
+}
+
+func (r *groupRepository) ListParents(groupIDs []uint) ([]models.Subgroup, error) {
+	var subgroups []models.Subgroup
+	err := r.db.Preload("Parent").Joins("JOIN `groups` ON `groups`.id = subgroups.parent_id AND `groups`.deleted_at IS NULL").
+		Where("subgroups.child_id IN ?", groupIDs).Find(&subgroups).Error
+	return subgroups, err
+}
+
+func (r *groupRepository) ListChildren(groupIDs []uint) ([]models.Subgroup, error) {
+	var subgroups []models.Subgroup
+	err := r.db.Where("parent_id IN ?", groupIDs).Find(&subgroups).Error
+	return subgroups, err
+}
+
+func (r *groupRepository) AddSubgroup(parentID, childID uint) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.Subgroup{ParentID: parentID, ChildID: childID}).Error
+}
+
+func (r *groupRepository) RemoveSubgroup(parentID, childID uint) error {
+	return r.db.Where("parent_id = ? AND child_id = ?", parentID, childID).Delete(&models.Subgroup{}).Error
+}
+
+// incrementGroupVersion 使SCIM客户端持有的ETag失效
+func incrementGroupVersion(tx *gorm.DB, groupID uint) error {
+	return tx.Model(&models.Group{}).Where("id = ?", groupID).UpdateColumn("version", gorm.Expr("version + 1")).Error
+}
+
+func createGroupMembers(tx *gorm.DB, groupID uint, memberIDs []uint) error {
+	if len(memberIDs) == 0 {
+		return nil
+	}
+	members := make([]models.GroupMember, 0, len(memberIDs))
+	for _, userID := range memberIDs {
+		members = append(members, models.GroupMember{GroupID: groupID, UserID: userID})
+	}
+	return tx.Create(&members).Error
+}
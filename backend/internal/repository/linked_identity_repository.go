@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/user/user-management/internal/models"
+	"gorm.io/gorm"
+)
+
+type LinkedIdentityRepository interface {
+	Create(identity *models.LinkedIdentity) error
+	GetBySubject(issuer, subject string) (*models.LinkedIdentity, error)
+	ListByUser(userID uint) ([]models.LinkedIdentity, error)
+	ListByIssuer(issuer string) ([]models.LinkedIdentity, error)
+	TouchLastLogin(id uint, loginAt time.Time) error
+}
+
+type linkedIdentityRepository struct {
+	db *gorm.DB
+}
+
+func NewLinkedIdentityRepository(db *gorm.DB) LinkedIdentityRepository {
+	return &linkedIdentityRepository{db: db}
+}
+
+func (r *linkedIdentityRepository) Create(identity *models.LinkedIdentity) error {
+	return r.db.Create(identity).Error
+}
+
+func (r *linkedIdentityRepository) GetBySubject(issuer, subject string) (*models.LinkedIdentity, error) {
+	var identity models.LinkedIdentity
+	err := r.db.Where("issuer = ? AND subject = ?", issuer, subject).First(&identity).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &identity, err
+}
+
+func (r *linkedIdentityRepository) ListByUser(userID uint) ([]models.LinkedIdentity, error) {
+	var identities []models.LinkedIdentity
+	err := r.db.Where("user_id = ?", userID).Order("created_at").Find(&identities).Error
+	return identities, err
+}
+
+func (r *linkedIdentityRepository) ListByIssuer(issuer string) ([]models.LinkedIdentity, error) {
+	var identities []models.LinkedIdentity
+	err := r.db.Where("issuer = ?", issuer).Order("id").Find(&identities).Error
+	return identities, err
+}
+
+func (r *linkedIdentityRepository) TouchLastLogin(id uint, loginAt time.Time) error {
+	return r.db.Model(&models.LinkedIdentity{}).Where("id = ?", id).Update("last_login_at", loginAt).Error
+}
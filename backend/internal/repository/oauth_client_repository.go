@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/user/user-management/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OAuthClientRepository interface {
+	Create(client *models.OAuthClient) error
+	GetByID(id uint) (*models.OAuthClient, error)
+	GetByClientID(clientID string) (*models.OAuthClient, error)
+	List() ([]models.OAuthClient, error)
+	Delete(client *models.OAuthClient) error
+	GetConsent(userID uint, clientID string) (*models.OAuthConsent, error)
+	SaveConsent(consent *models.OAuthConsent) error
+}
+
+type oauthClientRepository struct {
+	db *gorm.DB
+}
+
+func NewOAuthClientRepository(db *gorm.DB) OAuthClientRepository {
+	return &oauthClientRepository{db: db}
+}
+
+func (r *oauthClientRepository) Create(client *models.OAuthClient) error {
+	return r.db.Create(client).Error
+}
+
+func (r *oauthClientRepository) GetByID(id uint) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	err := r.db.First(&client, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &client, err
+}
+
+func (r *oauthClientRepository) GetByClientID(clientID string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	err := r.db.Where("client_id = ?", clientID).First(&client).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &client, err
+}
+
+func (r *oauthClientRepository) List() ([]models.OAuthClient, error) {
+	var clients []models.OAuthClient
+	err := r.db.Order("id").Find(&clients).Error
+	return clients, err
+}
+
+// Delete 删除客户端并清除用户对它的授权
+func (r *oauthClientRepository) Delete(client *models.OAuthClient) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("client_id = ?", client.ClientID).Delete(&models.OAuthConsent{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(client).Error
+	})
+}
+
+func (r *oauthClientRepository) GetConsent(userID uint, clientID string) (*models.OAuthConsent, error) {
+	var consent models.OAuthConsent
+	err := r.db.Where("user_id = ? AND client_id = ?", userID, clientID).First(&consent).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &consent, err
+}
+
+// SaveConsent 按用户和客户端插入或更新授权范围
+func (r *oauthClientRepository) SaveConsent(consent *models.OAuthConsent) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "client_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"scopes", "updated_at"}),
+	}).Create(consent).Error
+}
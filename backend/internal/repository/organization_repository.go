@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/user/user-management/internal/models"
+	"gorm.io/gorm"
+)
+
+type OrganizationRepository interface {
+	Create(org *models.Organization, owner *models.OrganizationMember) error
+	GetByID(id uint) (*models.Organization, error)
+	GetBySlug(slug string) (*models.Organization, error)
+	Delete(id uint) error
+	GetMember(organizationID, userID uint) (*models.OrganizationMember, error)
+	// ListMembers 返回组织的成员，User已预加载
+	ListMembers(organizationID uint) ([]models.OrganizationMember, error)
+	// ListByUser 返回用户的成员关系，按加入时间排序，Organization已预加载
+	ListByUser(userID uint) ([]models.OrganizationMember, error)
+	CountByRole(organizationID uint, role string) (int64, error)
+	UpdateMemberRole(organizationID, userID uint, role string) error
+	RemoveMember(organizationID, userID uint) error
+	CreateInvitation(invitation *models.OrganizationInvitation) error
+	GetInvitation(organizationID, id uint) (*models.OrganizationInvitation, error)
+	GetInvitationByHash(tokenHash string) (*models.OrganizationInvitation, error)
+	// ListPendingInvitations 返回未接受且未过期的邀请
+	ListPendingInvitations(organizationID uint) ([]models.OrganizationInvitation, error)
+	DeleteInvitation(id uint) error
+	// DeletePendingInvitations 删除发给email的未接受邀请，重新邀请时使旧链接失效
+	DeletePendingInvitations(organizationID uint, email string) error
+	// AcceptInvitation 在同一事务中标记邀请已接受并加入成员；邀请已被使用时返回ErrVersionConflict
+	AcceptInvitation(invitation *models.OrganizationInvitation, member *models.OrganizationMember) error
+}
+
+type organizationRepository struct {
+	db *gorm.DB
+}
+
+func NewOrganizationRepository(db *gorm.DB) OrganizationRepository {
+	return &organizationRepository{db: db}
+}
+
+// Create 在同一事务中创建组织和第一个owner
+func (r *organizationRepository) Create(org *models.Organization, owner *models.OrganizationMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		owner.OrganizationID = org.ID
+		return tx.Create(owner).Error
+	})
+}
+
+func (r *organizationRepository) GetByID(id uint) (*models.Organization, error) {
+	var org models.Organization
+	err := r.db.First(&org, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &org, err
+}
+
+func (r *organizationRepository) GetBySlug(slug string) (*models.Organization, error) {
+	var org models.Organization
+	err := r.db.Where("slug = ?", slug).First(&org).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &org, err
+}
+
+// Delete 软删除组织，并删除成员关系和邀请，原成员回到不属于该组织的状态
+func (r *organizationRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("organization_id = ?", id).Delete(&models.OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("organization_id = ?", id).Delete(&models.OrganizationInvitation{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Organization{}, id).Error
+	})
+}
+
+func (r *organizationRepository) GetMember(organizationID, userID uint) (*models.OrganizationMember, error) {
+	var member models.OrganizationMember
+	err := r.db.Where("organization_id = ? AND user_id = ?", organizationID, userID).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &member, err
+}
+
+func (r *organizationRepository) ListMembers(organizationID uint) ([]models.OrganizationMember, error) {
+	var members []models.OrganizationMember
+	err := r.db.Preload("User").Joins("JOIN users ON users.id = organization_members.user_id AND users.deleted_at IS NULL").
+		Where("organization_members.organization_id = ?", organizationID).Order("organization_members.created_at").Find(&members).Error
+	return members, err
+}
+
+func (r *organizationRepository) ListByUser(userID uint) ([]models.OrganizationMember, error) {
+	var members []models.OrganizationMember
+	err := r.db.Preload("Organization").Joins("JOIN organizations ON organizations.id = organization_members.organization_id AND organizations.deleted_at IS NULL").
+		Where("organization_members.user_id = ?", userID).Order("organization_members.created_at").Find(&members).Error
+	return members, err
+}
+
+func (r *organizationRepository) CountByRole(organizationID uint, role string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.OrganizationMember{}).Where("organization_id = ? AND role = ?", organizationID, role).Count(&count).Error
+	return count, err
+}
+
+func (r *organizationRepository) UpdateMemberRole(organizationID, userID uint, role string) error {
+	return r.db.Model(&models.OrganizationMember{}).Where("organization_id = ? AND user_id = ?", organizationID, userID).Update("role", role).Error
+}
+
+func (r *organizationRepository) RemoveMember(organizationID, userID uint) error {
+	return r.db.Where("organization_id = ? AND user_id = ?", organizationID, userID).Delete(&models.OrganizationMember{}).Error
+}
+
+func (r *organizationRepository) CreateInvitation(invitation *models.OrganizationInvitation) error {
+	return r.db.Create(invitation).Error
+}
+
+func (r *organizationRepository) GetInvitation(organizationID, id uint) (*models.OrganizationInvitation, error) {
+	var invitation models.OrganizationInvitation
+	err := r.db.Where("organization_id = ? AND id = ?", organizationID, id).First(&invitation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &invitation, err
+}
+
+func (r *organizationRepository) GetInvitationByHash(tokenHash string) (*models.OrganizationInvitation, error) {
+	var invitation models.OrganizationInvitation
+	err := r.db.Preload("Organization").Where("token_hash = ?", tokenHash).First(&invitation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &invitation, err
+}
+
+func (r *organizationRepository) ListPendingInvitations(organizationID uint) ([]models.OrganizationInvitation, error) {
+	var invitations []models.OrganizationInvitation
+	err := r.db.Where("organization_id = ? AND accepted_at IS NULL AND expires_at > ?", organizationID, time.Now()).
+		Order("created_at DESC").Find(&invitations).Error
+	return invitations, err
+}
+
+func (r *organizationRepository) DeleteInvitation(id uint) error {
+	return r.db.Delete(&models.OrganizationInvitation{}, id).Error
+}
+
+func (r *organizationRepository) DeletePendingInvitations(organizationID uint, email string) error {
+	return r.db.Where("organization_id = ? AND email = ? AND accepted_at IS NULL", organizationID, email).Delete(&models.OrganizationInvitation{}).Error
+}
+
+func (r *organizationRepository) AcceptInvitation(invitation *models.OrganizationInvitation, member *models.OrganizationMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(invitation).Where("accepted_at IS NULL").Update("accepted_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+		return tx.Create(member).Error
+	})
+}
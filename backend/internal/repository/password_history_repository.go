@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"github.com/user/user-management/internal/models"
+	"gorm.io/gorm"
+)
+
+type PasswordHistoryRepository interface {
+	Create(entry *models.PasswordHistory) error
+	// ListRecent 按设置时间从新到旧返回最近limit条
+	ListRecent(userID uint, limit int) ([]models.PasswordHistory, error)
+	// Prune 只保留最近keep条
+	Prune(userID uint, keep int) error
+}
+
+type passwordHistoryRepository struct {
+	db *gorm.DB
+}
+
+func NewPasswordHistoryRepository(db *gorm.DB) PasswordHistoryRepository {
+	return &passwordHistoryRepository{db: db}
+}
+
+func (r *passwordHistoryRepository) Create(entry *models.PasswordHistory) error {
+	return r.db.Create(entry).Error
+}
+
+func (r *passwordHistoryRepository) ListRecent(userID uint, limit int) ([]models.PasswordHistory, error) {
+	var entries []models.PasswordHistory
+	err := r.db.Where("user_id = ?", userID).Order("id DESC").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+func (r *passwordHistoryRepository) Prune(userID uint, keep int) error {
+	var kept []uint
+	if err := r.db.Model(&models.PasswordHistory{}).Where("user_id = ?", userID).
+		Order("id DESC").Limit(keep).Pluck("id", &kept).Error; err != nil {
+		return err
+	}
+	query := r.db.Where("user_id = ?", userID)
+	if len(kept) > 0 {
+		query = query.Where("id NOT IN ?", kept)
+	}
+	return query.Delete(&models.PasswordHistory{}).Error
+}
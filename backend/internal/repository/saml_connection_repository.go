@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/user/user-management/internal/models"
+	"gorm.io/gorm"
+)
+
+type SAMLConnectionRepository interface {
+	Create(connection *models.SAMLConnection) error
+	GetByID(id uint) (*models.SAMLConnection, error)
+	GetBySlug(slug string) (*models.SAMLConnection, error)
+	List() ([]models.SAMLConnection, error)
+	Delete(id uint) error
+}
+
+type samlConnectionRepository struct {
+	db *gorm.DB
+}
+
+func NewSAMLConnectionRepository(db *gorm.DB) SAMLConnectionRepository {
+	return &samlConnectionRepository{db: db}
+}
+
+func (r *samlConnectionRepository) Create(connection *models.SAMLConnection) error {
+	return r.db.Create(connection).Error
+}
+
+func (r *samlConnectionRepository) GetByID(id uint) (*models.SAMLConnection, error) {
+	var connection models.SAMLConnection
+	err := r.db.First(&connection, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &connection, err
+}
+
+func (r *samlConnectionRepository) GetBySlug(slug string) (*models.SAMLConnection, error) {
+	var connection models.SAMLConnection
+	err := r.db.Where("slug = ?", slug).First(&connection).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &connection, err
+}
+
+func (r *samlConnectionRepository) List() ([]models.SAMLConnection, error) {
+	var connections []models.SAMLConnection
+	err := r.db.Order("id").Find(&connections).Error
+	return connections, err
+}
+
+func (r *samlConnectionRepository) Delete(id uint) error {
+	return r.db.Delete(&models.SAMLConnection{}, id).Error
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/user/user-management/internal/models"
+	"gorm.io/gorm"
+)
+
+type ServiceAccountRepository interface {
+	Create(account *models.ServiceAccount) error
+	GetByID(id uint) (*models.ServiceAccount, error)
+	GetByClientID(clientID string) (*models.ServiceAccount, error)
+	GetByName(name string) (*models.ServiceAccount, error)
+	List() ([]models.ServiceAccount, error)
+	Delete(id uint) error
+}
+
+type serviceAccountRepository struct {
+	db *gorm.DB
+}
+
+func NewServiceAccountRepository(db *gorm.DB) ServiceAccountRepository {
+	return &serviceAccountRepository{db: db}
+}
+
+func (r *serviceAccountRepository) Create(account *models.ServiceAccount) error {
+	return r.db.Create(account).Error
+}
+
+func (r *serviceAccountRepository) GetByID(id uint) (*models.ServiceAccount, error) {
+	var account models.ServiceAccount
+	err := r.db.First(&account, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &account, err
+}
+
+func (r *serviceAccountRepository) GetByClientID(clientID string) (*models.ServiceAccount, error) {
+	var account models.ServiceAccount
+	err := r.db.Where("client_id = ?", clientID).First(&account).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &account, err
+}
+
+func (r *serviceAccountRepository) GetByName(name string) (*models.ServiceAccount, error) {
+	var account models.ServiceAccount
+	err := r.db.Where("name = ?", name).First(&account).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &account, err
+}
+
+func (r *serviceAccountRepository) List() ([]models.ServiceAccount, error) {
+	var accounts []models.ServiceAccount
+	err := r.db.Order("id").Find(&accounts).Error
+	return accounts, err
+}
+
+func (r *serviceAccountRepository) Delete(id uint) error {
+	return r.db.Delete(&models.ServiceAccount{}, id).Error
+}
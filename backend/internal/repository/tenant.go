@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/user/user-management/internal/models"
+	"gorm.io/gorm"
+)
+
+type tenantKey struct{}
+
+// tenant 是context中的租户范围，all为true表示调用方明确不限定租户
+type tenant struct {
+	organizationID uint
+	all            bool
+}
+
+// WithTenant 返回携带租户的context，organizationID为0表示不属于任何组织的用户
+func WithTenant(ctx context.Context, organizationID uint) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant{organizationID: organizationID})
+}
+
+// WithAllTenants 返回明确不限定租户的context，只用于服务账号等不属于任何组织的调用方
+func WithAllTenants(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant{all: true})
+}
+
+// contextScope 返回按ctx中的租户限定users表查询的GORM scope；
+// context没有设置租户时不匹配任何用户，遗漏租户的调用方不会看到其他组织的用户
+func contextScope(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	t, ok := ctx.Value(tenantKey{}).(tenant)
+	switch {
+	case !ok:
+		return func(db *gorm.DB) *gorm.DB {
+			return db.Where("1 = 0")
+		}
+	case t.all:
+		return func(db *gorm.DB) *gorm.DB {
+			return db
+		}
+	default:
+		return TenantScope(t.organizationID)
+	}
+}
+
+// TenantScope 是限定users表查询范围的GORM scope：组织的成员，或者organizationID为0时不属于任何组织的用户
+func TenantScope(organizationID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		members := db.Session(&gorm.Session{NewDB: true}).Model(&models.OrganizationMember{}).Select("user_id")
+		if organizationID == 0 {
+			return db.Where("users.id NOT IN (?)", members)
+		}
+		return db.Where("users.id IN (?)", members.Where("organization_id = ?", organizationID))
+	}
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/user/user-management/internal/models"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// userQuery 在DryRun模式下生成仓库查询用户的SQL，不需要数据库连接
+func userQuery(t *testing.T, repo UserRepository) string {
+	t.Helper()
+
+	r := repo.(*userRepository)
+	var users []models.User
+	return r.db.Scopes(r.tenantScope).Find(&users).Statement.SQL.String()
+}
+
+func TestUserRepositoryTenantScope(t *testing.T) {
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:password@tcp(127.0.0.1:3306)/test", SkipInitializeWithVersion: true}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := NewUserRepository(db)
+
+	const unrestricted = "SELECT * FROM `users` WHERE `users`.`deleted_at` IS NULL"
+	tests := []struct {
+		name string
+		repo UserRepository
+		want string
+	}{
+		{name: "unscoped repository", repo: base, want: unrestricted},
+		// 遗漏租户的context查不到任何用户
+		{name: "context without tenant", repo: base.WithContext(context.Background()), want: "SELECT * FROM `users` WHERE 1 = 0 AND `users`.`deleted_at` IS NULL"},
+		{name: "all tenants", repo: base.WithContext(WithAllTenants(context.Background())), want: unrestricted},
+		{name: "organization", repo: base.WithContext(WithTenant(context.Background(), 7)), want: "SELECT * FROM `users` WHERE users.id IN (SELECT `user_id` FROM `organization_members` WHERE organization_id = ?) AND `users`.`deleted_at` IS NULL"},
+		{name: "no organization", repo: base.WithContext(WithTenant(context.Background(), 0)), want: "SELECT * FROM `users` WHERE users.id NOT IN (SELECT `user_id` FROM `organization_members`) AND `users`.`deleted_at` IS NULL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if query := userQuery(t, tt.repo); query != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, query)
+			}
+		})
+	}
+}
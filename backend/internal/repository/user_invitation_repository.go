@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/user/user-management/internal/models"
+	"gorm.io/gorm"
+)
+
+type UserInvitationRepository interface {
+	Create(invitation *models.UserInvitation) error
+	GetByID(id uint) (*models.UserInvitation, error)
+	// GetByHash 按令牌哈希查找邀请，Organization已预加载
+	GetByHash(tokenHash string) (*models.UserInvitation, error)
+	// ListPending 返回未接受的邀请（包括已过期的），按创建时间倒序，Organization已预加载
+	ListPending() ([]models.UserInvitation, error)
+	// UpdateToken 更换未接受邀请的令牌和过期时间，旧链接随之失效；邀请已被接受时返回ErrVersionConflict
+	UpdateToken(id uint, tokenHash string, expiresAt time.Time) error
+	Delete(id uint) error
+	// DeletePending 删除发给email的未接受邀请，重新邀请时使旧链接失效
+	DeletePending(email string) error
+	// Accept 在同一事务中标记邀请已接受、创建用户并加入组织（member为nil时不加入）；
+	// 邀请已被使用时返回ErrVersionConflict
+	Accept(invitation *models.UserInvitation, user *models.User, member *models.OrganizationMember) error
+}
+
+type userInvitationRepository struct {
+	db *gorm.DB
+}
+
+func NewUserInvitationRepository(db *gorm.DB) UserInvitationRepository {
+	return &userInvitationRepository{db: db}
+}
+
+func (r *userInvitationRepository) Create(invitation *models.UserInvitation) error {
+	return r.db.Create(invitation).Error
+}
+
+func (r *userInvitationRepository) GetByID(id uint) (*models.UserInvitation, error) {
+	var invitation models.UserInvitation
+	err := r.db.First(&invitation, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &invitation, err
+}
+
+func (r *userInvitationRepository) GetByHash(tokenHash string) (*models.UserInvitation, error) {
+	var invitation models.UserInvitation
+	err := r.db.Preload("Organization").Where("token_hash = ?", tokenHash).First(&invitation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &invitation, err
+}
+
+func (r *userInvitationRepository) ListPending() ([]models.UserInvitation, error) {
+	var invitations []models.UserInvitation
+	err := r.db.Preload("Organization").Where("accepted_at IS NULL").Order("created_at DESC").Find(&invitations).Error
+	return invitations, err
+}
+
+func (r *userInvitationRepository) UpdateToken(id uint, tokenHash string, expiresAt time.Time) error {
+	result := r.db.Model(&models.UserInvitation{}).Where("id = ? AND accepted_at IS NULL", id).
+		Updates(map[string]interface{}{"token_hash": tokenHash, "expires_at": expiresAt})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+func (r *userInvitationRepository) Delete(id uint) error {
+	return r.db.Delete(&models.UserInvitation{}, id).Error
+}
+
+func (r *userInvitationRepository) DeletePending(email string) error {
+	return r.db.Where("email = ? AND accepted_at IS NULL", email).Delete(&models.UserInvitation{}).Error
+}
+
+func (r *userInvitationRepository) Accept(invitation *models.UserInvitation, user *models.User, member *models.OrganizationMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		result := tx.Model(invitation).Where("accepted_at IS NULL").
+			Updates(map[string]interface{}{"accepted_at": now, "user_id": user.ID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+		if member == nil {
+			return nil
+		}
+		member.UserID = user.ID
+		return tx.Create(member).Error
+	})
+}
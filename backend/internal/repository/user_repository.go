@@ -1,41 +1,71 @@
 package repository
 
 import (
+	"context"
 	"errors"
 
 	"github.com/user/user-management/internal/models"
 	"gorm.io/gorm"
 )
 
+// ErrVersionConflict 表示记录在读取之后已被其他请求修改
+var ErrVersionConflict = errors.New("version conflict")
+
+// UserRepository 的GetByEmail和GetByUsername用于登录和唯一性检查，始终在全部用户中查找。
+// NewUserRepository返回的仓库不限定租户，供认证、令牌等按凭据或当前用户查找的流程使用；
+// 处理用户管理请求时通过WithContext得到按context中的租户限定的仓库，context没有租户时查不到任何用户
 type UserRepository interface {
+	WithContext(ctx context.Context) UserRepository
 	Create(user *models.User) error
 	GetByID(id uint) (*models.User, error)
 	GetByEmail(email string) (*models.User, error)
 	GetByUsername(username string) (*models.User, error)
 	Update(user *models.User) error
+	UpdatePasswordHash(id uint, oldHash, newHash string) error
 	Delete(id uint) error
 	List(offset, limit int) ([]models.User, int64, error)
+	Search(conditions []Condition, offset, limit int) ([]models.User, int64, error)
 	SaveRefreshToken(token *models.RefreshToken) error
 	GetRefreshToken(token string) (*models.RefreshToken, error)
 	DeleteRefreshToken(token string) error
 	DeleteUserRefreshTokens(userID uint) error
+	// DeleteOtherRefreshTokens 删除用户不属于sessionID会话的刷新令牌
+	DeleteOtherRefreshTokens(userID uint, sessionID string) error
+	ListRefreshTokens(userID uint) ([]models.RefreshToken, error)
+	CreateLoginRecord(session *models.UserSession) error
+	ListLoginRecords(userID uint) ([]models.UserSession, error)
+	Erase(user *models.User, tombstone *models.ErasureTombstone) error
 }
 
 type userRepository struct {
 	db *gorm.DB
+	// scope 不为nil时只能查询和修改范围内的用户，由WithContext按context中的租户设置
+	scope func(*gorm.DB) *gorm.DB
 }
 
 func NewUserRepository(db *gorm.DB) UserRepository {
 	return &userRepository{db: db}
 }
 
+func (r *userRepository) WithContext(ctx context.Context) UserRepository {
+	return &userRepository{db: r.db.WithContext(ctx), scope: contextScope(ctx)}
+}
+
+// tenantScope 应用r.scope，不限定租户的仓库不改变查询
+func (r *userRepository) tenantScope(db *gorm.DB) *gorm.DB {
+	if r.scope == nil {
+		return db
+	}
+	return r.scope(db)
+}
+
 func (r *userRepository) Create(user *models.User) error {
 	return r.db.Create(user).Error
 }
 
 func (r *userRepository) GetByID(id uint) (*models.User, error) {
 	var user models.User
-	err := r.db.First(&user, id).Error
+	err := r.db.Scopes(r.tenantScope).First(&user, id).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, nil
 	}
@@ -60,24 +90,67 @@ func (r *userRepository) GetByUsername(username string) (*models.User, error) {
 	return &user, err
 }
 
+// Update 以读取时的版本号作为条件更新，并递增版本号（乐观锁）
 func (r *userRepository) Update(user *models.User) error {
-	return r.db.Save(user).Error
+	version := user.Version
+	user.Version = version + 1
+
+	result := r.db.Model(user).Scopes(r.tenantScope).Where("version = ?", version).Select("*").Omit("created_at").Updates(user)
+	if result.Error != nil {
+		user.Version = version
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		user.Version = version
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// UpdatePasswordHash 只替换密码哈希，不递增版本号：同一密码重新生成哈希不改变用户的表示。
+// 以oldHash作为条件，哈希已被其他请求修改（例如修改了密码）时返回ErrVersionConflict
+func (r *userRepository) UpdatePasswordHash(id uint, oldHash, newHash string) error {
+	result := r.db.Model(&models.User{}).Scopes(r.tenantScope).Where("id = ? AND password_hash = ?", id, oldHash).UpdateColumn("password_hash", newHash)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	return nil
 }
 
 func (r *userRepository) Delete(id uint) error {
-	return r.db.Delete(&models.User{}, id).Error
+	return r.db.Scopes(r.tenantScope).Delete(&models.User{}, id).Error
 }
 
 func (r *userRepository) List(offset, limit int) ([]models.User, int64, error) {
 	var users []models.User
 	var total int64
 
-	err := r.db.Model(&models.User{}).Count(&total).Error
+	err := r.db.Model(&models.User{}).Scopes(r.tenantScope).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = r.db.Scopes(r.tenantScope).Offset(offset).Limit(limit).Find(&users).Error
+	return users, total, err
+}
+
+// Search 按条件分页查询用户，按ID排序保证分页稳定
+func (r *userRepository) Search(conditions []Condition, offset, limit int) ([]models.User, int64, error) {
+	var users []models.User
+	var total int64
+
+	query, err := whereConditions(r.db.Model(&models.User{}).Scopes(r.tenantScope), conditions)
 	if err != nil {
 		return nil, 0, err
 	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
 
-	err = r.db.Offset(offset).Limit(limit).Find(&users).Error
+	err = query.Order("id").Offset(offset).Limit(limit).Find(&users).Error
 	return users, total, err
 }
 
@@ -100,4 +173,64 @@ func (r *userRepository) DeleteRefreshToken(token string) error {
 
 func (r *userRepository) DeleteUserRefreshTokens(userID uint) error {
 	return r.db.Where("user_id = ?", userID).Delete(&models.RefreshToken{}).Error
-}
\ No newline at end of file
+}
+
+func (r *userRepository) DeleteOtherRefreshTokens(userID uint, sessionID string) error {
+	return r.db.Where("user_id = ? AND session_id <> ?", userID, sessionID).Delete(&models.RefreshToken{}).Error
+}
+
+func (r *userRepository) ListRefreshTokens(userID uint) ([]models.RefreshToken, error) {
+	var tokens []models.RefreshToken
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *userRepository) CreateLoginRecord(session *models.UserSession) error {
+	return r.db.Create(session).Error
+}
+
+func (r *userRepository) ListLoginRecords(userID uint) ([]models.UserSession, error) {
+	var sessions []models.UserSession
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// Erase 在同一事务中匿名化用户记录、删除关联数据并写入擦除记录
+func (r *userRepository) Erase(user *models.User, tombstone *models.ErasureTombstone) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.RefreshToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserSession{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.PersonalAccessToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.OAuthConsent{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.LinkedIdentity{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.WebAuthnCredential{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.PasswordHistory{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.GroupMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(user).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(user).Error; err != nil {
+			return err
+		}
+		return tx.Create(tombstone).Error
+	})
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/user/user-management/internal/models"
+	"gorm.io/gorm"
+)
+
+type WebAuthnCredentialRepository interface {
+	Create(credential *models.WebAuthnCredential) error
+	GetByID(userID, id uint) (*models.WebAuthnCredential, error)
+	ListByUser(userID uint) ([]models.WebAuthnCredential, error)
+	CountByUser(userID uint) (int64, error)
+	UpdateSignCount(id uint, signCount uint32, backupState bool, usedAt time.Time) error
+	Delete(id uint) error
+}
+
+type webAuthnCredentialRepository struct {
+	db *gorm.DB
+}
+
+func NewWebAuthnCredentialRepository(db *gorm.DB) WebAuthnCredentialRepository {
+	return &webAuthnCredentialRepository{db: db}
+}
+
+func (r *webAuthnCredentialRepository) Create(credential *models.WebAuthnCredential) error {
+	return r.db.Create(credential).Error
+}
+
+// GetByID 按所属用户查询，避免操作其他用户的凭据
+func (r *webAuthnCredentialRepository) GetByID(userID, id uint) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&credential).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &credential, err
+}
+
+func (r *webAuthnCredentialRepository) ListByUser(userID uint) ([]models.WebAuthnCredential, error) {
+	var credentials []models.WebAuthnCredential
+	err := r.db.Where("user_id = ?", userID).Order("id").Find(&credentials).Error
+	return credentials, err
+}
+
+func (r *webAuthnCredentialRepository) CountByUser(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.WebAuthnCredential{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// UpdateSignCount 在每次成功断言后保存认证器的签名计数和备份状态
+func (r *webAuthnCredentialRepository) UpdateSignCount(id uint, signCount uint32, backupState bool, usedAt time.Time) error {
+	return r.db.Model(&models.WebAuthnCredential{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"sign_count":   signCount,
+		"backup_state": backupState,
+		"last_used_at": usedAt,
+	}).Error
+}
+
+func (r *webAuthnCredentialRepository) Delete(id uint) error {
+	return r.db.Delete(&models.WebAuthnCredential{}, id).Error
+}
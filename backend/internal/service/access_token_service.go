@@ -0,0 +1,152 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+// AccessTokenPrefix 是个人访问令牌的固定前缀，便于识别和密钥扫描
+const AccessTokenPrefix = "ump_"
+
+// lastUsedInterval 内重复使用令牌不更新last_used_at，减少写入
+const lastUsedInterval = time.Minute
+
+type AccessTokenService interface {
+	// Create 创建只能访问organizationID组织的令牌，organizationID为0表示不属于任何组织
+	Create(userID, organizationID uint, name string, scopes []string, expiresAt *time.Time) (*models.PersonalAccessToken, string, error)
+	List(userID uint) ([]models.PersonalAccessToken, error)
+	Revoke(userID, id uint) (*models.PersonalAccessToken, error)
+	Authenticate(secret string) (*models.PersonalAccessToken, error)
+}
+
+type accessTokenService struct {
+	tokenRepo repository.AccessTokenRepository
+	userRepo  repository.UserRepository
+	orgRepo   repository.OrganizationRepository
+}
+
+func NewAccessTokenService(tokenRepo repository.AccessTokenRepository, userRepo repository.UserRepository, orgRepo repository.OrganizationRepository) AccessTokenService {
+	return &accessTokenService{
+		tokenRepo: tokenRepo,
+		userRepo:  userRepo,
+		orgRepo:   orgRepo,
+	}
+}
+
+func (s *accessTokenService) Create(userID, organizationID uint, name string, scopes []string, expiresAt *time.Time) (*models.PersonalAccessToken, string, error) {
+	for _, scope := range scopes {
+		if !containsScope(models.Scopes, scope) {
+			return nil, "", invalidScope(scope, models.Scopes)
+		}
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", ErrInvalidTokenExpiry
+	}
+
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return nil, "", err
+	}
+	secret := AccessTokenPrefix + hex.EncodeToString(bytes)
+
+	token := &models.PersonalAccessToken{
+		UserID:         userID,
+		OrganizationID: organizationID,
+		Name:           name,
+		TokenPrefix:    secret[:len(AccessTokenPrefix)+8],
+		TokenHash:      hashAccessToken(secret),
+		Scopes:         strings.Join(scopes, " "),
+		ExpiresAt:      expiresAt,
+	}
+	if err := s.tokenRepo.Create(token); err != nil {
+		return nil, "", err
+	}
+
+	return token, secret, nil
+}
+
+func (s *accessTokenService) List(userID uint) ([]models.PersonalAccessToken, error) {
+	return s.tokenRepo.ListByUser(userID)
+}
+
+func (s *accessTokenService) Revoke(userID, id uint) (*models.PersonalAccessToken, error) {
+	token, err := s.tokenRepo.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, ErrAccessTokenNotFound
+	}
+
+	if err := s.tokenRepo.Revoke(token.ID, time.Now()); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// Authenticate 校验令牌是否存在、未过期，所属用户仍处于启用状态且仍是令牌所在组织的成员
+func (s *accessTokenService) Authenticate(secret string) (*models.PersonalAccessToken, error) {
+	if !strings.HasPrefix(secret, AccessTokenPrefix) {
+		return nil, ErrInvalidToken
+	}
+
+	token, err := s.tokenRepo.GetByHash(hashAccessToken(secret))
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, ErrInvalidToken
+	}
+
+	now := time.Now()
+	if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.userRepo.GetByID(token.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.IsActive {
+		return nil, ErrInvalidToken
+	}
+	if _, err := organizationRole(s.orgRepo, token.UserID, token.OrganizationID); err != nil {
+		return nil, err
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > lastUsedInterval {
+		// 更新使用时间失败不影响本次认证
+		if err := s.tokenRepo.TouchLastUsed(token.ID, now); err != nil {
+			log.Printf("Failed to update access token %d last used time: %v", token.ID, err)
+		}
+		token.LastUsedAt = &now
+	}
+
+	return token, nil
+}
+
+// TokenScopes 返回令牌授予的scope列表
+func TokenScopes(token *models.PersonalAccessToken) []string {
+	return strings.Fields(token.Scopes)
+}
+
+func hashAccessToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+// memoryAccessTokenRepository 与数据库实现一样只按哈希查找未撤销的令牌
+type memoryAccessTokenRepository struct {
+	repository.AccessTokenRepository
+	tokens []*models.PersonalAccessToken
+}
+
+func (r *memoryAccessTokenRepository) Create(token *models.PersonalAccessToken) error {
+	token.ID = uint(len(r.tokens) + 1)
+	r.tokens = append(r.tokens, token)
+	return nil
+}
+
+func (r *memoryAccessTokenRepository) GetByHash(hash string) (*models.PersonalAccessToken, error) {
+	for _, token := range r.tokens {
+		if token.TokenHash == hash && token.RevokedAt == nil {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryAccessTokenRepository) GetByID(userID, id uint) (*models.PersonalAccessToken, error) {
+	for _, token := range r.tokens {
+		if token.ID == id && token.UserID == userID && token.RevokedAt == nil {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryAccessTokenRepository) ListByUser(userID uint) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			tokens = append(tokens, *token)
+		}
+	}
+	return tokens, nil
+}
+
+func (r *memoryAccessTokenRepository) Revoke(id uint, revokedAt time.Time) error {
+	r.tokens[id-1].RevokedAt = &revokedAt
+	return nil
+}
+
+func (r *memoryAccessTokenRepository) TouchLastUsed(id uint, usedAt time.Time) error {
+	r.tokens[id-1].LastUsedAt = &usedAt
+	return nil
+}
+
+func TestAccessTokens(t *testing.T) {
+	users := &memoryUserRepository{}
+	alice := &models.User{Username: "alice", Email: "alice@example.com", IsActive: true, Role: models.RoleUser}
+	users.Create(alice)
+	tokens := &memoryAccessTokenRepository{}
+	svc := NewAccessTokenService(tokens, users, &memoryOrganizationRepository{})
+
+	if _, _, err := svc.Create(alice.ID, 0, "ci", []string{models.ScopeUsersRead, "users:everything"}, nil); ErrorCode(err) != "invalid_scope" {
+		t.Fatalf("expected unknown scope to be rejected, got %v", err)
+	}
+	past := time.Now().Add(-time.Minute)
+	if _, _, err := svc.Create(alice.ID, 0, "ci", nil, &past); !errors.Is(err, ErrInvalidTokenExpiry) {
+		t.Fatalf("expected past expiry to be rejected, got %v", err)
+	}
+
+	token, secret, err := svc.Create(alice.ID, 0, "ci", []string{models.ScopeUsersRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 只保存哈希和用于识别的前缀，不保存明文
+	if !strings.HasPrefix(secret, AccessTokenPrefix) || !strings.HasPrefix(secret, token.TokenPrefix) {
+		t.Fatalf("unexpected secret %q for prefix %q", secret, token.TokenPrefix)
+	}
+	if token.TokenHash != hashAccessToken(secret) || strings.Contains(token.TokenHash, secret[len(AccessTokenPrefix):]) {
+		t.Fatal("expected only the token hash to be stored")
+	}
+
+	authenticated, err := svc.Authenticate(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authenticated.ID != token.ID || authenticated.LastUsedAt == nil {
+		t.Fatalf("expected token %d to authenticate and record its use, got %+v", token.ID, authenticated)
+	}
+	if scopes := TokenScopes(authenticated); len(scopes) != 1 || scopes[0] != models.ScopeUsersRead {
+		t.Fatalf("unexpected scopes %v", scopes)
+	}
+
+	for _, candidate := range []string{
+		strings.TrimPrefix(secret, AccessTokenPrefix),
+		"ghp_" + strings.TrimPrefix(secret, AccessTokenPrefix),
+		secret + "0",
+	} {
+		if _, err := svc.Authenticate(candidate); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("expected %q to be rejected, got %v", candidate, err)
+		}
+	}
+
+	// 过期的令牌
+	future := time.Now().Add(time.Hour)
+	expiring, expiringSecret, err := svc.Create(alice.ID, 0, "short", nil, &future)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := time.Now().Add(-time.Second)
+	tokens.tokens[expiring.ID-1].ExpiresAt = &expired
+	if _, err := svc.Authenticate(expiringSecret); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected expired token to be rejected, got %v", err)
+	}
+
+	// 停用用户后令牌失效
+	alice.IsActive = false
+	if _, err := svc.Authenticate(secret); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected token of inactive user to be rejected, got %v", err)
+	}
+	alice.IsActive = true
+
+	// 只能撤销自己的令牌，撤销后不能再使用
+	if _, err := svc.Revoke(alice.ID+1, token.ID); !errors.Is(err, ErrAccessTokenNotFound) {
+		t.Fatalf("expected other users to be unable to revoke the token, got %v", err)
+	}
+	if _, err := svc.Revoke(alice.ID, token.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Authenticate(secret); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected revoked token to be rejected, got %v", err)
+	}
+	if _, err := svc.Revoke(alice.ID, token.ID); !errors.Is(err, ErrAccessTokenNotFound) {
+		t.Fatalf("expected revoking twice to report not found, got %v", err)
+	}
+}
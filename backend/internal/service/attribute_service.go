@@ -0,0 +1,288 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+var attributeNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// AttributeService 管理扩展资料属性的定义，并按可见性限定查看和修改属性值；
+// 属性值的校验在UserService.UpdateUser中进行
+type AttributeService interface {
+	List() ([]models.AttributeDefinition, error)
+	Create(definition *models.AttributeDefinition) error
+	// Update 以changes替换定义中除Name和Type以外的字段，已保存的属性值不重新校验
+	Update(id uint, changes *models.AttributeDefinition) (*models.AttributeDefinition, error)
+	// Delete 删除定义并移除所有用户的该属性值
+	Delete(id uint) (*models.AttributeDefinition, error)
+	// Redact 从users中移除viewerID对应的查看者不可见的属性值；admin表示查看者是管理员或服务账号
+	Redact(viewerID uint, admin bool, users ...*models.User) error
+	// CheckEditable 检查查看者能否修改changes中的属性，未定义的属性由UpdateUser报告
+	CheckEditable(changes map[string]interface{}, self, admin bool) error
+}
+
+type attributeService struct {
+	attributeRepo repository.AttributeRepository
+}
+
+func NewAttributeService(attributeRepo repository.AttributeRepository) AttributeService {
+	return &attributeService{attributeRepo: attributeRepo}
+}
+
+func (s *attributeService) List() ([]models.AttributeDefinition, error) {
+	return s.attributeRepo.List()
+}
+
+func (s *attributeService) Create(definition *models.AttributeDefinition) error {
+	if !attributeNamePattern.MatchString(definition.Name) {
+		return ErrInvalidAttributeName
+	}
+	if !containsString(models.AttributeTypes, definition.Type) {
+		return ErrInvalidAttributeType
+	}
+	if err := normalizeDefinition(definition); err != nil {
+		return err
+	}
+
+	existing, err := s.attributeRepo.GetByName(definition.Name)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return ErrAttributeNameTaken
+	}
+	return s.attributeRepo.Create(definition)
+}
+
+func (s *attributeService) Update(id uint, changes *models.AttributeDefinition) (*models.AttributeDefinition, error) {
+	definition, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	definition.Label = changes.Label
+	definition.Required = changes.Required
+	definition.Visibility = changes.Visibility
+	definition.Pattern = changes.Pattern
+	definition.MaxLength = changes.MaxLength
+	definition.Options = changes.Options
+	if err := normalizeDefinition(definition); err != nil {
+		return nil, err
+	}
+	if err := s.attributeRepo.Update(definition); err != nil {
+		return nil, err
+	}
+	return definition, nil
+}
+
+func (s *attributeService) Delete(id uint) (*models.AttributeDefinition, error) {
+	definition, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attributeRepo.Delete(definition); err != nil {
+		return nil, err
+	}
+	return definition, nil
+}
+
+func (s *attributeService) Redact(viewerID uint, admin bool, users ...*models.User) error {
+	if admin {
+		return nil
+	}
+	definitions, err := s.attributeRepo.List()
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		self := user.ID == viewerID
+		for _, definition := range definitions {
+			if !definition.ReadableBy(self, admin) {
+				delete(user.Attributes, definition.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *attributeService) CheckEditable(changes map[string]interface{}, self, admin bool) error {
+	if len(changes) == 0 || admin {
+		return nil
+	}
+	definitions, err := s.attributeRepo.List()
+	if err != nil {
+		return err
+	}
+
+	for _, definition := range definitions {
+		if _, ok := changes[definition.Name]; ok && !definition.EditableBy(self, admin) {
+			return attributeNotEditable(definition.Name)
+		}
+	}
+	return nil
+}
+
+func (s *attributeService) get(id uint) (*models.AttributeDefinition, error) {
+	definition, err := s.attributeRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if definition == nil {
+		return nil, ErrAttributeNotFound
+	}
+	return definition, nil
+}
+
+// normalizeDefinition 校验可修改的字段并清除与类型无关的约束，enum的可选值去除空白和重复
+func normalizeDefinition(definition *models.AttributeDefinition) error {
+	definition.Label = strings.TrimSpace(definition.Label)
+	if definition.Visibility == "" {
+		definition.Visibility = models.AttributeSelfEditable
+	}
+	if !containsString(models.AttributeVisibilities, definition.Visibility) {
+		return ErrInvalidVisibility
+	}
+
+	if definition.Type != models.AttributeTypeString {
+		definition.Pattern = ""
+		definition.MaxLength = 0
+	}
+	if definition.MaxLength < 0 {
+		definition.MaxLength = 0
+	}
+	if definition.Pattern != "" {
+		if _, err := regexp.Compile(definition.Pattern); err != nil {
+			return ErrInvalidAttributePattern
+		}
+	}
+
+	if definition.Type != models.AttributeTypeEnum {
+		definition.Options = ""
+		return nil
+	}
+	var options []string
+	for _, option := range strings.Split(definition.Options, "\n") {
+		if option = strings.TrimSpace(option); option != "" && !containsString(options, option) {
+			options = append(options, option)
+		}
+	}
+	if len(options) == 0 {
+		return ErrAttributeOptionsRequired
+	}
+	definition.Options = strings.Join(options, "\n")
+	return nil
+}
+
+// applyAttributes 把changes合并到current并按定义校验，值为nil或空字符串时移除该属性；
+// 合并后所有必填属性都必须有值。所有失败的属性一起报告
+func applyAttributes(definitions []models.AttributeDefinition, current models.UserAttributes, changes map[string]interface{}) (models.UserAttributes, error) {
+	byName := make(map[string]*models.AttributeDefinition, len(definitions))
+	for i := range definitions {
+		byName[definitions[i].Name] = &definitions[i]
+	}
+
+	merged := make(models.UserAttributes, len(current)+len(changes))
+	for name, value := range current {
+		merged[name] = value
+	}
+
+	var fields []FieldError
+	for name, value := range changes {
+		field := "attributes." + name
+		definition, ok := byName[name]
+		if !ok {
+			fields = append(fields, FieldError{Field: field, Code: "unknown_attribute", Message: field + " is not a defined attribute"})
+			continue
+		}
+		if value == nil || value == "" {
+			delete(merged, name)
+			continue
+		}
+		normalized, fieldErr := checkAttributeValue(definition, field, value)
+		if fieldErr != nil {
+			fields = append(fields, *fieldErr)
+			continue
+		}
+		merged[name] = normalized
+	}
+
+	for _, definition := range definitions {
+		if _, ok := merged[definition.Name]; definition.Required && !ok {
+			field := "attributes." + definition.Name
+			fields = append(fields, FieldError{Field: field, Code: "required", Message: field + " is required"})
+		}
+	}
+	if len(fields) > 0 {
+		return nil, NewValidationError(fields...)
+	}
+	if len(merged) == 0 {
+		return nil, nil
+	}
+	return merged, nil
+}
+
+// checkAttributeValue 校验单个属性值，数值统一保存为float64
+func checkAttributeValue(definition *models.AttributeDefinition, field string, value interface{}) (interface{}, *FieldError) {
+	typeError := &FieldError{Field: field, Code: "type", Message: field + " must be of type " + definition.Type, Param: definition.Type}
+
+	switch definition.Type {
+	case models.AttributeTypeNumber:
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case int:
+			return float64(v), nil
+		}
+		return nil, typeError
+	case models.AttributeTypeBoolean:
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+		return nil, typeError
+	}
+
+	text, ok := value.(string)
+	if !ok {
+		return nil, typeError
+	}
+	switch definition.Type {
+	case models.AttributeTypeDate:
+		if _, err := time.Parse("2006-01-02", text); err != nil {
+			return nil, &FieldError{Field: field, Code: "date", Message: field + " must be a date in YYYY-MM-DD format"}
+		}
+	case models.AttributeTypeEnum:
+		options := definition.OptionList()
+		if !containsString(options, text) {
+			param := strings.Join(options, ", ")
+			return nil, &FieldError{Field: field, Code: "oneof", Message: field + " must be one of: " + param, Param: param}
+		}
+	default:
+		if definition.MaxLength > 0 && utf8.RuneCountInString(text) > definition.MaxLength {
+			param := strconv.Itoa(definition.MaxLength)
+			return nil, &FieldError{Field: field, Code: "max", Message: fmt.Sprintf("%s must be at most %s characters", field, param), Param: param}
+		}
+		// 定义中的模式须匹配整个值
+		if definition.Pattern != "" && !regexp.MustCompile(`^(?:`+definition.Pattern+`)$`).MatchString(text) {
+			return nil, &FieldError{Field: field, Code: "pattern", Message: field + " must match " + definition.Pattern, Param: definition.Pattern}
+		}
+	}
+	return text, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+type memoryAttributeRepository struct {
+	repository.AttributeRepository
+	definitions []models.AttributeDefinition
+}
+
+func (r *memoryAttributeRepository) List() ([]models.AttributeDefinition, error) {
+	return append([]models.AttributeDefinition(nil), r.definitions...), nil
+}
+
+func (r *memoryAttributeRepository) GetByName(name string) (*models.AttributeDefinition, error) {
+	for _, definition := range r.definitions {
+		if definition.Name == name {
+			return &definition, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryAttributeRepository) Create(definition *models.AttributeDefinition) error {
+	definition.ID = uint(len(r.definitions) + 1)
+	r.definitions = append(r.definitions, *definition)
+	return nil
+}
+
+func TestUserAttributes(t *testing.T) {
+	attributes := &memoryAttributeRepository{}
+	svc := NewAttributeService(attributes)
+
+	if err := svc.Create(&models.AttributeDefinition{Name: "Department", Label: "Department", Type: models.AttributeTypeString}); !errors.Is(err, ErrInvalidAttributeName) {
+		t.Fatalf("expected invalid name to be rejected, got %v", err)
+	}
+	if err := svc.Create(&models.AttributeDefinition{Name: "locale", Label: "Locale", Type: models.AttributeTypeEnum, Options: " \n"}); !errors.Is(err, ErrAttributeOptionsRequired) {
+		t.Fatalf("expected enum without options to be rejected, got %v", err)
+	}
+	for _, definition := range []*models.AttributeDefinition{
+		{Name: "department", Label: "Department", Type: models.AttributeTypeString, Required: true, MaxLength: 10, Pattern: "[a-z]+"},
+		{Name: "employee_no", Label: "Employee number", Type: models.AttributeTypeNumber, Visibility: models.AttributeAdminOnly},
+		{Name: "phone", Label: "Phone", Type: models.AttributeTypeString, Visibility: models.AttributePrivate},
+		{Name: "locale", Label: "Locale", Type: models.AttributeTypeEnum, Options: "en\nzh-CN\nen"},
+		{Name: "birthday", Label: "Birthday", Type: models.AttributeTypeDate},
+	} {
+		if err := svc.Create(definition); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := svc.Create(&models.AttributeDefinition{Name: "phone", Label: "Phone", Type: models.AttributeTypeString}); !errors.Is(err, ErrAttributeNameTaken) {
+		t.Fatalf("expected duplicate name to be rejected, got %v", err)
+	}
+
+	users := &memoryUserRepository{}
+	alice := &models.User{Username: "alice", Email: "alice@example.com", IsActive: true, Role: models.RoleUser}
+	users.Create(alice)
+	userService := NewUserService(users, attributes, newTestPasswordPolicy(t))
+	ctx := repository.WithAllTenants(context.Background())
+
+	// 所有失败的属性一起报告，缺少必填属性同样报告
+	_, err := userService.UpdateUser(ctx, alice.ID, map[string]interface{}{"attributes": map[string]interface{}{
+		"employee_no": "42",
+		"locale":      "fr",
+		"birthday":    "1990-13-01",
+		"nickname":    "al",
+	}}, 0)
+	domainErr, ok := AsError(err)
+	if !ok || domainErr.Kind != KindValidation {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	codes := make(map[string]string)
+	for _, field := range domainErr.Fields {
+		codes[field.Field] = field.Code
+	}
+	expected := map[string]string{
+		"attributes.employee_no": "type",
+		"attributes.locale":      "oneof",
+		"attributes.birthday":    "date",
+		"attributes.nickname":    "unknown_attribute",
+		"attributes.department":  "required",
+	}
+	for field, code := range expected {
+		if codes[field] != code {
+			t.Fatalf("expected %s to fail with %s, got %v", field, code, domainErr.Fields)
+		}
+	}
+
+	if _, err := userService.UpdateUser(ctx, alice.ID, map[string]interface{}{"attributes": map[string]interface{}{"department": "Engineering"}}, 0); err == nil {
+		t.Fatal("expected pattern mismatch to be rejected")
+	}
+	user, err := userService.UpdateUser(ctx, alice.ID, map[string]interface{}{"attributes": map[string]interface{}{
+		"department":  "eng",
+		"employee_no": float64(42),
+		"phone":       "555-0100",
+		"locale":      "zh-CN",
+	}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Attributes["department"] != "eng" || user.Attributes["employee_no"] != float64(42) {
+		t.Fatalf("unexpected attributes %v", user.Attributes)
+	}
+
+	// 值为nil时移除属性，必填属性不能移除
+	if user, err = userService.UpdateUser(ctx, alice.ID, map[string]interface{}{"attributes": map[string]interface{}{"locale": nil}}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := user.Attributes["locale"]; ok {
+		t.Fatalf("expected locale to be removed, got %v", user.Attributes)
+	}
+	if _, err := userService.UpdateUser(ctx, alice.ID, map[string]interface{}{"attributes": map[string]interface{}{"department": nil}}, 0); err == nil {
+		t.Fatal("expected required attribute removal to be rejected")
+	}
+
+	if err := svc.CheckEditable(map[string]interface{}{"phone": "555-0101"}, true, false); err != nil {
+		t.Fatalf("expected private attribute to be editable by its owner, got %v", err)
+	}
+	if err := svc.CheckEditable(map[string]interface{}{"employee_no": 7}, true, false); err == nil {
+		t.Fatal("expected admin_only attribute to be read-only for its owner")
+	}
+	if err := svc.CheckEditable(map[string]interface{}{"department": "ops"}, false, false); err == nil {
+		t.Fatal("expected other users' attributes to be read-only")
+	}
+
+	viewed := &models.User{ID: alice.ID, Attributes: models.UserAttributes{"department": "eng", "phone": "555-0100"}}
+	if err := svc.Redact(alice.ID+1, false, viewed); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := viewed.Attributes["phone"]; ok || viewed.Attributes["department"] != "eng" {
+		t.Fatalf("expected only private attributes to be hidden, got %v", viewed.Attributes)
+	}
+
+	if _, _, err := userService.ListUsers(ctx, 1, 10, map[string]string{"phone": "555-0100"}, false); err == nil {
+		t.Fatal("expected non-admins to be unable to filter by private attributes")
+	}
+}
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+const (
+	AuditActionRegister             = "auth.register"
+	AuditActionLogin                = "auth.login"
+	AuditActionLoginFailed          = "auth.login_failed"
+	AuditActionLogout               = "auth.logout"
+	AuditActionRefresh              = "auth.refresh"
+	AuditActionProfileUpdate        = "user.profile_update"
+	AuditActionUserUpdate           = "user.update"
+	AuditActionUserDelete           = "user.delete"
+	AuditActionRoleChange           = "user.role_change"
+	AuditActionDataExport           = "user.data_export"
+	AuditActionDataErasure          = "user.data_erasure"
+	AuditActionTokenCreate          = "token.create"
+	AuditActionTokenRevoke          = "token.revoke"
+	AuditActionClientCredentials    = "auth.client_credentials"
+	AuditActionServiceAccountCreate = "service_account.create"
+	AuditActionServiceAccountDelete = "service_account.delete"
+	AuditActionOAuthConsent         = "oauth.consent"
+	AuditActionOAuthCodeExchange    = "oauth.code_exchange"
+	AuditActionOAuthClientCreate    = "oauth_client.create"
+	AuditActionOAuthClientDelete    = "oauth_client.delete"
+	AuditActionIdentityLink         = "auth.identity_link"
+	AuditActionSAMLConnectionCreate = "saml_connection.create"
+	AuditActionSAMLConnectionDelete = "saml_connection.delete"
+	AuditActionDirectoryDeactivate  = "user.directory_deactivate"
+	AuditActionUserCreate           = "user.create"
+	AuditActionGroupCreate          = "group.create"
+	AuditActionGroupUpdate          = "group.update"
+	AuditActionGroupDelete          = "group.delete"
+	AuditActionGroupMemberAdd       = "group.member_add"
+	AuditActionGroupMemberRemove    = "group.member_remove"
+	AuditActionSubgroupAdd          = "group.subgroup_add"
+	AuditActionSubgroupRemove       = "group.subgroup_remove"
+	AuditActionWebAuthnRegister     = "webauthn.register"
+	AuditActionWebAuthnDelete       = "webauthn.delete"
+	AuditActionEmailChangeRequest   = "user.email_change_request"
+	AuditActionEmailChange          = "user.email_change"
+	AuditActionImpersonationStart   = "auth.impersonation_start"
+	AuditActionImpersonationEnd     = "auth.impersonation_end"
+	AuditActionOrganizationSwitch   = "auth.organization_switch"
+	AuditActionOrganizationCreate   = "organization.create"
+	AuditActionOrganizationDelete   = "organization.delete"
+	AuditActionMemberRoleChange     = "organization.member_role_change"
+	AuditActionMemberRemove         = "organization.member_remove"
+	AuditActionInvitationCreate     = "organization.invitation_create"
+	AuditActionInvitationRevoke     = "organization.invitation_revoke"
+	AuditActionInvitationAccept     = "organization.invitation_accept"
+	AuditActionUserInviteCreate     = "user.invitation_create"
+	AuditActionUserInviteResend     = "user.invitation_resend"
+	AuditActionUserInviteRevoke     = "user.invitation_revoke"
+	AuditActionUserInviteAccept     = "auth.invitation_accept"
+	AuditActionAttributeCreate      = "attribute.create"
+	AuditActionAttributeUpdate      = "attribute.update"
+	AuditActionAttributeDelete      = "attribute.delete"
+	AuditActionAvatarUpdate         = "user.avatar_update"
+	AuditActionAvatarDelete         = "user.avatar_delete"
+)
+
+const auditVerifyBatchSize = 500
+
+type AuditService interface {
+	Record(event AuditEvent) error
+	List(filter repository.AuditFilter, page, limit int) ([]models.AuditLog, int64, error)
+	Verify() (*AuditVerification, error)
+}
+
+// AuditEvent 描述一次需要审计的操作，由处理器填充请求相关的信息
+// 模拟登录期间ActorID是实际操作的管理员，ImpersonatedUserID是被模拟的用户，记录时写入元数据
+type AuditEvent struct {
+	ActorID            *uint
+	TargetID           *uint
+	Action             string
+	IPAddress          string
+	UserAgent          string
+	RequestID          string
+	Changes            map[string]FieldChange
+	Metadata           map[string]interface{}
+	ImpersonatedUserID *uint
+}
+
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+type AuditVerification struct {
+	Valid     bool      `json:"valid"`
+	Checked   int       `json:"checked"`
+	BrokenAt  *uint     `json:"broken_at,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+type auditService struct {
+	auditRepo repository.AuditRepository
+}
+
+func NewAuditService(auditRepo repository.AuditRepository) AuditService {
+	return &auditService{
+		auditRepo: auditRepo,
+	}
+}
+
+func (s *auditService) Record(event AuditEvent) error {
+	entry := &models.AuditLog{
+		ActorID:   event.ActorID,
+		TargetID:  event.TargetID,
+		Action:    event.Action,
+		IPAddress: event.IPAddress,
+		UserAgent: truncate(event.UserAgent, 255),
+		RequestID: truncate(event.RequestID, 64),
+		// 数据库时间戳精度为秒，哈希计算使用相同精度
+		CreatedAt: time.Now().Truncate(time.Second),
+	}
+
+	if len(event.Changes) > 0 {
+		data, err := json.Marshal(event.Changes)
+		if err != nil {
+			return err
+		}
+		entry.Changes = string(data)
+	}
+	metadata := event.Metadata
+	if event.ImpersonatedUserID != nil {
+		metadata = make(map[string]interface{}, len(event.Metadata)+1)
+		for key, value := range event.Metadata {
+			metadata[key] = value
+		}
+		metadata["impersonated_user_id"] = *event.ImpersonatedUserID
+	}
+	if len(metadata) > 0 {
+		data, err := json.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+		entry.Metadata = string(data)
+	}
+
+	return s.auditRepo.Append(entry, func(prevHash string) string {
+		return hashAuditEntry(prevHash, entry)
+	})
+}
+
+func (s *auditService) List(filter repository.AuditFilter, page, limit int) ([]models.AuditLog, int64, error) {
+	offset := (page - 1) * limit
+	return s.auditRepo.List(filter, offset, limit)
+}
+
+// Verify 从头重新计算哈希链，发现第一条不一致的记录即停止；
+// 遍历完成后与链头比较，删除最新的记录或清空表同样视为被篡改
+func (s *auditService) Verify() (*AuditVerification, error) {
+	result := &AuditVerification{Valid: true}
+	prevHash := ""
+	var lastID uint
+
+	err := s.auditRepo.Walk(auditVerifyBatchSize, func(entries []models.AuditLog) error {
+		if !result.Valid {
+			return nil
+		}
+		for i := range entries {
+			entry := &entries[i]
+			result.Checked++
+			if entry.PrevHash != prevHash || entry.Hash != hashAuditEntry(prevHash, entry) {
+				result.Valid = false
+				result.BrokenAt = &entry.ID
+				return nil
+			}
+			prevHash = entry.Hash
+			lastID = entry.ID
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Valid {
+		head, err := s.auditRepo.Head()
+		if err != nil {
+			return nil, err
+		}
+		if head == nil {
+			head = &models.AuditChainHead{}
+		}
+		// BrokenAt为链头记录的最后一条，即缺失部分的末尾
+		if head.LastID != lastID || head.LastHash != prevHash {
+			result.Valid = false
+			result.BrokenAt = &head.LastID
+		}
+	}
+
+	result.CheckedAt = time.Now()
+	return result, nil
+}
+
+// DiffUsers 比较用户更新前后的可审计字段，密码只记录是否变更
+func DiffUsers(before, after *models.User) map[string]FieldChange {
+	changes := make(map[string]FieldChange)
+	if before.Username != after.Username {
+		changes["username"] = FieldChange{Before: before.Username, After: after.Username}
+	}
+	if before.Email != after.Email {
+		changes["email"] = FieldChange{Before: before.Email, After: after.Email}
+	}
+	if before.IsActive != after.IsActive {
+		changes["is_active"] = FieldChange{Before: before.IsActive, After: after.IsActive}
+	}
+	if before.Role != after.Role {
+		changes["role"] = FieldChange{Before: before.Role, After: after.Role}
+	}
+	if before.PasswordHash != after.PasswordHash {
+		changes["password"] = FieldChange{After: "changed"}
+	}
+	for name, value := range after.Attributes {
+		if previous, ok := before.Attributes[name]; !ok || previous != value {
+			changes["attributes."+name] = FieldChange{Before: previous, After: value}
+		}
+	}
+	for name, value := range before.Attributes {
+		if _, ok := after.Attributes[name]; !ok {
+			changes["attributes."+name] = FieldChange{Before: value}
+		}
+	}
+	return changes
+}
+
+func hashAuditEntry(prevHash string, entry *models.AuditLog) string {
+	fields := []string{
+		prevHash,
+		strconv.FormatInt(entry.CreatedAt.Unix(), 10),
+		formatOptionalID(entry.ActorID),
+		formatOptionalID(entry.TargetID),
+		entry.Action,
+		entry.IPAddress,
+		entry.UserAgent,
+		entry.RequestID,
+		entry.Changes,
+		entry.Metadata,
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\x1f")))
+	return hex.EncodeToString(sum[:])
+}
+
+func formatOptionalID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
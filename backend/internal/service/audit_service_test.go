@@ -0,0 +1,119 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+// memoryAuditRepository 模拟数据库中的审计表和链头，测试可以直接篡改entries
+type memoryAuditRepository struct {
+	repository.AuditRepository
+	entries []models.AuditLog
+	head    *models.AuditChainHead
+}
+
+func (r *memoryAuditRepository) Append(entry *models.AuditLog, chain func(prevHash string) string) error {
+	if r.head == nil {
+		r.head = &models.AuditChainHead{ID: 1}
+	}
+	entry.ID = uint(len(r.entries) + 1)
+	entry.PrevHash = r.head.LastHash
+	entry.Hash = chain(r.head.LastHash)
+	r.entries = append(r.entries, *entry)
+	r.head.LastID = entry.ID
+	r.head.LastHash = entry.Hash
+	return nil
+}
+
+func (r *memoryAuditRepository) ListByUser(userID uint) ([]models.AuditLog, error) {
+	var entries []models.AuditLog
+	for _, entry := range r.entries {
+		if (entry.ActorID != nil && *entry.ActorID == userID) || (entry.TargetID != nil && *entry.TargetID == userID) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (r *memoryAuditRepository) Walk(batchSize int, fn func(entries []models.AuditLog) error) error {
+	for start := 0; start < len(r.entries); start += batchSize {
+		end := start + batchSize
+		if end > len(r.entries) {
+			end = len(r.entries)
+		}
+		if err := fn(append([]models.AuditLog(nil), r.entries[start:end]...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *memoryAuditRepository) Head() (*models.AuditChainHead, error) {
+	if r.head == nil {
+		return nil, nil
+	}
+	head := *r.head
+	return &head, nil
+}
+
+func TestAuditVerify(t *testing.T) {
+	newChain := func(t *testing.T) (*memoryAuditRepository, AuditService) {
+		repo := &memoryAuditRepository{}
+		svc := NewAuditService(repo)
+		for _, action := range []string{AuditActionRegister, AuditActionLogin, AuditActionProfileUpdate, AuditActionLogout} {
+			if err := svc.Record(AuditEvent{Action: action, IPAddress: "127.0.0.1"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return repo, svc
+	}
+	verify := func(t *testing.T, svc AuditService) *AuditVerification {
+		t.Helper()
+		result, err := svc.Verify()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	t.Run("intact", func(t *testing.T) {
+		_, svc := newChain(t)
+		if result := verify(t, svc); !result.Valid || result.Checked != 4 {
+			t.Fatalf("expected intact chain to verify, got %+v", result)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		if result := verify(t, NewAuditService(&memoryAuditRepository{})); !result.Valid {
+			t.Fatalf("expected empty log to verify, got %+v", result)
+		}
+	})
+
+	t.Run("modified row", func(t *testing.T) {
+		repo, svc := newChain(t)
+		repo.entries[1].IPAddress = "10.0.0.1"
+		result := verify(t, svc)
+		if result.Valid || result.BrokenAt == nil || *result.BrokenAt != 2 {
+			t.Fatalf("expected chain to break at entry 2, got %+v", result)
+		}
+	})
+
+	t.Run("deleted tail", func(t *testing.T) {
+		repo, svc := newChain(t)
+		repo.entries = repo.entries[:2]
+		result := verify(t, svc)
+		if result.Valid || result.BrokenAt == nil || *result.BrokenAt != 4 {
+			t.Fatalf("expected missing tail to be reported at entry 4, got %+v", result)
+		}
+	})
+
+	t.Run("truncated table", func(t *testing.T) {
+		repo, svc := newChain(t)
+		repo.entries = nil
+		if result := verify(t, svc); result.Valid {
+			t.Fatalf("expected truncated log to fail verification, got %+v", result)
+		}
+	})
+}
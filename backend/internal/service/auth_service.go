@@ -2,37 +2,102 @@ package service
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/user/user-management/internal/models"
 	"github.com/user/user-management/internal/repository"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthService interface {
 	Register(username, email, password string) (*models.User, error)
-	Login(email, password string) (*models.User, string, string, error)
-	RefreshToken(refreshToken string) (string, string, error)
+	Login(email, password, ipAddress, userAgent string) (*models.User, string, string, error)
+	StartSession(user *models.User, ipAddress, userAgent string) (string, string, error)
+	RefreshToken(refreshToken string) (uint, string, string, error)
 	Logout(token string, userID uint) error
-	ValidateToken(tokenString string) (uint, error)
+	ClientCredentials(clientID, clientSecret string, scopes []string) (*ClientCredentialsToken, error)
+	ValidateToken(tokenString string) (*Principal, error)
+	// Impersonate 以targetID的身份为管理员actorID签发不能刷新的访问令牌，令牌的act声明记录actorID
+	Impersonate(actorID, targetID uint) (*ImpersonationToken, error)
+	// EndImpersonation 使模拟令牌失效，被模拟用户自己的会话不受影响
+	EndImpersonation(token string) error
+	// SwitchOrganization 使已通过认证的访问令牌token失效，在同一会话中签发organizationID组织的访问令牌和刷新令牌；
+	// 创建或加入组织后旧令牌不再有效，也通过它换发令牌。不能用于模拟令牌
+	SwitchOrganization(token string, userID uint, sessionID string, authTime time.Time, organizationID uint) (string, string, error)
+}
+
+// 令牌所代表的主体类型
+const (
+	PrincipalUser           = "user"
+	PrincipalServiceAccount = "service_account"
+)
+
+// Principal 是访问令牌所代表的主体；Scopes为nil表示登录会话，不受scope限制
+// 登录会话的SessionID标识该次登录，AuthTime为该次登录完成身份验证的时间（旧令牌可能为零值）
+// 管理员模拟用户时ID为被模拟的用户，ActorID为实际操作的管理员
+// OrganizationID是令牌所在的组织（0表示不属于任何组织），OrganizationRole是用户在该组织中的角色
+type Principal struct {
+	Type             string
+	ID               uint
+	Scopes           []string
+	SessionID        string
+	AuthTime         time.Time
+	ActorID          uint
+	OrganizationID   uint
+	OrganizationRole string
+}
+
+// ImpersonationToken 是模拟用户登录签发的访问令牌
+type ImpersonationToken struct {
+	User        *models.User
+	AccessToken string
+	ExpiresIn   time.Duration
+}
+
+// ClientCredentialsToken 是client_credentials授权签发的访问令牌
+type ClientCredentialsToken struct {
+	Account     *models.ServiceAccount
+	AccessToken string
+	ExpiresIn   time.Duration
+	Scopes      []string
 }
 
 type authService struct {
-	userRepo       repository.UserRepository
-	sessionService SessionService
-	jwtSecret      string
-	tokenExpiry    time.Duration
+	userRepo           repository.UserRepository
+	serviceAccountRepo repository.ServiceAccountRepository
+	orgRepo            repository.OrganizationRepository
+	groupService       GroupService
+	sessionService     SessionService
+	authenticators     []Authenticator
+	secondFactor       SecondFactor
+	passwordPolicy     PasswordPolicy
+	jwtSecret          string
+	tokenExpiry        time.Duration
+	impersonationTTL   time.Duration
 }
 
-func NewAuthService(userRepo repository.UserRepository, sessionService SessionService, jwtSecret string, tokenExpiry time.Duration) AuthService {
+// NewAuthService 创建认证服务，登录时按顺序尝试authenticators，第一个认可凭据的生效；
+// secondFactor不为nil时，需要第二因素的账号登录返回SecondFactorRequiredError；groupService提供用户令牌的groups声明
+func NewAuthService(userRepo repository.UserRepository, serviceAccountRepo repository.ServiceAccountRepository, orgRepo repository.OrganizationRepository, groupService GroupService, sessionService SessionService, authenticators []Authenticator, secondFactor SecondFactor, passwordPolicy PasswordPolicy, jwtSecret string, tokenExpiry, impersonationTTL time.Duration) AuthService {
 	return &authService{
-		userRepo:       userRepo,
-		sessionService: sessionService,
-		jwtSecret:      jwtSecret,
-		tokenExpiry:    tokenExpiry,
+		userRepo:           userRepo,
+		serviceAccountRepo: serviceAccountRepo,
+		orgRepo:            orgRepo,
+		groupService:       groupService,
+		sessionService:     sessionService,
+		authenticators:     authenticators,
+		secondFactor:       secondFactor,
+		passwordPolicy:     passwordPolicy,
+		jwtSecret:          jwtSecret,
+		tokenExpiry:        tokenExpiry,
+		impersonationTTL:   impersonationTTL,
 	}
 }
 
@@ -40,114 +105,166 @@ func (s *authService) Register(username, email, password string) (*models.User,
 	// 检查用户是否已存在
 	existingUser, _ := s.userRepo.GetByEmail(email)
 	if existingUser != nil {
-		return nil, errors.New("email already exists")
+		return nil, ErrEmailTaken
 	}
 
 	existingUser, _ = s.userRepo.GetByUsername(username)
 	if existingUser != nil {
-		return nil, errors.New("username already exists")
+		return nil, ErrUsernameTaken
 	}
 
-	// 哈希密码
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return nil, err
+	user := &models.User{
+		Username: username,
+		Email:    email,
+		IsActive: true,
+		Role:     models.RoleUser,
 	}
 
-	// 创建用户
-	user := &models.User{
-		Username:     username,
-		Email:        email,
-		PasswordHash: string(hashedPassword),
-		IsActive:     true,
+	// 校验并哈希密码
+	if err := s.passwordPolicy.SetPassword(user, password); err != nil {
+		return nil, err
 	}
 
+	// 创建用户
 	if err := s.userRepo.Create(user); err != nil {
 		return nil, err
 	}
+	s.passwordPolicy.Remember(user)
 
 	return user, nil
 }
 
-func (s *authService) Login(email, password string) (*models.User, string, string, error) {
-	// 查找用户
-	user, err := s.userRepo.GetByEmail(email)
+func (s *authService) Login(email, password, ipAddress, userAgent string) (*models.User, string, string, error) {
+	// 验证凭据
+	user, err := s.authenticate(email, password)
 	if err != nil {
 		return nil, "", "", err
 	}
-	if user == nil {
-		return nil, "", "", errors.New("invalid credentials")
+
+	// 检查用户是否激活
+	if !user.IsActive {
+		return nil, "", "", ErrAccountDisabled
 	}
 
-	// 验证密码
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
-		return nil, "", "", errors.New("invalid credentials")
+	if s.secondFactor != nil {
+		ticket, err := s.secondFactor.Challenge(user)
+		if err != nil {
+			return nil, "", "", err
+		}
+		if ticket != "" {
+			return user, "", "", &SecondFactorRequiredError{Ticket: ticket}
+		}
 	}
 
-	// 检查用户是否激活
+	accessToken, refreshToken, err := s.StartSession(user, ipAddress, userAgent)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return user, accessToken, refreshToken, nil
+}
+
+// authenticate 依次尝试各个Authenticator，只有凭据不匹配时才继续尝试下一个
+func (s *authService) authenticate(email, password string) (*models.User, error) {
+	for _, authenticator := range s.authenticators {
+		user, err := authenticator.Authenticate(email, password)
+		if err == nil {
+			return user, nil
+		}
+		if !errors.Is(err, ErrInvalidCredentials) {
+			return nil, err
+		}
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// StartSession 为已通过身份验证的用户签发访问令牌和刷新令牌，并记录登录历史
+// 密码登录和外部身份提供方登录共用这一流程
+func (s *authService) StartSession(user *models.User, ipAddress, userAgent string) (string, string, error) {
 	if !user.IsActive {
-		return nil, "", "", errors.New("user account is disabled")
+		return "", "", ErrAccountDisabled
 	}
 
-	// 生成访问令牌
-	accessToken, err := s.generateAccessToken(user.ID)
+	// 每次登录是一个新的会话，刷新令牌轮换时沿用会话ID和认证时间
+	sessionID, err := randomHex(16)
 	if err != nil {
-		return nil, "", "", err
+		return "", "", err
 	}
+	now := time.Now()
 
-	// 在Redis中创建session
-	err = s.sessionService.CreateSession(user.ID, accessToken, s.tokenExpiry)
+	// 登录后进入最早加入的组织，之后可以切换
+	organizationID, err := defaultOrganization(s.orgRepo, user.ID)
 	if err != nil {
-		return nil, "", "", err
+		return "", "", err
 	}
 
-	// 生成刷新令牌
-	refreshToken, err := s.generateRefreshToken(user.ID)
+	accessToken, refreshToken, err := s.issueSessionTokens(user.ID, sessionID, now, organizationID)
 	if err != nil {
-		return nil, "", "", err
+		return "", "", err
 	}
 
-	return user, accessToken, refreshToken, nil
+	// 记录登录历史，写入失败不影响登录
+	if err := s.userRepo.CreateLoginRecord(&models.UserSession{
+		UserID:       user.ID,
+		IPAddress:    ipAddress,
+		UserAgent:    truncate(userAgent, 255),
+		LastActivity: now,
+		CreatedAt:    now,
+	}); err != nil {
+		log.Printf("Failed to record login history for user %d: %v", user.ID, err)
+	}
+
+	return accessToken, refreshToken, nil
 }
 
-func (s *authService) RefreshToken(refreshToken string) (string, string, error) {
+func (s *authService) RefreshToken(refreshToken string) (uint, string, string, error) {
 	// 查找刷新令牌
 	token, err := s.userRepo.GetRefreshToken(refreshToken)
 	if err != nil {
-		return "", "", err
+		return 0, "", "", err
 	}
 	if token == nil {
-		return "", "", errors.New("invalid refresh token")
+		return 0, "", "", ErrInvalidRefreshToken
 	}
 
 	// 检查是否过期
 	if time.Now().After(token.ExpiresAt) {
 		s.userRepo.DeleteRefreshToken(refreshToken)
-		return "", "", errors.New("refresh token expired")
+		return 0, "", "", ErrRefreshTokenExpired
 	}
 
-	// 生成新的访问令牌
-	accessToken, err := s.generateAccessToken(token.UserID)
-	if err != nil {
-		return "", "", err
+	// 添加会话ID之前签发的刷新令牌没有会话ID和认证时间，视为新的会话
+	sessionID := token.SessionID
+	if sessionID == "" {
+		if sessionID, err = randomHex(16); err != nil {
+			return 0, "", "", err
+		}
+	}
+	var authTime time.Time
+	if token.AuthTime != nil {
+		authTime = *token.AuthTime
 	}
 
-	// 在Redis中创建新的session
-	err = s.sessionService.CreateSession(token.UserID, accessToken, s.tokenExpiry)
-	if err != nil {
-		return "", "", err
+	// 用户已离开刷新令牌所在的组织，或加入了组织但令牌不属于任何组织时，回到最早加入的组织
+	organizationID := token.OrganizationID
+	if _, err := organizationRole(s.orgRepo, token.UserID, organizationID); err != nil {
+		if !errors.Is(err, ErrInvalidToken) {
+			return 0, "", "", err
+		}
+		if organizationID, err = defaultOrganization(s.orgRepo, token.UserID); err != nil {
+			return 0, "", "", err
+		}
 	}
 
-	// 生成新的刷新令牌
-	newRefreshToken, err := s.generateRefreshToken(token.UserID)
+	accessToken, newRefreshToken, err := s.issueSessionTokens(token.UserID, sessionID, authTime, organizationID)
 	if err != nil {
-		return "", "", err
+		return 0, "", "", err
 	}
 
 	// 删除旧的刷新令牌
 	s.userRepo.DeleteRefreshToken(refreshToken)
 
-	return accessToken, newRefreshToken, nil
+	return token.UserID, accessToken, newRefreshToken, nil
 }
 
 func (s *authService) Logout(token string, userID uint) error {
@@ -161,54 +278,309 @@ func (s *authService) Logout(token string, userID uint) error {
 	return s.userRepo.DeleteUserRefreshTokens(userID)
 }
 
-func (s *authService) ValidateToken(tokenString string) (uint, error) {
-	// 首先检查Redis中的session
-	sessionData, err := s.sessionService.GetSession(tokenString)
+// ClientCredentials 校验服务账号凭据并签发JWT；未指定scope时授予服务账号的全部scope
+func (s *authService) ClientCredentials(clientID, clientSecret string, scopes []string) (*ClientCredentialsToken, error) {
+	account, err := s.serviceAccountRepo.GetByClientID(clientID)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	if sessionData == nil {
-		return 0, errors.New("session not found")
+	if account == nil || !account.IsActive {
+		return nil, ErrInvalidClient
+	}
+	if subtle.ConstantTimeCompare([]byte(hashAccessToken(clientSecret)), []byte(account.ClientSecretHash)) != 1 {
+		return nil, ErrInvalidClient
+	}
+
+	granted := AccountScopes(account)
+	if len(scopes) == 0 {
+		scopes = granted
+	}
+	for _, scope := range scopes {
+		if !containsScope(granted, scope) {
+			return nil, invalidScope(scope, granted)
+		}
+	}
+
+	// 服务账号令牌不创建Redis session，校验时直接检查账号状态
+	claims := jwt.MapClaims{
+		"sub":                fmt.Sprintf("%s:%d", PrincipalServiceAccount, account.ID),
+		"service_account_id": account.ID,
+		"scope":              strings.Join(scopes, " "),
+		"exp":                time.Now().Add(s.tokenExpiry).Unix(),
+		"iat":                time.Now().Unix(),
+	}
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.jwtSecret))
+	if err != nil {
+		return nil, err
 	}
 
-	// 然后验证JWT token
+	return &ClientCredentialsToken{
+		Account:     account,
+		AccessToken: accessToken,
+		ExpiresIn:   s.tokenExpiry,
+		Scopes:      scopes,
+	}, nil
+}
+
+// Impersonate 不签发刷新令牌，令牌到期或结束模拟后需要重新发起
+func (s *authService) Impersonate(actorID, targetID uint) (*ImpersonationToken, error) {
+	user, err := s.userRepo.GetByID(targetID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	// 模拟其他管理员相当于借用其权限，停用的用户本身也无法登录
+	if user.ID == actorID || user.Role == models.RoleAdmin || !user.IsActive {
+		return nil, ErrImpersonationNotAllowed
+	}
+
+	sessionID, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	organizationID, err := defaultOrganization(s.orgRepo, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	// act声明的格式参照RFC 8693，sub与ID令牌一样是用户ID；不写入auth_time，修改邮箱或密码时无法通过再次认证
+	claims := jwt.MapClaims{
+		"user_id": user.ID,
+		"sid":     sessionID,
+		"act":     map[string]interface{}{"sub": strconv.FormatUint(uint64(actorID), 10)},
+		"exp":     time.Now().Add(s.impersonationTTL).Unix(),
+		"iat":     time.Now().Unix(),
+	}
+	if organizationID != 0 {
+		claims["org_id"] = organizationID
+	}
+	if err := s.setGroupsClaim(claims, user.ID); err != nil {
+		return nil, err
+	}
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.jwtSecret))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.sessionService.CreateImpersonationSession(user.ID, actorID, sessionID, accessToken, s.impersonationTTL); err != nil {
+		return nil, err
+	}
+
+	return &ImpersonationToken{User: user, AccessToken: accessToken, ExpiresIn: s.impersonationTTL}, nil
+}
+
+func (s *authService) EndImpersonation(token string) error {
+	return s.sessionService.DeleteSession(token)
+}
+
+func (s *authService) SwitchOrganization(token string, userID uint, sessionID string, authTime time.Time, organizationID uint) (string, string, error) {
+	if _, err := organizationRole(s.orgRepo, userID, organizationID); err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			return "", "", ErrNotOrganizationMember
+		}
+		return "", "", err
+	}
+
+	if err := s.sessionService.DeleteSession(token); err != nil {
+		return "", "", err
+	}
+	if sessionID == "" {
+		var err error
+		if sessionID, err = randomHex(16); err != nil {
+			return "", "", err
+		}
+	}
+	return s.issueSessionTokens(userID, sessionID, authTime, organizationID)
+}
+
+func (s *authService) ValidateToken(tokenString string) (*Principal, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("unexpected signing method")
 		}
 		return []byte(s.jwtSecret), nil
 	})
+	if err != nil {
+		return nil, err
+	}
 
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	if accountID, ok := claims["service_account_id"].(float64); ok {
+		return s.validateServiceAccountToken(uint(accountID), claims)
+	}
+
+	// 用户令牌需要检查Redis中的session
+	sessionData, err := s.sessionService.GetSession(tokenString)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	if sessionData == nil {
+		return nil, ErrInvalidToken
+	}
+
+	userIDClaim, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	userID := uint(userIDClaim)
+
+	// 验证session中的用户ID与token中的一致
+	if userID != sessionData.UserID {
+		return nil, ErrInvalidToken
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		userID := uint(claims["user_id"].(float64))
-		
-		// 验证session中的用户ID与token中的一致
-		if userID != sessionData.UserID {
-			return 0, errors.New("session user mismatch")
+	principal := &Principal{Type: PrincipalUser, ID: userID, SessionID: sessionData.SessionID}
+	if authTime, ok := claims["auth_time"].(float64); ok {
+		principal.AuthTime = time.Unix(int64(authTime), 0)
+	}
+
+	// 令牌所在的组织必须仍然有效，用户被移出组织后令牌立即失效
+	organizationID, err := organizationClaim(claims)
+	if err != nil {
+		return nil, err
+	}
+	principal.OrganizationRole, err = organizationRole(s.orgRepo, userID, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	principal.OrganizationID = organizationID
+
+	// 模拟令牌的act声明必须与会话记录的管理员一致
+	actorID, err := actorClaim(claims)
+	if err != nil || actorID != sessionData.ActorID {
+		return nil, ErrInvalidToken
+	}
+	if actorID != 0 {
+		// 管理员被停用或不再是管理员后，其模拟令牌立即失效
+		actor, err := s.userRepo.GetByID(actorID)
+		if err != nil {
+			return nil, err
+		}
+		if actor == nil || !actor.IsActive || actor.Role != models.RoleAdmin {
+			return nil, ErrInvalidToken
 		}
-		
-		return userID, nil
+		principal.ActorID = actorID
 	}
+	return principal, nil
+}
 
-	return 0, errors.New("invalid token")
+// actorClaim 返回act声明中的管理员ID，没有act声明时返回0
+func actorClaim(claims jwt.MapClaims) (uint, error) {
+	act, ok := claims["act"]
+	if !ok {
+		return 0, nil
+	}
+	actor, ok := act.(map[string]interface{})
+	if !ok {
+		return 0, ErrInvalidToken
+	}
+	subject, _ := actor["sub"].(string)
+	id, err := strconv.ParseUint(subject, 10, 32)
+	if err != nil || id == 0 {
+		return 0, ErrInvalidToken
+	}
+	return uint(id), nil
+}
+
+// organizationClaim 返回org_id声明中的组织ID，没有org_id声明时返回0
+func organizationClaim(claims jwt.MapClaims) (uint, error) {
+	org, ok := claims["org_id"]
+	if !ok {
+		return 0, nil
+	}
+	id, ok := org.(float64)
+	if !ok || id <= 0 {
+		return 0, ErrInvalidToken
+	}
+	return uint(id), nil
+}
+
+// validateServiceAccountToken 每次校验都读取账号，删除或停用后令牌立即失效
+func (s *authService) validateServiceAccountToken(accountID uint, claims jwt.MapClaims) (*Principal, error) {
+	account, err := s.serviceAccountRepo.GetByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil || !account.IsActive {
+		return nil, ErrInvalidToken
+	}
+
+	scope, _ := claims["scope"].(string)
+	scopes := strings.Fields(scope)
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	return &Principal{Type: PrincipalServiceAccount, ID: account.ID, Scopes: scopes}, nil
+}
+
+// issueSessionTokens 为会话签发访问令牌和刷新令牌，并在Redis中创建session
+func (s *authService) issueSessionTokens(userID uint, sessionID string, authTime time.Time, organizationID uint) (string, string, error) {
+	// 生成访问令牌
+	accessToken, err := s.generateAccessToken(userID, sessionID, authTime, organizationID)
+	if err != nil {
+		return "", "", err
+	}
+
+	// 在Redis中创建session
+	err = s.sessionService.CreateSession(userID, sessionID, accessToken, s.tokenExpiry)
+	if err != nil {
+		return "", "", err
+	}
+
+	// 生成刷新令牌
+	refreshToken, err := s.generateRefreshToken(userID, sessionID, authTime, organizationID)
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
 }
 
-func (s *authService) generateAccessToken(userID uint) (string, error) {
+// generateAccessToken 的authTime为零值时不写入auth_time，这样的令牌修改邮箱或密码时必须提供当前密码；
+// organizationID为0时不写入org_id；groups声明为签发时用户的有效组
+func (s *authService) generateAccessToken(userID uint, sessionID string, authTime time.Time, organizationID uint) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id": userID,
+		"sid":     sessionID,
 		"exp":     time.Now().Add(s.tokenExpiry).Unix(),
 		"iat":     time.Now().Unix(),
 	}
+	if !authTime.IsZero() {
+		claims["auth_time"] = authTime.Unix()
+	}
+	if organizationID != 0 {
+		claims["org_id"] = organizationID
+	}
+	if err := s.setGroupsClaim(claims, userID); err != nil {
+		return "", err
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(s.jwtSecret))
 }
 
-func (s *authService) generateRefreshToken(userID uint) (string, error) {
+// setGroupsClaim 把用户的有效组名写入groups声明；超过上限时只写入groups_overage，
+// 由客户端通过GET /users/{id}/groups查询
+func (s *authService) setGroupsClaim(claims jwt.MapClaims, userID uint) error {
+	names, overage, err := s.groupService.ClaimGroups(userID)
+	if err != nil {
+		return err
+	}
+	if overage {
+		claims["groups_overage"] = true
+		return nil
+	}
+	claims["groups"] = names
+	return nil
+}
+
+func (s *authService) generateRefreshToken(userID uint, sessionID string, authTime time.Time, organizationID uint) (string, error) {
 	// 生成随机令牌
 	bytes := make([]byte, 32)
 	if _, err := rand.Read(bytes); err != nil {
@@ -218,9 +590,14 @@ func (s *authService) generateRefreshToken(userID uint) (string, error) {
 
 	// 保存到数据库
 	refreshToken := &models.RefreshToken{
-		UserID:    userID,
-		Token:     tokenString,
-		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
+		UserID:         userID,
+		Token:          tokenString,
+		SessionID:      sessionID,
+		OrganizationID: organizationID,
+		ExpiresAt:      time.Now().Add(7 * 24 * time.Hour),
+	}
+	if !authTime.IsZero() {
+		refreshToken.AuthTime = &authTime
 	}
 
 	if err := s.userRepo.SaveRefreshToken(refreshToken); err != nil {
@@ -228,4 +605,11 @@ func (s *authService) generateRefreshToken(userID uint) (string, error) {
 	}
 
 	return tokenString, nil
-}
\ No newline at end of file
+}
+
+func truncate(value string, max int) string {
+	if len(value) > max {
+		return value[:max]
+	}
+	return value
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/user/user-management/internal/models"
+)
+
+func TestImpersonation(t *testing.T) {
+	mr := miniredis.RunT(t)
+	sessions := NewSessionService(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+
+	users := &memoryUserRepository{}
+	admin := &models.User{Username: "admin", Email: "admin@example.com", Role: models.RoleAdmin, IsActive: true}
+	otherAdmin := &models.User{Username: "root", Email: "root@example.com", Role: models.RoleAdmin, IsActive: true}
+	alice := &models.User{Username: "alice", Email: "alice@example.com", Role: models.RoleUser, IsActive: true}
+	disabled := &models.User{Username: "bob", Email: "bob@example.com", Role: models.RoleUser}
+	for _, user := range []*models.User{admin, otherAdmin, alice, disabled} {
+		users.Create(user)
+	}
+
+	svc := NewAuthService(users, nil, &memoryOrganizationRepository{}, newTestGroupService(users), sessions, nil, nil, newTestPasswordPolicy(t), "test-secret", time.Hour, 30*time.Minute)
+
+	for _, target := range []*models.User{admin, otherAdmin, disabled} {
+		if _, err := svc.Impersonate(admin.ID, target.ID); !errors.Is(err, ErrImpersonationNotAllowed) {
+			t.Fatalf("%s: expected impersonation to be refused, got %v", target.Username, err)
+		}
+	}
+	if _, err := svc.Impersonate(admin.ID, 99); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected unknown user to be rejected, got %v", err)
+	}
+
+	token, err := svc.Impersonate(admin.ID, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.ExpiresIn != 30*time.Minute {
+		t.Fatalf("expected impersonation TTL, got %s", token.ExpiresIn)
+	}
+	principal, err := svc.ValidateToken(token.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.ID != alice.ID || principal.ActorID != admin.ID {
+		t.Fatalf("expected alice impersonated by admin, got %+v", principal)
+	}
+	if !principal.AuthTime.IsZero() {
+		t.Fatal("impersonation token must not pass step-up authentication")
+	}
+
+	// 管理员降级后模拟令牌立即失效
+	admin.Role = models.RoleUser
+	if _, err := svc.ValidateToken(token.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected token of demoted admin to be rejected, got %v", err)
+	}
+	admin.Role = models.RoleAdmin
+
+	if err := svc.EndImpersonation(token.AccessToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.ValidateToken(token.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ended impersonation token to be rejected, got %v", err)
+	}
+}
+
+func TestOrganizationClaim(t *testing.T) {
+	mr := miniredis.RunT(t)
+	sessions := NewSessionService(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+
+	users := &memoryUserRepository{}
+	alice := &models.User{Username: "alice", Email: "alice@example.com", Role: models.RoleUser, IsActive: true}
+	users.Create(alice)
+	orgs := &memoryOrganizationRepository{}
+	svc := NewAuthService(users, nil, orgs, newTestGroupService(users), sessions, nil, nil, newTestPasswordPolicy(t), "test-secret", time.Hour, 30*time.Minute)
+
+	accessToken, refreshToken, err := svc.StartSession(alice, "127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	principal, err := svc.ValidateToken(accessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.OrganizationID != 0 {
+		t.Fatalf("expected no organization, got %d", principal.OrganizationID)
+	}
+
+	// 加入组织后不属于任何组织的令牌失效，否则仍能看到组织外的用户
+	acme := &models.Organization{Slug: "acme", Name: "Acme"}
+	orgs.Create(acme, &models.OrganizationMember{UserID: alice.ID, Role: models.OrgRoleOwner})
+	if _, err := svc.ValidateToken(accessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected token without organization to be rejected, got %v", err)
+	}
+
+	globex := &models.Organization{Slug: "globex", Name: "Globex"}
+	orgs.Create(globex, &models.OrganizationMember{UserID: 99, Role: models.OrgRoleOwner})
+	if _, _, err := svc.SwitchOrganization(accessToken, alice.ID, principal.SessionID, principal.AuthTime, globex.ID); !errors.Is(err, ErrNotOrganizationMember) {
+		t.Fatalf("expected switching to a foreign organization to fail, got %v", err)
+	}
+	switched, _, err := svc.SwitchOrganization(accessToken, alice.ID, principal.SessionID, principal.AuthTime, acme.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	principal, err = svc.ValidateToken(switched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.OrganizationID != acme.ID || principal.OrganizationRole != models.OrgRoleOwner {
+		t.Fatalf("expected owner of acme, got %+v", principal)
+	}
+
+	// 被移出组织后令牌失效，刷新时回到不属于任何组织的状态
+	orgs.RemoveMember(acme.ID, alice.ID)
+	if _, err := svc.ValidateToken(switched); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected token of removed member to be rejected, got %v", err)
+	}
+	_, refreshed, _, err := svc.RefreshToken(refreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	principal, err = svc.ValidateToken(refreshed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.OrganizationID != 0 {
+		t.Fatalf("expected refresh to fall back to no organization, got %d", principal.OrganizationID)
+	}
+}
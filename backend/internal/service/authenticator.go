@@ -0,0 +1,59 @@
+package service
+
+import (
+	"errors"
+	"log"
+
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+// Authenticator 校验登录邮箱和密码，成功时返回对应的本地用户。
+// 凭据不匹配或用户不存在时返回ErrInvalidCredentials，authService会继续尝试下一个Authenticator
+type Authenticator interface {
+	Authenticate(email, password string) (*models.User, error)
+}
+
+// SecondFactor 在第一因素通过后决定是否还需要第二因素，需要时返回完成第二步登录用的一次性票据，否则返回空字符串
+type SecondFactor interface {
+	Challenge(user *models.User) (string, error)
+}
+
+// passwordAuthenticator 用本地保存的密码哈希校验，哈希的算法或参数过时时按当前配置重新生成
+type passwordAuthenticator struct {
+	userRepo       repository.UserRepository
+	passwordHasher PasswordHasher
+}
+
+func NewPasswordAuthenticator(userRepo repository.UserRepository, passwordHasher PasswordHasher) Authenticator {
+	return &passwordAuthenticator{userRepo: userRepo, passwordHasher: passwordHasher}
+}
+
+func (a *passwordAuthenticator) Authenticate(email, password string) (*models.User, error) {
+	user, err := a.userRepo.GetByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	if !a.passwordHasher.Verify(user.PasswordHash, password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	// 重新生成失败不影响本次登录，下次登录时会再次尝试；
+	// 哈希在校验之后被修改（例如同时修改了密码）时放弃，不能用旧密码覆盖新密码
+	if a.passwordHasher.NeedsRehash(user.PasswordHash) {
+		if hashedPassword, err := a.passwordHasher.Hash(password); err != nil {
+			log.Printf("Failed to rehash password of user %d: %v", user.ID, err)
+		} else if err := a.userRepo.UpdatePasswordHash(user.ID, user.PasswordHash, hashedPassword); err != nil {
+			if !errors.Is(err, repository.ErrVersionConflict) {
+				log.Printf("Failed to rehash password of user %d: %v", user.ID, err)
+			}
+		} else {
+			user.PasswordHash = hashedPassword
+		}
+	}
+	return user, nil
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/user/user-management/internal/config"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+	"github.com/user/user-management/internal/storage"
+)
+
+// maxAvatarPixels 限制解码后的像素数，防止体积很小但尺寸巨大的图片耗尽内存
+const maxAvatarPixels = 4096 * 4096
+
+// avatarDecoders 按嗅探出的内容类型选择解码器，不依赖上传时声明的Content-Type和文件名
+var avatarDecoders = map[string]func([]byte) (image.Image, error){
+	"image/jpeg": decodeWith(jpeg.Decode, jpeg.DecodeConfig),
+	"image/png":  decodeWith(png.Decode, png.DecodeConfig),
+	"image/gif":  decodeWith(gif.Decode, gif.DecodeConfig),
+}
+
+// AvatarService 管理用户头像：上传的图片裁剪为正方形、缩小并重新编码为JPEG（同时去除EXIF等元数据），
+// 头像和缩略图保存在BlobStore中，地址保存在用户记录上
+type AvatarService interface {
+	Upload(ctx context.Context, userID uint, data []byte) (*models.User, error)
+	Delete(ctx context.Context, userID uint) (*models.User, error)
+}
+
+type avatarService struct {
+	cfg      config.AvatarConfig
+	userRepo repository.UserRepository
+	blobs    storage.BlobStore
+}
+
+func NewAvatarService(cfg config.AvatarConfig, userRepo repository.UserRepository, blobs storage.BlobStore) AvatarService {
+	return &avatarService{
+		cfg:      cfg,
+		userRepo: userRepo,
+		blobs:    blobs,
+	}
+}
+
+func (s *avatarService) Upload(ctx context.Context, userID uint, data []byte) (*models.User, error) {
+	avatar, err := processAvatar(data, s.cfg.Size)
+	if err != nil {
+		return nil, err
+	}
+	thumbnail := resizeSquare(avatar, s.cfg.ThumbnailSize)
+
+	user, err := s.get(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 每次上传使用新的key，旧地址可以被客户端和CDN长期缓存
+	name, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("avatars/%d/%s.jpg", userID, name)
+	if err := s.put(ctx, key, avatar); err != nil {
+		return nil, err
+	}
+	if err := s.put(ctx, avatarThumbnailKey(key), thumbnail); err != nil {
+		deleteAvatarBlobs(ctx, s.blobs, key)
+		return nil, err
+	}
+
+	previous := user.AvatarKey
+	user.AvatarKey = key
+	user.AvatarURL = s.blobs.URL(key)
+	user.AvatarThumbnailURL = s.blobs.URL(avatarThumbnailKey(key))
+	if err := s.update(user); err != nil {
+		deleteAvatarBlobs(ctx, s.blobs, key)
+		return nil, err
+	}
+
+	deleteAvatarBlobs(ctx, s.blobs, previous)
+	return user, nil
+}
+
+func (s *avatarService) Delete(ctx context.Context, userID uint) (*models.User, error) {
+	user, err := s.get(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.AvatarKey == "" {
+		return user, nil
+	}
+
+	previous := user.AvatarKey
+	user.AvatarKey = ""
+	user.AvatarURL = ""
+	user.AvatarThumbnailURL = ""
+	if err := s.update(user); err != nil {
+		return nil, err
+	}
+
+	deleteAvatarBlobs(ctx, s.blobs, previous)
+	return user, nil
+}
+
+func (s *avatarService) get(userID uint) (*models.User, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (s *avatarService) update(user *models.User) error {
+	err := s.userRepo.Update(user)
+	if errors.Is(err, repository.ErrVersionConflict) {
+		return ErrPreconditionFailed
+	}
+	return err
+}
+
+func (s *avatarService) put(ctx context.Context, key string, img image.Image) error {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		return err
+	}
+	return s.blobs.Put(ctx, key, buf.Bytes(), "image/jpeg")
+}
+
+// avatarThumbnailKey 由头像的key派生缩略图的key
+func avatarThumbnailKey(key string) string {
+	return strings.TrimSuffix(key, ".jpg") + "_thumb.jpg"
+}
+
+// deleteAvatarBlobs 删除头像和缩略图；失败只记录日志，残留的文件不影响用户记录
+func deleteAvatarBlobs(ctx context.Context, blobs storage.BlobStore, key string) {
+	if key == "" {
+		return
+	}
+	for _, k := range []string{key, avatarThumbnailKey(key)} {
+		if err := blobs.Delete(ctx, k); err != nil {
+			log.Printf("Failed to delete avatar blob %s: %v", k, err)
+		}
+	}
+}
+
+// processAvatar 嗅探并解码图片，居中裁剪为正方形后缩小到size，不放大
+func processAvatar(data []byte, size int) (*image.RGBA, error) {
+	decode, ok := avatarDecoders[http.DetectContentType(data)]
+	if !ok {
+		return nil, ErrUnsupportedAvatar
+	}
+	img, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	origin := image.Pt(bounds.Min.X+(bounds.Dx()-side)/2, bounds.Min.Y+(bounds.Dy()-side)/2)
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	// 透明部分铺白色背景，JPEG不支持透明度
+	draw.Draw(square, square.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(square, square.Bounds(), img, origin, draw.Over)
+
+	return resizeSquare(square, size), nil
+}
+
+// resizeSquare 用区域平均把正方形图片缩小到size×size，size不小于原尺寸时原样返回
+func resizeSquare(src *image.RGBA, size int) *image.RGBA {
+	side := src.Bounds().Dx()
+	if size <= 0 || size >= side {
+		return src
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0, y1 := y*side/size, (y+1)*side/size
+		for x := 0; x < size; x++ {
+			x0, x1 := x*side/size, (x+1)*side/size
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[src.PixOffset(x0, sy):src.PixOffset(x1, sy)]
+				for i := 0; i < len(row); i += 4 {
+					r += int(row[i])
+					g += int(row[i+1])
+					b += int(row[i+2])
+					a += int(row[i+3])
+					n++
+				}
+			}
+			offset := dst.PixOffset(x, y)
+			dst.Pix[offset] = uint8(r / n)
+			dst.Pix[offset+1] = uint8(g / n)
+			dst.Pix[offset+2] = uint8(b / n)
+			dst.Pix[offset+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// decodeWith 先读取图片尺寸，尺寸合理时才完整解码；GIF只取第一帧
+func decodeWith(decode func(io.Reader) (image.Image, error), decodeConfig func(io.Reader) (image.Config, error)) func([]byte) (image.Image, error) {
+	return func(data []byte) (image.Image, error) {
+		cfg, err := decodeConfig(bytes.NewReader(data))
+		if err != nil || cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxAvatarPixels {
+			return nil, ErrInvalidAvatar
+		}
+		img, err := decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrInvalidAvatar
+		}
+		return img, nil
+	}
+}
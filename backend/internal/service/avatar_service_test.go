@@ -0,0 +1,123 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/user/user-management/internal/config"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/storage"
+)
+
+func TestAvatarUpload(t *testing.T) {
+	dir := t.TempDir()
+	blobs, err := storage.NewLocalStore(dir, "/api/v1/blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := &memoryUserRepository{}
+	alice := &models.User{Username: "alice", Email: "alice@example.com", IsActive: true, Role: models.RoleUser}
+	users.Create(alice)
+	svc := NewAvatarService(config.AvatarConfig{MaxSize: 1 << 20, Size: 128, ThumbnailSize: 32}, users, blobs)
+	ctx := context.Background()
+
+	// 300×200的PNG，左侧透明，右侧红色
+	src := image.NewNRGBA(image.Rect(0, 0, 300, 200))
+	for y := 0; y < 200; y++ {
+		for x := 150; x < 300; x++ {
+			src.Set(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, src); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := svc.Upload(ctx, alice.ID, pngData.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(user.AvatarURL, "/api/v1/blobs/avatars/1/") || !strings.HasSuffix(user.AvatarThumbnailURL, "_thumb.jpg") {
+		t.Fatalf("unexpected avatar URLs %s, %s", user.AvatarURL, user.AvatarThumbnailURL)
+	}
+	avatar := readAvatar(t, dir, user.AvatarKey)
+	if size := avatar.Bounds().Size(); size != image.Pt(128, 128) {
+		t.Fatalf("expected 128x128 avatar, got %v", size)
+	}
+	// 居中裁剪后左侧1/4透明，背景为白色
+	if r, g, b, _ := avatar.At(5, 64).RGBA(); r>>8 < 240 || g>>8 < 240 || b>>8 < 240 {
+		t.Fatalf("expected transparent area to be white, got %d %d %d", r>>8, g>>8, b>>8)
+	}
+	if r, g, _, _ := avatar.At(120, 64).RGBA(); r>>8 < 240 || g>>8 > 20 {
+		t.Fatalf("expected right side to be red, got %d %d", r>>8, g>>8)
+	}
+	thumbnail := readAvatar(t, dir, avatarThumbnailKey(user.AvatarKey))
+	if size := thumbnail.Bounds().Size(); size != image.Pt(32, 32) {
+		t.Fatalf("expected 32x32 thumbnail, got %v", size)
+	}
+
+	// JPEG中的EXIF在重新编码后被去除，比目标尺寸小的图片不放大
+	var jpegData bytes.Buffer
+	if err := jpeg.Encode(&jpegData, image.NewRGBA(image.Rect(0, 0, 40, 60)), nil); err != nil {
+		t.Fatal(err)
+	}
+	exif := append([]byte{0xFF, 0xE1, 0x00, 0x10}, []byte("Exif\x00\x00GPS-DATA")...)
+	withExif := append(append(append([]byte{}, jpegData.Bytes()[:2]...), exif...), jpegData.Bytes()[2:]...)
+	previous := user.AvatarKey
+	if user, err = svc.Upload(ctx, alice.ID, withExif); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := os.ReadFile(filepath.Join(dir, user.AvatarKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stored, []byte("GPS-DATA")) {
+		t.Fatal("expected EXIF metadata to be stripped")
+	}
+	if size := readAvatar(t, dir, user.AvatarKey).Bounds().Size(); size != image.Pt(40, 40) {
+		t.Fatalf("expected small avatar not to be upscaled, got %v", size)
+	}
+	if _, err := os.Stat(filepath.Join(dir, previous)); !os.IsNotExist(err) {
+		t.Fatalf("expected previous avatar to be deleted, got %v", err)
+	}
+
+	if _, err := svc.Upload(ctx, alice.ID, []byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>")); !errors.Is(err, ErrUnsupportedAvatar) {
+		t.Fatalf("expected SVG to be rejected, got %v", err)
+	}
+	if _, err := svc.Upload(ctx, alice.ID, pngData.Bytes()[:64]); !errors.Is(err, ErrInvalidAvatar) {
+		t.Fatalf("expected truncated PNG to be rejected, got %v", err)
+	}
+
+	key := user.AvatarKey
+	if user, err = svc.Delete(ctx, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	if user.AvatarKey != "" || user.AvatarURL != "" || user.AvatarThumbnailURL != "" {
+		t.Fatalf("expected avatar to be cleared, got %+v", user)
+	}
+	if _, err := os.Stat(filepath.Join(dir, avatarThumbnailKey(key))); !os.IsNotExist(err) {
+		t.Fatalf("expected thumbnail to be deleted, got %v", err)
+	}
+}
+
+func readAvatar(t *testing.T, dir, key string) image.Image {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// BreachedPasswordSource 按k-anonymity方式查询泄露密码：调用方只提交SHA-1的前5位十六进制，
+// 取回该前缀下所有哈希的后35位后在本地比对，与Have I Been Pwned的range接口一致
+type BreachedPasswordSource interface {
+	Range(prefix string) ([]string, error)
+}
+
+const breachedHashPrefixLength = 5
+
+// breachedPasswordFile 在按哈希升序排列的"SHA1:次数"文件中二分查找前缀，
+// 不把文件读入内存，完整的泄露库有数十GB
+type breachedPasswordFile struct {
+	file *os.File
+	size int64
+}
+
+// OpenBreachedPasswordFile 打开泄露密码文件，文件需在服务运行期间保持可读
+func OpenBreachedPasswordFile(path string) (BreachedPasswordSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &breachedPasswordFile{file: file, size: info.Size()}, nil
+}
+
+func (f *breachedPasswordFile) Range(prefix string) ([]string, error) {
+	prefix = strings.ToUpper(prefix)
+	if len(prefix) != breachedHashPrefixLength {
+		return nil, fmt.Errorf("hash prefix must be %d hex characters", breachedHashPrefixLength)
+	}
+
+	// 找到第一行哈希不小于prefix的位置：对任意偏移取其后第一个完整行比较
+	lo, hi := int64(0), f.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		_, line, err := f.lineAfter(mid)
+		if err != nil {
+			return nil, err
+		}
+		if line != "" && breachedHash(line) < prefix {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+
+	start, _, err := f.lineAfter(lo)
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(io.NewSectionReader(f.file, start, f.size-start))
+	var suffixes []string
+	for {
+		line, err := reader.ReadString('\n')
+		hash := breachedHash(line)
+		if !strings.HasPrefix(hash, prefix) {
+			break
+		}
+		suffixes = append(suffixes, hash[breachedHashPrefixLength:])
+		if err != nil {
+			break
+		}
+	}
+	return suffixes, nil
+}
+
+// lineAfter 返回从offset起（offset不在行首时跳到下一行）第一个完整行的起始位置和内容，到达文件末尾时内容为空
+func (f *breachedPasswordFile) lineAfter(offset int64) (int64, string, error) {
+	start := offset
+	if offset > 0 {
+		start = offset - 1
+	}
+	reader := bufio.NewReaderSize(io.NewSectionReader(f.file, start, f.size-start), 256)
+	if offset > 0 {
+		skipped, err := reader.ReadString('\n')
+		if errors.Is(err, io.EOF) {
+			return f.size, "", nil
+		}
+		if err != nil {
+			return 0, "", err
+		}
+		start += int64(len(skipped))
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, "", err
+	}
+	return start, line, nil
+}
+
+// breachedHash 取出一行中冒号前的哈希
+func breachedHash(line string) string {
+	hash, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+	return strings.ToUpper(hash)
+}
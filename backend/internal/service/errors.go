@@ -0,0 +1,251 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrorKind 决定错误对应的HTTP状态码，由middleware.ErrorHandler统一映射
+type ErrorKind int
+
+const (
+	KindInternal ErrorKind = iota
+	KindBadRequest
+	KindValidation
+	KindUnauthorized
+	KindInvalidCredentials
+	KindForbidden
+	KindDisabled
+	KindLocked
+	KindNotFound
+	KindConflict
+	KindPreconditionFailed
+	KindPreconditionRequired
+	KindUnsupportedMediaType
+	KindTooManyRequests
+	KindPayloadTooLarge
+)
+
+// Error 是带有稳定错误码的领域错误，Code供客户端识别，不随提示文案变化
+// Params用于填充本地化消息模板中的占位符
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+	Params  map[string]string
+	Fields  []FieldError
+}
+
+// FieldError 描述单个字段的校验失败原因
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Param   string `json:"param,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is 按错误码比较，使携带字段详情的副本仍能匹配对应的哨兵错误
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+func NewError(kind ErrorKind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func NewValidationError(fields ...FieldError) *Error {
+	return &Error{
+		Kind:    KindValidation,
+		Code:    "validation_failed",
+		Message: "Request validation failed",
+		Fields:  fields,
+	}
+}
+
+// AsError 提取领域错误，其他错误视为内部错误
+func AsError(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
+
+// ErrorCode 返回错误码，非领域错误返回internal_error
+func ErrorCode(err error) string {
+	if e, ok := AsError(err); ok {
+		return e.Code
+	}
+	return ErrInternal.Code
+}
+
+var (
+	ErrInternal                 = NewError(KindInternal, "internal_error", "Internal server error")
+	ErrInvalidRequest           = NewError(KindBadRequest, "invalid_request", "Invalid request format")
+	ErrUnauthorized             = NewError(KindUnauthorized, "unauthorized", "Authorization header is required")
+	ErrInvalidAuthHeader        = NewError(KindUnauthorized, "invalid_authorization_header", "Invalid authorization header format")
+	ErrInvalidToken             = NewError(KindUnauthorized, "invalid_token", "Invalid or expired token")
+	ErrInvalidCredentials       = NewError(KindInvalidCredentials, "invalid_credentials", "Invalid credentials")
+	ErrInvalidRefreshToken      = NewError(KindUnauthorized, "invalid_refresh_token", "Invalid refresh token")
+	ErrRefreshTokenExpired      = NewError(KindUnauthorized, "refresh_token_expired", "Refresh token expired")
+	ErrForbidden                = NewError(KindForbidden, "forbidden", "Insufficient permissions")
+	ErrAccountDisabled          = NewError(KindDisabled, "account_disabled", "User account is disabled")
+	ErrAccountLocked            = NewError(KindLocked, "account_locked", "User account is locked")
+	ErrUserNotFound             = NewError(KindNotFound, "user_not_found", "User not found")
+	ErrEmailTaken               = NewError(KindConflict, "email_taken", "Email already exists")
+	ErrUsernameTaken            = NewError(KindConflict, "username_taken", "Username already exists")
+	ErrRegistrationDisabled     = NewError(KindForbidden, "registration_disabled", "Sign-up is by invitation only, please ask an administrator to invite you")
+	ErrInvalidRole              = &Error{Kind: KindValidation, Code: "invalid_role", Message: "Invalid role", Fields: []FieldError{{Field: "role", Code: "oneof", Message: "role must be one of: user admin", Param: "user admin"}}}
+	ErrPreconditionFailed       = NewError(KindPreconditionFailed, "precondition_failed", "User has been modified by another request")
+	ErrPreconditionMissing      = NewError(KindPreconditionRequired, "precondition_required", "If-Match header is required")
+	ErrAccessTokenNotFound      = NewError(KindNotFound, "access_token_not_found", "Access token not found")
+	ErrInsufficientScope        = NewError(KindForbidden, "insufficient_scope", "Access token does not grant the required scope")
+	ErrSessionRequired          = NewError(KindForbidden, "session_required", "This operation requires an interactive login session")
+	ErrInvalidClient            = NewError(KindUnauthorized, "invalid_client", "Invalid client credentials")
+	ErrServiceAccountNotFound   = NewError(KindNotFound, "service_account_not_found", "Service account not found")
+	ErrServiceAccountNameTaken  = NewError(KindConflict, "service_account_name_taken", "Service account name already exists")
+	ErrOAuthClientNotFound      = NewError(KindNotFound, "oauth_client_not_found", "OAuth client not found")
+	ErrInvalidGrant             = NewError(KindBadRequest, "invalid_grant", "Authorization code is invalid, expired or already used")
+	ErrUnsupportedResponseType  = NewError(KindBadRequest, "unsupported_response_type", "Only the authorization code flow is supported")
+	ErrPKCERequired             = NewError(KindBadRequest, "pkce_required", "PKCE with code_challenge_method S256 is required")
+	ErrFederationDisabled       = NewError(KindNotFound, "federation_disabled", "External identity provider login is not configured")
+	ErrFederationState          = NewError(KindBadRequest, "invalid_federation_state", "Login request is invalid or has expired")
+	ErrFederatedLoginFailed     = NewError(KindUnauthorized, "federated_login_failed", "Identity provider login could not be verified")
+	ErrFederatedEmailUnverified = NewError(KindForbidden, "federated_email_unverified", "Identity provider did not return a verified email address")
+	ErrInvalidLoginCode         = NewError(KindUnauthorized, "invalid_login_code", "Login code is invalid or has expired")
+	ErrSAMLConnectionNotFound   = NewError(KindNotFound, "saml_connection_not_found", "SAML connection not found")
+	ErrSAMLConnectionSlugTaken  = NewError(KindConflict, "saml_connection_slug_taken", "SAML connection slug already exists")
+	ErrSAMLAssertionInvalid     = NewError(KindUnauthorized, "saml_assertion_invalid", "SAML assertion could not be verified")
+	ErrSAMLAssertionReplayed    = NewError(KindUnauthorized, "saml_assertion_replayed", "SAML assertion has already been used")
+	ErrSAMLEmailDomain          = NewError(KindForbidden, "saml_email_domain", "Email address is not in a domain allowed for this organisation")
+	ErrInvalidSAMLSlug          = &Error{Kind: KindValidation, Code: "invalid_saml_slug", Message: "Slug may only contain lowercase letters, digits and hyphens", Fields: []FieldError{{Field: "slug", Code: "slug", Message: "slug may only contain lowercase letters, digits and hyphens"}}}
+	ErrInvalidSAMLMetadata      = &Error{Kind: KindValidation, Code: "invalid_saml_metadata", Message: "IdP metadata is not a valid SAML EntityDescriptor", Fields: []FieldError{{Field: "idp_metadata", Code: "saml_metadata", Message: "idp_metadata must be an EntityDescriptor with a signing certificate and an HTTP-Redirect SSO endpoint"}}}
+	ErrGroupNotFound            = NewError(KindNotFound, "group_not_found", "Group not found")
+	ErrGroupNameTaken           = NewError(KindConflict, "group_name_taken", "Group name already exists")
+	ErrInvalidGroupName         = &Error{Kind: KindValidation, Code: "invalid_group_name", Message: "Group name must not be blank", Fields: []FieldError{{Field: "name", Code: "required", Message: "name must not be blank"}}}
+	ErrGroupCycle               = NewError(KindConflict, "group_cycle", "A group cannot contain itself or any group it belongs to")
+	ErrGroupTooDeep             = NewError(KindConflict, "group_nesting_too_deep", "Group nesting would exceed the maximum depth")
+	ErrSCIMInvalidFilter        = NewError(KindBadRequest, "scim_invalid_filter", "Filter expression is invalid or uses an unsupported attribute or operator")
+	ErrSCIMInvalidPath          = NewError(KindBadRequest, "scim_invalid_path", "PATCH path is invalid or not supported")
+	ErrSCIMInvalidValue         = NewError(KindBadRequest, "scim_invalid_value", "Attribute value is missing or invalid")
+	ErrWebAuthnCeremony         = NewError(KindBadRequest, "webauthn_ceremony_invalid", "Security key request is invalid or has expired")
+	ErrWebAuthnVerification     = NewError(KindInvalidCredentials, "webauthn_verification_failed", "Security key response could not be verified")
+	ErrWebAuthnKeyNotFound      = NewError(KindNotFound, "webauthn_credential_not_found", "Security key not found")
+	ErrSecondFactorTicket       = NewError(KindUnauthorized, "invalid_second_factor_ticket", "Second factor ticket is invalid or has expired")
+	ErrInvalidMagicLink         = NewError(KindUnauthorized, "invalid_magic_link", "Sign-in link is invalid, has expired or was requested from another browser")
+	ErrMagicLinkRateLimited     = NewError(KindTooManyRequests, "magic_link_rate_limited", "Too many sign-in links requested for this email, please try again later")
+	ErrReauthenticationRequired = NewError(KindForbidden, "reauthentication_required", "This action requires the current password or a recent login")
+	ErrInvalidCurrentPassword   = &Error{Kind: KindValidation, Code: "invalid_current_password", Message: "Current password is incorrect", Fields: []FieldError{{Field: "current_password", Code: "current_password", Message: "current_password is incorrect"}}}
+	ErrInvalidEmailChangeLink   = NewError(KindBadRequest, "invalid_email_change_link", "Email change link is invalid, has expired or has been superseded")
+	ErrImpersonationForbidden   = NewError(KindForbidden, "impersonation_forbidden", "This operation is not allowed while impersonating another user")
+	ErrImpersonationNotAllowed  = NewError(KindForbidden, "impersonation_not_allowed", "Administrators, inactive users and your own account cannot be impersonated")
+	ErrNotImpersonating         = NewError(KindBadRequest, "not_impersonating", "The current session is not impersonating a user")
+	ErrOrganizationNotFound     = NewError(KindNotFound, "organization_not_found", "Organization not found")
+	ErrOrganizationSlugTaken    = NewError(KindConflict, "organization_slug_taken", "Organization slug already exists")
+	ErrInvalidOrganizationSlug  = &Error{Kind: KindValidation, Code: "invalid_organization_slug", Message: "Slug may only contain lowercase letters, digits and hyphens", Fields: []FieldError{{Field: "slug", Code: "slug", Message: "slug may only contain lowercase letters, digits and hyphens"}}}
+	ErrInvalidOrganizationRole  = &Error{Kind: KindValidation, Code: "invalid_organization_role", Message: "Invalid organization role", Fields: []FieldError{{Field: "role", Code: "oneof", Message: "role must be one of: owner admin member", Param: "owner admin member"}}}
+	ErrNotOrganizationMember    = NewError(KindForbidden, "not_organization_member", "You are not a member of this organization")
+	ErrMemberNotFound           = NewError(KindNotFound, "organization_member_not_found", "Organization member not found")
+	ErrAlreadyMember            = NewError(KindConflict, "already_organization_member", "User is already a member of this organization")
+	ErrLastOwner                = NewError(KindConflict, "last_organization_owner", "An organization must keep at least one owner")
+	ErrInvitationNotFound       = NewError(KindNotFound, "invitation_not_found", "Invitation not found")
+	ErrInvalidInvitation        = NewError(KindBadRequest, "invalid_invitation", "Invitation is invalid, has expired, was already used or was sent to another email address")
+	ErrInvalidTokenExpiry       = &Error{Kind: KindValidation, Code: "invalid_token_expiry", Message: "Token expiry must be in the future", Fields: []FieldError{{Field: "expires_at", Code: "future", Message: "expires_at must be in the future"}}}
+	ErrAttributeNotFound        = NewError(KindNotFound, "attribute_not_found", "Attribute not found")
+	ErrAttributeNameTaken       = NewError(KindConflict, "attribute_name_taken", "Attribute name already exists")
+	ErrInvalidAttributeName     = &Error{Kind: KindValidation, Code: "invalid_attribute_name", Message: "Attribute name must start with a lowercase letter and contain only lowercase letters, digits and underscores", Fields: []FieldError{{Field: "name", Code: "attribute_name", Message: "name must start with a lowercase letter and contain only lowercase letters, digits and underscores"}}}
+	ErrInvalidAttributeType     = &Error{Kind: KindValidation, Code: "invalid_attribute_type", Message: "Invalid attribute type", Fields: []FieldError{{Field: "type", Code: "oneof", Message: "type must be one of: string number boolean date enum", Param: "string number boolean date enum"}}}
+	ErrInvalidVisibility        = &Error{Kind: KindValidation, Code: "invalid_attribute_visibility", Message: "Invalid attribute visibility", Fields: []FieldError{{Field: "visibility", Code: "oneof", Message: "visibility must be one of: self_editable admin_only private", Param: "self_editable admin_only private"}}}
+	ErrInvalidAttributePattern  = &Error{Kind: KindValidation, Code: "invalid_attribute_pattern", Message: "Attribute pattern is not a valid regular expression", Fields: []FieldError{{Field: "pattern", Code: "regexp", Message: "pattern must be a valid regular expression"}}}
+	ErrAttributeOptionsRequired = &Error{Kind: KindValidation, Code: "attribute_options_required", Message: "Enum attributes need at least one option", Fields: []FieldError{{Field: "options", Code: "required", Message: "options is required"}}}
+	ErrUnsupportedAvatar        = NewError(KindUnsupportedMediaType, "unsupported_avatar_type", "Avatar must be a JPEG, PNG or GIF image")
+	ErrInvalidAvatar            = NewError(KindValidation, "invalid_avatar", "Avatar image could not be decoded or is too large")
+)
+
+// SecondFactorRequiredError 表示第一因素已通过但账号启用了第二因素，凭Ticket完成第二步登录
+type SecondFactorRequiredError struct {
+	Ticket string
+}
+
+func (e *SecondFactorRequiredError) Error() string {
+	return "second factor required"
+}
+
+// invalidScope 返回scope不在允许范围内的校验错误
+func invalidScope(scope string, allowed []string) *Error {
+	return &Error{
+		Kind:    KindValidation,
+		Code:    "invalid_scope",
+		Message: fmt.Sprintf("Unknown scope %q", scope),
+		Params:  map[string]string{"scope": scope},
+		Fields:  []FieldError{{Field: "scopes", Code: "oneof", Message: "scopes must be one of: " + strings.Join(allowed, " "), Param: strings.Join(allowed, " ")}},
+	}
+}
+
+// attributeNotEditable 返回当前用户无权修改扩展属性的错误
+func attributeNotEditable(name string) *Error {
+	return &Error{
+		Kind:    KindForbidden,
+		Code:    "attribute_not_editable",
+		Message: fmt.Sprintf("Attribute %q cannot be modified by the current user", name),
+		Params:  map[string]string{"attribute": name},
+		Fields:  []FieldError{{Field: "attributes." + name, Code: "not_patchable", Message: "attributes." + name + " cannot be modified"}},
+	}
+}
+
+// invalidAttributeFilter 返回按未定义或不可见的扩展属性过滤用户列表的错误
+func invalidAttributeFilter(name string) *Error {
+	return &Error{
+		Kind:    KindBadRequest,
+		Code:    "invalid_attribute_filter",
+		Message: fmt.Sprintf("Users cannot be filtered by attribute %q", name),
+		Params:  map[string]string{"attribute": name},
+	}
+}
+
+// weakPassword 返回新密码不满足密码策略的校验错误，fields为未通过的各条规则
+func weakPassword(fields []FieldError) *Error {
+	return &Error{
+		Kind:    KindValidation,
+		Code:    "weak_password",
+		Message: "Password does not meet the password policy",
+		Fields:  fields,
+	}
+}
+
+// invalidRedirectURI 返回回调地址不合法或未注册的校验错误
+func invalidRedirectURI(redirectURI string) *Error {
+	return &Error{
+		Kind:    KindValidation,
+		Code:    "invalid_redirect_uri",
+		Message: fmt.Sprintf("Invalid or unregistered redirect URI %q", redirectURI),
+		Params:  map[string]string{"redirect_uri": redirectURI},
+		Fields:  []FieldError{{Field: "redirect_uri", Code: "redirect_uri", Message: "redirect_uri must be a registered absolute http(s) URL without fragment"}},
+	}
+}
+
+// invalidSCIMPath 返回PATCH操作的path不合法或不支持的错误
+func invalidSCIMPath(path string) *Error {
+	return &Error{
+		Kind:    KindBadRequest,
+		Code:    ErrSCIMInvalidPath.Code,
+		Message: fmt.Sprintf("PATCH path %q is invalid or not supported", path),
+		Params:  map[string]string{"path": path},
+	}
+}
+
+// invalidSCIMValue 返回SCIM属性值缺失或不合法的错误
+func invalidSCIMValue(attribute string) *Error {
+	return &Error{
+		Kind:    KindBadRequest,
+		Code:    ErrSCIMInvalidValue.Code,
+		Message: fmt.Sprintf("Value of attribute %q is missing or invalid", attribute),
+		Params:  map[string]string{"attribute": attribute},
+	}
+}
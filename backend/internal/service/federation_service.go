@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/redis/go-redis/v9"
+	"github.com/user/user-management/internal/config"
+	"github.com/user/user-management/internal/repository"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// FederationStateTTL 是用户在身份提供方完成登录的时限
+	FederationStateTTL = 10 * time.Minute
+	// 回调后前端用一次性登录码换取令牌的时限
+	federationHandoffTTL = time.Minute
+)
+
+// FederationHandoff 是回调时签发的会话，前端凭一次性登录码领取，避免令牌出现在URL中
+type FederationHandoff struct {
+	UserID       uint   `json:"user_id"`
+	Username     string `json:"username"`
+	Email        string `json:"email"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type FederationService interface {
+	Enabled() bool
+	Name() string
+	Begin(ctx context.Context, redirect string) (authURL, state string, err error)
+	Complete(ctx context.Context, state, code string) (*FederatedLogin, error)
+	CreateHandoff(handoff *FederationHandoff) (string, error)
+	RedeemHandoff(code string) (*FederationHandoff, error)
+}
+
+// federationState 在跳转到身份提供方前保存，回调时一次性取出
+type federationState struct {
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Redirect string `json:"redirect"`
+}
+
+type federationService struct {
+	cfg    config.FederationConfig
+	linker *identityLinker
+	redis  *redis.Client
+
+	// 发现文档在首次使用时获取，身份提供方不可用时不影响服务启动
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+func NewFederationService(cfg config.FederationConfig, userRepo repository.UserRepository, identityRepo repository.LinkedIdentityRepository, redisClient *redis.Client) FederationService {
+	return &federationService{
+		cfg:    cfg,
+		linker: &identityLinker{userRepo: userRepo, identityRepo: identityRepo, invitationOnly: cfg.InvitationOnly},
+		redis:  redisClient,
+	}
+}
+
+func (s *federationService) Enabled() bool {
+	return s.cfg.Issuer != "" && s.cfg.ClientID != ""
+}
+
+func (s *federationService) Name() string {
+	return s.cfg.Name
+}
+
+// Begin 生成state、nonce和PKCE校验码，返回身份提供方的授权地址
+func (s *federationService) Begin(ctx context.Context, redirect string) (string, string, error) {
+	if !s.Enabled() {
+		return "", "", ErrFederationDisabled
+	}
+	provider, err := s.getProvider()
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := randomHex(16)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomHex(16)
+	if err != nil {
+		return "", "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	data, err := json.Marshal(federationState{Nonce: nonce, Verifier: verifier, Redirect: redirect})
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.redis.Set(ctx, federationStateKey(state), data, FederationStateTTL).Err(); err != nil {
+		return "", "", err
+	}
+
+	authURL := s.oauth2Config(provider).AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+	return authURL, state, nil
+}
+
+// Complete 用授权码换取ID Token并校验，然后找到或创建对应的本地用户
+func (s *federationService) Complete(ctx context.Context, state, code string) (*FederatedLogin, error) {
+	if !s.Enabled() {
+		return nil, ErrFederationDisabled
+	}
+
+	data, err := s.redis.GetDel(ctx, federationStateKey(state)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrFederationState
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved federationState
+	if err := json.Unmarshal([]byte(data), &saved); err != nil {
+		return nil, err
+	}
+
+	provider, err := s.getProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.oauth2Config(provider).Exchange(ctx, code, oauth2.VerifierOption(saved.Verifier))
+	if err != nil {
+		return nil, ErrFederatedLoginFailed
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, ErrFederatedLoginFailed
+	}
+
+	// 校验签名（身份提供方的JWKS）、iss、aud和exp
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: s.cfg.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, ErrFederatedLoginFailed
+	}
+	if idToken.Nonce != saved.Nonce {
+		return nil, ErrFederatedLoginFailed
+	}
+
+	var claims struct {
+		Email             string      `json:"email"`
+		EmailVerified     interface{} `json:"email_verified"`
+		PreferredUsername string      `json:"preferred_username"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, ErrFederatedLoginFailed
+	}
+
+	login, err := s.linker.resolve(idToken.Issuer, idToken.Subject, claims.Email, emailVerified(claims.EmailVerified), claims.PreferredUsername)
+	if err != nil {
+		return nil, err
+	}
+	login.Redirect = saved.Redirect
+	return login, nil
+}
+
+func (s *federationService) CreateHandoff(handoff *FederationHandoff) (string, error) {
+	code, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(handoff)
+	if err != nil {
+		return "", err
+	}
+	if err := s.redis.Set(context.Background(), federationHandoffKey(code), data, federationHandoffTTL).Err(); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+func (s *federationService) RedeemHandoff(code string) (*FederationHandoff, error) {
+	data, err := s.redis.GetDel(context.Background(), federationHandoffKey(code)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrInvalidLoginCode
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var handoff FederationHandoff
+	if err := json.Unmarshal([]byte(data), &handoff); err != nil {
+		return nil, err
+	}
+	return &handoff, nil
+}
+
+func (s *federationService) getProvider() (*oidc.Provider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.provider == nil {
+		// Provider会在之后按需刷新JWKS，不能绑定到单个请求的context
+		ctx := oidc.ClientContext(context.Background(), &http.Client{Timeout: 10 * time.Second})
+		provider, err := oidc.NewProvider(ctx, s.cfg.Issuer)
+		if err != nil {
+			return nil, fmt.Errorf("discover identity provider: %w", err)
+		}
+		s.provider = provider
+	}
+	return s.provider, nil
+}
+
+func (s *federationService) oauth2Config(provider *oidc.Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     s.cfg.ClientID,
+		ClientSecret: s.cfg.ClientSecret,
+		RedirectURL:  s.cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       s.cfg.Scopes,
+	}
+}
+
+// emailVerified 兼容部分身份提供方以字符串返回email_verified
+func emailVerified(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+func federationStateKey(state string) string {
+	return fmt.Sprintf("federation:state:%s", state)
+}
+
+func federationHandoffKey(code string) string {
+	return fmt.Sprintf("federation:handoff:%s", hashAccessToken(code))
+}
@@ -0,0 +1,465 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/user/user-management/internal/config"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+const mockClientID = "user-management"
+
+// mockIdP 是本地的OpenID Connect身份提供方，授权端点由测试直接调用issueCode代替浏览器跳转
+type mockIdP struct {
+	server *httptest.Server
+	key    *SigningKey
+	// forgedKey 不为空时用它签发ID Token，JWKS仍然只发布key
+	forgedKey *SigningKey
+
+	mu    sync.Mutex
+	codes map[string]mockGrant
+}
+
+type mockGrant struct {
+	claims    jwt.MapClaims
+	challenge string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdP{key: newSigningKey(key), codes: make(map[string]mockGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                idp.server.URL,
+			"authorization_endpoint":                idp.server.URL + "/authorize",
+			"token_endpoint":                        idp.server.URL + "/token",
+			"jwks_uri":                              idp.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []JWK{idp.key.JWK()}})
+	})
+	mux.HandleFunc("/token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	grant, ok := idp.codes[r.PostFormValue("code")]
+	delete(idp.codes, r.PostFormValue("code"))
+	idp.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	key := idp.key
+	if idp.forgedKey != nil {
+		key = idp.forgedKey
+	}
+	idToken, err := idp.sign(key, grant.claims)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "mock-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (idp *mockIdP) sign(key *SigningKey, claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
+}
+
+// issueCode 模拟用户在身份提供方登录后签发授权码，claims覆盖默认的ID Token声明
+func (idp *mockIdP) issueCode(t *testing.T, authURL string, claims jwt.MapClaims) (state, code string) {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("client_id") != mockClientID {
+		t.Fatalf("unexpected authorization request: %s", authURL)
+	}
+
+	now := time.Now()
+	idClaims := jwt.MapClaims{
+		"iss":   idp.server.URL,
+		"aud":   mockClientID,
+		"sub":   "idp-user-1",
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"nonce": query.Get("nonce"),
+	}
+	for name, value := range claims {
+		idClaims[name] = value
+	}
+
+	code, err = randomHex(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.mu.Lock()
+	idp.codes[code] = mockGrant{claims: idClaims, challenge: query.Get("code_challenge")}
+	idp.mu.Unlock()
+	return query.Get("state"), code
+}
+
+type memoryUserRepository struct {
+	repository.UserRepository
+	users         []*models.User
+	refreshTokens []*models.RefreshToken
+}
+
+// WithContext 返回自身：内存仓库不区分租户
+func (r *memoryUserRepository) WithContext(ctx context.Context) repository.UserRepository {
+	return r
+}
+
+func (r *memoryUserRepository) Create(user *models.User) error {
+	user.ID = uint(len(r.users) + 1)
+	r.users = append(r.users, user)
+	return nil
+}
+
+func (r *memoryUserRepository) GetByID(id uint) (*models.User, error) {
+	for _, user := range r.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryUserRepository) GetByEmail(email string) (*models.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryUserRepository) GetByUsername(username string) (*models.User, error) {
+	for _, user := range r.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+// Update 不需要做任何事，users中保存的就是调用方修改的指针
+func (r *memoryUserRepository) Update(user *models.User) error {
+	return nil
+}
+
+func (r *memoryUserRepository) UpdatePasswordHash(id uint, oldHash, newHash string) error {
+	user, _ := r.GetByID(id)
+	if user.PasswordHash != oldHash {
+		return repository.ErrVersionConflict
+	}
+	user.PasswordHash = newHash
+	return nil
+}
+
+func (r *memoryUserRepository) DeleteUserRefreshTokens(userID uint) error {
+	return nil
+}
+
+func (r *memoryUserRepository) SaveRefreshToken(token *models.RefreshToken) error {
+	r.refreshTokens = append(r.refreshTokens, token)
+	return nil
+}
+
+func (r *memoryUserRepository) GetRefreshToken(token string) (*models.RefreshToken, error) {
+	for _, refreshToken := range r.refreshTokens {
+		if refreshToken.Token == token {
+			return refreshToken, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryUserRepository) DeleteRefreshToken(token string) error {
+	for i, refreshToken := range r.refreshTokens {
+		if refreshToken.Token == token {
+			r.refreshTokens = append(r.refreshTokens[:i], r.refreshTokens[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (r *memoryUserRepository) CreateLoginRecord(session *models.UserSession) error {
+	return nil
+}
+
+type memoryIdentityRepository struct {
+	identities []*models.LinkedIdentity
+}
+
+func (r *memoryIdentityRepository) Create(identity *models.LinkedIdentity) error {
+	identity.ID = uint(len(r.identities) + 1)
+	r.identities = append(r.identities, identity)
+	return nil
+}
+
+func (r *memoryIdentityRepository) GetBySubject(issuer, subject string) (*models.LinkedIdentity, error) {
+	for _, identity := range r.identities {
+		if identity.Issuer == issuer && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryIdentityRepository) ListByUser(userID uint) ([]models.LinkedIdentity, error) {
+	var identities []models.LinkedIdentity
+	for _, identity := range r.identities {
+		if identity.UserID == userID {
+			identities = append(identities, *identity)
+		}
+	}
+	return identities, nil
+}
+
+func (r *memoryIdentityRepository) ListByIssuer(issuer string) ([]models.LinkedIdentity, error) {
+	var identities []models.LinkedIdentity
+	for _, identity := range r.identities {
+		if identity.Issuer == issuer {
+			identities = append(identities, *identity)
+		}
+	}
+	return identities, nil
+}
+
+func (r *memoryIdentityRepository) TouchLastLogin(id uint, loginAt time.Time) error {
+	return nil
+}
+
+func newTestFederationService(t *testing.T, idp *mockIdP, users *memoryUserRepository) FederationService {
+	t.Helper()
+
+	redisClient := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	return NewFederationService(config.FederationConfig{
+		Issuer:      idp.server.URL,
+		ClientID:    mockClientID,
+		RedirectURL: "http://localhost/api/v1/auth/oidc/callback",
+		Scopes:      []string{"openid", "profile", "email"},
+	}, users, &memoryIdentityRepository{}, redisClient)
+}
+
+// federatedLogin 走完一次完整的登录流程
+func federatedLogin(t *testing.T, svc FederationService, idp *mockIdP, claims jwt.MapClaims) (*FederatedLogin, error) {
+	t.Helper()
+
+	authURL, state, err := svc.Begin(context.Background(), "/profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	returnedState, code := idp.issueCode(t, authURL, claims)
+	if returnedState != state {
+		t.Fatalf("state = %q, want %q", returnedState, state)
+	}
+	return svc.Complete(context.Background(), state, code)
+}
+
+func TestFederationProvisionsUserOnFirstLogin(t *testing.T) {
+	idp := newMockIdP(t)
+	users := &memoryUserRepository{}
+	svc := newTestFederationService(t, idp, users)
+
+	claims := jwt.MapClaims{"email": "alice@corp.example", "email_verified": true, "preferred_username": "alice"}
+	first, err := federatedLogin(t, svc, idp, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.Provisioned || first.User.Username != "alice" || first.User.Email != "alice@corp.example" || first.Redirect != "/profile" {
+		t.Fatalf("unexpected first login: %+v", first)
+	}
+
+	second, err := federatedLogin(t, svc, idp, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Provisioned || second.Linked || second.User.ID != first.User.ID {
+		t.Fatalf("second login should reuse the linked identity: %+v", second)
+	}
+	if len(users.users) != 1 {
+		t.Fatalf("users = %d, want 1", len(users.users))
+	}
+}
+
+func TestFederationLinksExistingUserByVerifiedEmail(t *testing.T) {
+	idp := newMockIdP(t)
+	users := &memoryUserRepository{}
+	users.Create(&models.User{Username: "bob", Email: "bob@corp.example", IsActive: true})
+	svc := newTestFederationService(t, idp, users)
+
+	result, err := federatedLogin(t, svc, idp, jwt.MapClaims{"sub": "idp-bob", "email": "bob@corp.example", "email_verified": "true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Linked || result.User.ID != 1 || result.Identity.Subject != "idp-bob" {
+		t.Fatalf("unexpected login: %+v", result)
+	}
+}
+
+// REGISTRATION_MODE=invitation时只关联已有用户，不为首次登录的外部身份创建新用户
+func TestFederationInvitationOnlyDoesNotProvision(t *testing.T) {
+	idp := newMockIdP(t)
+	users := &memoryUserRepository{}
+	users.Create(&models.User{Username: "bob", Email: "bob@corp.example", IsActive: true})
+	svc := NewFederationService(config.FederationConfig{
+		Issuer:         idp.server.URL,
+		ClientID:       mockClientID,
+		RedirectURL:    "http://localhost/api/v1/auth/oidc/callback",
+		Scopes:         []string{"openid", "profile", "email"},
+		InvitationOnly: true,
+	}, users, &memoryIdentityRepository{}, redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}))
+
+	if _, err := federatedLogin(t, svc, idp, jwt.MapClaims{"email": "alice@corp.example", "email_verified": true}); !errors.Is(err, ErrRegistrationDisabled) {
+		t.Fatalf("err = %v, want %v", err, ErrRegistrationDisabled)
+	}
+	result, err := federatedLogin(t, svc, idp, jwt.MapClaims{"sub": "idp-bob", "email": "bob@corp.example", "email_verified": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Linked || len(users.users) != 1 {
+		t.Fatalf("unexpected login: %+v", result)
+	}
+}
+
+func TestFederationRejectsUnverifiedEmail(t *testing.T) {
+	idp := newMockIdP(t)
+	users := &memoryUserRepository{}
+	users.Create(&models.User{Username: "bob", Email: "bob@corp.example", IsActive: true})
+	svc := newTestFederationService(t, idp, users)
+
+	_, err := federatedLogin(t, svc, idp, jwt.MapClaims{"email": "bob@corp.example", "email_verified": false})
+	if !errors.Is(err, ErrFederatedEmailUnverified) {
+		t.Fatalf("err = %v, want %v", err, ErrFederatedEmailUnverified)
+	}
+}
+
+func TestFederationRejectsInvalidIDToken(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+	}{
+		{"nonce mismatch", jwt.MapClaims{"nonce": "replayed"}},
+		{"wrong audience", jwt.MapClaims{"aud": "another-client"}},
+		{"wrong issuer", jwt.MapClaims{"iss": "https://evil.example"}},
+		{"expired", jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newMockIdP(t)
+			svc := newTestFederationService(t, idp, &memoryUserRepository{})
+
+			claims := jwt.MapClaims{"email": "alice@corp.example", "email_verified": true}
+			for name, value := range tt.claims {
+				claims[name] = value
+			}
+			if _, err := federatedLogin(t, svc, idp, claims); !errors.Is(err, ErrFederatedLoginFailed) {
+				t.Fatalf("err = %v, want %v", err, ErrFederatedLoginFailed)
+			}
+		})
+	}
+
+	t.Run("unknown signing key", func(t *testing.T) {
+		idp := newMockIdP(t)
+		svc := newTestFederationService(t, idp, &memoryUserRepository{})
+		authURL, state, err := svc.Begin(context.Background(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, code := idp.issueCode(t, authURL, jwt.MapClaims{"email": "alice@corp.example", "email_verified": true})
+		idp.forgedKey = newSigningKey(otherKey)
+		idp.forgedKey.ID = idp.key.ID
+
+		if _, err := svc.Complete(context.Background(), state, code); !errors.Is(err, ErrFederatedLoginFailed) {
+			t.Fatalf("err = %v, want %v", err, ErrFederatedLoginFailed)
+		}
+	})
+}
+
+func TestFederationStateIsSingleUse(t *testing.T) {
+	idp := newMockIdP(t)
+	svc := newTestFederationService(t, idp, &memoryUserRepository{})
+
+	authURL, state, err := svc.Begin(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, code := idp.issueCode(t, authURL, jwt.MapClaims{"email": "alice@corp.example", "email_verified": true})
+	if _, err := svc.Complete(context.Background(), state, code); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Complete(context.Background(), state, code); !errors.Is(err, ErrFederationState) {
+		t.Fatalf("err = %v, want %v", err, ErrFederationState)
+	}
+	if _, err := svc.Complete(context.Background(), "unknown", code); !errors.Is(err, ErrFederationState) {
+		t.Fatalf("err = %v, want %v", err, ErrFederationState)
+	}
+}
+
+func TestFederationHandoffIsSingleUse(t *testing.T) {
+	idp := newMockIdP(t)
+	svc := newTestFederationService(t, idp, &memoryUserRepository{})
+
+	code, err := svc.CreateHandoff(&FederationHandoff{UserID: 1, AccessToken: "access", RefreshToken: "refresh"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handoff, err := svc.RedeemHandoff(code)
+	if err != nil || handoff.AccessToken != "access" {
+		t.Fatalf("handoff = %+v, err = %v", handoff, err)
+	}
+	if _, err := svc.RedeemHandoff(code); !errors.Is(err, ErrInvalidLoginCode) {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidLoginCode)
+	}
+}
@@ -0,0 +1,314 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/user/user-management/internal/config"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+// GroupDetail 是组及其直接成员和直接子组
+type GroupDetail struct {
+	Group     *models.Group
+	Members   []models.User
+	Subgroups []models.Group
+}
+
+// EffectiveGroup 是用户的有效组；Direct为false表示通过子组间接属于该组
+type EffectiveGroup struct {
+	Group  models.Group
+	Direct bool
+}
+
+// GroupService 管理组、直接成员和子组。用户的有效组是直接所属的组及其所有上级组，
+// 子组的成员同时属于父组；嵌套不能成环，层数不超过MaxDepth
+type GroupService interface {
+	List(page, limit int) ([]models.Group, int64, error)
+	Create(name string) (*models.Group, error)
+	// Get 只列出ctx中的租户（repository.WithTenant）内的成员
+	Get(ctx context.Context, id uint) (*GroupDetail, error)
+	Delete(id uint) (*models.Group, error)
+	// AddMember 和RemoveMember 只能操作ctx中的租户内的用户，其他用户视为不存在
+	AddMember(ctx context.Context, groupID, userID uint) error
+	RemoveMember(ctx context.Context, groupID, userID uint) error
+	// AddSubgroup 使childID组成为parentID组的子组
+	AddSubgroup(parentID, childID uint) error
+	RemoveSubgroup(parentID, childID uint) error
+	// EffectiveGroups 返回用户的有效组，按组名排序
+	EffectiveGroups(userID uint) ([]EffectiveGroup, error)
+	// ClaimGroups 返回写入访问令牌groups声明的有效组名；超过ClaimLimit时overage为true，不返回组名
+	ClaimGroups(userID uint) (names []string, overage bool, err error)
+	// InAnyGroup 判断用户的有效组是否包含names中的任一组
+	InAnyGroup(userID uint, names []string) (bool, error)
+}
+
+type groupService struct {
+	cfg       config.GroupConfig
+	groupRepo repository.GroupRepository
+	userRepo  repository.UserRepository
+}
+
+func NewGroupService(cfg config.GroupConfig, groupRepo repository.GroupRepository, userRepo repository.UserRepository) GroupService {
+	return &groupService{
+		cfg:       cfg,
+		groupRepo: groupRepo,
+		userRepo:  userRepo,
+	}
+}
+
+func (s *groupService) List(page, limit int) ([]models.Group, int64, error) {
+	offset := (page - 1) * limit
+	return s.groupRepo.Search(nil, offset, limit)
+}
+
+func (s *groupService) Create(name string) (*models.Group, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrInvalidGroupName
+	}
+
+	existing, err := s.groupRepo.GetByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrGroupNameTaken
+	}
+
+	group := &models.Group{Name: name}
+	if err := s.groupRepo.Create(group, nil); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (s *groupService) Get(ctx context.Context, id uint) (*GroupDetail, error) {
+	group, err := s.getGroup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &GroupDetail{Group: group}
+	if detail.Members, err = s.groupRepo.WithContext(ctx).ListMembers(id); err != nil {
+		return nil, err
+	}
+	if detail.Subgroups, err = s.groupRepo.ListSubgroups(id); err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
+
+func (s *groupService) Delete(id uint) (*models.Group, error) {
+	group, err := s.getGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.groupRepo.Delete(id); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (s *groupService) AddMember(ctx context.Context, groupID, userID uint) error {
+	if err := s.checkMember(ctx, groupID, userID); err != nil {
+		return err
+	}
+	return s.groupRepo.AddMember(groupID, userID)
+}
+
+func (s *groupService) RemoveMember(ctx context.Context, groupID, userID uint) error {
+	if err := s.checkMember(ctx, groupID, userID); err != nil {
+		return err
+	}
+	return s.groupRepo.RemoveMember(groupID, userID)
+}
+
+// checkMember 检查组存在，并且用户在ctx的租户内
+func (s *groupService) checkMember(ctx context.Context, groupID, userID uint) error {
+	if _, err := s.getGroup(groupID); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.WithContext(ctx).GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (s *groupService) AddSubgroup(parentID, childID uint) error {
+	if _, err := s.getGroup(parentID); err != nil {
+		return err
+	}
+	if _, err := s.getGroup(childID); err != nil {
+		return err
+	}
+	if parentID == childID {
+		return ErrGroupCycle
+	}
+
+	// 子组已经是父组的上级组时会成环；嵌套后最长的链为父组以上的层数、新的一层和子组以下的层数之和
+	above, ancestors, err := s.nestingDepth(parentID, s.parentIDs)
+	if err != nil {
+		return err
+	}
+	if ancestors[childID] {
+		return ErrGroupCycle
+	}
+	below, _, err := s.nestingDepth(childID, s.childIDs)
+	if err != nil {
+		return err
+	}
+	if above+1+below > s.cfg.MaxDepth {
+		return ErrGroupTooDeep
+	}
+
+	return s.groupRepo.AddSubgroup(parentID, childID)
+}
+
+func (s *groupService) RemoveSubgroup(parentID, childID uint) error {
+	if _, err := s.getGroup(parentID); err != nil {
+		return err
+	}
+	return s.groupRepo.RemoveSubgroup(parentID, childID)
+}
+
+func (s *groupService) EffectiveGroups(userID uint) ([]EffectiveGroup, error) {
+	direct, err := s.groupRepo.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[uint]bool, len(direct))
+	groups := make([]EffectiveGroup, 0, len(direct))
+	frontier := make([]uint, 0, len(direct))
+	for _, group := range direct {
+		seen[group.ID] = true
+		groups = append(groups, EffectiveGroup{Group: group, Direct: true})
+		frontier = append(frontier, group.ID)
+	}
+
+	// 从直接所属的组逐层向上查找父组，嵌套不超过MaxDepth层
+	for depth := 0; depth < s.cfg.MaxDepth && len(frontier) > 0; depth++ {
+		parents, err := s.groupRepo.ListParents(frontier)
+		if err != nil {
+			return nil, err
+		}
+		frontier = frontier[:0]
+		for _, parent := range parents {
+			if seen[parent.ParentID] {
+				continue
+			}
+			seen[parent.ParentID] = true
+			groups = append(groups, EffectiveGroup{Group: parent.Parent})
+			frontier = append(frontier, parent.ParentID)
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Group.Name < groups[j].Group.Name })
+	return groups, nil
+}
+
+func (s *groupService) ClaimGroups(userID uint) ([]string, bool, error) {
+	groups, err := s.EffectiveGroups(userID)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(groups) > s.cfg.ClaimLimit {
+		return nil, true, nil
+	}
+
+	names := make([]string, 0, len(groups))
+	for _, group := range groups {
+		names = append(names, group.Group.Name)
+	}
+	return names, false, nil
+}
+
+func (s *groupService) InAnyGroup(userID uint, names []string) (bool, error) {
+	if len(names) == 0 {
+		return false, nil
+	}
+	groups, err := s.EffectiveGroups(userID)
+	if err != nil {
+		return false, err
+	}
+	for _, group := range groups {
+		for _, name := range names {
+			if group.Group.Name == name {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (s *groupService) getGroup(id uint) (*models.Group, error) {
+	group, err := s.groupRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, ErrGroupNotFound
+	}
+	return group, nil
+}
+
+// nestingDepth 沿next逐层查找，返回从id出发最长的链的层数和经过的所有组；
+// 已有的嵌套不超过MaxDepth层，查找到MaxDepth+1层为止
+func (s *groupService) nestingDepth(id uint, next func(ids []uint) ([]uint, error)) (int, map[uint]bool, error) {
+	reached := make(map[uint]bool)
+	depth := 0
+	layer := []uint{id}
+	for depth <= s.cfg.MaxDepth {
+		ids, err := next(layer)
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(ids) == 0 {
+			break
+		}
+		depth++
+		// 同一层内去重，但不跳过更浅层已到达的组，这样得到的是最长链而不是最短路径
+		inLayer := make(map[uint]bool, len(ids))
+		layer = make([]uint, 0, len(ids))
+		for _, groupID := range ids {
+			reached[groupID] = true
+			if !inLayer[groupID] {
+				inLayer[groupID] = true
+				layer = append(layer, groupID)
+			}
+		}
+	}
+	return depth, reached, nil
+}
+
+func (s *groupService) parentIDs(ids []uint) ([]uint, error) {
+	subgroups, err := s.groupRepo.ListParents(ids)
+	if err != nil {
+		return nil, err
+	}
+	parents := make([]uint, 0, len(subgroups))
+	for _, subgroup := range subgroups {
+		parents = append(parents, subgroup.ParentID)
+	}
+	return parents, nil
+}
+
+func (s *groupService) childIDs(ids []uint) ([]uint, error) {
+	subgroups, err := s.groupRepo.ListChildren(ids)
+	if err != nil {
+		return nil, err
+	}
+	children := make([]uint, 0, len(subgroups))
+	for _, subgroup := range subgroups {
+		children = append(children, subgroup.ChildID)
+	}
+	return children, nil
+}
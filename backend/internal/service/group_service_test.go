@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/user/user-management/internal/config"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+func (r *memoryGroupRepository) ListByUser(userID uint) ([]models.Group, error) {
+	var groups []models.Group
+	for _, group := range r.groups {
+		for _, memberID := range r.members[group.ID] {
+			if memberID == userID {
+				groups = append(groups, *group)
+			}
+		}
+	}
+	return groups, nil
+}
+
+func (r *memoryGroupRepository) AddMember(groupID, userID uint) error {
+	r.members[groupID] = append(r.members[groupID], userID)
+	return nil
+}
+
+func (r *memoryGroupRepository) ListParents(groupIDs []uint) ([]models.Subgroup, error) {
+	var parents []models.Subgroup
+	for _, subgroup := range r.subgroups {
+		for _, id := range groupIDs {
+			if subgroup.ChildID == id {
+				parent, _ := r.GetByID(subgroup.ParentID)
+				subgroup.Parent = *parent
+				parents = append(parents, subgroup)
+			}
+		}
+	}
+	return parents, nil
+}
+
+func (r *memoryGroupRepository) ListChildren(groupIDs []uint) ([]models.Subgroup, error) {
+	var children []models.Subgroup
+	for _, subgroup := range r.subgroups {
+		for _, id := range groupIDs {
+			if subgroup.ParentID == id {
+				children = append(children, subgroup)
+			}
+		}
+	}
+	return children, nil
+}
+
+func (r *memoryGroupRepository) AddSubgroup(parentID, childID uint) error {
+	r.subgroups = append(r.subgroups, models.Subgroup{ParentID: parentID, ChildID: childID})
+	return nil
+}
+
+func newTestGroupService(users *memoryUserRepository) GroupService {
+	groups := &memoryGroupRepository{users: users, members: make(map[uint][]uint)}
+	return NewGroupService(config.GroupConfig{MaxDepth: 5, ClaimLimit: 50}, groups, users)
+}
+
+// effectiveGroupNames 返回有效组的组名，间接所属的组名前加上~
+func effectiveGroupNames(t *testing.T, svc GroupService, userID uint) []string {
+	t.Helper()
+
+	groups, err := svc.EffectiveGroups(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(groups))
+	for _, group := range groups {
+		if group.Direct {
+			names = append(names, group.Group.Name)
+		} else {
+			names = append(names, "~"+group.Group.Name)
+		}
+	}
+	return names
+}
+
+func TestNestedGroups(t *testing.T) {
+	users := &memoryUserRepository{}
+	alice := &models.User{Username: "alice", Email: "alice@example.com", IsActive: true}
+	users.Create(alice)
+	svc := NewGroupService(config.GroupConfig{MaxDepth: 2, ClaimLimit: 50}, &memoryGroupRepository{users: users, members: make(map[uint][]uint)}, users)
+	ctx := repository.WithAllTenants(context.Background())
+
+	group := func(name string) *models.Group {
+		created, err := svc.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return created
+	}
+	engineering, backend, platform, security := group("engineering"), group("backend"), group("platform"), group("security")
+	if _, err := svc.Create(" backend "); !errors.Is(err, ErrGroupNameTaken) {
+		t.Fatalf("expected duplicate name to be rejected, got %v", err)
+	}
+
+	for _, nesting := range [][2]*models.Group{{engineering, backend}, {backend, platform}, {security, platform}} {
+		if err := svc.AddSubgroup(nesting[0].ID, nesting[1].ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := svc.AddMember(ctx, platform.ID, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.AddMember(ctx, platform.ID, 99); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected unknown user to be rejected, got %v", err)
+	}
+
+	// 子组的成员同时属于所有上级组，包括多个父组
+	want := []string{"~backend", "~engineering", "platform", "~security"}
+	if got := effectiveGroupNames(t, svc, alice.ID); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if member, _ := svc.InAnyGroup(alice.ID, []string{"sales", "engineering"}); !member {
+		t.Fatal("expected alice to be in engineering through platform")
+	}
+
+	if err := svc.AddSubgroup(platform.ID, platform.ID); !errors.Is(err, ErrGroupCycle) {
+		t.Fatalf("expected self nesting to be rejected, got %v", err)
+	}
+	if err := svc.AddSubgroup(platform.ID, engineering.ID); !errors.Is(err, ErrGroupCycle) {
+		t.Fatalf("expected cycle to be rejected, got %v", err)
+	}
+	// engineering > backend > platform 已达到两层
+	company := group("company")
+	if err := svc.AddSubgroup(company.ID, engineering.ID); !errors.Is(err, ErrGroupTooDeep) {
+		t.Fatalf("expected nesting above the limit to be rejected, got %v", err)
+	}
+	if err := svc.AddSubgroup(platform.ID, company.ID); !errors.Is(err, ErrGroupTooDeep) {
+		t.Fatalf("expected nesting above the limit to be rejected, got %v", err)
+	}
+	if err := svc.AddSubgroup(company.ID, backend.ID); err != nil {
+		t.Fatalf("expected company > backend > platform to be allowed, got %v", err)
+	}
+}
+
+func TestGroupsClaim(t *testing.T) {
+	mr := miniredis.RunT(t)
+	sessions := NewSessionService(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+
+	users := &memoryUserRepository{}
+	alice := &models.User{Username: "alice", Email: "alice@example.com", IsActive: true}
+	users.Create(alice)
+	groups := NewGroupService(config.GroupConfig{MaxDepth: 5, ClaimLimit: 2}, &memoryGroupRepository{users: users, members: make(map[uint][]uint)}, users)
+	ctx := repository.WithAllTenants(context.Background())
+	svc := NewAuthService(users, nil, &memoryOrganizationRepository{}, groups, sessions, nil, nil, newTestPasswordPolicy(t), "test-secret", time.Hour, 30*time.Minute)
+
+	claims := func() jwt.MapClaims {
+		accessToken, _, err := svc.StartSession(alice, "127.0.0.1", "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims := jwt.MapClaims{}
+		if _, _, err := jwt.NewParser().ParseUnverified(accessToken, claims); err != nil {
+			t.Fatal(err)
+		}
+		return claims
+	}
+
+	if got := claims()["groups"]; !reflect.DeepEqual(got, []interface{}{}) {
+		t.Fatalf("expected empty groups claim, got %v", got)
+	}
+
+	departments, _ := groups.Create("departments")
+	backend, _ := groups.Create("backend")
+	groups.AddSubgroup(departments.ID, backend.ID)
+	groups.AddMember(ctx, backend.ID, alice.ID)
+	if got := claims()["groups"]; !reflect.DeepEqual(got, []interface{}{"backend", "departments"}) {
+		t.Fatalf("expected nested groups in claim, got %v", got)
+	}
+
+	// 超过上限时不写入组名，由客户端调用接口查询
+	oncall, _ := groups.Create("oncall")
+	groups.AddMember(ctx, oncall.ID, alice.ID)
+	got := claims()
+	if _, ok := got["groups"]; ok || got["groups_overage"] != true {
+		t.Fatalf("expected groups overage, got %v", got)
+	}
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// FederatedLogin 是外部身份提供方登录的结果
+type FederatedLogin struct {
+	User        *models.User
+	Identity    *models.LinkedIdentity
+	Linked      bool
+	Provisioned bool
+	Redirect    string
+}
+
+// identityLinker 把外部身份提供方（OIDC、SAML）的账号关联到本地用户
+type identityLinker struct {
+	userRepo     repository.UserRepository
+	identityRepo repository.LinkedIdentityRepository
+	// invitationOnly 为true时只关联邮箱已存在的用户，不创建新用户
+	invitationOnly bool
+}
+
+// resolve 按(issuer, subject)查找已关联的用户；首次登录时按已验证的邮箱关联已有用户，没有则创建新用户
+func (l *identityLinker) resolve(issuer, subject, email string, verified bool, preferredUsername string) (*FederatedLogin, error) {
+	now := time.Now()
+
+	identity, err := l.identityRepo.GetBySubject(issuer, subject)
+	if err != nil {
+		return nil, err
+	}
+	if identity != nil {
+		user, err := l.userRepo.GetByID(identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, ErrUserNotFound
+		}
+		l.identityRepo.TouchLastLogin(identity.ID, now)
+		return &FederatedLogin{User: user, Identity: identity}, nil
+	}
+
+	// 未验证的邮箱可能被他人冒用，不能据此关联或创建账号
+	if email == "" || !verified {
+		return nil, ErrFederatedEmailUnverified
+	}
+
+	login := &FederatedLogin{}
+	user, err := l.userRepo.GetByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		login.Linked = true
+	} else {
+		if l.invitationOnly {
+			return nil, ErrRegistrationDisabled
+		}
+		if user, err = l.provisionUser(email, preferredUsername); err != nil {
+			return nil, err
+		}
+		login.Provisioned = true
+	}
+
+	identity = &models.LinkedIdentity{
+		UserID:      user.ID,
+		Issuer:      issuer,
+		Subject:     subject,
+		Email:       email,
+		LastLoginAt: &now,
+	}
+	if err := l.identityRepo.Create(identity); err != nil {
+		return nil, err
+	}
+
+	login.User = user
+	login.Identity = identity
+	return login, nil
+}
+
+// provisionUser 创建只能通过身份提供方登录的用户，不能用本地密码登录
+func (l *identityLinker) provisionUser(email, preferredUsername string) (*models.User, error) {
+	username, err := l.availableUsername(email, preferredUsername)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		Username:     username,
+		Email:        email,
+		PasswordHash: UnusablePassword,
+		IsActive:     true,
+		Role:         models.RoleUser,
+	}
+	if err := l.userRepo.Create(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// availableUsername 优先使用preferred_username，其次是邮箱的本地部分；已被占用时追加随机后缀
+func (l *identityLinker) availableUsername(email, preferredUsername string) (string, error) {
+	base := preferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(email, "@")
+	}
+	base = usernameInvalidChars.ReplaceAllString(base, "")
+	if len(base) > 15 {
+		base = base[:15]
+	}
+
+	candidate := base
+	for attempt := 0; attempt < 5; attempt++ {
+		if len(candidate) >= 3 {
+			existing, err := l.userRepo.GetByUsername(candidate)
+			if err != nil {
+				return "", err
+			}
+			if existing == nil {
+				return candidate, nil
+			}
+		}
+		suffix, err := randomHex(2)
+		if err != nil {
+			return "", err
+		}
+		candidate = base + "_" + suffix
+	}
+	return "", ErrUsernameTaken
+}
@@ -0,0 +1,408 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/user/user-management/internal/config"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+const (
+	// LDAPIssuer 是目录用户在linked_identities中的issuer，subject为用户的DN
+	LDAPIssuer  = "ldap"
+	ldapTimeout = 10 * time.Second
+)
+
+// LDAPSyncResult 是一次目录同步的统计
+type LDAPSyncResult struct {
+	Checked     int
+	Updated     int
+	Deactivated int
+}
+
+// LDAPService 用LDAP/Active Directory校验密码，并定期把目录中的变化同步到本地用户
+type LDAPService interface {
+	Authenticator
+	Sync(ctx context.Context) (*LDAPSyncResult, error)
+	RunSync(ctx context.Context, interval time.Duration)
+}
+
+// directoryUser 是从目录读取的用户属性和所属组的DN
+type directoryUser struct {
+	DN       string
+	Email    string
+	Username string
+	Groups   []string
+}
+
+type ldapService struct {
+	cfg            config.LDAPConfig
+	tlsConfig      *tls.Config
+	linker         *identityLinker
+	userRepo       repository.UserRepository
+	identityRepo   repository.LinkedIdentityRepository
+	sessionService SessionService
+	auditService   AuditService
+}
+
+// NewLDAPService 创建LDAP认证后端，CACertFile不为空时只信任该CA签发的服务器证书
+func NewLDAPService(cfg config.LDAPConfig, userRepo repository.UserRepository, identityRepo repository.LinkedIdentityRepository, sessionService SessionService, auditService AuditService) (LDAPService, error) {
+	serverURL, err := url.Parse(cfg.URL)
+	if err != nil || (serverURL.Scheme != "ldap" && serverURL.Scheme != "ldaps") {
+		return nil, fmt.Errorf("invalid LDAP_URL %q", cfg.URL)
+	}
+
+	tlsConfig := &tls.Config{ServerName: serverURL.Hostname(), MinVersion: tls.VersionTLS12}
+	if cfg.CACertFile != "" {
+		data, err := os.ReadFile(cfg.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(data) {
+			return nil, errors.New("LDAP CA certificate file does not contain a PEM certificate")
+		}
+	}
+
+	// LDAP目录由管理员维护，目录中的用户视为已受邀，linker不受REGISTRATION_MODE限制
+	return &ldapService{
+		cfg:            cfg,
+		tlsConfig:      tlsConfig,
+		linker:         &identityLinker{userRepo: userRepo, identityRepo: identityRepo},
+		userRepo:       userRepo,
+		identityRepo:   identityRepo,
+		sessionService: sessionService,
+		auditService:   auditService,
+	}, nil
+}
+
+// Authenticate 以用户身份绑定校验密码：配置了UserDNTemplate时直接绑定，否则先用服务账号搜索用户的DN。
+// 目录中没有该用户时返回ErrInvalidCredentials，由下一个Authenticator（本地密码）继续校验
+func (s *ldapService) Authenticate(email, password string) (*models.User, error) {
+	// 空密码会被当作匿名绑定而成功
+	if password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var user *directoryUser
+	if s.cfg.UserDNTemplate != "" {
+		if err := s.bindUser(conn, fmt.Sprintf(s.cfg.UserDNTemplate, ldap.EscapeDN(email)), password); err != nil {
+			return nil, err
+		}
+		if user, err = s.findUser(conn, email); err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, ErrInvalidCredentials
+		}
+	} else {
+		if err := s.bindService(conn); err != nil {
+			return nil, err
+		}
+		if user, err = s.findUser(conn, email); err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, ErrInvalidCredentials
+		}
+		if err := s.bindUser(conn, user.DN, password); err != nil {
+			return nil, err
+		}
+	}
+
+	if user.Email == "" {
+		user.Email = email
+	}
+	login, err := s.linker.resolve(LDAPIssuer, user.DN, user.Email, true, user.Username)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.applyDirectory(login.User, user); err != nil {
+		return nil, err
+	}
+	return login.User, nil
+}
+
+// Sync 逐个检查已关联的目录用户：已从目录删除（或不再匹配UserFilter）的停用并撤销会话，其余同步邮箱和角色。
+// 目录查询出错时立即中止，避免把连接问题误判为用户被删除
+func (s *ldapService) Sync(ctx context.Context) (*LDAPSyncResult, error) {
+	if s.cfg.BindDN == "" {
+		return nil, errors.New("LDAP sync requires LDAP_BIND_DN")
+	}
+
+	identities, err := s.identityRepo.ListByIssuer(LDAPIssuer)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := s.bindService(conn); err != nil {
+		return nil, err
+	}
+
+	result := &LDAPSyncResult{}
+	for _, identity := range identities {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		user, err := s.userRepo.GetByID(identity.UserID)
+		if err != nil {
+			return result, err
+		}
+		if user == nil {
+			continue
+		}
+		result.Checked++
+
+		entry, err := s.lookupDN(conn, identity.Subject)
+		if err != nil {
+			return result, err
+		}
+		if entry == nil {
+			if user.IsActive {
+				if err := s.deactivate(user, identity.Subject); err != nil {
+					return result, err
+				}
+				result.Deactivated++
+			}
+			continue
+		}
+
+		updated, err := s.applyDirectory(user, entry)
+		if err != nil {
+			return result, err
+		}
+		if updated {
+			result.Updated++
+		}
+	}
+	return result, nil
+}
+
+// RunSync 按interval定期同步，直到ctx结束
+func (s *ldapService) RunSync(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			result, err := s.Sync(ctx)
+			if err != nil {
+				log.Printf("LDAP sync failed: %v", err)
+				continue
+			}
+			log.Printf("LDAP sync finished: checked=%d updated=%d deactivated=%d", result.Checked, result.Updated, result.Deactivated)
+		}
+	}
+}
+
+func (s *ldapService) connect() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(s.cfg.URL, ldap.DialWithTLSConfig(s.tlsConfig), ldap.DialWithDialer(&net.Dialer{Timeout: ldapTimeout}))
+	if err != nil {
+		return nil, fmt.Errorf("connect to LDAP server: %w", err)
+	}
+	conn.SetTimeout(ldapTimeout)
+
+	if s.cfg.StartTLS && strings.HasPrefix(s.cfg.URL, "ldap://") {
+		if err := conn.StartTLS(s.tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("LDAP StartTLS: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+func (s *ldapService) bindService(conn *ldap.Conn) error {
+	if s.cfg.BindDN == "" {
+		return nil
+	}
+	if err := conn.Bind(s.cfg.BindDN, s.cfg.BindPassword); err != nil {
+		return fmt.Errorf("LDAP service account bind: %w", err)
+	}
+	return nil
+}
+
+func (s *ldapService) bindUser(conn *ldap.Conn, dn, password string) error {
+	err := conn.Bind(dn, password)
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		return ErrInvalidCredentials
+	}
+	return err
+}
+
+// findUser 在UserBaseDN下按登录属性搜索用户，找到多个时视为配置错误
+func (s *ldapService) findUser(conn *ldap.Conn, email string) (*directoryUser, error) {
+	filter := fmt.Sprintf("(&%s(%s=%s))", s.cfg.UserFilter, s.cfg.LoginAttribute, ldap.EscapeFilter(email))
+	entries, err := s.search(conn, s.cfg.UserBaseDN, ldap.ScopeWholeSubtree, filter)
+	if err != nil {
+		return nil, err
+	}
+	switch len(entries) {
+	case 0:
+		return nil, nil
+	case 1:
+		return s.directoryUser(conn, entries[0])
+	default:
+		return nil, fmt.Errorf("LDAP filter %s matched %d entries", filter, len(entries))
+	}
+}
+
+// lookupDN 按DN读取用户，用户不存在或不再匹配UserFilter时返回nil
+func (s *ldapService) lookupDN(conn *ldap.Conn, dn string) (*directoryUser, error) {
+	entries, err := s.search(conn, dn, ldap.ScopeBaseObject, s.cfg.UserFilter)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return s.directoryUser(conn, entries[0])
+}
+
+// search 执行搜索，把noSuchObject当作没有结果
+func (s *ldapService) search(conn *ldap.Conn, baseDN string, scope int, filter string, attributes ...string) ([]*ldap.Entry, error) {
+	if len(attributes) == 0 {
+		attributes = []string{s.cfg.EmailAttribute, s.cfg.UsernameAttribute, "memberOf"}
+	}
+	request := ldap.NewSearchRequest(baseDN, scope, ldap.NeverDerefAliases, 0, int(ldapTimeout.Seconds()), false, filter, attributes, nil)
+	result, err := conn.Search(request)
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("LDAP search %s: %w", filter, err)
+	}
+	return result.Entries, nil
+}
+
+// directoryUser 读取用户属性，所属组合并memberOf属性和GroupBaseDN下的组搜索结果
+func (s *ldapService) directoryUser(conn *ldap.Conn, entry *ldap.Entry) (*directoryUser, error) {
+	user := &directoryUser{
+		DN:       entry.DN,
+		Email:    strings.ToLower(entry.GetEqualFoldAttributeValue(s.cfg.EmailAttribute)),
+		Username: entry.GetEqualFoldAttributeValue(s.cfg.UsernameAttribute),
+		Groups:   entry.GetEqualFoldAttributeValues("memberOf"),
+	}
+
+	if s.cfg.GroupBaseDN != "" {
+		filter := fmt.Sprintf(s.cfg.GroupFilter, ldap.EscapeFilter(entry.DN))
+		groups, err := s.search(conn, s.cfg.GroupBaseDN, ldap.ScopeWholeSubtree, filter, "dn")
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range groups {
+			user.Groups = append(user.Groups, group.DN)
+		}
+	}
+	return user, nil
+}
+
+// applyDirectory 把目录中的邮箱和组成员关系同步到本地用户，返回是否有修改
+func (s *ldapService) applyDirectory(user *models.User, directory *directoryUser) (bool, error) {
+	changes := make(map[string]FieldChange)
+
+	if directory.Email != "" && !strings.EqualFold(user.Email, directory.Email) {
+		existing, err := s.userRepo.GetByEmail(directory.Email)
+		if err != nil {
+			return false, err
+		}
+		if existing == nil {
+			changes["email"] = FieldChange{Before: user.Email, After: directory.Email}
+			user.Email = directory.Email
+		} else {
+			log.Printf("LDAP sync: email %s of %s is already used by user %d", directory.Email, directory.DN, existing.ID)
+		}
+	}
+
+	if len(s.cfg.AdminGroups) > 0 {
+		role := models.RoleUser
+		if s.isAdmin(directory.Groups) {
+			role = models.RoleAdmin
+		}
+		if user.Role != role {
+			changes["role"] = FieldChange{Before: user.Role, After: role}
+			user.Role = role
+		}
+	}
+
+	if len(changes) == 0 {
+		return false, nil
+	}
+	if err := s.userRepo.Update(user); err != nil {
+		return false, err
+	}
+
+	action := AuditActionUserUpdate
+	if _, ok := changes["role"]; ok {
+		action = AuditActionRoleChange
+	}
+	s.record(AuditEvent{
+		TargetID: &user.ID,
+		Action:   action,
+		Changes:  changes,
+		Metadata: map[string]interface{}{"source": LDAPIssuer, "dn": directory.DN},
+	})
+	return true, nil
+}
+
+func (s *ldapService) isAdmin(groups []string) bool {
+	for _, group := range groups {
+		for _, admin := range s.cfg.AdminGroups {
+			if strings.EqualFold(group, admin) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// deactivate 停用已从目录删除的用户，并撤销其会话和刷新令牌
+func (s *ldapService) deactivate(user *models.User, dn string) error {
+	user.IsActive = false
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+	if err := s.sessionService.DeleteUserSessions(user.ID); err != nil {
+		return err
+	}
+	if err := s.userRepo.DeleteUserRefreshTokens(user.ID); err != nil {
+		return err
+	}
+
+	s.record(AuditEvent{
+		TargetID: &user.ID,
+		Action:   AuditActionDirectoryDeactivate,
+		Changes:  map[string]FieldChange{"is_active": {Before: true, After: false}},
+		Metadata: map[string]interface{}{"source": LDAPIssuer, "dn": dn},
+	})
+	return nil
+}
+
+func (s *ldapService) record(event AuditEvent) {
+	if err := s.auditService.Record(event); err != nil {
+		log.Printf("Failed to record audit event %s: %v", event.Action, err)
+	}
+}
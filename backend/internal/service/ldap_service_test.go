@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jimlambrt/gldap/testdirectory"
+	"github.com/redis/go-redis/v9"
+	"github.com/user/user-management/internal/config"
+	"github.com/user/user-management/internal/models"
+)
+
+const (
+	testLDAPUserDN  = "ou=people,dc=example,dc=org"
+	testLDAPGroupDN = "ou=groups,dc=example,dc=org"
+	testLDAPAdmins  = "cn=admins," + testLDAPGroupDN
+)
+
+type memoryAuditService struct {
+	AuditService
+	events []AuditEvent
+}
+
+func (s *memoryAuditService) Record(event AuditEvent) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+// testLDAPDirectory 启动内嵌的LDAP服务器，用户DN为userPrincipalName=<name>@example.com,ou=people,...，密码都是password
+type testLDAPDirectory struct {
+	*testdirectory.Directory
+	defaults testdirectory.Option
+}
+
+func startTestLDAPDirectory(t *testing.T, opts ...testdirectory.Option) *testLDAPDirectory {
+	t.Helper()
+
+	defaults := testdirectory.WithDefaults(t, &testdirectory.Defaults{
+		UserAttr:  "userPrincipalName",
+		GroupAttr: "cn",
+		UserDN:    testLDAPUserDN,
+		GroupDN:   testLDAPGroupDN,
+		UPNDomain: "example.com",
+	})
+	d := &testLDAPDirectory{
+		Directory: testdirectory.Start(t, append(opts, defaults)...),
+		defaults:  defaults,
+	}
+	d.setMembers(t, []string{"alice", "bob", "svc"}, []string{"alice"})
+	return d
+}
+
+func (d *testLDAPDirectory) setMembers(t *testing.T, users, admins []string) {
+	d.SetUsers(testdirectory.NewUsers(t, users, d.defaults)...)
+	d.SetGroups(testdirectory.NewGroup(t, "admins", admins, d.defaults))
+}
+
+func (d *testLDAPDirectory) config(t *testing.T, scheme string) config.LDAPConfig {
+	t.Helper()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, []byte(d.Cert()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return config.LDAPConfig{
+		URL:               fmt.Sprintf("%s://%s:%d", scheme, d.Host(), d.Port()),
+		CACertFile:        caFile,
+		BindDN:            testLDAPDN("svc"),
+		BindPassword:      "password",
+		UserBaseDN:        testLDAPUserDN,
+		UserFilter:        "(objectClass=person)",
+		LoginAttribute:    "userPrincipalName",
+		EmailAttribute:    "email",
+		UsernameAttribute: "name",
+		GroupBaseDN:       testLDAPGroupDN,
+		GroupFilter:       "(member=%s)",
+		AdminGroups:       []string{testLDAPAdmins},
+	}
+}
+
+func testLDAPDN(name string) string {
+	return fmt.Sprintf("userPrincipalName=%s@example.com,%s", name, testLDAPUserDN)
+}
+
+func newTestLDAPService(t *testing.T, cfg config.LDAPConfig, users *memoryUserRepository, identities *memoryIdentityRepository, audit *memoryAuditService) LDAPService {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	sessions := NewSessionService(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	svc, err := NewLDAPService(cfg, users, identities, sessions, audit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc
+}
+
+func TestLDAPSearchThenBind(t *testing.T) {
+	d := startTestLDAPDirectory(t)
+	users := &memoryUserRepository{}
+	identities := &memoryIdentityRepository{}
+	svc := newTestLDAPService(t, d.config(t, "ldaps"), users, identities, &memoryAuditService{})
+
+	alice, err := svc.Authenticate("alice@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice.Username != "alice" || alice.Email != "alice@example.com" || alice.Role != models.RoleAdmin {
+		t.Fatalf("unexpected user: %+v", alice)
+	}
+	if identity, _ := identities.GetBySubject(LDAPIssuer, testLDAPDN("alice")); identity == nil || identity.UserID != alice.ID {
+		t.Fatalf("directory identity not linked: %+v", identity)
+	}
+
+	bob, err := svc.Authenticate("bob@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bob.Role != models.RoleUser {
+		t.Fatalf("bob is not in the admin group, role = %s", bob.Role)
+	}
+
+	again, err := svc.Authenticate("alice@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != alice.ID || len(users.users) != 2 {
+		t.Fatalf("second login should reuse the linked user: %+v", again)
+	}
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+	}{
+		{"wrong password", "alice@example.com", "wrong"},
+		{"empty password", "alice@example.com", ""},
+		{"unknown user", "carol@example.com", "password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.Authenticate(tt.email, tt.password); !errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("err = %v, want %v", err, ErrInvalidCredentials)
+			}
+		})
+	}
+}
+
+func TestLDAPDirectBindWithStartTLS(t *testing.T) {
+	d := startTestLDAPDirectory(t, testdirectory.WithNoTLS(t))
+	cfg := d.config(t, "ldap")
+	cfg.StartTLS = true
+	cfg.BindDN, cfg.BindPassword = "", ""
+	cfg.UserDNTemplate = "userPrincipalName=%s," + testLDAPUserDN
+	svc := newTestLDAPService(t, cfg, &memoryUserRepository{}, &memoryIdentityRepository{}, &memoryAuditService{})
+
+	user, err := svc.Authenticate("bob@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "bob@example.com" {
+		t.Fatalf("unexpected user: %+v", user)
+	}
+	if _, err := svc.Authenticate("bob@example.com", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidCredentials)
+	}
+}
+
+func TestLDAPUntrustedCertificate(t *testing.T) {
+	d := startTestLDAPDirectory(t)
+	cfg := d.config(t, "ldaps")
+	other := startTestLDAPDirectory(t)
+	cfg.CACertFile = other.config(t, "ldaps").CACertFile
+	svc := newTestLDAPService(t, cfg, &memoryUserRepository{}, &memoryIdentityRepository{}, &memoryAuditService{})
+
+	// 证书校验失败不能当作凭据错误回退到本地密码
+	if _, err := svc.Authenticate("alice@example.com", "password"); err == nil || errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("err = %v, want a connection error", err)
+	}
+}
+
+func TestLDAPSyncDeactivatesRemovedUsers(t *testing.T) {
+	d := startTestLDAPDirectory(t)
+	users := &memoryUserRepository{}
+	audit := &memoryAuditService{}
+	svc := newTestLDAPService(t, d.config(t, "ldaps"), users, &memoryIdentityRepository{}, audit)
+
+	alice, err := svc.Authenticate("alice@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := svc.Authenticate("bob@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := &models.User{Username: "carol", Email: "carol@example.com", IsActive: true, Role: models.RoleUser}
+	users.Create(local)
+
+	// bob被删除，alice被移出管理员组
+	d.setMembers(t, []string{"alice", "svc"}, nil)
+	result, err := svc.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *result != (LDAPSyncResult{Checked: 2, Updated: 1, Deactivated: 1}) {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if bob.IsActive || !alice.IsActive || alice.Role != models.RoleUser {
+		t.Fatalf("unexpected users after sync: alice=%+v bob=%+v", alice, bob)
+	}
+	if !local.IsActive {
+		t.Fatal("local users must not be touched by the directory sync")
+	}
+
+	last := audit.events[len(audit.events)-1]
+	if last.Action != AuditActionDirectoryDeactivate || *last.TargetID != bob.ID {
+		t.Fatalf("unexpected audit event: %+v", last)
+	}
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/user/user-management/internal/config"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+// MagicLinkPage 是邮件中链接指向的前端页面，token作为查询参数
+const MagicLinkPage = "/login/magic"
+
+// MagicLinkService 通过邮件发送一次性登录链接。链接与发起请求的浏览器绑定：
+// 请求时返回的nonce由处理器写入Cookie，使用链接时必须携带同一个nonce
+type MagicLinkService interface {
+	// Request 无论邮箱是否存在都返回nonce，调用方据此返回统一的响应；nonce不为空时沿用，使同一浏览器先前请求的链接仍然有效
+	Request(email, nonce string) (string, error)
+	// Consume 取出链接并校验nonce，账号启用了第二因素时返回SecondFactorRequiredError
+	Consume(token, nonce string) (*models.User, error)
+}
+
+// magicLink 保存在Redis中，使用时一次性取出
+type magicLink struct {
+	UserID    uint   `json:"user_id"`
+	NonceHash string `json:"nonce_hash"`
+}
+
+type magicLinkService struct {
+	cfg          config.MagicLinkConfig
+	userRepo     repository.UserRepository
+	secondFactor SecondFactor
+	mailer       Mailer
+	redis        *redis.Client
+	baseURL      string
+	ctx          context.Context
+}
+
+// NewMagicLinkService 的baseURL是前端的对外地址，用于拼接邮件中的链接
+func NewMagicLinkService(cfg config.MagicLinkConfig, userRepo repository.UserRepository, secondFactor SecondFactor, mailer Mailer, redisClient *redis.Client, baseURL string) MagicLinkService {
+	return &magicLinkService{
+		cfg:          cfg,
+		userRepo:     userRepo,
+		secondFactor: secondFactor,
+		mailer:       mailer,
+		redis:        redisClient,
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		ctx:          context.Background(),
+	}
+}
+
+func (s *magicLinkService) Request(email, nonce string) (string, error) {
+	email = strings.TrimSpace(email)
+
+	// 按邮箱限流，不区分账号是否存在，避免借此探测账号
+	rateKey := magicLinkRateKey(strings.ToLower(email))
+	count, err := s.redis.Incr(s.ctx, rateKey).Result()
+	if err != nil {
+		return "", err
+	}
+	if count == 1 {
+		s.redis.Expire(s.ctx, rateKey, s.cfg.RateWindow)
+	}
+	if count > int64(s.cfg.RateLimit) {
+		return "", ErrMagicLinkRateLimited
+	}
+
+	if !validNonce(nonce) {
+		if nonce, err = randomHex(32); err != nil {
+			return "", err
+		}
+	}
+
+	user, err := s.userRepo.GetByEmail(email)
+	if err != nil {
+		return "", err
+	}
+	if user == nil || !user.IsActive {
+		return nonce, nil
+	}
+
+	token, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(magicLink{UserID: user.ID, NonceHash: hashAccessToken(nonce)})
+	if err != nil {
+		return "", err
+	}
+	if err := s.redis.Set(s.ctx, magicLinkKey(token), data, s.cfg.TTL).Err(); err != nil {
+		return "", err
+	}
+
+	// 异步发送，响应时间不因账号是否存在而不同
+	link := s.baseURL + MagicLinkPage + "?token=" + url.QueryEscape(token)
+	go func() {
+		if err := s.mailer.Send(user.Email, "Your sign-in link / 登录链接", fmt.Sprintf(
+			"Use the link below to sign in. It expires in %s and only works in the browser where you requested it.\n"+
+				"点击以下链接登录，链接%s内有效，只能在发起请求的浏览器中使用。\n\n%s\n\n"+
+				"If you did not request this, you can ignore this email.\n如果不是您本人操作，请忽略此邮件。\n",
+			s.cfg.TTL, s.cfg.TTL, link)); err != nil {
+			log.Printf("Failed to send magic link: %v", err)
+		}
+	}()
+	return nonce, nil
+}
+
+func (s *magicLinkService) Consume(token, nonce string) (*models.User, error) {
+	data, err := s.redis.GetDel(s.ctx, magicLinkKey(token)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrInvalidMagicLink
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var link magicLink
+	if err := json.Unmarshal([]byte(data), &link); err != nil {
+		return nil, err
+	}
+	// 链接已被取出，nonce不符时同样作废，避免被他人截获后猜测
+	if subtle.ConstantTimeCompare([]byte(link.NonceHash), []byte(hashAccessToken(nonce))) != 1 {
+		return nil, ErrInvalidMagicLink
+	}
+
+	user, err := s.userRepo.GetByID(link.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidMagicLink
+	}
+	if !user.IsActive {
+		return nil, ErrAccountDisabled
+	}
+
+	ticket, err := s.secondFactor.Challenge(user)
+	if err != nil {
+		return nil, err
+	}
+	if ticket != "" {
+		return user, &SecondFactorRequiredError{Ticket: ticket}
+	}
+	return user, nil
+}
+
+func validNonce(nonce string) bool {
+	decoded, err := hex.DecodeString(nonce)
+	return err == nil && len(decoded) == 32
+}
+
+func magicLinkKey(token string) string {
+	return fmt.Sprintf("magiclink:token:%s", hashAccessToken(token))
+}
+
+func magicLinkRateKey(email string) string {
+	return fmt.Sprintf("magiclink:rate:%s", hashAccessToken(email))
+}
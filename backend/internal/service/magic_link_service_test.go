@@ -0,0 +1,147 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/user/user-management/internal/config"
+	"github.com/user/user-management/internal/models"
+)
+
+type sentMail struct {
+	to, subject, body string
+}
+
+type channelMailer chan sentMail
+
+func (m channelMailer) Send(to, subject, body string) error {
+	m <- sentMail{to: to, subject: subject, body: body}
+	return nil
+}
+
+// fixedSecondFactor 对指定用户要求第二因素
+type fixedSecondFactor map[uint]string
+
+func (f fixedSecondFactor) Challenge(user *models.User) (string, error) {
+	return f[user.ID], nil
+}
+
+func newTestMagicLinkService(t *testing.T, secondFactor SecondFactor) (MagicLinkService, channelMailer, *miniredis.Miniredis, *models.User) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	users := &memoryUserRepository{}
+	user := &models.User{Username: "alice", Email: "alice@example.com", IsActive: true}
+	users.Create(user)
+	mailer := make(channelMailer, 10)
+	cfg := config.MagicLinkConfig{TTL: 15 * time.Minute, RateLimit: 2, RateWindow: time.Hour}
+	svc := NewMagicLinkService(cfg, users, secondFactor, mailer, redis.NewClient(&redis.Options{Addr: mr.Addr()}), "https://example.com/")
+	return svc, mailer, mr, user
+}
+
+// receiveLinkToken 等待异步发送的邮件并取出链接中的token
+func receiveLinkToken(t *testing.T, mailer channelMailer) string {
+	t.Helper()
+
+	select {
+	case mail := <-mailer:
+		for _, line := range strings.Split(mail.body, "\n") {
+			if strings.HasPrefix(line, "https://example.com"+MagicLinkPage+"?") {
+				link, err := url.Parse(line)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return link.Query().Get("token")
+			}
+		}
+		t.Fatalf("no sign-in link in mail: %q", mail.body)
+	case <-time.After(time.Second):
+		t.Fatal("no mail sent")
+	}
+	return ""
+}
+
+func TestMagicLinkBoundToBrowser(t *testing.T) {
+	svc, mailer, _, user := newTestMagicLinkService(t, fixedSecondFactor{})
+
+	nonce, err := svc.Request(" alice@example.com ", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := receiveLinkToken(t, mailer)
+
+	// 其他浏览器使用链接失败，链接随即作废
+	if _, err := svc.Consume(token, strings.Repeat("0", 64)); !errors.Is(err, ErrInvalidMagicLink) {
+		t.Fatalf("expected foreign browser to be rejected, got %v", err)
+	}
+	if _, err := svc.Consume(token, nonce); !errors.Is(err, ErrInvalidMagicLink) {
+		t.Fatalf("expected link to be burnt after a mismatched nonce, got %v", err)
+	}
+
+	// 同一浏览器再次请求时沿用nonce
+	again, err := svc.Request("alice@example.com", nonce)
+	if err != nil || again != nonce {
+		t.Fatalf("expected nonce to be reused, got %q %v", again, err)
+	}
+	token = receiveLinkToken(t, mailer)
+	loggedIn, err := svc.Consume(token, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loggedIn.ID != user.ID {
+		t.Fatalf("expected user %d, got %d", user.ID, loggedIn.ID)
+	}
+	if _, err := svc.Consume(token, nonce); !errors.Is(err, ErrInvalidMagicLink) {
+		t.Fatalf("expected link to be single use, got %v", err)
+	}
+}
+
+func TestMagicLinkUniformResponse(t *testing.T) {
+	svc, mailer, mr, _ := newTestMagicLinkService(t, fixedSecondFactor{})
+
+	nonce, err := svc.Request("nobody@example.com", "")
+	if err != nil || !validNonce(nonce) {
+		t.Fatalf("expected a nonce for unknown email, got %q %v", nonce, err)
+	}
+	for _, key := range mr.Keys() {
+		if strings.HasPrefix(key, "magiclink:token:") {
+			t.Fatalf("no link should be stored for unknown email, found %s", key)
+		}
+	}
+	select {
+	case mail := <-mailer:
+		t.Fatalf("unexpected mail to %s", mail.to)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// 限流按邮箱计数，与账号是否存在无关
+	if _, err := svc.Request("NOBODY@example.com", nonce); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Request("nobody@example.com", nonce); !errors.Is(err, ErrMagicLinkRateLimited) {
+		t.Fatalf("expected rate limit, got %v", err)
+	}
+	mr.FastForward(time.Hour)
+	if _, err := svc.Request("nobody@example.com", nonce); err != nil {
+		t.Fatalf("expected rate limit to reset after the window, got %v", err)
+	}
+}
+
+func TestMagicLinkSecondFactor(t *testing.T) {
+	svc, mailer, _, user := newTestMagicLinkService(t, fixedSecondFactor{1: "ticket"})
+
+	nonce, err := svc.Request(user.Email, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = svc.Consume(receiveLinkToken(t, mailer), nonce)
+	var secondFactor *SecondFactorRequiredError
+	if !errors.As(err, &secondFactor) || secondFactor.Ticket != "ticket" {
+		t.Fatalf("expected second factor to be required, got %v", err)
+	}
+}
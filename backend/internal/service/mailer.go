@@ -0,0 +1,56 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+
+	"github.com/user/user-management/internal/config"
+)
+
+// Mailer 发送纯文本邮件
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// NewMailer 在未配置SMTP服务器时返回只写日志的实现，便于本地开发
+func NewMailer(cfg config.SMTPConfig) Mailer {
+	if cfg.Host == "" {
+		return logMailer{}
+	}
+	return &smtpMailer{cfg: cfg}
+}
+
+type smtpMailer struct {
+	cfg config.SMTPConfig
+}
+
+func (m *smtpMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+	}
+
+	var message strings.Builder
+	fmt.Fprintf(&message, "From: %s\r\n", m.cfg.From)
+	fmt.Fprintf(&message, "To: %s\r\n", to)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	message.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	if err := smtp.SendMail(net.JoinHostPort(m.cfg.Host, m.cfg.Port), auth, m.cfg.From, []string{to}, []byte(message.String())); err != nil {
+		return fmt.Errorf("send mail to %s: %w", to, err)
+	}
+	return nil
+}
+
+type logMailer struct{}
+
+func (logMailer) Send(to, subject, body string) error {
+	log.Printf("Mail to %s: %s\n%s", to, subject, body)
+	return nil
+}
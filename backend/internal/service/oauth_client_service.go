@@ -0,0 +1,111 @@
+package service
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+const (
+	OAuthClientIDPrefix     = "oc_"
+	OAuthClientSecretPrefix = "umoc_"
+)
+
+type OAuthClientService interface {
+	Register(name string, redirectURIs, scopes []string, public bool, createdBy uint) (*models.OAuthClient, string, error)
+	List() ([]models.OAuthClient, error)
+	Delete(id uint) (*models.OAuthClient, error)
+}
+
+type oauthClientService struct {
+	clientRepo repository.OAuthClientRepository
+}
+
+func NewOAuthClientService(clientRepo repository.OAuthClientRepository) OAuthClientService {
+	return &oauthClientService{clientRepo: clientRepo}
+}
+
+// Register 注册客户端，机密客户端的client_secret只在注册时返回一次；未指定scope时允许全部OIDC scope
+func (s *oauthClientService) Register(name string, redirectURIs, scopes []string, public bool, createdBy uint) (*models.OAuthClient, string, error) {
+	for _, redirectURI := range redirectURIs {
+		if !validRedirectURI(redirectURI) {
+			return nil, "", invalidRedirectURI(redirectURI)
+		}
+	}
+	if len(scopes) == 0 {
+		scopes = models.OIDCScopes
+	}
+	for _, scope := range scopes {
+		if !containsScope(models.OIDCScopes, scope) {
+			return nil, "", invalidScope(scope, models.OIDCScopes)
+		}
+	}
+
+	clientID, err := randomHex(16)
+	if err != nil {
+		return nil, "", err
+	}
+
+	client := &models.OAuthClient{
+		Name:         name,
+		ClientID:     OAuthClientIDPrefix + clientID,
+		RedirectURIs: strings.Join(redirectURIs, " "),
+		Scopes:       strings.Join(scopes, " "),
+		Public:       public,
+		CreatedBy:    createdBy,
+	}
+
+	var secret string
+	if !public {
+		if secret, err = randomHex(32); err != nil {
+			return nil, "", err
+		}
+		secret = OAuthClientSecretPrefix + secret
+		client.ClientSecretHash = hashAccessToken(secret)
+	}
+
+	if err := s.clientRepo.Create(client); err != nil {
+		return nil, "", err
+	}
+	return client, secret, nil
+}
+
+func (s *oauthClientService) List() ([]models.OAuthClient, error) {
+	return s.clientRepo.List()
+}
+
+func (s *oauthClientService) Delete(id uint) (*models.OAuthClient, error) {
+	client, err := s.clientRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, ErrOAuthClientNotFound
+	}
+
+	if err := s.clientRepo.Delete(client); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// ClientRedirectURIs 返回客户端注册的回调地址
+func ClientRedirectURIs(client *models.OAuthClient) []string {
+	return strings.Fields(client.RedirectURIs)
+}
+
+// ClientScopes 返回客户端允许申请的scope
+func ClientScopes(client *models.OAuthClient) []string {
+	return strings.Fields(client.Scopes)
+}
+
+// validRedirectURI 回调地址必须是不带fragment的绝对http(s)地址，授权时按字符串完全匹配
+func validRedirectURI(redirectURI string) bool {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "https" || u.Scheme == "http") && u.Host != "" && u.Fragment == "" && !strings.ContainsAny(redirectURI, " \t\n")
+}
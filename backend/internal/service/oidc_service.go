@@ -0,0 +1,401 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+const (
+	// 授权码只能使用一次，有效期按RFC 6749建议不超过10分钟，这里取1分钟
+	authorizationCodeTTL = time.Minute
+	pkceMethodS256       = "S256"
+	responseTypeCode     = "code"
+	// accessTokenType 区分OIDC访问令牌与ID Token（RFC 9068）
+	accessTokenType = "at+jwt"
+)
+
+// AuthorizationRequest 是授权端点的请求参数
+type AuthorizationRequest struct {
+	ResponseType        string `json:"response_type" form:"response_type"`
+	ClientID            string `json:"client_id" form:"client_id"`
+	RedirectURI         string `json:"redirect_uri" form:"redirect_uri"`
+	Scope               string `json:"scope" form:"scope"`
+	State               string `json:"state" form:"state"`
+	Nonce               string `json:"nonce" form:"nonce"`
+	CodeChallenge       string `json:"code_challenge" form:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method" form:"code_challenge_method"`
+}
+
+// AuthorizationPrompt 是同意页面需要展示的信息
+type AuthorizationPrompt struct {
+	Client          *models.OAuthClient
+	Scopes          []string
+	ConsentRequired bool
+}
+
+// OIDCTokens 是授权码换取的令牌
+type OIDCTokens struct {
+	AccessToken string
+	IDToken     string
+	ExpiresIn   time.Duration
+	Scopes      []string
+	UserID      uint
+	ClientID    string
+}
+
+type OIDCService interface {
+	Issuer() string
+	JWKS() []JWK
+	Authorize(req AuthorizationRequest, userID uint) (*AuthorizationPrompt, error)
+	Approve(req AuthorizationRequest, userID uint, authTime time.Time) (string, error)
+	Deny(req AuthorizationRequest, userID uint) (string, error)
+	ExchangeCode(clientID, clientSecret, code, redirectURI, codeVerifier string) (*OIDCTokens, error)
+	UserInfo(accessToken string) (map[string]interface{}, error)
+}
+
+// authorizationCode 保存在Redis中，兑换时一次性取出
+type authorizationCode struct {
+	ClientID      string    `json:"client_id"`
+	UserID        uint      `json:"user_id"`
+	RedirectURI   string    `json:"redirect_uri"`
+	Scopes        []string  `json:"scopes"`
+	Nonce         string    `json:"nonce,omitempty"`
+	CodeChallenge string    `json:"code_challenge"`
+	AuthTime      time.Time `json:"auth_time"`
+}
+
+type oidcService struct {
+	clientRepo  repository.OAuthClientRepository
+	userRepo    repository.UserRepository
+	redis       *redis.Client
+	ctx         context.Context
+	key         *SigningKey
+	issuer      string
+	tokenExpiry time.Duration
+}
+
+func NewOIDCService(clientRepo repository.OAuthClientRepository, userRepo repository.UserRepository, redisClient *redis.Client, key *SigningKey, issuer string, tokenExpiry time.Duration) OIDCService {
+	return &oidcService{
+		clientRepo:  clientRepo,
+		userRepo:    userRepo,
+		redis:       redisClient,
+		ctx:         context.Background(),
+		key:         key,
+		issuer:      strings.TrimRight(issuer, "/"),
+		tokenExpiry: tokenExpiry,
+	}
+}
+
+func (s *oidcService) Issuer() string {
+	return s.issuer
+}
+
+func (s *oidcService) JWKS() []JWK {
+	return []JWK{s.key.JWK()}
+}
+
+// Authorize 校验授权请求，并判断用户是否已经同意过这些scope
+func (s *oidcService) Authorize(req AuthorizationRequest, userID uint) (*AuthorizationPrompt, error) {
+	client, scopes, err := s.validateAuthorization(req)
+	if err != nil {
+		return nil, err
+	}
+
+	consent, err := s.clientRepo.GetConsent(userID, client.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	consentRequired := true
+	if consent != nil {
+		granted := strings.Fields(consent.Scopes)
+		consentRequired = false
+		for _, scope := range scopes {
+			if !containsScope(granted, scope) {
+				consentRequired = true
+				break
+			}
+		}
+	}
+
+	return &AuthorizationPrompt{Client: client, Scopes: scopes, ConsentRequired: consentRequired}, nil
+}
+
+// Approve 记录用户同意并签发授权码，返回携带code和state的回调地址
+func (s *oidcService) Approve(req AuthorizationRequest, userID uint, authTime time.Time) (string, error) {
+	client, scopes, err := s.validateAuthorization(req)
+	if err != nil {
+		return "", err
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return "", err
+	}
+	if user == nil || !user.IsActive {
+		return "", ErrAccountDisabled
+	}
+
+	if err := s.clientRepo.SaveConsent(&models.OAuthConsent{
+		UserID:   userID,
+		ClientID: client.ClientID,
+		Scopes:   strings.Join(scopes, " "),
+	}); err != nil {
+		return "", err
+	}
+
+	code, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(authorizationCode{
+		ClientID:      client.ClientID,
+		UserID:        userID,
+		RedirectURI:   req.RedirectURI,
+		Scopes:        scopes,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		AuthTime:      authTime,
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := s.redis.Set(s.ctx, authorizationCodeKey(code), data, authorizationCodeTTL).Err(); err != nil {
+		return "", err
+	}
+
+	return s.redirectWith(req.RedirectURI, url.Values{"code": {code}, "state": {req.State}}), nil
+}
+
+// Deny 返回携带access_denied错误的回调地址
+func (s *oidcService) Deny(req AuthorizationRequest, userID uint) (string, error) {
+	if _, _, err := s.validateAuthorization(req); err != nil {
+		return "", err
+	}
+	return s.redirectWith(req.RedirectURI, url.Values{"error": {"access_denied"}, "state": {req.State}}), nil
+}
+
+// ExchangeCode 用授权码换取访问令牌和ID Token，授权码无论成功与否都只能使用一次
+func (s *oidcService) ExchangeCode(clientID, clientSecret, code, redirectURI, codeVerifier string) (*OIDCTokens, error) {
+	client, err := s.clientRepo.GetByClientID(clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, ErrInvalidClient
+	}
+	if !client.Public && subtle.ConstantTimeCompare([]byte(hashAccessToken(clientSecret)), []byte(client.ClientSecretHash)) != 1 {
+		return nil, ErrInvalidClient
+	}
+
+	data, err := s.redis.GetDel(s.ctx, authorizationCodeKey(code)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrInvalidGrant
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var grant authorizationCode
+	if err := json.Unmarshal([]byte(data), &grant); err != nil {
+		return nil, err
+	}
+	if grant.ClientID != client.ClientID || grant.RedirectURI != redirectURI {
+		return nil, ErrInvalidGrant
+	}
+	if !verifyPKCE(codeVerifier, grant.CodeChallenge) {
+		return nil, ErrInvalidGrant
+	}
+
+	user, err := s.userRepo.GetByID(grant.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.IsActive {
+		return nil, ErrInvalidGrant
+	}
+
+	now := time.Now()
+	accessToken, err := s.sign(accessTokenType, jwt.MapClaims{
+		"iss":       s.issuer,
+		"sub":       strconv.FormatUint(uint64(user.ID), 10),
+		"aud":       client.ClientID,
+		"client_id": client.ClientID,
+		"scope":     strings.Join(grant.Scopes, " "),
+		"exp":       now.Add(s.tokenExpiry).Unix(),
+		"iat":       now.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	idClaims := jwt.MapClaims{
+		"iss":       s.issuer,
+		"sub":       strconv.FormatUint(uint64(user.ID), 10),
+		"aud":       client.ClientID,
+		"azp":       client.ClientID,
+		"exp":       now.Add(s.tokenExpiry).Unix(),
+		"iat":       now.Unix(),
+		"auth_time": grant.AuthTime.Unix(),
+		"at_hash":   leftHalfHash(accessToken),
+	}
+	if grant.Nonce != "" {
+		idClaims["nonce"] = grant.Nonce
+	}
+	for name, value := range userClaims(user, grant.Scopes) {
+		idClaims[name] = value
+	}
+	idToken, err := s.sign("JWT", idClaims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OIDCTokens{
+		AccessToken: accessToken,
+		IDToken:     idToken,
+		ExpiresIn:   s.tokenExpiry,
+		Scopes:      grant.Scopes,
+		UserID:      user.ID,
+		ClientID:    client.ClientID,
+	}, nil
+}
+
+// UserInfo 校验OIDC访问令牌并按scope返回用户声明
+func (s *oidcService) UserInfo(accessToken string) (map[string]interface{}, error) {
+	token, err := jwt.Parse(accessToken, func(token *jwt.Token) (interface{}, error) {
+		if token.Header["typ"] != accessTokenType {
+			return nil, errors.New("not an access token")
+		}
+		return &s.key.PrivateKey.PublicKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}), jwt.WithIssuer(s.issuer))
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	subject, _ := claims.GetSubject()
+	userID, err := strconv.ParseUint(subject, 10, 32)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.userRepo.GetByID(uint(userID))
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.IsActive {
+		return nil, ErrInvalidToken
+	}
+
+	scope, _ := claims["scope"].(string)
+	info := userClaims(user, strings.Fields(scope))
+	info["sub"] = subject
+	return info, nil
+}
+
+// validateAuthorization 校验客户端、回调地址、scope和PKCE参数
+func (s *oidcService) validateAuthorization(req AuthorizationRequest) (*models.OAuthClient, []string, error) {
+	client, err := s.clientRepo.GetByClientID(req.ClientID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if client == nil {
+		return nil, nil, ErrOAuthClientNotFound
+	}
+	if !containsScope(ClientRedirectURIs(client), req.RedirectURI) {
+		return nil, nil, invalidRedirectURI(req.RedirectURI)
+	}
+
+	if req.ResponseType != responseTypeCode {
+		return nil, nil, ErrUnsupportedResponseType
+	}
+	// 所有客户端都必须使用S256方式的PKCE
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != pkceMethodS256 {
+		return nil, nil, ErrPKCERequired
+	}
+
+	scopes := strings.Fields(req.Scope)
+	if !containsScope(scopes, models.ScopeOpenID) {
+		return nil, nil, invalidScope(models.ScopeOpenID, ClientScopes(client))
+	}
+	for _, scope := range scopes {
+		if !containsScope(ClientScopes(client), scope) {
+			return nil, nil, invalidScope(scope, ClientScopes(client))
+		}
+	}
+
+	return client, scopes, nil
+}
+
+func (s *oidcService) sign(typ string, claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = s.key.ID
+	token.Header["typ"] = typ
+	return token.SignedString(s.key.PrivateKey)
+}
+
+// redirectWith 在回调地址上追加参数，iss按RFC 9207防止混淆攻击
+func (s *oidcService) redirectWith(redirectURI string, params url.Values) string {
+	u, _ := url.Parse(redirectURI)
+	query := u.Query()
+	for name, values := range params {
+		if values[0] != "" {
+			query.Set(name, values[0])
+		}
+	}
+	query.Set("iss", s.issuer)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// userClaims 按scope返回用户声明
+func userClaims(user *models.User, scopes []string) map[string]interface{} {
+	claims := make(map[string]interface{})
+	if containsScope(scopes, models.ScopeProfile) {
+		claims["preferred_username"] = user.Username
+		claims["name"] = user.Username
+		claims["updated_at"] = user.UpdatedAt.Unix()
+	}
+	if containsScope(scopes, models.ScopeEmail) {
+		claims["email"] = user.Email
+		// 系统尚未验证邮箱
+		claims["email_verified"] = false
+	}
+	return claims
+}
+
+func verifyPKCE(verifier, challenge string) bool {
+	if verifier == "" {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// leftHalfHash 计算ID Token中的at_hash
+func leftHalfHash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
+
+func authorizationCodeKey(code string) string {
+	return fmt.Sprintf("oauth:code:%s", hashAccessToken(code))
+}
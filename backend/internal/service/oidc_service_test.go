@@ -0,0 +1,219 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+type memoryOAuthClientRepository struct {
+	repository.OAuthClientRepository
+	clients  []*models.OAuthClient
+	consents []*models.OAuthConsent
+}
+
+func (r *memoryOAuthClientRepository) GetByClientID(clientID string) (*models.OAuthClient, error) {
+	for _, client := range r.clients {
+		if client.ClientID == clientID {
+			return client, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryOAuthClientRepository) GetConsent(userID uint, clientID string) (*models.OAuthConsent, error) {
+	for _, consent := range r.consents {
+		if consent.UserID == userID && consent.ClientID == clientID {
+			return consent, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryOAuthClientRepository) SaveConsent(consent *models.OAuthConsent) error {
+	r.consents = append(r.consents, consent)
+	return nil
+}
+
+type oidcFixture struct {
+	svc      OIDCService
+	key      *SigningKey
+	alice    *models.User
+	verifier string
+	request  AuthorizationRequest
+}
+
+func newTestOIDCService(t *testing.T) *oidcFixture {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingKey := newSigningKey(key)
+
+	users := &memoryUserRepository{}
+	alice := &models.User{Username: "alice", Email: "alice@example.com", IsActive: true}
+	users.Create(alice)
+
+	clients := &memoryOAuthClientRepository{clients: []*models.OAuthClient{{
+		Name:         "SPA",
+		ClientID:     "spa",
+		RedirectURIs: "https://app.example.com/callback",
+		Scopes:       "openid profile email",
+		Public:       true,
+	}}}
+
+	mr := miniredis.RunT(t)
+	svc := NewOIDCService(clients, users, redis.NewClient(&redis.Options{Addr: mr.Addr()}), signingKey, "https://id.example.com/", time.Hour)
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	sum := sha256.Sum256([]byte(verifier))
+	return &oidcFixture{
+		svc:      svc,
+		key:      signingKey,
+		alice:    alice,
+		verifier: verifier,
+		request: AuthorizationRequest{
+			ResponseType:        "code",
+			ClientID:            "spa",
+			RedirectURI:         "https://app.example.com/callback",
+			Scope:               "openid email",
+			State:               "xyz",
+			Nonce:               "n-0S6_WzA2Mj",
+			CodeChallenge:       base64.RawURLEncoding.EncodeToString(sum[:]),
+			CodeChallengeMethod: "S256",
+		},
+	}
+}
+
+// approve 同意授权请求并返回回调地址中的授权码
+func (f *oidcFixture) approve(t *testing.T, authTime time.Time) string {
+	t.Helper()
+
+	redirectTo, err := f.svc.Approve(f.request, f.alice.ID, authTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(redirectTo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Query().Get("state") != "xyz" || u.Query().Get("iss") != "https://id.example.com" {
+		t.Fatalf("expected state and iss in redirect, got %s", redirectTo)
+	}
+	return u.Query().Get("code")
+}
+
+func TestOIDCRequiresPKCES256(t *testing.T) {
+	f := newTestOIDCService(t)
+
+	plain := f.request
+	plain.CodeChallengeMethod = "plain"
+	missing := f.request
+	missing.CodeChallenge = ""
+	noMethod := f.request
+	noMethod.CodeChallengeMethod = ""
+
+	for name, req := range map[string]AuthorizationRequest{"plain": plain, "missing challenge": missing, "missing method": noMethod} {
+		if _, err := f.svc.Authorize(req, f.alice.ID); !errors.Is(err, ErrPKCERequired) {
+			t.Fatalf("%s: expected authorize to require S256, got %v", name, err)
+		}
+		if _, err := f.svc.Approve(req, f.alice.ID, time.Now()); !errors.Is(err, ErrPKCERequired) {
+			t.Fatalf("%s: expected approve to require S256, got %v", name, err)
+		}
+	}
+
+	if prompt, err := f.svc.Authorize(f.request, f.alice.ID); err != nil || !prompt.ConsentRequired {
+		t.Fatalf("expected first authorization to require consent, got %+v %v", prompt, err)
+	}
+}
+
+func TestOIDCCodeIsSingleUse(t *testing.T) {
+	f := newTestOIDCService(t)
+	redirectURI := f.request.RedirectURI
+
+	code := f.approve(t, time.Now())
+	if _, err := f.svc.ExchangeCode("spa", "", code, redirectURI, f.verifier); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.svc.ExchangeCode("spa", "", code, redirectURI, f.verifier); !errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("expected reused code to be rejected, got %v", err)
+	}
+
+	// 校验失败的兑换同样消耗授权码，不能用正确的verifier重试
+	code = f.approve(t, time.Now())
+	if _, err := f.svc.ExchangeCode("spa", "", code, redirectURI, "wrong-verifier"); !errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("expected wrong verifier to be rejected, got %v", err)
+	}
+	if _, err := f.svc.ExchangeCode("spa", "", code, redirectURI, f.verifier); !errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("expected code to be consumed by the failed exchange, got %v", err)
+	}
+
+	code = f.approve(t, time.Now())
+	if _, err := f.svc.ExchangeCode("spa", "", code, "https://evil.example.com/callback", f.verifier); !errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("expected mismatched redirect URI to be rejected, got %v", err)
+	}
+
+	if prompt, err := f.svc.Authorize(f.request, f.alice.ID); err != nil || prompt.ConsentRequired {
+		t.Fatalf("expected saved consent to skip the prompt, got %+v %v", prompt, err)
+	}
+}
+
+func TestOIDCIDTokenClaims(t *testing.T) {
+	f := newTestOIDCService(t)
+
+	authTime := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	tokens, err := f.svc.ExchangeCode("spa", "", f.approve(t, authTime), f.request.RedirectURI, f.verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(tokens.IDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return &f.key.PrivateKey.PublicKey, nil
+	}, jwt.WithValidMethods([]string{"RS256"}), jwt.WithIssuer("https://id.example.com"), jwt.WithAudience("spa")); err != nil {
+		t.Fatal(err)
+	}
+
+	// at_hash是访问令牌SHA-256摘要左半部分的base64url编码
+	sum := sha256.Sum256([]byte(tokens.AccessToken))
+	if want := base64.RawURLEncoding.EncodeToString(sum[:16]); claims["at_hash"] != want {
+		t.Fatalf("expected at_hash %s, got %v", want, claims["at_hash"])
+	}
+	if claims["auth_time"] != float64(authTime.Unix()) {
+		t.Fatalf("expected auth_time %d, got %v", authTime.Unix(), claims["auth_time"])
+	}
+	if claims["nonce"] != "n-0S6_WzA2Mj" || claims["sub"] != "1" || claims["azp"] != "spa" {
+		t.Fatalf("unexpected ID token claims %v", claims)
+	}
+	if claims["email"] != "alice@example.com" {
+		t.Fatalf("expected email claim for the email scope, got %v", claims)
+	}
+	if _, ok := claims["preferred_username"]; ok {
+		t.Fatalf("expected no profile claims without the profile scope, got %v", claims)
+	}
+
+	// ID Token不能当作访问令牌调用userinfo
+	if _, err := f.svc.UserInfo(tokens.IDToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ID token to be rejected by userinfo, got %v", err)
+	}
+	info, err := f.svc.UserInfo(tokens.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info["sub"] != "1" || info["email"] != "alice@example.com" {
+		t.Fatalf("unexpected userinfo %v", info)
+	}
+}
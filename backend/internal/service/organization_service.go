@@ -0,0 +1,352 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/user/user-management/internal/config"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+// InvitationPage 是邀请邮件中链接指向的前端页面，token作为查询参数
+const InvitationPage = "/invitations/accept"
+
+var organizationSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,62}[a-z0-9]$`)
+
+// OrganizationService 管理组织、成员和邀请。操作者在组织中的角色由调用方通过GetMembership取得后传入
+type OrganizationService interface {
+	// Create 创建组织，创建者成为owner
+	Create(slug, name string, createdBy uint) (*models.Organization, error)
+	ListByUser(userID uint) ([]models.OrganizationMember, error)
+	// GetMembership 返回用户在组织中的成员关系，组织不存在时返回ErrOrganizationNotFound，不是成员时返回ErrNotOrganizationMember
+	GetMembership(organizationID, userID uint) (*models.OrganizationMember, error)
+	Delete(organizationID uint) (*models.Organization, error)
+	ListMembers(organizationID uint) ([]models.OrganizationMember, error)
+	// UpdateMemberRole 只有owner可以授予或撤销owner角色，组织至少保留一个owner
+	UpdateMemberRole(organizationID uint, actorRole string, userID uint, role string) (*models.OrganizationMember, error)
+	// RemoveMember 成员可以退出组织，移除其他成员需要owner或admin，移除owner需要owner
+	RemoveMember(organizationID uint, actorID uint, actorRole string, userID uint) error
+	// Invite 向email发送邀请链接，同一邮箱之前未接受的邀请作废；只有owner可以邀请owner
+	Invite(organizationID uint, actorRole, email, role string, invitedBy uint) (*models.OrganizationInvitation, error)
+	ListInvitations(organizationID uint) ([]models.OrganizationInvitation, error)
+	RevokeInvitation(organizationID, id uint) (*models.OrganizationInvitation, error)
+	// AcceptInvitation 邀请只能由邮箱与邀请一致的用户接受
+	AcceptInvitation(token string, userID uint) (*models.OrganizationMember, error)
+}
+
+type organizationService struct {
+	cfg      config.OrganizationConfig
+	orgRepo  repository.OrganizationRepository
+	userRepo repository.UserRepository
+	mailer   Mailer
+	baseURL  string
+}
+
+// NewOrganizationService 的baseURL是前端的对外地址，用于拼接邀请邮件中的链接
+func NewOrganizationService(cfg config.OrganizationConfig, orgRepo repository.OrganizationRepository, userRepo repository.UserRepository, mailer Mailer, baseURL string) OrganizationService {
+	return &organizationService{
+		cfg:      cfg,
+		orgRepo:  orgRepo,
+		userRepo: userRepo,
+		mailer:   mailer,
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+func (s *organizationService) Create(slug, name string, createdBy uint) (*models.Organization, error) {
+	if !organizationSlugPattern.MatchString(slug) {
+		return nil, ErrInvalidOrganizationSlug
+	}
+
+	existing, err := s.orgRepo.GetBySlug(slug)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrOrganizationSlugTaken
+	}
+
+	org := &models.Organization{Slug: slug, Name: name, CreatedBy: createdBy}
+	owner := &models.OrganizationMember{UserID: createdBy, Role: models.OrgRoleOwner}
+	if err := s.orgRepo.Create(org, owner); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+func (s *organizationService) ListByUser(userID uint) ([]models.OrganizationMember, error) {
+	return s.orgRepo.ListByUser(userID)
+}
+
+func (s *organizationService) GetMembership(organizationID, userID uint) (*models.OrganizationMember, error) {
+	org, err := s.orgRepo.GetByID(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if org == nil {
+		return nil, ErrOrganizationNotFound
+	}
+
+	member, err := s.orgRepo.GetMember(organizationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member == nil {
+		return nil, ErrNotOrganizationMember
+	}
+	member.Organization = *org
+	return member, nil
+}
+
+func (s *organizationService) Delete(organizationID uint) (*models.Organization, error) {
+	org, err := s.orgRepo.GetByID(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if org == nil {
+		return nil, ErrOrganizationNotFound
+	}
+	if err := s.orgRepo.Delete(organizationID); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+func (s *organizationService) ListMembers(organizationID uint) ([]models.OrganizationMember, error) {
+	return s.orgRepo.ListMembers(organizationID)
+}
+
+func (s *organizationService) UpdateMemberRole(organizationID uint, actorRole string, userID uint, role string) (*models.OrganizationMember, error) {
+	if !validOrgRole(role) {
+		return nil, ErrInvalidOrganizationRole
+	}
+
+	member, err := s.orgRepo.GetMember(organizationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member == nil {
+		return nil, ErrMemberNotFound
+	}
+	if member.Role == role {
+		return member, nil
+	}
+	if (role == models.OrgRoleOwner || member.Role == models.OrgRoleOwner) && actorRole != models.OrgRoleOwner {
+		return nil, ErrForbidden
+	}
+	if member.Role == models.OrgRoleOwner {
+		if err := s.ensureAnotherOwner(organizationID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.orgRepo.UpdateMemberRole(organizationID, userID, role); err != nil {
+		return nil, err
+	}
+	member.Role = role
+	return member, nil
+}
+
+func (s *organizationService) RemoveMember(organizationID uint, actorID uint, actorRole string, userID uint) error {
+	member, err := s.orgRepo.GetMember(organizationID, userID)
+	if err != nil {
+		return err
+	}
+	if member == nil {
+		return ErrMemberNotFound
+	}
+	if userID != actorID {
+		if actorRole != models.OrgRoleOwner && actorRole != models.OrgRoleAdmin {
+			return ErrForbidden
+		}
+		if member.Role == models.OrgRoleOwner && actorRole != models.OrgRoleOwner {
+			return ErrForbidden
+		}
+	}
+	if member.Role == models.OrgRoleOwner {
+		if err := s.ensureAnotherOwner(organizationID); err != nil {
+			return err
+		}
+	}
+	return s.orgRepo.RemoveMember(organizationID, userID)
+}
+
+// ensureAnotherOwner 在降级或移除一个owner之前确认组织还有其他owner
+func (s *organizationService) ensureAnotherOwner(organizationID uint) error {
+	owners, err := s.orgRepo.CountByRole(organizationID, models.OrgRoleOwner)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+func (s *organizationService) Invite(organizationID uint, actorRole, email, role string, invitedBy uint) (*models.OrganizationInvitation, error) {
+	if !validOrgRole(role) {
+		return nil, ErrInvalidOrganizationRole
+	}
+	if role == models.OrgRoleOwner && actorRole != models.OrgRoleOwner {
+		return nil, ErrForbidden
+	}
+
+	org, err := s.orgRepo.GetByID(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if org == nil {
+		return nil, ErrOrganizationNotFound
+	}
+
+	email = strings.ToLower(strings.TrimSpace(email))
+	if user, err := s.userRepo.GetByEmail(email); err != nil {
+		return nil, err
+	} else if user != nil {
+		member, err := s.orgRepo.GetMember(organizationID, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if member != nil {
+			return nil, ErrAlreadyMember
+		}
+	}
+
+	if err := s.orgRepo.DeletePendingInvitations(organizationID, email); err != nil {
+		return nil, err
+	}
+
+	token, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	invitation := &models.OrganizationInvitation{
+		OrganizationID: organizationID,
+		Email:          email,
+		Role:           role,
+		TokenHash:      hashAccessToken(token),
+		InvitedBy:      invitedBy,
+		ExpiresAt:      time.Now().Add(s.cfg.InvitationTTL),
+	}
+	if err := s.orgRepo.CreateInvitation(invitation); err != nil {
+		return nil, err
+	}
+
+	link := s.baseURL + InvitationPage + "?token=" + url.QueryEscape(token)
+	go func() {
+		if err := s.mailer.Send(email, fmt.Sprintf("Invitation to join %s / 加入%s的邀请", org.Name, org.Name), fmt.Sprintf(
+			"You have been invited to join %s. Sign in with this email address and open the link below within %s to accept.\n"+
+				"您被邀请加入%s。请使用此邮箱登录，并在%s内打开以下链接接受邀请。\n\n%s\n\n"+
+				"If you were not expecting this invitation, you can ignore this email.\n如果您不认识邀请方，请忽略此邮件。\n",
+			org.Name, s.cfg.InvitationTTL, org.Name, s.cfg.InvitationTTL, link)); err != nil {
+			log.Printf("Failed to send organization invitation: %v", err)
+		}
+	}()
+	return invitation, nil
+}
+
+func (s *organizationService) ListInvitations(organizationID uint) ([]models.OrganizationInvitation, error) {
+	return s.orgRepo.ListPendingInvitations(organizationID)
+}
+
+func (s *organizationService) RevokeInvitation(organizationID, id uint) (*models.OrganizationInvitation, error) {
+	invitation, err := s.orgRepo.GetInvitation(organizationID, id)
+	if err != nil {
+		return nil, err
+	}
+	if invitation == nil {
+		return nil, ErrInvitationNotFound
+	}
+	if err := s.orgRepo.DeleteInvitation(id); err != nil {
+		return nil, err
+	}
+	return invitation, nil
+}
+
+func (s *organizationService) AcceptInvitation(token string, userID uint) (*models.OrganizationMember, error) {
+	invitation, err := s.orgRepo.GetInvitationByHash(hashAccessToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if invitation == nil || invitation.AcceptedAt != nil || time.Now().After(invitation.ExpiresAt) {
+		return nil, ErrInvalidInvitation
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !strings.EqualFold(user.Email, invitation.Email) {
+		return nil, ErrInvalidInvitation
+	}
+
+	existing, err := s.orgRepo.GetMember(invitation.OrganizationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrAlreadyMember
+	}
+
+	member := &models.OrganizationMember{
+		OrganizationID: invitation.OrganizationID,
+		UserID:         userID,
+		Role:           invitation.Role,
+	}
+	if err := s.orgRepo.AcceptInvitation(invitation, member); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			return nil, ErrInvalidInvitation
+		}
+		return nil, err
+	}
+	member.Organization = invitation.Organization
+	return member, nil
+}
+
+func validOrgRole(role string) bool {
+	for _, r := range models.OrgRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultOrganization 返回用户最早加入的组织，不属于任何组织时返回0
+func defaultOrganization(orgRepo repository.OrganizationRepository, userID uint) (uint, error) {
+	memberships, err := orgRepo.ListByUser(userID)
+	if err != nil || len(memberships) == 0 {
+		return 0, err
+	}
+	return memberships[0].OrganizationID, nil
+}
+
+// organizationRole 校验令牌所在的组织并返回用户在其中的角色；organizationID为0时要求用户不属于任何组织，
+// 否则加入组织后仍可用旧令牌看到不属于任何组织的用户
+func organizationRole(orgRepo repository.OrganizationRepository, userID, organizationID uint) (string, error) {
+	if organizationID == 0 {
+		memberships, err := orgRepo.ListByUser(userID)
+		if err != nil {
+			return "", err
+		}
+		if len(memberships) > 0 {
+			return "", ErrInvalidToken
+		}
+		return "", nil
+	}
+
+	member, err := orgRepo.GetMember(organizationID, userID)
+	if err != nil {
+		return "", err
+	}
+	if member == nil {
+		return "", ErrInvalidToken
+	}
+	return member.Role, nil
+}
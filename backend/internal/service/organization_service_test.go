@@ -0,0 +1,264 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/user-management/internal/config"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+type memoryOrganizationRepository struct {
+	repository.OrganizationRepository
+	orgs        []*models.Organization
+	members     []*models.OrganizationMember
+	invitations []*models.OrganizationInvitation
+}
+
+func (r *memoryOrganizationRepository) Create(org *models.Organization, owner *models.OrganizationMember) error {
+	org.ID = uint(len(r.orgs) + 1)
+	org.CreatedAt = time.Now()
+	r.orgs = append(r.orgs, org)
+	owner.OrganizationID = org.ID
+	r.members = append(r.members, owner)
+	return nil
+}
+
+func (r *memoryOrganizationRepository) GetByID(id uint) (*models.Organization, error) {
+	for _, org := range r.orgs {
+		if org.ID == id {
+			return org, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryOrganizationRepository) GetBySlug(slug string) (*models.Organization, error) {
+	for _, org := range r.orgs {
+		if org.Slug == slug {
+			return org, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryOrganizationRepository) GetMember(organizationID, userID uint) (*models.OrganizationMember, error) {
+	for _, member := range r.members {
+		if member.OrganizationID == organizationID && member.UserID == userID {
+			copied := *member
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryOrganizationRepository) ListByUser(userID uint) ([]models.OrganizationMember, error) {
+	var memberships []models.OrganizationMember
+	for _, member := range r.members {
+		if member.UserID == userID {
+			memberships = append(memberships, *member)
+		}
+	}
+	return memberships, nil
+}
+
+func (r *memoryOrganizationRepository) CountByRole(organizationID uint, role string) (int64, error) {
+	var count int64
+	for _, member := range r.members {
+		if member.OrganizationID == organizationID && member.Role == role {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryOrganizationRepository) UpdateMemberRole(organizationID, userID uint, role string) error {
+	for _, member := range r.members {
+		if member.OrganizationID == organizationID && member.UserID == userID {
+			member.Role = role
+		}
+	}
+	return nil
+}
+
+func (r *memoryOrganizationRepository) RemoveMember(organizationID, userID uint) error {
+	for i, member := range r.members {
+		if member.OrganizationID == organizationID && member.UserID == userID {
+			r.members = append(r.members[:i], r.members[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (r *memoryOrganizationRepository) CreateInvitation(invitation *models.OrganizationInvitation) error {
+	invitation.ID = uint(len(r.invitations) + 1)
+	r.invitations = append(r.invitations, invitation)
+	return nil
+}
+
+func (r *memoryOrganizationRepository) GetInvitationByHash(tokenHash string) (*models.OrganizationInvitation, error) {
+	for _, invitation := range r.invitations {
+		if invitation.TokenHash == tokenHash {
+			copied := *invitation
+			org, _ := r.GetByID(invitation.OrganizationID)
+			copied.Organization = *org
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryOrganizationRepository) DeletePendingInvitations(organizationID uint, email string) error {
+	kept := r.invitations[:0]
+	for _, invitation := range r.invitations {
+		if invitation.OrganizationID != organizationID || invitation.Email != email || invitation.AcceptedAt != nil {
+			kept = append(kept, invitation)
+		}
+	}
+	r.invitations = kept
+	return nil
+}
+
+func (r *memoryOrganizationRepository) AcceptInvitation(invitation *models.OrganizationInvitation, member *models.OrganizationMember) error {
+	for _, stored := range r.invitations {
+		if stored.ID == invitation.ID {
+			if stored.AcceptedAt != nil {
+				return repository.ErrVersionConflict
+			}
+			now := time.Now()
+			stored.AcceptedAt = &now
+		}
+	}
+	r.members = append(r.members, member)
+	return nil
+}
+
+// receiveInvitationToken 等待异步发送的邀请邮件并取出指向page的链接中的token
+func receiveInvitationToken(t *testing.T, mailer channelMailer, page string) string {
+	t.Helper()
+
+	select {
+	case mail := <-mailer:
+		for _, line := range strings.Split(mail.body, "\n") {
+			if strings.HasPrefix(line, "https://example.com"+page+"?") {
+				link, err := url.Parse(line)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return link.Query().Get("token")
+			}
+		}
+		t.Fatalf("no invitation link in mail: %q", mail.body)
+	case <-time.After(time.Second):
+		t.Fatal("no mail sent")
+	}
+	return ""
+}
+
+func TestOrganizationInvitations(t *testing.T) {
+	users := &memoryUserRepository{}
+	owner := &models.User{Username: "owner", Email: "owner@example.com", IsActive: true}
+	alice := &models.User{Username: "alice", Email: "Alice@example.com", IsActive: true}
+	mallory := &models.User{Username: "mallory", Email: "mallory@example.com", IsActive: true}
+	for _, user := range []*models.User{owner, alice, mallory} {
+		users.Create(user)
+	}
+	orgs := &memoryOrganizationRepository{}
+	mailer := make(channelMailer, 10)
+	svc := NewOrganizationService(config.OrganizationConfig{InvitationTTL: time.Hour}, orgs, users, mailer, "https://example.com/")
+
+	if _, err := svc.Create("Acme Corp", "Acme", owner.ID); !errors.Is(err, ErrInvalidOrganizationSlug) {
+		t.Fatalf("expected invalid slug, got %v", err)
+	}
+	org, err := svc.Create("acme", "Acme", owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Create("acme", "Other", alice.ID); !errors.Is(err, ErrOrganizationSlugTaken) {
+		t.Fatalf("expected slug conflict, got %v", err)
+	}
+
+	// 只有owner可以邀请owner
+	if _, err := svc.Invite(org.ID, models.OrgRoleAdmin, "alice@example.com", models.OrgRoleOwner, owner.ID); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected admin to be unable to invite owners, got %v", err)
+	}
+	if _, err := svc.Invite(org.ID, models.OrgRoleOwner, "owner@example.com", models.OrgRoleMember, owner.ID); !errors.Is(err, ErrAlreadyMember) {
+		t.Fatalf("expected existing member to be rejected, got %v", err)
+	}
+
+	if _, err := svc.Invite(org.ID, models.OrgRoleOwner, "alice@example.com", models.OrgRoleMember, owner.ID); err != nil {
+		t.Fatal(err)
+	}
+	superseded := receiveInvitationToken(t, mailer, InvitationPage)
+	if _, err := svc.Invite(org.ID, models.OrgRoleOwner, " ALICE@example.com ", models.OrgRoleAdmin, owner.ID); err != nil {
+		t.Fatal(err)
+	}
+	token := receiveInvitationToken(t, mailer, InvitationPage)
+
+	if _, err := svc.AcceptInvitation(superseded, alice.ID); !errors.Is(err, ErrInvalidInvitation) {
+		t.Fatalf("expected superseded invitation to be rejected, got %v", err)
+	}
+	// 邀请链接被转发给其他用户时不能使用
+	if _, err := svc.AcceptInvitation(token, mallory.ID); !errors.Is(err, ErrInvalidInvitation) {
+		t.Fatalf("expected invitation for another email to be rejected, got %v", err)
+	}
+
+	member, err := svc.AcceptInvitation(token, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if member.OrganizationID != org.ID || member.Role != models.OrgRoleAdmin || member.Organization.Slug != "acme" {
+		t.Fatalf("unexpected membership %+v", member)
+	}
+	if _, err := svc.AcceptInvitation(token, alice.ID); !errors.Is(err, ErrInvalidInvitation) {
+		t.Fatalf("expected used invitation to be rejected, got %v", err)
+	}
+}
+
+func TestOrganizationOwners(t *testing.T) {
+	users := &memoryUserRepository{}
+	orgs := &memoryOrganizationRepository{}
+	svc := NewOrganizationService(config.OrganizationConfig{InvitationTTL: time.Hour}, orgs, users, make(channelMailer, 10), "https://example.com/")
+
+	org, err := svc.Create("acme", "Acme", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orgs.members = append(orgs.members,
+		&models.OrganizationMember{OrganizationID: org.ID, UserID: 2, Role: models.OrgRoleAdmin},
+		&models.OrganizationMember{OrganizationID: org.ID, UserID: 3, Role: models.OrgRoleMember})
+
+	if _, err := svc.UpdateMemberRole(org.ID, models.OrgRoleOwner, 1, models.OrgRoleAdmin); !errors.Is(err, ErrLastOwner) {
+		t.Fatalf("expected last owner to be kept, got %v", err)
+	}
+	if err := svc.RemoveMember(org.ID, 1, models.OrgRoleOwner, 1); !errors.Is(err, ErrLastOwner) {
+		t.Fatalf("expected last owner to be unable to leave, got %v", err)
+	}
+	if _, err := svc.UpdateMemberRole(org.ID, models.OrgRoleAdmin, 3, models.OrgRoleOwner); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected admin to be unable to grant owner, got %v", err)
+	}
+	if err := svc.RemoveMember(org.ID, 2, models.OrgRoleAdmin, 1); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected admin to be unable to remove owner, got %v", err)
+	}
+	if err := svc.RemoveMember(org.ID, 3, models.OrgRoleMember, 2); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected member to be unable to remove others, got %v", err)
+	}
+
+	if _, err := svc.UpdateMemberRole(org.ID, models.OrgRoleOwner, 2, models.OrgRoleOwner); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.RemoveMember(org.ID, 1, models.OrgRoleOwner, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.RemoveMember(org.ID, 3, models.OrgRoleMember, 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.GetMembership(org.ID, 3); !errors.Is(err, ErrNotOrganizationMember) {
+		t.Fatalf("expected member to have left, got %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/user/user-management/internal/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 支持的密码哈希算法
+const (
+	PasswordAlgorithmArgon2id = "argon2id"
+	PasswordAlgorithmBcrypt   = "bcrypt"
+)
+
+// UnusablePassword 用作只能通过外部身份提供方登录的用户的密码哈希，不与任何密码匹配
+const UnusablePassword = "!"
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var errUnknownPasswordHash = errors.New("unknown password hash format")
+
+// PasswordHasher 以PHC字符串格式生成和校验密码哈希，哈希中包含算法和参数，
+// 因此调整算法或参数后旧的哈希仍可校验，并在登录时按NeedsRehash重新生成
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(encoded, password string) bool
+	// NeedsRehash 在哈希使用的算法或参数与当前配置不同时返回true
+	NeedsRehash(encoded string) bool
+}
+
+type passwordHasher struct {
+	cfg config.PasswordConfig
+}
+
+func NewPasswordHasher(cfg config.PasswordConfig) (PasswordHasher, error) {
+	switch cfg.Algorithm {
+	case PasswordAlgorithmArgon2id:
+		if cfg.Argon2Memory == 0 || cfg.Argon2Iterations == 0 || cfg.Argon2Parallelism == 0 {
+			return nil, fmt.Errorf("argon2id memory, iterations and parallelism must be positive")
+		}
+	case PasswordAlgorithmBcrypt:
+		if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm %q", cfg.Algorithm)
+	}
+	return &passwordHasher{cfg: cfg}, nil
+}
+
+func (h *passwordHasher) Hash(password string) (string, error) {
+	if h.cfg.Algorithm == PasswordAlgorithmBcrypt {
+		// bcrypt自身的$2a$格式即为其PHC表示
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cfg.BcryptCost)
+		return string(hash), err
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	params := argon2Params{
+		memory:      h.cfg.Argon2Memory,
+		iterations:  h.cfg.Argon2Iterations,
+		parallelism: h.cfg.Argon2Parallelism,
+	}
+	key := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, argon2KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, params.memory, params.iterations, params.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *passwordHasher) Verify(encoded, password string) bool {
+	if isBcryptHash(encoded) {
+		return bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)) == nil
+	}
+
+	params, salt, key, err := decodeArgon2(encoded)
+	if err != nil {
+		return false
+	}
+	actual := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(actual, key) == 1
+}
+
+func (h *passwordHasher) NeedsRehash(encoded string) bool {
+	if isBcryptHash(encoded) {
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return h.cfg.Algorithm != PasswordAlgorithmBcrypt || err != nil || cost != h.cfg.BcryptCost
+	}
+
+	params, salt, key, err := decodeArgon2(encoded)
+	if err != nil {
+		// 无法识别的哈希无法通过校验，也就不会走到重新生成
+		return false
+	}
+	return h.cfg.Algorithm != PasswordAlgorithmArgon2id ||
+		params.memory != h.cfg.Argon2Memory ||
+		params.iterations != h.cfg.Argon2Iterations ||
+		params.parallelism != h.cfg.Argon2Parallelism ||
+		len(salt) != argon2SaltLength || len(key) != argon2KeyLength
+}
+
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+// decodeArgon2 解析$argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>格式的哈希
+func decodeArgon2(encoded string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != PasswordAlgorithmArgon2id {
+		return params, nil, nil, errUnknownPasswordHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errUnknownPasswordHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil ||
+		params.memory == 0 || params.iterations == 0 || params.parallelism == 0 {
+		return params, nil, nil, errUnknownPasswordHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, errUnknownPasswordHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, errUnknownPasswordHash
+	}
+	return params, salt, key, nil
+}
+
+func isBcryptHash(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
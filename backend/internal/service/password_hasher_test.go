@@ -0,0 +1,155 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/user/user-management/internal/config"
+	"github.com/user/user-management/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 测试使用较低的参数，避免拖慢测试
+var testPasswordConfig = config.PasswordConfig{
+	Algorithm:         PasswordAlgorithmArgon2id,
+	Argon2Memory:      1024,
+	Argon2Iterations:  1,
+	Argon2Parallelism: 1,
+	BcryptCost:        bcrypt.MinCost,
+}
+
+func newTestPasswordHasher(t *testing.T) PasswordHasher {
+	t.Helper()
+
+	hasher, err := NewPasswordHasher(testPasswordConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hasher
+}
+
+func TestPasswordHasher(t *testing.T) {
+	argon := newTestPasswordHasher(t)
+	bcryptConfig := testPasswordConfig
+	bcryptConfig.Algorithm = PasswordAlgorithmBcrypt
+	bcryptHasher, err := NewPasswordHasher(bcryptConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	argonHash, err := argon.Hash("secret123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(argonHash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("unexpected argon2id hash: %s", argonHash)
+	}
+	bcryptHash, err := bcryptHasher.Hash("secret123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 两种哈希都可以由任一配置校验
+	for _, hasher := range []PasswordHasher{argon, bcryptHasher} {
+		for _, hash := range []string{argonHash, bcryptHash} {
+			if !hasher.Verify(hash, "secret123") || hasher.Verify(hash, "secret124") {
+				t.Errorf("unexpected verification result for %s", hash)
+			}
+		}
+	}
+	for _, hash := range []string{"", UnusablePassword, "$argon2id$v=19$m=0,t=1,p=1$AAAA$AAAA", "$argon2i$v=19$m=1024,t=1,p=1$AAAA$AAAA"} {
+		if argon.Verify(hash, "") || argon.NeedsRehash(hash) {
+			t.Errorf("unusable hash %q must never verify or be rehashed", hash)
+		}
+	}
+
+	if argon.NeedsRehash(argonHash) || !argon.NeedsRehash(bcryptHash) {
+		t.Error("argon2id configuration must only rehash bcrypt hashes")
+	}
+	if bcryptHasher.NeedsRehash(bcryptHash) || !bcryptHasher.NeedsRehash(argonHash) {
+		t.Error("bcrypt configuration must only rehash argon2id hashes")
+	}
+
+	stronger := testPasswordConfig
+	stronger.Argon2Iterations = 2
+	strongerHasher, err := NewPasswordHasher(stronger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strongerHasher.NeedsRehash(argonHash) {
+		t.Error("raising argon2id iterations must require a rehash")
+	}
+
+	for _, cfg := range []config.PasswordConfig{{Algorithm: "md5"}, {Algorithm: PasswordAlgorithmBcrypt, BcryptCost: 40}, {Algorithm: PasswordAlgorithmArgon2id}} {
+		if _, err := NewPasswordHasher(cfg); err == nil {
+			t.Errorf("expected %+v to be rejected", cfg)
+		}
+	}
+}
+
+func TestPasswordAuthenticatorRehashesOnLogin(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := &memoryUserRepository{}
+	user := &models.User{Username: "alice", Email: "alice@example.com", PasswordHash: string(legacy), IsActive: true}
+	users.Create(user)
+	authenticator := NewPasswordAuthenticator(users, newTestPasswordHasher(t))
+
+	if _, err := authenticator.Authenticate("alice@example.com", "wrong"); err != ErrInvalidCredentials {
+		t.Fatalf("expected invalid credentials, got %v", err)
+	}
+	if user.PasswordHash != string(legacy) {
+		t.Fatal("failed login must not rehash the password")
+	}
+
+	if _, err := authenticator.Authenticate("alice@example.com", "secret123"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(user.PasswordHash, "$argon2id$") {
+		t.Fatalf("expected password to be rehashed with argon2id, got %s", user.PasswordHash)
+	}
+	if _, err := authenticator.Authenticate("alice@example.com", "secret123"); err != nil {
+		t.Fatalf("rehashed password must still verify: %v", err)
+	}
+}
+
+// staleUserRepository 返回读取时的快照，随后模拟另一个请求在重新生成哈希之前修改了密码
+type staleUserRepository struct {
+	*memoryUserRepository
+	changedHash string
+}
+
+func (r *staleUserRepository) GetByEmail(email string) (*models.User, error) {
+	user, err := r.memoryUserRepository.GetByEmail(email)
+	if user == nil || err != nil {
+		return user, err
+	}
+	snapshot := *user
+	user.PasswordHash = r.changedHash
+	return &snapshot, nil
+}
+
+func TestPasswordAuthenticatorSkipsRehashAfterConcurrentChange(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasher := newTestPasswordHasher(t)
+	changed, err := hasher.Hash("new-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := &staleUserRepository{memoryUserRepository: &memoryUserRepository{}, changedHash: changed}
+	user := &models.User{Username: "alice", Email: "alice@example.com", PasswordHash: string(legacy), IsActive: true}
+	users.Create(user)
+
+	// 用旧密码的本次登录仍然成功，但不能用旧密码的哈希覆盖新密码
+	if _, err := NewPasswordAuthenticator(users, hasher).Authenticate("alice@example.com", "secret123"); err != nil {
+		t.Fatal(err)
+	}
+	if user.PasswordHash != changed {
+		t.Fatal("expected rehash to be skipped after the password changed")
+	}
+}
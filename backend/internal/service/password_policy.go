@@ -0,0 +1,210 @@
+package service
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"log"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nbutton23/zxcvbn-go"
+	"github.com/user/user-management/internal/config"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+// 个人信息中短于该长度的部分不作为禁用词，避免误伤
+const minBannedWordLength = 3
+
+// zxcvbn的耗时随长度快速增长，只评估密码的前zxcvbnMaxLength个字符
+const zxcvbnMaxLength = 64
+
+// PasswordPolicy 是所有设置密码的入口共用的密码规则
+type PasswordPolicy interface {
+	// SetPassword 按策略校验user的新密码，通过后把哈希写入user.PasswordHash，由调用方保存用户；
+	// 不满足时返回weak_password错误，Fields中列出每条未通过的规则
+	SetPassword(user *models.User, password string) error
+	// Remember 在用户保存后把当前密码记入历史，只保留策略要求的个数
+	Remember(user *models.User)
+}
+
+type passwordPolicy struct {
+	cfg            config.PasswordPolicyConfig
+	passwordHasher PasswordHasher
+	historyRepo    repository.PasswordHistoryRepository
+	breached       BreachedPasswordSource
+}
+
+// NewPasswordPolicy 的breached为nil时不检查泄露密码
+func NewPasswordPolicy(cfg config.PasswordPolicyConfig, passwordHasher PasswordHasher, historyRepo repository.PasswordHistoryRepository, breached BreachedPasswordSource) PasswordPolicy {
+	return &passwordPolicy{
+		cfg:            cfg,
+		passwordHasher: passwordHasher,
+		historyRepo:    historyRepo,
+		breached:       breached,
+	}
+}
+
+func (p *passwordPolicy) SetPassword(user *models.User, password string) error {
+	fields := p.checkRules(user, password)
+
+	if p.breached != nil {
+		breached, err := p.isBreached(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			fields = append(fields, FieldError{Field: "password", Code: "password_breached", Message: "password has appeared in a data breach"})
+		}
+	}
+
+	// 与历史哈希逐个比对代价较高，其他规则都通过后才检查
+	if len(fields) == 0 && p.cfg.History > 0 && user.ID != 0 {
+		reused, err := p.isReused(user, password)
+		if err != nil {
+			return err
+		}
+		if reused {
+			fields = append(fields, FieldError{Field: "password", Code: "password_reused", Message: "password must not be one of the last " + strconv.Itoa(p.cfg.History) + " passwords", Param: strconv.Itoa(p.cfg.History)})
+		}
+	}
+
+	if len(fields) > 0 {
+		return weakPassword(fields)
+	}
+
+	hashedPassword, err := p.passwordHasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hashedPassword
+	return nil
+}
+
+func (p *passwordPolicy) Remember(user *models.User) {
+	if p.cfg.History <= 0 {
+		return
+	}
+	if err := p.historyRepo.Create(&models.PasswordHistory{UserID: user.ID, PasswordHash: user.PasswordHash}); err != nil {
+		log.Printf("Failed to record password history for user %d: %v", user.ID, err)
+		return
+	}
+	if err := p.historyRepo.Prune(user.ID, p.cfg.History); err != nil {
+		log.Printf("Failed to prune password history for user %d: %v", user.ID, err)
+	}
+}
+
+// checkRules 检查长度、字符类别、禁用词和强度评分
+func (p *passwordPolicy) checkRules(user *models.User, password string) []FieldError {
+	var fields []FieldError
+
+	if p.cfg.MinLength > 0 && utf8.RuneCountInString(password) < p.cfg.MinLength {
+		param := strconv.Itoa(p.cfg.MinLength)
+		fields = append(fields, FieldError{Field: "password", Code: "password_length", Message: "password must be at least " + param + " characters", Param: param})
+	}
+
+	if p.cfg.MinClasses > 0 && characterClasses(password) < p.cfg.MinClasses {
+		param := strconv.Itoa(p.cfg.MinClasses)
+		fields = append(fields, FieldError{Field: "password", Code: "password_classes", Message: "password must contain at least " + param + " of: lowercase letters, uppercase letters, digits, symbols", Param: param})
+	}
+
+	bannedWords := p.bannedWords(user)
+	lower := strings.ToLower(password)
+	for _, word := range bannedWords {
+		if strings.Contains(lower, word) {
+			fields = append(fields, FieldError{Field: "password", Code: "password_personal", Message: "password must not contain the username, email address or other easily guessed words"})
+			break
+		}
+	}
+
+	if p.cfg.MinScore > 0 {
+		analysed := password
+		if utf8.RuneCountInString(analysed) > zxcvbnMaxLength {
+			analysed = string([]rune(analysed)[:zxcvbnMaxLength])
+		}
+		if zxcvbn.PasswordStrength(analysed, bannedWords).Score < p.cfg.MinScore {
+			fields = append(fields, FieldError{Field: "password", Code: "password_strength", Message: "password is too easy to guess"})
+		}
+	}
+
+	return fields
+}
+
+// bannedWords 返回小写的禁用词：配置的词、用户名和邮箱@之前的部分
+func (p *passwordPolicy) bannedWords(user *models.User) []string {
+	localPart, _, _ := strings.Cut(user.Email, "@")
+	candidates := append([]string{user.Username, localPart}, p.cfg.BannedWords...)
+
+	words := make([]string, 0, len(candidates))
+	for _, word := range candidates {
+		if word = strings.ToLower(strings.TrimSpace(word)); utf8.RuneCountInString(word) >= minBannedWordLength {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// isBreached 只把SHA-1的前缀交给泄露密码源，在本地比对后缀
+func (p *passwordPolicy) isBreached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes, err := p.breached.Range(hash[:breachedHashPrefixLength])
+	if err != nil {
+		return false, err
+	}
+	for _, suffix := range suffixes {
+		if strings.EqualFold(suffix, hash[breachedHashPrefixLength:]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isReused 与当前密码和历史中最近的密码比对；当前密码也单独比对，以覆盖启用历史记录之前设置的密码
+func (p *passwordPolicy) isReused(user *models.User, password string) (bool, error) {
+	history, err := p.historyRepo.ListRecent(user.ID, p.cfg.History)
+	if err != nil {
+		return false, err
+	}
+
+	hashes := []string{user.PasswordHash}
+	for _, entry := range history {
+		if entry.PasswordHash != user.PasswordHash {
+			hashes = append(hashes, entry.PasswordHash)
+		}
+	}
+	for _, hash := range hashes {
+		if hash != "" && hash != UnusablePassword && p.passwordHasher.Verify(hash, password) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// characterClasses 统计密码包含的字符类别数
+func characterClasses(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	count := 0
+	for _, present := range []bool{lower, upper, digit, other} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
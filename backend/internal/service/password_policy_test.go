@@ -0,0 +1,196 @@
+package service
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/user/user-management/internal/config"
+	"github.com/user/user-management/internal/models"
+)
+
+type memoryPasswordHistoryRepository struct {
+	entries []models.PasswordHistory
+}
+
+func (r *memoryPasswordHistoryRepository) Create(entry *models.PasswordHistory) error {
+	entry.ID = uint(len(r.entries) + 1)
+	r.entries = append(r.entries, *entry)
+	return nil
+}
+
+func (r *memoryPasswordHistoryRepository) ListRecent(userID uint, limit int) ([]models.PasswordHistory, error) {
+	var recent []models.PasswordHistory
+	for i := len(r.entries) - 1; i >= 0 && len(recent) < limit; i-- {
+		if r.entries[i].UserID == userID {
+			recent = append(recent, r.entries[i])
+		}
+	}
+	return recent, nil
+}
+
+func (r *memoryPasswordHistoryRepository) Prune(userID uint, keep int) error {
+	recent, _ := r.ListRecent(userID, keep)
+	kept := make(map[uint]bool, len(recent))
+	for _, entry := range recent {
+		kept[entry.ID] = true
+	}
+
+	var entries []models.PasswordHistory
+	for _, entry := range r.entries {
+		if entry.UserID != userID || kept[entry.ID] {
+			entries = append(entries, entry)
+		}
+	}
+	r.entries = entries
+	return nil
+}
+
+// newTestPasswordPolicy 不启用任何规则，供只关心密码能否设置的测试使用
+func newTestPasswordPolicy(t *testing.T) PasswordPolicy {
+	t.Helper()
+	return NewPasswordPolicy(config.PasswordPolicyConfig{}, newTestPasswordHasher(t), &memoryPasswordHistoryRepository{}, nil)
+}
+
+// weakPasswordCodes 返回weak_password错误中各条规则的错误码
+func weakPasswordCodes(t *testing.T, err error) []string {
+	t.Helper()
+
+	domainErr, ok := AsError(err)
+	if !ok || domainErr.Code != "weak_password" {
+		t.Fatalf("expected weak_password, got %v", err)
+	}
+	codes := make([]string, len(domainErr.Fields))
+	for i, field := range domainErr.Fields {
+		codes[i] = field.Code
+	}
+	return codes
+}
+
+func TestPasswordPolicyRules(t *testing.T) {
+	hasher := newTestPasswordHasher(t)
+	policy := NewPasswordPolicy(config.PasswordPolicyConfig{
+		MinLength:   10,
+		MinClasses:  3,
+		MinScore:    3,
+		BannedWords: []string{"acme"},
+	}, hasher, &memoryPasswordHistoryRepository{}, nil)
+	user := &models.User{Username: "alice", Email: "wonderland@example.com"}
+
+	cases := []struct {
+		password string
+		codes    []string
+	}{
+		{"abc", []string{"password_length", "password_classes", "password_strength"}},
+		{"Alice-1984-Tea", []string{"password_personal", "password_strength"}},
+		{"Wonderland#2024", []string{"password_personal", "password_strength"}},
+		{"ACME-corp-2024", []string{"password_personal"}},
+		{"Password123", []string{"password_strength"}},
+		{"correcthorsebatterystaple", []string{"password_classes"}},
+	}
+	for _, tc := range cases {
+		err := policy.SetPassword(user, tc.password)
+		if codes := weakPasswordCodes(t, err); !reflect.DeepEqual(codes, tc.codes) {
+			t.Errorf("%q: expected %v, got %v", tc.password, tc.codes, codes)
+		}
+	}
+	if user.PasswordHash != "" {
+		t.Fatal("rejected password must not be stored")
+	}
+
+	if err := policy.SetPassword(user, "Vq7#marble-Orbit"); err != nil {
+		t.Fatal(err)
+	}
+	if !hasher.Verify(user.PasswordHash, "Vq7#marble-Orbit") {
+		t.Fatal("expected password hash to be set")
+	}
+}
+
+func TestPasswordPolicyHistory(t *testing.T) {
+	history := &memoryPasswordHistoryRepository{}
+	policy := NewPasswordPolicy(config.PasswordPolicyConfig{History: 2}, newTestPasswordHasher(t), history, nil)
+	user := &models.User{ID: 1, Username: "alice", Email: "alice@example.com"}
+
+	for _, password := range []string{"first-secret", "second-secret", "third-secret"} {
+		if err := policy.SetPassword(user, password); err != nil {
+			t.Fatal(err)
+		}
+		policy.Remember(user)
+	}
+	if len(history.entries) != 2 {
+		t.Fatalf("expected history to be pruned to 2 entries, got %d", len(history.entries))
+	}
+
+	for _, password := range []string{"third-secret", "second-secret"} {
+		if codes := weakPasswordCodes(t, policy.SetPassword(user, password)); !reflect.DeepEqual(codes, []string{"password_reused"}) {
+			t.Fatalf("%q: expected password_reused, got %v", password, codes)
+		}
+	}
+	if err := policy.SetPassword(user, "first-secret"); err != nil {
+		t.Fatalf("expected password older than the history to be allowed, got %v", err)
+	}
+
+	// 新注册的用户还没有历史
+	if err := policy.SetPassword(&models.User{Username: "bob"}, "third-secret"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeBreachedPasswords(t *testing.T, passwords ...string) string {
+	t.Helper()
+
+	lines := []string{
+		"0000000A1B2C3D4E5F60718293A4B5C6D7E8F901:3",
+		"FFFFFFFA1B2C3D4E5F60718293A4B5C6D7E8F901:1",
+	}
+	for _, password := range passwords {
+		sum := sha1.Sum([]byte(password))
+		lines = append(lines, strings.ToUpper(hex.EncodeToString(sum[:]))+":42")
+	}
+	sort.Strings(lines)
+
+	path := filepath.Join(t.TempDir(), "pwned-passwords.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBreachedPasswordFile(t *testing.T) {
+	source, err := OpenBreachedPasswordFile(writeBreachedPasswords(t, "password", "letmein", "hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string][]string{
+		"5BAA6": {"1E4C9B93F3F0682250B6CF8331B7EE68FD8"},
+		"00000": {"00A1B2C3D4E5F60718293A4B5C6D7E8F901"},
+		"fffff": {"FFA1B2C3D4E5F60718293A4B5C6D7E8F901"},
+		"12345": nil,
+	}
+	for prefix, expected := range cases {
+		suffixes, err := source.Range(prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(suffixes, expected) {
+			t.Errorf("%s: expected %v, got %v", prefix, expected, suffixes)
+		}
+	}
+
+	policy := NewPasswordPolicy(config.PasswordPolicyConfig{}, newTestPasswordHasher(t), &memoryPasswordHistoryRepository{}, source)
+	user := &models.User{Username: "alice", Email: "alice@example.com"}
+	for _, password := range []string{"password", "letmein", "hunter2"} {
+		if codes := weakPasswordCodes(t, policy.SetPassword(user, password)); !reflect.DeepEqual(codes, []string{"password_breached"}) {
+			t.Fatalf("%q: expected password_breached, got %v", password, codes)
+		}
+	}
+	if err := policy.SetPassword(user, "not-in-the-corpus"); err != nil {
+		t.Fatal(err)
+	}
+}
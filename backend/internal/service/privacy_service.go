@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/user/user-management/internal/config"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+	"github.com/user/user-management/internal/storage"
+)
+
+type PrivacyService interface {
+	ExportUserData(userID uint) (*UserDataExport, error)
+	// EraseUser 匿名化用户并删除关联数据。有密码的用户需提供当前密码，
+	// 只能通过外部身份登录的用户需在StepUpConfig.MaxAge内完成过登录
+	EraseUser(userID uint, reauth Reauthentication) error
+}
+
+// UserDataExport 是数据主体导出的完整档案
+type UserDataExport struct {
+	ExportedAt       time.Time                    `json:"exported_at"`
+	User             *models.User                 `json:"user"`
+	Sessions         []SessionData                `json:"sessions"`
+	LoginHistory     []LoginHistoryEntry          `json:"login_history"`
+	RefreshTokens    []RefreshTokenInfo           `json:"refresh_tokens"`
+	AccessTokens     []models.PersonalAccessToken `json:"access_tokens"`
+	LinkedIdentities []models.LinkedIdentity      `json:"linked_identities"`
+	Passkeys         []models.WebAuthnCredential  `json:"passkeys"`
+	AuditEntries     []models.AuditLog            `json:"audit_entries"`
+}
+
+type LoginHistoryEntry struct {
+	IPAddress    string    `json:"ip_address"`
+	UserAgent    string    `json:"user_agent"`
+	LastActivity time.Time `json:"last_activity"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// RefreshTokenInfo 只包含令牌的元数据，不导出令牌本身
+type RefreshTokenInfo struct {
+	ID        uint      `json:"id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type privacyService struct {
+	stepUp          config.StepUpConfig
+	userRepo        repository.UserRepository
+	auditRepo       repository.AuditRepository
+	accessTokenRepo repository.AccessTokenRepository
+	identityRepo    repository.LinkedIdentityRepository
+	webAuthnRepo    repository.WebAuthnCredentialRepository
+	sessionService  SessionService
+	passwordHasher  PasswordHasher
+	blobs           storage.BlobStore
+}
+
+func NewPrivacyService(stepUp config.StepUpConfig, userRepo repository.UserRepository, auditRepo repository.AuditRepository, accessTokenRepo repository.AccessTokenRepository, identityRepo repository.LinkedIdentityRepository, webAuthnRepo repository.WebAuthnCredentialRepository, sessionService SessionService, passwordHasher PasswordHasher, blobs storage.BlobStore) PrivacyService {
+	return &privacyService{
+		stepUp:          stepUp,
+		userRepo:        userRepo,
+		auditRepo:       auditRepo,
+		accessTokenRepo: accessTokenRepo,
+		identityRepo:    identityRepo,
+		webAuthnRepo:    webAuthnRepo,
+		sessionService:  sessionService,
+		passwordHasher:  passwordHasher,
+		blobs:           blobs,
+	}
+}
+
+func (s *privacyService) ExportUserData(userID uint) (*UserDataExport, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	sessions, err := s.sessionService.ListUserSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	loginRecords, err := s.userRepo.ListLoginRecords(userID)
+	if err != nil {
+		return nil, err
+	}
+	loginHistory := make([]LoginHistoryEntry, 0, len(loginRecords))
+	for _, record := range loginRecords {
+		loginHistory = append(loginHistory, LoginHistoryEntry{
+			IPAddress:    record.IPAddress,
+			UserAgent:    record.UserAgent,
+			LastActivity: record.LastActivity,
+			CreatedAt:    record.CreatedAt,
+		})
+	}
+
+	tokens, err := s.userRepo.ListRefreshTokens(userID)
+	if err != nil {
+		return nil, err
+	}
+	refreshTokens := make([]RefreshTokenInfo, 0, len(tokens))
+	for _, token := range tokens {
+		refreshTokens = append(refreshTokens, RefreshTokenInfo{
+			ID:        token.ID,
+			ExpiresAt: token.ExpiresAt,
+			CreatedAt: token.CreatedAt,
+		})
+	}
+
+	accessTokens, err := s.accessTokenRepo.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	linkedIdentities, err := s.identityRepo.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	passkeys, err := s.webAuthnRepo.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	auditEntries, err := s.auditRepo.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserDataExport{
+		ExportedAt:       time.Now(),
+		User:             user,
+		Sessions:         sessions,
+		LoginHistory:     loginHistory,
+		RefreshTokens:    refreshTokens,
+		AccessTokens:     accessTokens,
+		LinkedIdentities: linkedIdentities,
+		Passkeys:         passkeys,
+		AuditEntries:     auditEntries,
+	}, nil
+}
+
+func (s *privacyService) EraseUser(userID uint, reauth Reauthentication) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	// 擦除不可恢复，需再次确认身份；没有可用密码的用户改为要求最近登录
+	if reauth.ActorID != 0 {
+		return ErrImpersonationForbidden
+	}
+	if user.PasswordHash == UnusablePassword {
+		if err := requireRecentLogin(s.stepUp, reauth.AuthTime); err != nil {
+			return err
+		}
+	} else if !s.passwordHasher.Verify(user.PasswordHash, reauth.CurrentPassword) {
+		return ErrInvalidCredentials
+	}
+
+	// 匿名化个人数据，保留ID以维持引用完整性
+	// 审计日志属于法定留存数据且只允许追加，不在擦除范围内
+	user.Username = fmt.Sprintf("erased_%d", user.ID)
+	user.Email = fmt.Sprintf("erased_%d@erased.invalid", user.ID)
+	user.PasswordHash = ""
+	user.IsActive = false
+	user.Attributes = nil
+	avatarKey := user.AvatarKey
+	user.AvatarKey = ""
+	user.AvatarURL = ""
+	user.AvatarThumbnailURL = ""
+
+	tombstone := &models.ErasureTombstone{
+		UserID:      user.ID,
+		RequestedBy: userID,
+		ErasedAt:    time.Now(),
+	}
+
+	if err := s.userRepo.Erase(user, tombstone); err != nil {
+		return err
+	}
+	deleteAvatarBlobs(context.Background(), s.blobs, avatarKey)
+
+	// 清除Redis中的所有session
+	return s.sessionService.DeleteUserSessions(userID)
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/user/user-management/internal/config"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/storage"
+)
+
+// erasingUserRepository 与数据库实现一样在Erase中删除刷新令牌，并记录写入的擦除记录
+type erasingUserRepository struct {
+	*memoryUserRepository
+	loginRecords []models.UserSession
+	tombstones   []*models.ErasureTombstone
+}
+
+func (r *erasingUserRepository) ListRefreshTokens(userID uint) ([]models.RefreshToken, error) {
+	var tokens []models.RefreshToken
+	for _, token := range r.refreshTokens {
+		if token.UserID == userID {
+			tokens = append(tokens, *token)
+		}
+	}
+	return tokens, nil
+}
+
+func (r *erasingUserRepository) ListLoginRecords(userID uint) ([]models.UserSession, error) {
+	var records []models.UserSession
+	for _, record := range r.loginRecords {
+		if record.UserID == userID {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (r *erasingUserRepository) Erase(user *models.User, tombstone *models.ErasureTombstone) error {
+	kept := r.refreshTokens[:0]
+	for _, token := range r.refreshTokens {
+		if token.UserID != user.ID {
+			kept = append(kept, token)
+		}
+	}
+	r.refreshTokens = kept
+	r.tombstones = append(r.tombstones, tombstone)
+	return nil
+}
+
+type privacyFixture struct {
+	service  PrivacyService
+	users    *erasingUserRepository
+	sessions SessionService
+	user     *models.User
+}
+
+// newTestPrivacyService 创建密码哈希为passwordHash的alice，带一个登录会话、刷新令牌、个人访问令牌、关联身份、通行密钥和审计记录
+func newTestPrivacyService(t *testing.T, passwordHash string) *privacyFixture {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	sessions := NewSessionService(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	blobs, err := storage.NewLocalStore(t.TempDir(), "/api/v1/blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := &erasingUserRepository{memoryUserRepository: &memoryUserRepository{}}
+	user := &models.User{Username: "alice", Email: "alice@example.com", PasswordHash: passwordHash, IsActive: true, Role: models.RoleUser}
+	users.Create(user)
+	users.loginRecords = []models.UserSession{{ID: 1, UserID: user.ID, IPAddress: "203.0.113.7", UserAgent: "test-agent"}}
+	users.SaveRefreshToken(&models.RefreshToken{ID: 1, UserID: user.ID, Token: "refresh-secret", SessionID: "current", ExpiresAt: time.Now().Add(time.Hour)})
+	if err := sessions.CreateSession(user.ID, "current", "access-token", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := &memoryAccessTokenRepository{}
+	tokens.Create(&models.PersonalAccessToken{UserID: user.ID, Name: "ci", TokenHash: "hash"})
+	identities := &memoryIdentityRepository{}
+	identities.Create(&models.LinkedIdentity{UserID: user.ID, Issuer: "https://idp.example.com", Subject: "alice-sub"})
+	passkeys := &memoryWebAuthnCredentialRepository{}
+	passkeys.Create(&models.WebAuthnCredential{UserID: user.ID, Name: "YubiKey", CredentialID: []byte("credential-id")})
+	audit := &memoryAuditRepository{}
+	audit.Append(&models.AuditLog{Action: AuditActionLogin, ActorID: &user.ID}, func(prevHash string) string { return "hash-1" })
+
+	svc := NewPrivacyService(config.StepUpConfig{MaxAge: 10 * time.Minute}, users, audit, tokens, identities, passkeys, sessions, newTestPasswordHasher(t), blobs)
+	return &privacyFixture{service: svc, users: users, sessions: sessions, user: user}
+}
+
+func TestPrivacyExport(t *testing.T) {
+	f := newTestPrivacyService(t, UnusablePassword)
+
+	export, err := f.service.ExportUserData(f.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export.User.ID != f.user.ID || len(export.Sessions) != 1 || export.Sessions[0].SessionID != "current" {
+		t.Fatalf("expected user and redis session in export, got %+v", export)
+	}
+	if len(export.LoginHistory) != 1 || export.LoginHistory[0].IPAddress != "203.0.113.7" {
+		t.Fatalf("expected login history, got %+v", export.LoginHistory)
+	}
+	if len(export.RefreshTokens) != 1 || len(export.AccessTokens) != 1 || len(export.LinkedIdentities) != 1 || len(export.AuditEntries) != 1 {
+		t.Fatalf("expected tokens, identities and audit entries, got %+v", export)
+	}
+	if len(export.Passkeys) != 1 || export.Passkeys[0].Name != "YubiKey" {
+		t.Fatalf("expected passkeys in export, got %+v", export.Passkeys)
+	}
+
+	// 导出只包含令牌的元数据，不包含令牌和凭据本身
+	data, err := json.Marshal(export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"refresh-secret", "password_hash"} {
+		if strings.Contains(string(data), secret) {
+			t.Fatalf("expected export not to contain %s: %s", secret, data)
+		}
+	}
+}
+
+func TestPrivacyEraseUser(t *testing.T) {
+	hash, err := newTestPasswordHasher(t).Hash("old-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := newTestPrivacyService(t, hash)
+
+	if err := f.service.EraseUser(f.user.ID, Reauthentication{CurrentPassword: "wrong", AuthTime: time.Now()}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected wrong password to be rejected even after a recent login, got %v", err)
+	}
+	if err := f.service.EraseUser(f.user.ID, Reauthentication{CurrentPassword: "old-password", ActorID: 99}); !errors.Is(err, ErrImpersonationForbidden) {
+		t.Fatalf("expected erasure to be refused while impersonating, got %v", err)
+	}
+	if len(f.users.tombstones) != 0 || f.user.Username != "alice" {
+		t.Fatal("expected rejected requests not to erase anything")
+	}
+
+	if err := f.service.EraseUser(f.user.ID, Reauthentication{CurrentPassword: "old-password"}); err != nil {
+		t.Fatal(err)
+	}
+	if f.user.Username != "erased_1" || f.user.Email != "erased_1@erased.invalid" || f.user.PasswordHash != "" || f.user.IsActive {
+		t.Fatalf("expected anonymised inactive user, got %+v", f.user)
+	}
+	if len(f.users.tombstones) != 1 || f.users.tombstones[0].UserID != f.user.ID || f.users.tombstones[0].RequestedBy != f.user.ID {
+		t.Fatalf("expected a tombstone requested by the user, got %+v", f.users.tombstones)
+	}
+
+	// 会话和刷新令牌都被撤销
+	if session, err := f.sessions.GetSession("access-token"); err != nil || session != nil {
+		t.Fatalf("expected session to be revoked, got %+v, %v", session, err)
+	}
+	if tokens, _ := f.users.ListRefreshTokens(f.user.ID); len(tokens) != 0 {
+		t.Fatalf("expected refresh tokens to be deleted, got %d", len(tokens))
+	}
+}
+
+func TestPrivacyEraseWithoutPassword(t *testing.T) {
+	f := newTestPrivacyService(t, UnusablePassword)
+
+	// 外部身份登录的用户没有密码，只能用最近的登录确认
+	for _, reauth := range []Reauthentication{
+		{},
+		{CurrentPassword: UnusablePassword},
+		{AuthTime: time.Now().Add(-time.Hour)},
+	} {
+		if err := f.service.EraseUser(f.user.ID, reauth); !errors.Is(err, ErrReauthenticationRequired) {
+			t.Fatalf("expected %+v to require a recent login, got %v", reauth, err)
+		}
+	}
+
+	if err := f.service.EraseUser(f.user.ID, Reauthentication{AuthTime: time.Now().Add(-time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if f.user.Username != "erased_1" || len(f.users.tombstones) != 1 {
+		t.Fatalf("expected user to be erased after a recent login, got %+v", f.user)
+	}
+}
@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/user/user-management/internal/config"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+// EmailChangePage 是确认邮件中链接指向的前端页面，token作为查询参数
+const EmailChangePage = "/email-change"
+
+// 确认链接发往的地址
+const (
+	emailChangeOldAddress = "old"
+	emailChangeNewAddress = "new"
+)
+
+// Reauthentication 是修改本人邮箱、密码或擦除个人数据时的身份证明：当前密码，或者在StepUpConfig.MaxAge内完成登录的会话
+type Reauthentication struct {
+	CurrentPassword string
+	// AuthTime 为零值表示请求不是来自带auth_time的登录会话，例如个人访问令牌
+	AuthTime time.Time
+	// SessionID 是发起请求的会话，修改完成后保留，其余会话被撤销
+	SessionID string
+	// ActorID 不为0表示管理员正在模拟该用户，此时不允许修改邮箱和密码
+	ActorID uint
+}
+
+// EmailChangeConfirmation 是确认一个链接后的结果，Completed为true时邮箱已修改，User为修改后的用户
+type EmailChangeConfirmation struct {
+	UserID    uint
+	OldEmail  string
+	NewEmail  string
+	Completed bool
+	User      *models.User
+}
+
+// ProfileService 处理用户修改本人资料；邮箱和密码属于敏感信息，需要再次认证
+type ProfileService interface {
+	// UpdateProfile 修改本人资料，返回修改后的用户和等待确认的新邮箱。
+	// 新邮箱不会立即生效，而是向新旧地址各发送一个确认链接；修改密码后撤销其他会话
+	UpdateProfile(ctx context.Context, userID uint, updates map[string]interface{}, expectedVersion uint, reauth Reauthentication) (*models.User, string, error)
+	// ConfirmEmailChange 确认邮件中的一个链接，两个链接都确认后修改邮箱并撤销其他会话
+	ConfirmEmailChange(token string) (*EmailChangeConfirmation, error)
+}
+
+// emailChangeToken 保存在Redis中，使用时一次性取出；ChangeID用于使旧请求的链接失效
+type emailChangeToken struct {
+	UserID   uint   `json:"user_id"`
+	ChangeID string `json:"change_id"`
+	Address  string `json:"address"`
+}
+
+type profileService struct {
+	stepUp         config.StepUpConfig
+	emailChange    config.EmailChangeConfig
+	userService    UserService
+	userRepo       repository.UserRepository
+	sessionService SessionService
+	passwordHasher PasswordHasher
+	mailer         Mailer
+	redis          *redis.Client
+	baseURL        string
+	ctx            context.Context
+}
+
+// NewProfileService 的baseURL是前端的对外地址，用于拼接邮件中的链接
+func NewProfileService(stepUp config.StepUpConfig, emailChange config.EmailChangeConfig, userService UserService, userRepo repository.UserRepository, sessionService SessionService, passwordHasher PasswordHasher, mailer Mailer, redisClient *redis.Client, baseURL string) ProfileService {
+	return &profileService{
+		stepUp:         stepUp,
+		emailChange:    emailChange,
+		userService:    userService,
+		userRepo:       userRepo,
+		sessionService: sessionService,
+		passwordHasher: passwordHasher,
+		mailer:         mailer,
+		redis:          redisClient,
+		baseURL:        strings.TrimSuffix(baseURL, "/"),
+		ctx:            context.Background(),
+	}
+}
+
+func (s *profileService) UpdateProfile(ctx context.Context, userID uint, updates map[string]interface{}, expectedVersion uint, reauth Reauthentication) (*models.User, string, error) {
+	user, err := s.userService.GetByID(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if expectedVersion != 0 && user.Version != expectedVersion {
+		return nil, "", ErrPreconditionFailed
+	}
+
+	newEmail, _ := updates["email"].(string)
+	if newEmail == user.Email {
+		newEmail = ""
+	}
+	password, _ := updates["password"].(string)
+	if newEmail != "" || password != "" {
+		if err := s.verifyReauthentication(user, reauth); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// 新邮箱确认后才生效，这里只检查是否已被占用
+	delete(updates, "email")
+	if newEmail != "" {
+		existing, err := s.userRepo.GetByEmail(newEmail)
+		if err != nil {
+			return nil, "", err
+		}
+		if existing != nil {
+			return nil, "", ErrEmailTaken
+		}
+	}
+
+	if len(updates) > 0 {
+		if user, err = s.userService.UpdateUser(ctx, userID, updates, user.Version); err != nil {
+			return nil, "", err
+		}
+	}
+	if password != "" {
+		if err := s.revokeOtherSessions(userID, reauth.SessionID); err != nil {
+			return nil, "", err
+		}
+	}
+	if newEmail != "" {
+		if err := s.requestEmailChange(user, newEmail, reauth.SessionID); err != nil {
+			return nil, "", err
+		}
+	}
+	return user, newEmail, nil
+}
+
+// verifyReauthentication 提供了当前密码时只校验密码，否则要求会话的登录时间在MaxAge之内
+func (s *profileService) verifyReauthentication(user *models.User, reauth Reauthentication) error {
+	if reauth.ActorID != 0 {
+		return ErrImpersonationForbidden
+	}
+	if reauth.CurrentPassword != "" {
+		if !s.passwordHasher.Verify(user.PasswordHash, reauth.CurrentPassword) {
+			return ErrInvalidCurrentPassword
+		}
+		return nil
+	}
+	return requireRecentLogin(s.stepUp, reauth.AuthTime)
+}
+
+// requireRecentLogin 要求会话的登录时间在MaxAge之内，authTime为零值时总是拒绝
+func requireRecentLogin(stepUp config.StepUpConfig, authTime time.Time) error {
+	if authTime.IsZero() || time.Since(authTime) > stepUp.MaxAge {
+		return ErrReauthenticationRequired
+	}
+	return nil
+}
+
+// requestEmailChange 生成发往新旧地址的两个链接，新的请求会使之前请求的链接失效
+func (s *profileService) requestEmailChange(user *models.User, newEmail, sessionID string) error {
+	changeID, err := randomHex(16)
+	if err != nil {
+		return err
+	}
+	tokens := make(map[string]string, 2)
+	for _, address := range []string{emailChangeOldAddress, emailChangeNewAddress} {
+		token, err := randomHex(32)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(emailChangeToken{UserID: user.ID, ChangeID: changeID, Address: address})
+		if err != nil {
+			return err
+		}
+		if err := s.redis.Set(s.ctx, emailChangeTokenKey(token), data, s.emailChange.TTL).Err(); err != nil {
+			return err
+		}
+		tokens[address] = token
+	}
+
+	key := emailChangeKey(user.ID)
+	pipe := s.redis.TxPipeline()
+	pipe.Del(s.ctx, key)
+	pipe.HSet(s.ctx, key, "change_id", changeID, "new_email", newEmail, "session_id", sessionID)
+	pipe.Expire(s.ctx, key, s.emailChange.TTL)
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return err
+	}
+
+	oldLink := s.emailChangeLink(tokens[emailChangeOldAddress])
+	newLink := s.emailChangeLink(tokens[emailChangeNewAddress])
+	oldEmail := user.Email
+	go func() {
+		if err := s.mailer.Send(oldEmail, "Confirm your email address change / 确认修改邮箱", fmt.Sprintf(
+			"A request was made to change the email address of your account to %s. Open the link below within %s to approve it.\n"+
+				"您的账号申请将邮箱修改为%s，请在%s内点击以下链接确认。\n\n%s\n\n"+
+				"If you did not request this, ignore this email and change your password.\n如果不是您本人操作，请忽略此邮件并修改密码。\n",
+			newEmail, s.emailChange.TTL, newEmail, s.emailChange.TTL, oldLink)); err != nil {
+			log.Printf("Failed to send email change confirmation: %v", err)
+		}
+		if err := s.mailer.Send(newEmail, "Confirm your new email address / 确认新邮箱", fmt.Sprintf(
+			"Open the link below within %s to confirm this address for your account. The change takes effect once the link sent to %s is confirmed as well.\n"+
+				"请在%s内点击以下链接确认新邮箱，发往%s的链接也确认后修改才会生效。\n\n%s\n",
+			s.emailChange.TTL, oldEmail, s.emailChange.TTL, oldEmail, newLink)); err != nil {
+			log.Printf("Failed to send email change confirmation: %v", err)
+		}
+	}()
+	return nil
+}
+
+// confirmEmailChangeScript 在请求未被替换时标记一个地址已确认，两个地址都确认后删除请求并返回1；
+// 请求已过期或被新的请求替换时返回-1。两个链接同时确认时只有一方得到1
+var confirmEmailChangeScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], "change_id") ~= ARGV[1] then
+	return -1
+end
+redis.call("HSET", KEYS[1], ARGV[2], "1")
+if redis.call("HEXISTS", KEYS[1], "old_confirmed") == 1 and redis.call("HEXISTS", KEYS[1], "new_confirmed") == 1 then
+	redis.call("DEL", KEYS[1])
+	return 1
+end
+return 0
+`)
+
+func (s *profileService) ConfirmEmailChange(token string) (*EmailChangeConfirmation, error) {
+	data, err := s.redis.GetDel(s.ctx, emailChangeTokenKey(token)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrInvalidEmailChangeLink
+	}
+	if err != nil {
+		return nil, err
+	}
+	var link emailChangeToken
+	if err := json.Unmarshal([]byte(data), &link); err != nil {
+		return nil, err
+	}
+
+	key := emailChangeKey(link.UserID)
+	values, err := s.redis.HGetAll(s.ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	state, err := confirmEmailChangeScript.Run(s.ctx, s.redis, []string{key}, link.ChangeID, link.Address+"_confirmed").Int()
+	if err != nil {
+		return nil, err
+	}
+	if state < 0 {
+		return nil, ErrInvalidEmailChangeLink
+	}
+
+	// 确认链接不需要登录，链接本身证明了用户身份，不按租户限定
+	ctx := repository.WithAllTenants(s.ctx)
+	user, err := s.userService.GetByID(ctx, link.UserID)
+	if err != nil {
+		return nil, err
+	}
+	confirmation := &EmailChangeConfirmation{UserID: user.ID, OldEmail: user.Email, NewEmail: values["new_email"]}
+	if state == 0 {
+		return confirmation, nil
+	}
+
+	if user, err = s.userService.UpdateUser(ctx, user.ID, map[string]interface{}{"email": confirmation.NewEmail}, 0); err != nil {
+		return nil, err
+	}
+	if err := s.revokeOtherSessions(user.ID, values["session_id"]); err != nil {
+		return nil, err
+	}
+	confirmation.Completed = true
+	confirmation.User = user
+	return confirmation, nil
+}
+
+// revokeOtherSessions 撤销sessionID以外的会话和刷新令牌；没有会话ID（如通过个人访问令牌修改）时全部撤销
+func (s *profileService) revokeOtherSessions(userID uint, sessionID string) error {
+	if sessionID == "" {
+		if err := s.sessionService.DeleteUserSessions(userID); err != nil {
+			return err
+		}
+		return s.userRepo.DeleteUserRefreshTokens(userID)
+	}
+	if err := s.sessionService.DeleteOtherSessions(userID, sessionID); err != nil {
+		return err
+	}
+	return s.userRepo.DeleteOtherRefreshTokens(userID, sessionID)
+}
+
+func (s *profileService) emailChangeLink(token string) string {
+	return s.baseURL + EmailChangePage + "?token=" + url.QueryEscape(token)
+}
+
+func emailChangeKey(userID uint) string {
+	return fmt.Sprintf("emailchange:user:%d", userID)
+}
+
+func emailChangeTokenKey(token string) string {
+	return fmt.Sprintf("emailchange:token:%s", hashAccessToken(token))
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/user/user-management/internal/config"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+// sessionUserRepository 记录撤销其他刷新令牌时保留的会话
+type sessionUserRepository struct {
+	*memoryUserRepository
+	keptSessions []string
+}
+
+func (r *sessionUserRepository) DeleteOtherRefreshTokens(userID uint, sessionID string) error {
+	r.keptSessions = append(r.keptSessions, sessionID)
+	return nil
+}
+
+type profileFixture struct {
+	service  ProfileService
+	users    *sessionUserRepository
+	sessions SessionService
+	mailer   channelMailer
+	user     *models.User
+	// ctx 是不属于任何组织的用户的请求context
+	ctx context.Context
+}
+
+func newTestProfileService(t *testing.T) *profileFixture {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	hasher := newTestPasswordHasher(t)
+	hash, err := hasher.Hash("old-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := &sessionUserRepository{memoryUserRepository: &memoryUserRepository{}}
+	user := &models.User{Username: "alice", Email: "alice@example.com", PasswordHash: hash, IsActive: true, Version: 1}
+	users.Create(user)
+
+	sessions := NewSessionService(client)
+	for _, sessionID := range []string{"current", "other"} {
+		if err := sessions.CreateSession(user.ID, sessionID, sessionID+"-token", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mailer := make(channelMailer, 10)
+	svc := NewProfileService(
+		config.StepUpConfig{MaxAge: 10 * time.Minute},
+		config.EmailChangeConfig{TTL: time.Hour},
+		NewUserService(users, &memoryAttributeRepository{}, newTestPasswordPolicy(t)), users, sessions, hasher, mailer, client, "https://example.com/",
+	)
+	return &profileFixture{service: svc, users: users, sessions: sessions, mailer: mailer, user: user, ctx: repository.WithTenant(context.Background(), 0)}
+}
+
+// receiveEmailChangeLinks 等待发往新旧地址的两封邮件，按收件人返回链接中的token
+func receiveEmailChangeLinks(t *testing.T, mailer channelMailer) map[string]string {
+	t.Helper()
+
+	tokens := make(map[string]string)
+	for len(tokens) < 2 {
+		select {
+		case mail := <-mailer:
+			for _, line := range strings.Split(mail.body, "\n") {
+				if strings.HasPrefix(line, "https://example.com"+EmailChangePage+"?") {
+					link, err := url.Parse(line)
+					if err != nil {
+						t.Fatal(err)
+					}
+					tokens[mail.to] = link.Query().Get("token")
+				}
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected two confirmation mails, got %d", len(tokens))
+		}
+	}
+	return tokens
+}
+
+func TestProfileStepUp(t *testing.T) {
+	f := newTestProfileService(t)
+	change := func(reauth Reauthentication) error {
+		_, _, err := f.service.UpdateProfile(f.ctx, f.user.ID, map[string]interface{}{"password": "new-password"}, 0, reauth)
+		return err
+	}
+
+	if err := change(Reauthentication{SessionID: "current"}); !errors.Is(err, ErrReauthenticationRequired) {
+		t.Fatalf("expected token without auth_time to need the current password, got %v", err)
+	}
+	if err := change(Reauthentication{AuthTime: time.Now().Add(-time.Hour), SessionID: "current"}); !errors.Is(err, ErrReauthenticationRequired) {
+		t.Fatalf("expected stale login to be rejected, got %v", err)
+	}
+	if err := change(Reauthentication{CurrentPassword: "wrong", AuthTime: time.Now(), SessionID: "current"}); !errors.Is(err, ErrInvalidCurrentPassword) {
+		t.Fatalf("expected wrong current password to be rejected, got %v", err)
+	}
+	if err := change(Reauthentication{CurrentPassword: "old-password", ActorID: 99}); !errors.Is(err, ErrImpersonationForbidden) {
+		t.Fatalf("expected password change to be refused while impersonating, got %v", err)
+	}
+
+	// 不涉及邮箱和密码的修改不需要再次认证
+	if _, _, err := f.service.UpdateProfile(f.ctx, f.user.ID, map[string]interface{}{"username": "alice2"}, 0, Reauthentication{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := change(Reauthentication{AuthTime: time.Now().Add(-time.Minute), SessionID: "current"}); err != nil {
+		t.Fatal(err)
+	}
+	if session, _ := f.sessions.GetSession("current-token"); session == nil {
+		t.Fatal("expected the requesting session to be kept")
+	}
+	if session, _ := f.sessions.GetSession("other-token"); session != nil {
+		t.Fatal("expected other sessions to be revoked")
+	}
+	if len(f.users.keptSessions) != 1 || f.users.keptSessions[0] != "current" {
+		t.Fatalf("expected refresh tokens of other sessions to be revoked, got %v", f.users.keptSessions)
+	}
+}
+
+func TestProfileEmailChange(t *testing.T) {
+	f := newTestProfileService(t)
+	reauth := Reauthentication{CurrentPassword: "old-password", SessionID: "current"}
+
+	user, pending, err := f.service.UpdateProfile(f.ctx, f.user.ID, map[string]interface{}{"email": "first@example.com"}, 1, reauth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending != "first@example.com" || user.Email != "alice@example.com" {
+		t.Fatalf("expected email change to be pending, got %q and %q", pending, user.Email)
+	}
+	superseded := receiveEmailChangeLinks(t, f.mailer)
+
+	// 新的请求使之前的链接失效
+	if _, _, err := f.service.UpdateProfile(f.ctx, f.user.ID, map[string]interface{}{"email": "alice@new.example.com"}, 0, reauth); err != nil {
+		t.Fatal(err)
+	}
+	tokens := receiveEmailChangeLinks(t, f.mailer)
+	if _, err := f.service.ConfirmEmailChange(superseded["alice@example.com"]); !errors.Is(err, ErrInvalidEmailChangeLink) {
+		t.Fatalf("expected superseded link to be rejected, got %v", err)
+	}
+
+	confirmation, err := f.service.ConfirmEmailChange(tokens["alice@new.example.com"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confirmation.Completed || f.user.Email != "alice@example.com" {
+		t.Fatal("expected email to change only after both addresses confirmed")
+	}
+	if _, err := f.service.ConfirmEmailChange(tokens["alice@new.example.com"]); !errors.Is(err, ErrInvalidEmailChangeLink) {
+		t.Fatalf("expected link to be single use, got %v", err)
+	}
+
+	confirmation, err = f.service.ConfirmEmailChange(tokens["alice@example.com"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !confirmation.Completed || confirmation.OldEmail != "alice@example.com" || f.user.Email != "alice@new.example.com" {
+		t.Fatalf("expected email to be changed, got %+v", confirmation)
+	}
+	if session, _ := f.sessions.GetSession("other-token"); session != nil {
+		t.Fatal("expected other sessions to be revoked after the email change")
+	}
+	if session, _ := f.sessions.GetSession("current-token"); session == nil {
+		t.Fatal("expected the session that requested the change to be kept")
+	}
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"log"
+	"math/big"
+	"os"
+	"time"
+)
+
+// SAMLKeyPair 是SP签名和解密断言使用的证书与私钥，证书发布在SP元数据中
+type SAMLKeyPair struct {
+	Certificate *x509.Certificate
+	PrivateKey  *rsa.PrivateKey
+}
+
+// LoadSAMLKeyPair 从PEM文件读取证书和RSA私钥，两者都为空时生成临时的自签名证书
+func LoadSAMLKeyPair(certFile, keyFile string) (*SAMLKeyPair, error) {
+	if certFile == "" && keyFile == "" {
+		log.Println("SAML_CERTIFICATE_FILE is not set, generating an ephemeral service provider certificate")
+		return generateSAMLKeyPair()
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("SAML_CERTIFICATE_FILE and SAML_KEY_FILE must be set together")
+	}
+
+	certData, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(certData)
+	if block == nil {
+		return nil, errors.New("certificate file does not contain a PEM block")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	keyData, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := parseRSAPrivateKey(keyData)
+	if err != nil {
+		return nil, err
+	}
+	if !key.PublicKey.Equal(cert.PublicKey) {
+		return nil, errors.New("SAML certificate does not match the private key")
+	}
+	return &SAMLKeyPair{Certificate: cert, PrivateKey: key}, nil
+}
+
+func generateSAMLKeyPair() (*SAMLKeyPair, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "user-management SAML SP"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.AddDate(10, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &SAMLKeyPair{Certificate: cert, PrivateKey: key}, nil
+}
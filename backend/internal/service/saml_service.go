@@ -0,0 +1,386 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/redis/go-redis/v9"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+var samlSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,62}[a-z0-9]$`)
+
+// 未配置属性名时依次尝试的常见属性（LDAP风格、OID和ADFS的声明类型）
+var (
+	samlEmailAttributes = []string{
+		"email",
+		"mail",
+		"urn:oid:0.9.2342.19200300.100.1.3",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress",
+	}
+	samlUsernameAttributes = []string{
+		"username",
+		"uid",
+		"urn:oid:0.9.2342.19200300.100.1.1",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/name",
+	}
+)
+
+// SAMLConnectionInput 是创建SAML连接的参数，IdPMetadata为身份提供方导出的元数据XML
+type SAMLConnectionInput struct {
+	Slug              string
+	Name              string
+	IdPMetadata       string
+	EmailDomains      []string
+	EmailAttribute    string
+	UsernameAttribute string
+}
+
+type SAMLService interface {
+	Metadata(slug string) ([]byte, error)
+	Begin(ctx context.Context, slug, redirect string) (string, error)
+	Complete(ctx context.Context, slug, samlResponse, relayState string) (*FederatedLogin, error)
+	CreateConnection(input *SAMLConnectionInput, createdBy uint) (*models.SAMLConnection, error)
+	ListConnections() ([]models.SAMLConnection, error)
+	DeleteConnection(id uint) (*models.SAMLConnection, error)
+}
+
+// samlRequest 在发出AuthnRequest时保存，ACS收到响应时按RelayState一次性取出
+type samlRequest struct {
+	RequestID string `json:"request_id"`
+	Slug      string `json:"slug"`
+	Redirect  string `json:"redirect"`
+}
+
+type samlService struct {
+	connectionRepo repository.SAMLConnectionRepository
+	linker         *identityLinker
+	redis          *redis.Client
+	keyPair        *SAMLKeyPair
+	baseURL        string
+}
+
+// NewSAMLService 创建SAML服务提供方，baseURL是对外可访问的站点地址，SP的实体ID和ACS地址都基于它；
+// invitationOnly 与外部OIDC登录一致，为true时只关联邮箱已存在的用户
+func NewSAMLService(connectionRepo repository.SAMLConnectionRepository, userRepo repository.UserRepository, identityRepo repository.LinkedIdentityRepository, redisClient *redis.Client, keyPair *SAMLKeyPair, baseURL string, invitationOnly bool) SAMLService {
+	return &samlService{
+		connectionRepo: connectionRepo,
+		linker:         &identityLinker{userRepo: userRepo, identityRepo: identityRepo, invitationOnly: invitationOnly},
+		redis:          redisClient,
+		keyPair:        keyPair,
+		baseURL:        strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// Metadata 返回供身份提供方导入的SP元数据
+func (s *samlService) Metadata(slug string) ([]byte, error) {
+	_, sp, err := s.serviceProvider(slug)
+	if err != nil {
+		return nil, err
+	}
+	data, err := xml.MarshalIndent(sp.Metadata(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// Begin 生成AuthnRequest，返回HTTP-Redirect绑定下身份提供方的单点登录地址
+func (s *samlService) Begin(ctx context.Context, slug, redirect string) (string, error) {
+	_, sp, err := s.serviceProvider(slug)
+	if err != nil {
+		return "", err
+	}
+
+	request, err := sp.MakeAuthenticationRequest(sp.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		return "", err
+	}
+
+	relayState, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(samlRequest{RequestID: request.ID, Slug: slug, Redirect: redirect})
+	if err != nil {
+		return "", err
+	}
+	if err := s.redis.Set(ctx, samlRequestKey(relayState), data, FederationStateTTL).Err(); err != nil {
+		return "", err
+	}
+
+	location, err := request.Redirect(relayState, sp)
+	if err != nil {
+		return "", err
+	}
+	return location.String(), nil
+}
+
+// Complete 校验ACS收到的SAMLResponse，然后找到或创建对应的本地用户。
+// 签名、受众、有效期和InResponseTo由crewjam/saml校验，断言ID记录在Redis中防止重放
+func (s *samlService) Complete(ctx context.Context, slug, samlResponse, relayState string) (*FederatedLogin, error) {
+	data, err := s.redis.GetDel(ctx, samlRequestKey(relayState)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrFederationState
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved samlRequest
+	if err := json.Unmarshal([]byte(data), &saved); err != nil {
+		return nil, err
+	}
+	if saved.Slug != slug {
+		return nil, ErrFederationState
+	}
+
+	connection, sp, err := s.serviceProvider(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		return nil, ErrSAMLAssertionInvalid
+	}
+	assertion, err := sp.ParseXMLResponse(decoded, []string{saved.RequestID})
+	if err != nil {
+		var invalid *saml.InvalidResponseError
+		if errors.As(err, &invalid) {
+			err = invalid.PrivateErr
+		}
+		log.Printf("SAML assertion rejected for connection %s: %v", slug, err)
+		return nil, ErrSAMLAssertionInvalid
+	}
+	// 没有AudienceRestriction的断言可以被发给任意SP，不予接受
+	if assertion.Conditions == nil || len(assertion.Conditions.AudienceRestrictions) == 0 {
+		return nil, ErrSAMLAssertionInvalid
+	}
+	if assertion.Subject == nil || assertion.Subject.NameID == nil || assertion.Subject.NameID.Value == "" {
+		return nil, ErrSAMLAssertionInvalid
+	}
+
+	if err := s.markAssertionUsed(ctx, assertion); err != nil {
+		return nil, err
+	}
+
+	nameID := assertion.Subject.NameID
+	email := assertionAttribute(assertion, connection.EmailAttribute, samlEmailAttributes)
+	if email == "" && nameID.Format == string(saml.EmailAddressNameIDFormat) {
+		email = nameID.Value
+	}
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return nil, ErrFederatedEmailUnverified
+	}
+	// 只信任连接配置的域名下的邮箱，否则一个组织的身份提供方可以登录其他组织的账号
+	if !emailInDomains(email, strings.Fields(connection.EmailDomains)) {
+		return nil, ErrSAMLEmailDomain
+	}
+	username := assertionAttribute(assertion, connection.UsernameAttribute, samlUsernameAttributes)
+
+	login, err := s.linker.resolve(connection.IdPEntityID, nameID.Value, email, true, username)
+	if err != nil {
+		return nil, err
+	}
+	login.Redirect = saved.Redirect
+	return login, nil
+}
+
+func (s *samlService) CreateConnection(input *SAMLConnectionInput, createdBy uint) (*models.SAMLConnection, error) {
+	if !samlSlugPattern.MatchString(input.Slug) {
+		return nil, ErrInvalidSAMLSlug
+	}
+	metadata, err := parseIdPMetadata([]byte(input.IdPMetadata))
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.connectionRepo.GetBySlug(input.Slug)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrSAMLConnectionSlugTaken
+	}
+
+	domains := make([]string, 0, len(input.EmailDomains))
+	for _, domain := range input.EmailDomains {
+		domains = append(domains, strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@")))
+	}
+
+	connection := &models.SAMLConnection{
+		Slug:              input.Slug,
+		Name:              input.Name,
+		IdPEntityID:       metadata.EntityID,
+		IdPMetadata:       input.IdPMetadata,
+		EmailDomains:      strings.Join(domains, " "),
+		EmailAttribute:    input.EmailAttribute,
+		UsernameAttribute: input.UsernameAttribute,
+		IsActive:          true,
+		CreatedBy:         createdBy,
+	}
+	if err := s.connectionRepo.Create(connection); err != nil {
+		return nil, err
+	}
+	return connection, nil
+}
+
+func (s *samlService) ListConnections() ([]models.SAMLConnection, error) {
+	return s.connectionRepo.List()
+}
+
+func (s *samlService) DeleteConnection(id uint) (*models.SAMLConnection, error) {
+	connection, err := s.connectionRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if connection == nil {
+		return nil, ErrSAMLConnectionNotFound
+	}
+	if err := s.connectionRepo.Delete(id); err != nil {
+		return nil, err
+	}
+	return connection, nil
+}
+
+// serviceProvider 按连接构造SP，每个连接有独立的实体ID和ACS地址
+func (s *samlService) serviceProvider(slug string) (*models.SAMLConnection, *saml.ServiceProvider, error) {
+	connection, err := s.connectionRepo.GetBySlug(slug)
+	if err != nil {
+		return nil, nil, err
+	}
+	if connection == nil || !connection.IsActive {
+		return nil, nil, ErrSAMLConnectionNotFound
+	}
+	metadata, err := parseIdPMetadata([]byte(connection.IdPMetadata))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	base := s.baseURL + "/api/v1/auth/saml/" + url.PathEscape(slug)
+	metadataURL, err := url.Parse(base + "/metadata")
+	if err != nil {
+		return nil, nil, err
+	}
+	acsURL, err := url.Parse(base + "/acs")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return connection, &saml.ServiceProvider{
+		EntityID:          metadataURL.String(),
+		Key:               s.keyPair.PrivateKey,
+		Certificate:       s.keyPair.Certificate,
+		MetadataURL:       *metadataURL,
+		AcsURL:            *acsURL,
+		IDPMetadata:       metadata,
+		AuthnNameIDFormat: saml.UnspecifiedNameIDFormat,
+		AllowIDPInitiated: false,
+	}, nil
+}
+
+// markAssertionUsed 在断言失效前记住其ID，同一断言第二次提交时拒绝
+func (s *samlService) markAssertionUsed(ctx context.Context, assertion *saml.Assertion) error {
+	expiresAt := assertion.IssueInstant.Add(saml.MaxIssueDelay)
+	if notOnOrAfter := assertion.Conditions.NotOnOrAfter; notOnOrAfter.After(expiresAt) {
+		expiresAt = notOnOrAfter
+	}
+	ttl := time.Until(expiresAt.Add(saml.MaxClockSkew))
+	if ttl <= 0 {
+		ttl = saml.MaxClockSkew
+	}
+
+	stored, err := s.redis.SetNX(ctx, samlAssertionKey(assertion.Issuer.Value, assertion.ID), 1, ttl).Result()
+	if err != nil {
+		return err
+	}
+	if !stored {
+		return ErrSAMLAssertionReplayed
+	}
+	return nil
+}
+
+// parseIdPMetadata 解析身份提供方元数据，要求有签名证书和HTTP-Redirect绑定的单点登录地址
+func parseIdPMetadata(data []byte) (*saml.EntityDescriptor, error) {
+	var metadata saml.EntityDescriptor
+	if err := xml.Unmarshal(data, &metadata); err != nil || metadata.EntityID == "" {
+		return nil, ErrInvalidSAMLMetadata
+	}
+
+	hasSigningKey, hasRedirectSSO := false, false
+	for _, descriptor := range metadata.IDPSSODescriptors {
+		for _, key := range descriptor.KeyDescriptors {
+			if key.Use != "encryption" && len(key.KeyInfo.X509Data.X509Certificates) > 0 {
+				hasSigningKey = true
+			}
+		}
+		for _, endpoint := range descriptor.SingleSignOnServices {
+			if endpoint.Binding == saml.HTTPRedirectBinding {
+				hasRedirectSSO = true
+			}
+		}
+	}
+	if !hasSigningKey || !hasRedirectSSO {
+		return nil, ErrInvalidSAMLMetadata
+	}
+	return &metadata, nil
+}
+
+// assertionAttribute 按Name或FriendlyName查找属性值，configured为空时依次尝试fallbacks
+func assertionAttribute(assertion *saml.Assertion, configured string, fallbacks []string) string {
+	names := fallbacks
+	if configured != "" {
+		names = []string{configured}
+	}
+	for _, name := range names {
+		for _, statement := range assertion.AttributeStatements {
+			for _, attribute := range statement.Attributes {
+				if attribute.Name != name && attribute.FriendlyName != name {
+					continue
+				}
+				for _, value := range attribute.Values {
+					if value.Value != "" {
+						return value.Value
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func emailInDomains(email string, domains []string) bool {
+	_, domain, ok := strings.Cut(email, "@")
+	if !ok {
+		return false
+	}
+	for _, allowed := range domains {
+		if domain == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func samlRequestKey(relayState string) string {
+	return fmt.Sprintf("saml:request:%s", relayState)
+}
+
+func samlAssertionKey(issuer, id string) string {
+	sum := sha256.Sum256([]byte(issuer + "\x00" + id))
+	return fmt.Sprintf("saml:assertion:%s", hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,344 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/beevik/etree"
+	"github.com/crewjam/saml"
+	"github.com/redis/go-redis/v9"
+	"github.com/user/user-management/internal/models"
+)
+
+const testSAMLSlug = "acme"
+
+// mockSAMLIdP 用crewjam/saml的IdentityProvider签发响应，respond代替浏览器在身份提供方登录
+type mockSAMLIdP struct {
+	idp *saml.IdentityProvider
+	// audience 不为空时替换SP元数据中的实体ID，模拟发给其他SP的断言
+	audience string
+	sp       *saml.EntityDescriptor
+}
+
+func newMockSAMLIdP(t *testing.T) *mockSAMLIdP {
+	t.Helper()
+
+	keyPair, err := generateSAMLKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockSAMLIdP{}
+	m.idp = &saml.IdentityProvider{
+		Key:                     keyPair.PrivateKey,
+		Certificate:             keyPair.Certificate,
+		MetadataURL:             url.URL{Scheme: "https", Host: "idp.example", Path: "/metadata"},
+		SSOURL:                  url.URL{Scheme: "https", Host: "idp.example", Path: "/sso"},
+		ServiceProviderProvider: m,
+	}
+	return m
+}
+
+func (m *mockSAMLIdP) GetServiceProvider(r *http.Request, serviceProviderID string) (*saml.EntityDescriptor, error) {
+	sp := *m.sp
+	if m.audience != "" {
+		sp.EntityID = m.audience
+	}
+	return &sp, nil
+}
+
+func (m *mockSAMLIdP) metadata(t *testing.T) string {
+	t.Helper()
+
+	data, err := xml.Marshal(m.idp.Metadata())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// respond 处理SP跳转过来的AuthnRequest，返回POST到ACS的SAMLResponse和RelayState
+func (m *mockSAMLIdP) respond(t *testing.T, location string, session *saml.Session) (string, string) {
+	t.Helper()
+
+	req, err := saml.NewIdpAuthnRequest(m.idp, httptest.NewRequest(http.MethodGet, location, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := req.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := (saml.DefaultAssertionMaker{}).MakeAssertion(req, session); err != nil {
+		t.Fatal(err)
+	}
+	if err := req.MakeAssertionEl(); err != nil {
+		t.Fatal(err)
+	}
+	if err := req.MakeResponse(); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := etree.NewDocument()
+	doc.SetRoot(req.ResponseEl)
+	data, err := doc.WriteToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(data), req.RelayState
+}
+
+type memorySAMLConnectionRepository struct {
+	connections []*models.SAMLConnection
+}
+
+func (r *memorySAMLConnectionRepository) Create(connection *models.SAMLConnection) error {
+	connection.ID = uint(len(r.connections) + 1)
+	r.connections = append(r.connections, connection)
+	return nil
+}
+
+func (r *memorySAMLConnectionRepository) GetByID(id uint) (*models.SAMLConnection, error) {
+	for _, connection := range r.connections {
+		if connection.ID == id {
+			return connection, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memorySAMLConnectionRepository) GetBySlug(slug string) (*models.SAMLConnection, error) {
+	for _, connection := range r.connections {
+		if connection.Slug == slug {
+			return connection, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memorySAMLConnectionRepository) List() ([]models.SAMLConnection, error) {
+	return nil, nil
+}
+
+func (r *memorySAMLConnectionRepository) Delete(id uint) error {
+	return nil
+}
+
+func newTestSAMLService(t *testing.T, idp *mockSAMLIdP, users *memoryUserRepository, invitationOnly bool) (SAMLService, *miniredis.Miniredis) {
+	t.Helper()
+
+	keyPair, err := generateSAMLKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr := miniredis.RunT(t)
+	svc := NewSAMLService(&memorySAMLConnectionRepository{}, users, &memoryIdentityRepository{}, redis.NewClient(&redis.Options{Addr: mr.Addr()}), keyPair, "http://localhost", invitationOnly)
+
+	_, err = svc.CreateConnection(&SAMLConnectionInput{
+		Slug:           testSAMLSlug,
+		Name:           "Acme",
+		IdPMetadata:    idp.metadata(t),
+		EmailDomains:   []string{"corp.example"},
+		EmailAttribute: "eduPersonPrincipalName",
+	}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, err := svc.Metadata(testSAMLSlug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.sp = &saml.EntityDescriptor{}
+	if err := xml.Unmarshal(metadata, idp.sp); err != nil {
+		t.Fatal(err)
+	}
+	return svc, mr
+}
+
+func testSAMLSession(email string) *saml.Session {
+	return &saml.Session{NameID: "idp-user-1", UserEmail: email, UserName: "alice"}
+}
+
+func TestSAMLProvisionsUserOnFirstLogin(t *testing.T) {
+	idp := newMockSAMLIdP(t)
+	users := &memoryUserRepository{}
+	svc, _ := newTestSAMLService(t, idp, users, false)
+
+	login := func() *FederatedLogin {
+		location, err := svc.Begin(context.Background(), testSAMLSlug, "/profile")
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, relayState := idp.respond(t, location, testSAMLSession("alice@corp.example"))
+		result, err := svc.Complete(context.Background(), testSAMLSlug, response, relayState)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	first := login()
+	if !first.Provisioned || first.User.Username != "alice" || first.User.Email != "alice@corp.example" || first.Redirect != "/profile" {
+		t.Fatalf("unexpected first login: %+v", first)
+	}
+	if first.Identity.Issuer != idp.idp.MetadataURL.String() || first.Identity.Subject != "idp-user-1" {
+		t.Fatalf("unexpected identity: %+v", first.Identity)
+	}
+
+	second := login()
+	if second.Provisioned || second.User.ID != first.User.ID || len(users.users) != 1 {
+		t.Fatalf("second login should reuse the linked identity: %+v", second)
+	}
+}
+
+// REGISTRATION_MODE=invitation时与外部OIDC登录一样，只关联已有用户
+func TestSAMLInvitationOnlyDoesNotProvision(t *testing.T) {
+	idp := newMockSAMLIdP(t)
+	users := &memoryUserRepository{}
+	users.Create(&models.User{Username: "bob", Email: "bob@corp.example", IsActive: true})
+	svc, _ := newTestSAMLService(t, idp, users, true)
+
+	login := func(session *saml.Session) (*FederatedLogin, error) {
+		location, err := svc.Begin(context.Background(), testSAMLSlug, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, relayState := idp.respond(t, location, session)
+		return svc.Complete(context.Background(), testSAMLSlug, response, relayState)
+	}
+
+	if _, err := login(testSAMLSession("alice@corp.example")); !errors.Is(err, ErrRegistrationDisabled) {
+		t.Fatalf("err = %v, want %v", err, ErrRegistrationDisabled)
+	}
+	result, err := login(&saml.Session{NameID: "idp-bob", UserEmail: "bob@corp.example", UserName: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Linked || result.Provisioned || len(users.users) != 1 {
+		t.Fatalf("unexpected login: %+v", result)
+	}
+}
+
+func TestSAMLRejectsInvalidAssertion(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, idp *mockSAMLIdP)
+	}{
+		{"unknown signing key", func(t *testing.T, idp *mockSAMLIdP) {
+			forged, err := generateSAMLKeyPair()
+			if err != nil {
+				t.Fatal(err)
+			}
+			idp.idp.Key, idp.idp.Certificate = forged.PrivateKey, forged.Certificate
+		}},
+		{"wrong audience", func(t *testing.T, idp *mockSAMLIdP) {
+			idp.audience = "https://other-sp.example/metadata"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newMockSAMLIdP(t)
+			svc, _ := newTestSAMLService(t, idp, &memoryUserRepository{}, false)
+			tt.tamper(t, idp)
+
+			location, err := svc.Begin(context.Background(), testSAMLSlug, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			response, relayState := idp.respond(t, location, testSAMLSession("alice@corp.example"))
+			if _, err := svc.Complete(context.Background(), testSAMLSlug, response, relayState); !errors.Is(err, ErrSAMLAssertionInvalid) {
+				t.Fatalf("err = %v, want %v", err, ErrSAMLAssertionInvalid)
+			}
+		})
+	}
+
+	t.Run("expired", func(t *testing.T) {
+		idp := newMockSAMLIdP(t)
+		svc, _ := newTestSAMLService(t, idp, &memoryUserRepository{}, false)
+		location, err := svc.Begin(context.Background(), testSAMLSlug, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, relayState := idp.respond(t, location, testSAMLSession("alice@corp.example"))
+
+		saml.TimeNow = func() time.Time { return time.Now().Add(time.Hour) }
+		t.Cleanup(func() { saml.TimeNow = func() time.Time { return time.Now().UTC() } })
+		if _, err := svc.Complete(context.Background(), testSAMLSlug, response, relayState); !errors.Is(err, ErrSAMLAssertionInvalid) {
+			t.Fatalf("err = %v, want %v", err, ErrSAMLAssertionInvalid)
+		}
+	})
+}
+
+func TestSAMLRejectsReplayedAssertion(t *testing.T) {
+	idp := newMockSAMLIdP(t)
+	svc, mr := newTestSAMLService(t, idp, &memoryUserRepository{}, false)
+
+	location, err := svc.Begin(context.Background(), testSAMLSlug, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, relayState := idp.respond(t, location, testSAMLSession("alice@corp.example"))
+	saved, err := mr.Get(samlRequestKey(relayState))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.Complete(context.Background(), testSAMLSlug, response, relayState); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Complete(context.Background(), testSAMLSlug, response, relayState); !errors.Is(err, ErrFederationState) {
+		t.Fatalf("err = %v, want %v", err, ErrFederationState)
+	}
+
+	// 即使攻击者拿到了同一请求的RelayState，断言ID也已被记录
+	mr.Set(samlRequestKey("replayed"), saved)
+	if _, err := svc.Complete(context.Background(), testSAMLSlug, response, "replayed"); !errors.Is(err, ErrSAMLAssertionReplayed) {
+		t.Fatalf("err = %v, want %v", err, ErrSAMLAssertionReplayed)
+	}
+}
+
+func TestSAMLRejectsEmailOutsideDomains(t *testing.T) {
+	idp := newMockSAMLIdP(t)
+	users := &memoryUserRepository{}
+	users.Create(&models.User{Username: "victim", Email: "victim@other.example", IsActive: true})
+	svc, _ := newTestSAMLService(t, idp, users, false)
+
+	location, err := svc.Begin(context.Background(), testSAMLSlug, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, relayState := idp.respond(t, location, testSAMLSession("victim@other.example"))
+	if _, err := svc.Complete(context.Background(), testSAMLSlug, response, relayState); !errors.Is(err, ErrSAMLEmailDomain) {
+		t.Fatalf("err = %v, want %v", err, ErrSAMLEmailDomain)
+	}
+}
+
+func TestSAMLCreateConnectionValidation(t *testing.T) {
+	idp := newMockSAMLIdP(t)
+	svc, _ := newTestSAMLService(t, idp, &memoryUserRepository{}, false)
+
+	tests := []struct {
+		name  string
+		input SAMLConnectionInput
+		want  error
+	}{
+		{"invalid slug", SAMLConnectionInput{Slug: "Acme Corp", IdPMetadata: idp.metadata(t)}, ErrInvalidSAMLSlug},
+		{"invalid metadata", SAMLConnectionInput{Slug: "globex", IdPMetadata: "<EntityDescriptor/>"}, ErrInvalidSAMLMetadata},
+		{"slug taken", SAMLConnectionInput{Slug: testSAMLSlug, IdPMetadata: idp.metadata(t)}, ErrSAMLConnectionSlugTaken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.input.EmailDomains = []string{"corp.example"}
+			if _, err := svc.CreateConnection(&tt.input, 1); !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/user-management/internal/repository"
+)
+
+// scimAttributeKind 决定过滤值的类型和可用的运算符
+type scimAttributeKind int
+
+const (
+	scimString scimAttributeKind = iota
+	scimBoolean
+	scimID
+	scimDateTime
+)
+
+type scimAttribute struct {
+	column string
+	kind   scimAttributeKind
+}
+
+// 可过滤的属性，键为小写的属性路径（SCIM属性名不区分大小写）
+var (
+	scimUserAttributes = map[string]scimAttribute{
+		"id":                {column: "id", kind: scimID},
+		"username":          {column: "username", kind: scimString},
+		"emails":            {column: "email", kind: scimString},
+		"emails.value":      {column: "email", kind: scimString},
+		"active":            {column: "is_active", kind: scimBoolean},
+		"meta.created":      {column: "created_at", kind: scimDateTime},
+		"meta.lastmodified": {column: "updated_at", kind: scimDateTime},
+	}
+	scimGroupAttributes = map[string]scimAttribute{
+		"id":                {column: "id", kind: scimID},
+		"displayname":       {column: "name", kind: scimString},
+		"meta.created":      {column: "created_at", kind: scimDateTime},
+		"meta.lastmodified": {column: "updated_at", kind: scimDateTime},
+	}
+)
+
+var scimOperators = map[scimAttributeKind][]string{
+	scimString:   {repository.OpEqual, repository.OpNotEqual, repository.OpContains, repository.OpStartsWith, repository.OpEndsWith, repository.OpPresent},
+	scimBoolean:  {repository.OpEqual, repository.OpNotEqual, repository.OpPresent},
+	scimID:       {repository.OpEqual, repository.OpNotEqual, repository.OpPresent},
+	scimDateTime: {repository.OpEqual, repository.OpGreater, repository.OpGreaterEqual, repository.OpLess, repository.OpLessEqual, repository.OpPresent},
+}
+
+// parseSCIMFilter 解析RFC 7644 3.4.2.2过滤表达式的子集：以and连接的"属性 运算符 值"和"属性 pr"，
+// 不支持or、not、括号和值路径过滤
+func parseSCIMFilter(filter string, attributes map[string]scimAttribute) ([]repository.Condition, error) {
+	tokens, err := tokenizeSCIMFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var conditions []repository.Condition
+	for len(tokens) > 0 {
+		if len(conditions) > 0 {
+			if !strings.EqualFold(tokens[0], "and") {
+				return nil, ErrSCIMInvalidFilter
+			}
+			tokens = tokens[1:]
+		}
+		if len(tokens) < 2 {
+			return nil, ErrSCIMInvalidFilter
+		}
+
+		attribute, ok := attributes[strings.ToLower(tokens[0])]
+		if !ok {
+			return nil, ErrSCIMInvalidFilter
+		}
+		operator := strings.ToLower(tokens[1])
+		if !supportsSCIMOperator(attribute.kind, operator) {
+			return nil, ErrSCIMInvalidFilter
+		}
+
+		condition := repository.Condition{Column: attribute.column, Operator: operator}
+		if operator == repository.OpPresent {
+			tokens = tokens[2:]
+		} else {
+			if len(tokens) < 3 {
+				return nil, ErrSCIMInvalidFilter
+			}
+			if condition.Value, err = scimFilterValue(tokens[2], attribute.kind); err != nil {
+				return nil, err
+			}
+			tokens = tokens[3:]
+		}
+		conditions = append(conditions, condition)
+	}
+	return conditions, nil
+}
+
+func supportsSCIMOperator(kind scimAttributeKind, operator string) bool {
+	for _, supported := range scimOperators[kind] {
+		if supported == operator {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenizeSCIMFilter 按空格切分，双引号内的字符串按JSON字符串解码后保留引号以区分类型
+func tokenizeSCIMFilter(filter string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(filter); {
+		switch {
+		case filter[i] == ' ':
+			i++
+		case filter[i] == '"':
+			end := i + 1
+			for ; end < len(filter) && filter[end] != '"'; end++ {
+				if filter[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(filter) {
+				return nil, ErrSCIMInvalidFilter
+			}
+			tokens = append(tokens, filter[i:end+1])
+			i = end + 1
+		case filter[i] == '(' || filter[i] == ')' || filter[i] == '[' || filter[i] == ']':
+			return nil, ErrSCIMInvalidFilter
+		default:
+			end := strings.IndexByte(filter[i:], ' ')
+			if end < 0 {
+				end = len(filter) - i
+			}
+			tokens = append(tokens, filter[i:i+end])
+			i += end
+		}
+	}
+	return tokens, nil
+}
+
+func scimFilterValue(token string, kind scimAttributeKind) (interface{}, error) {
+	switch kind {
+	case scimBoolean:
+		value, err := strconv.ParseBool(token)
+		if err != nil {
+			return nil, ErrSCIMInvalidFilter
+		}
+		return value, nil
+	}
+
+	var value string
+	if err := json.Unmarshal([]byte(token), &value); err != nil {
+		return nil, ErrSCIMInvalidFilter
+	}
+	switch kind {
+	case scimID:
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			// 不存在的ID，返回空结果而不是错误
+			return uint(0), nil
+		}
+		return uint(id), nil
+	case scimDateTime:
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, ErrSCIMInvalidFilter
+		}
+		return at, nil
+	}
+	return value, nil
+}
@@ -0,0 +1,630 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+// SCIMMaxResults 是列表每页返回的最大资源数，count超过时按此截断
+const SCIMMaxResults = 200
+
+// SCIMUserInput 是SCIM User资源中本系统保存的属性，Password为空时不修改（创建时不能用本地密码登录），不为空时须满足密码策略
+type SCIMUserInput struct {
+	UserName string
+	Email    string
+	Active   bool
+	Password string
+}
+
+// SCIMGroupInput 是SCIM Group资源中本系统保存的属性，成员只能是用户
+type SCIMGroupInput struct {
+	DisplayName string
+	MemberIDs   []uint
+}
+
+// SCIMGroup 是组及其成员
+type SCIMGroup struct {
+	Group   *models.Group
+	Members []models.User
+}
+
+// SCIMPatchOperation 是PatchOp请求中的一个操作，Op不区分大小写
+type SCIMPatchOperation struct {
+	Op    string
+	Path  string
+	Value json.RawMessage
+}
+
+// SCIMService 实现SCIM 2.0 /Users和/Groups的资源操作；停用或删除用户时立即撤销其会话和刷新令牌。
+// expectedVersion为0时不校验客户端版本
+type SCIMService interface {
+	ListUsers(filter string, startIndex, count int) ([]models.User, int64, error)
+	GetUser(id uint) (*models.User, error)
+	CreateUser(input *SCIMUserInput) (*models.User, error)
+	ReplaceUser(id uint, input *SCIMUserInput, expectedVersion uint) (*models.User, error)
+	PatchUser(id uint, operations []SCIMPatchOperation, expectedVersion uint) (*models.User, error)
+	DeleteUser(id uint, expectedVersion uint) (*models.User, error)
+	UserGroups(userID uint) ([]models.Group, error)
+	ListGroups(filter string, startIndex, count int, withMembers bool) ([]SCIMGroup, int64, error)
+	GetGroup(id uint) (*SCIMGroup, error)
+	CreateGroup(input *SCIMGroupInput) (*SCIMGroup, error)
+	ReplaceGroup(id uint, input *SCIMGroupInput, expectedVersion uint) (*SCIMGroup, error)
+	PatchGroup(id uint, operations []SCIMPatchOperation, expectedVersion uint) (*SCIMGroup, error)
+	DeleteGroup(id uint, expectedVersion uint) (*models.Group, error)
+}
+
+type scimService struct {
+	userRepo       repository.UserRepository
+	groupRepo      repository.GroupRepository
+	sessionService SessionService
+	passwordPolicy PasswordPolicy
+}
+
+func NewSCIMService(userRepo repository.UserRepository, groupRepo repository.GroupRepository, sessionService SessionService, passwordPolicy PasswordPolicy) SCIMService {
+	return &scimService{
+		userRepo:       userRepo,
+		groupRepo:      groupRepo,
+		sessionService: sessionService,
+		passwordPolicy: passwordPolicy,
+	}
+}
+
+// emails[type eq "work"].value 等值路径都视为唯一的邮箱
+var scimEmailPath = regexp.MustCompile(`^emails(\[[^\]]*\])?(\.value)?$`)
+
+// members[value eq "12"] 指定要移除的成员
+var scimMemberPath = regexp.MustCompile(`^members\[value eq "(\d+)"\]$`)
+
+func (s *scimService) ListUsers(filter string, startIndex, count int) ([]models.User, int64, error) {
+	conditions, err := parseSCIMFilter(filter, scimUserAttributes)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset, limit := scimPage(startIndex, count)
+	return s.userRepo.Search(conditions, offset, limit)
+}
+
+func (s *scimService) GetUser(id uint) (*models.User, error) {
+	user, err := s.userRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (s *scimService) CreateUser(input *SCIMUserInput) (*models.User, error) {
+	if err := validateSCIMUser(input); err != nil {
+		return nil, err
+	}
+	if err := s.checkUserUnique(0, input); err != nil {
+		return nil, err
+	}
+
+	// 由身份提供方管理的账号通常通过单点登录，没有提供密码时不能用本地密码登录
+	user := &models.User{
+		Username:     input.UserName,
+		Email:        input.Email,
+		PasswordHash: UnusablePassword,
+		IsActive:     input.Active,
+		Role:         models.RoleUser,
+	}
+	if input.Password != "" {
+		if err := s.passwordPolicy.SetPassword(user, input.Password); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.userRepo.Create(user); err != nil {
+		return nil, err
+	}
+	if input.Password != "" {
+		s.passwordPolicy.Remember(user)
+	}
+	return user, nil
+}
+
+func (s *scimService) ReplaceUser(id uint, input *SCIMUserInput, expectedVersion uint) (*models.User, error) {
+	user, err := s.currentUser(id, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	return s.saveUser(user, input)
+}
+
+// PatchUser 按RFC 7644 3.5.2依次应用操作，任一操作失败时不做任何修改
+func (s *scimService) PatchUser(id uint, operations []SCIMPatchOperation, expectedVersion uint) (*models.User, error) {
+	user, err := s.currentUser(id, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	input := &SCIMUserInput{UserName: user.Username, Email: user.Email, Active: user.IsActive}
+	for _, operation := range operations {
+		if err := applySCIMUserOperation(input, operation); err != nil {
+			return nil, err
+		}
+	}
+	return s.saveUser(user, input)
+}
+
+func (s *scimService) DeleteUser(id uint, expectedVersion uint) (*models.User, error) {
+	user, err := s.currentUser(id, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.revokeSessions(user.ID); err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.Delete(user.ID); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *scimService) UserGroups(userID uint) ([]models.Group, error) {
+	return s.groupRepo.ListByUser(userID)
+}
+
+func (s *scimService) ListGroups(filter string, startIndex, count int, withMembers bool) ([]SCIMGroup, int64, error) {
+	conditions, err := parseSCIMFilter(filter, scimGroupAttributes)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset, limit := scimPage(startIndex, count)
+	groups, total, err := s.groupRepo.Search(conditions, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result := make([]SCIMGroup, 0, len(groups))
+	for i := range groups {
+		group := SCIMGroup{Group: &groups[i]}
+		if withMembers {
+			if group.Members, err = s.groupRepo.ListMembers(groups[i].ID); err != nil {
+				return nil, 0, err
+			}
+		}
+		result = append(result, group)
+	}
+	return result, total, nil
+}
+
+func (s *scimService) GetGroup(id uint) (*SCIMGroup, error) {
+	group, err := s.currentGroup(id, 0)
+	if err != nil {
+		return nil, err
+	}
+	return s.withMembers(group)
+}
+
+func (s *scimService) CreateGroup(input *SCIMGroupInput) (*SCIMGroup, error) {
+	if err := s.validateGroup(0, input); err != nil {
+		return nil, err
+	}
+
+	group := &models.Group{Name: input.DisplayName}
+	if err := s.groupRepo.Create(group, input.MemberIDs); err != nil {
+		return nil, err
+	}
+	return s.withMembers(group)
+}
+
+func (s *scimService) ReplaceGroup(id uint, input *SCIMGroupInput, expectedVersion uint) (*SCIMGroup, error) {
+	group, err := s.currentGroup(id, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	return s.saveGroup(group, input)
+}
+
+func (s *scimService) PatchGroup(id uint, operations []SCIMPatchOperation, expectedVersion uint) (*SCIMGroup, error) {
+	group, err := s.currentGroup(id, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.groupRepo.ListMembers(group.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	input := &SCIMGroupInput{DisplayName: group.Name}
+	for _, member := range members {
+		input.MemberIDs = append(input.MemberIDs, member.ID)
+	}
+	for _, operation := range operations {
+		if err := applySCIMGroupOperation(input, operation); err != nil {
+			return nil, err
+		}
+	}
+	return s.saveGroup(group, input)
+}
+
+func (s *scimService) DeleteGroup(id uint, expectedVersion uint) (*models.Group, error) {
+	group, err := s.currentGroup(id, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.groupRepo.Delete(group.ID); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (s *scimService) currentUser(id uint, expectedVersion uint) (*models.User, error) {
+	user, err := s.GetUser(id)
+	if err != nil {
+		return nil, err
+	}
+	if expectedVersion != 0 && user.Version != expectedVersion {
+		return nil, ErrPreconditionFailed
+	}
+	return user, nil
+}
+
+// saveUser 保存修改；账号从启用变为停用时立即撤销会话和刷新令牌
+func (s *scimService) saveUser(user *models.User, input *SCIMUserInput) (*models.User, error) {
+	if err := validateSCIMUser(input); err != nil {
+		return nil, err
+	}
+	if err := s.checkUserUnique(user.ID, input); err != nil {
+		return nil, err
+	}
+
+	deactivated := user.IsActive && !input.Active
+	user.Username = input.UserName
+	user.Email = input.Email
+	user.IsActive = input.Active
+	if input.Password != "" {
+		if err := s.passwordPolicy.SetPassword(user, input.Password); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.userRepo.Update(user); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			return nil, ErrPreconditionFailed
+		}
+		return nil, err
+	}
+	if input.Password != "" {
+		s.passwordPolicy.Remember(user)
+	}
+
+	if deactivated {
+		if err := s.revokeSessions(user.ID); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+func (s *scimService) checkUserUnique(id uint, input *SCIMUserInput) error {
+	existing, err := s.userRepo.GetByUsername(input.UserName)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != id {
+		return ErrUsernameTaken
+	}
+
+	existing, err = s.userRepo.GetByEmail(input.Email)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != id {
+		return ErrEmailTaken
+	}
+	return nil
+}
+
+func (s *scimService) revokeSessions(userID uint) error {
+	if err := s.sessionService.DeleteUserSessions(userID); err != nil {
+		return err
+	}
+	return s.userRepo.DeleteUserRefreshTokens(userID)
+}
+
+func (s *scimService) currentGroup(id uint, expectedVersion uint) (*models.Group, error) {
+	group, err := s.groupRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, ErrGroupNotFound
+	}
+	if expectedVersion != 0 && group.Version != expectedVersion {
+		return nil, ErrPreconditionFailed
+	}
+	return group, nil
+}
+
+func (s *scimService) saveGroup(group *models.Group, input *SCIMGroupInput) (*SCIMGroup, error) {
+	if err := s.validateGroup(group.ID, input); err != nil {
+		return nil, err
+	}
+
+	group.Name = input.DisplayName
+	if err := s.groupRepo.Update(group, input.MemberIDs); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			return nil, ErrPreconditionFailed
+		}
+		return nil, err
+	}
+	return s.withMembers(group)
+}
+
+// validateGroup 校验组名唯一，成员去重且必须是已存在的用户
+func (s *scimService) validateGroup(id uint, input *SCIMGroupInput) error {
+	input.DisplayName = strings.TrimSpace(input.DisplayName)
+	if input.DisplayName == "" || len(input.DisplayName) > 100 {
+		return invalidSCIMValue("displayName")
+	}
+
+	existing, err := s.groupRepo.GetByName(input.DisplayName)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != id {
+		return ErrGroupNameTaken
+	}
+
+	seen := make(map[uint]bool, len(input.MemberIDs))
+	memberIDs := make([]uint, 0, len(input.MemberIDs))
+	for _, memberID := range input.MemberIDs {
+		if seen[memberID] {
+			continue
+		}
+		seen[memberID] = true
+
+		user, err := s.userRepo.GetByID(memberID)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return invalidSCIMValue("members")
+		}
+		memberIDs = append(memberIDs, memberID)
+	}
+	input.MemberIDs = memberIDs
+	return nil
+}
+
+func (s *scimService) withMembers(group *models.Group) (*SCIMGroup, error) {
+	members, err := s.groupRepo.ListMembers(group.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &SCIMGroup{Group: group, Members: members}, nil
+}
+
+// scimPage 把从1开始的startIndex和count转换为offset和limit
+func scimPage(startIndex, count int) (int, int) {
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	if count < 0 {
+		count = 0
+	}
+	if count > SCIMMaxResults {
+		count = SCIMMaxResults
+	}
+	return startIndex - 1, count
+}
+
+func validateSCIMUser(input *SCIMUserInput) error {
+	input.UserName = strings.TrimSpace(input.UserName)
+	input.Email = strings.TrimSpace(input.Email)
+	if input.UserName == "" || len(input.UserName) > 50 {
+		return invalidSCIMValue("userName")
+	}
+	if !strings.Contains(input.Email, "@") || len(input.Email) > 100 {
+		return invalidSCIMValue("emails")
+	}
+	return nil
+}
+
+// applySCIMUserOperation 支持userName、emails、active和password；没有path时value为包含这些属性的对象
+func applySCIMUserOperation(input *SCIMUserInput, operation SCIMPatchOperation) error {
+	op := strings.ToLower(operation.Op)
+	if op != "add" && op != "replace" && op != "remove" {
+		return invalidSCIMValue("op")
+	}
+
+	if operation.Path == "" {
+		if op == "remove" {
+			return invalidSCIMPath("")
+		}
+		var attributes map[string]json.RawMessage
+		if err := json.Unmarshal(operation.Value, &attributes); err != nil {
+			return invalidSCIMValue("value")
+		}
+		// 对象中未保存的属性（如name、externalId）忽略
+		for name, value := range attributes {
+			if _, ok := scimUserPatchPaths[strings.ToLower(name)]; !ok && !scimEmailPath.MatchString(strings.ToLower(name)) {
+				continue
+			}
+			if err := applySCIMUserOperation(input, SCIMPatchOperation{Op: op, Path: name, Value: value}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	path := strings.ToLower(operation.Path)
+	if op == "remove" {
+		// 用户名、邮箱和状态都是必填属性，密码只写
+		if _, ok := scimUserPatchPaths[path]; ok || scimEmailPath.MatchString(path) {
+			return invalidSCIMValue(operation.Path)
+		}
+		return invalidSCIMPath(operation.Path)
+	}
+
+	switch {
+	case path == "username":
+		return decodeSCIMString(operation.Value, &input.UserName, "userName")
+	case path == "password":
+		return decodeSCIMString(operation.Value, &input.Password, "password")
+	case path == "active":
+		active, err := decodeSCIMBoolean(operation.Value)
+		if err != nil {
+			return err
+		}
+		input.Active = active
+		return nil
+	case path == "emails":
+		var emails []SCIMMultiValue
+		if err := json.Unmarshal(operation.Value, &emails); err != nil {
+			return invalidSCIMValue("emails")
+		}
+		input.Email = SCIMPrimaryValue(emails)
+		return nil
+	case scimEmailPath.MatchString(path):
+		return decodeSCIMString(operation.Value, &input.Email, "emails")
+	}
+	return invalidSCIMPath(operation.Path)
+}
+
+var scimUserPatchPaths = map[string]bool{"username": true, "password": true, "active": true, "emails": true}
+
+// applySCIMGroupOperation 支持displayName和members，remove可以通过members[value eq "id"]或value列表指定成员
+func applySCIMGroupOperation(input *SCIMGroupInput, operation SCIMPatchOperation) error {
+	op := strings.ToLower(operation.Op)
+	if op != "add" && op != "replace" && op != "remove" {
+		return invalidSCIMValue("op")
+	}
+
+	path := strings.ToLower(operation.Path)
+	switch {
+	case path == "" && op != "remove":
+		var attributes map[string]json.RawMessage
+		if err := json.Unmarshal(operation.Value, &attributes); err != nil {
+			return invalidSCIMValue("value")
+		}
+		for name, value := range attributes {
+			if lower := strings.ToLower(name); lower != "displayname" && lower != "members" {
+				continue
+			}
+			if err := applySCIMGroupOperation(input, SCIMPatchOperation{Op: op, Path: name, Value: value}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case path == "displayname" && op != "remove":
+		return decodeSCIMString(operation.Value, &input.DisplayName, "displayName")
+	case path == "members":
+		var members []uint
+		if op != "remove" || len(operation.Value) > 0 {
+			var err error
+			if members, err = decodeSCIMMembers(operation.Value); err != nil {
+				return err
+			}
+		}
+		switch {
+		case op == "add":
+			input.MemberIDs = append(input.MemberIDs, members...)
+		case op == "replace":
+			input.MemberIDs = members
+		case len(operation.Value) == 0:
+			input.MemberIDs = nil
+		default:
+			input.MemberIDs = removeIDs(input.MemberIDs, members)
+		}
+		return nil
+	case op == "remove" && scimMemberPath.MatchString(path):
+		id, _ := strconv.ParseUint(scimMemberPath.FindStringSubmatch(path)[1], 10, 32)
+		input.MemberIDs = removeIDs(input.MemberIDs, []uint{uint(id)})
+		return nil
+	case path == "displayname":
+		return invalidSCIMValue(operation.Path)
+	}
+	return invalidSCIMPath(operation.Path)
+}
+
+// SCIMMultiValue 是emails、members等多值属性中的一项
+type SCIMMultiValue struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+// SCIMPrimaryValue 返回primary为true的值，没有时返回第一个
+func SCIMPrimaryValue(values []SCIMMultiValue) string {
+	for _, value := range values {
+		if value.Primary {
+			return value.Value
+		}
+	}
+	if len(values) > 0 {
+		return values[0].Value
+	}
+	return ""
+}
+
+// ParseSCIMMembers 把members中的value转换为用户ID
+func ParseSCIMMembers(members []SCIMMultiValue) ([]uint, error) {
+	ids := make([]uint, 0, len(members))
+	for _, member := range members {
+		id, err := strconv.ParseUint(member.Value, 10, 32)
+		if err != nil {
+			return nil, invalidSCIMValue("members")
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+func decodeSCIMMembers(raw json.RawMessage) ([]uint, error) {
+	var members []SCIMMultiValue
+	if err := json.Unmarshal(raw, &members); err != nil {
+		return nil, invalidSCIMValue("members")
+	}
+	return ParseSCIMMembers(members)
+}
+
+func decodeSCIMString(raw json.RawMessage, target *string, attribute string) error {
+	if err := json.Unmarshal(raw, target); err != nil {
+		return invalidSCIMValue(attribute)
+	}
+	return nil
+}
+
+// decodeSCIMBoolean 兼容部分身份提供方以字符串"True"/"False"发送布尔值
+func decodeSCIMBoolean(raw json.RawMessage) (bool, error) {
+	var value bool
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return value, nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		if value, err := strconv.ParseBool(text); err == nil {
+			return value, nil
+		}
+	}
+	return false, invalidSCIMValue("active")
+}
+
+func removeIDs(ids, remove []uint) []uint {
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		keep := true
+		for _, removed := range remove {
+			if id == removed {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			result = append(result, id)
+		}
+	}
+	return result
+}
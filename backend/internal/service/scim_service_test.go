@@ -0,0 +1,279 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+// revokingUserRepository 记录被撤销刷新令牌的用户
+type revokingUserRepository struct {
+	*memoryUserRepository
+	revoked []uint
+}
+
+func (r *revokingUserRepository) DeleteUserRefreshTokens(userID uint) error {
+	r.revoked = append(r.revoked, userID)
+	return nil
+}
+
+type memoryGroupRepository struct {
+	repository.GroupRepository
+	users     *memoryUserRepository
+	groups    []*models.Group
+	members   map[uint][]uint
+	subgroups []models.Subgroup
+}
+
+func (r *memoryGroupRepository) Create(group *models.Group, memberIDs []uint) error {
+	group.ID = uint(len(r.groups) + 1)
+	group.Version = 1
+	r.groups = append(r.groups, group)
+	r.members[group.ID] = memberIDs
+	return nil
+}
+
+func (r *memoryGroupRepository) GetByID(id uint) (*models.Group, error) {
+	for _, group := range r.groups {
+		if group.ID == id {
+			return group, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryGroupRepository) GetByName(name string) (*models.Group, error) {
+	for _, group := range r.groups {
+		if group.Name == name {
+			return group, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryGroupRepository) Update(group *models.Group, memberIDs []uint) error {
+	group.Version++
+	r.members[group.ID] = memberIDs
+	return nil
+}
+
+func (r *memoryGroupRepository) ListMembers(groupID uint) ([]models.User, error) {
+	var members []models.User
+	for _, id := range r.members[groupID] {
+		user, _ := r.users.GetByID(id)
+		members = append(members, *user)
+	}
+	return members, nil
+}
+
+func newTestSCIMService(t *testing.T) (SCIMService, *revokingUserRepository, SessionService) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	sessions := NewSessionService(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	users := &revokingUserRepository{memoryUserRepository: &memoryUserRepository{}}
+	groups := &memoryGroupRepository{users: users.memoryUserRepository, members: make(map[uint][]uint)}
+	return NewSCIMService(users, groups, sessions, newTestPasswordPolicy(t)), users, sessions
+}
+
+func scimOperation(op, path string, value interface{}) SCIMPatchOperation {
+	operation := SCIMPatchOperation{Op: op, Path: path}
+	if value != nil {
+		operation.Value, _ = json.Marshal(value)
+	}
+	return operation
+}
+
+func TestParseSCIMFilter(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		filter string
+		want   []repository.Condition
+	}{
+		{filter: "", want: nil},
+		{filter: `userName eq "alice"`, want: []repository.Condition{{Column: "username", Operator: repository.OpEqual, Value: "alice"}}},
+		{filter: `emails.value co "@example.com" and active eq true`, want: []repository.Condition{
+			{Column: "email", Operator: repository.OpContains, Value: "@example.com"},
+			{Column: "is_active", Operator: repository.OpEqual, Value: true},
+		}},
+		{filter: `meta.created GE "2024-01-02T03:04:05Z"`, want: []repository.Condition{{Column: "created_at", Operator: repository.OpGreaterEqual, Value: created}}},
+		{filter: `id eq "abc"`, want: []repository.Condition{{Column: "id", Operator: repository.OpEqual, Value: uint(0)}}},
+		{filter: `emails pr`, want: []repository.Condition{{Column: "email", Operator: repository.OpPresent}}},
+	}
+	for _, tt := range tests {
+		got, err := parseSCIMFilter(tt.filter, scimUserAttributes)
+		if err != nil {
+			t.Errorf("%q: %v", tt.filter, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %+v, want %+v", tt.filter, got, tt.want)
+		}
+	}
+
+	for _, filter := range []string{
+		`userName eq alice`,
+		`userName gt "alice"`,
+		`active eq "true"`,
+		`name.familyName eq "x"`,
+		`userName eq "alice" or userName eq "bob"`,
+		`emails[type eq "work"]`,
+		`userName eq "alice`,
+		`userName eq`,
+	} {
+		if _, err := parseSCIMFilter(filter, scimUserAttributes); !errors.Is(err, ErrSCIMInvalidFilter) {
+			t.Errorf("%q: expected invalid filter, got %v", filter, err)
+		}
+	}
+}
+
+func TestSCIMDeactivationRevokesSessions(t *testing.T) {
+	svc, users, sessions := newTestSCIMService(t)
+
+	user, err := svc.CreateUser(&SCIMUserInput{UserName: "alice", Email: "alice@example.com", Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sessions.CreateSession(user.ID, "alice", "alice-session", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// Azure AD以字符串发送布尔值
+	user, err = svc.PatchUser(user.ID, []SCIMPatchOperation{scimOperation("Replace", "active", "False")}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.IsActive {
+		t.Fatal("expected user to be deactivated")
+	}
+	if session, _ := sessions.GetSession("alice-session"); session != nil {
+		t.Fatal("expected sessions to be revoked on deactivation")
+	}
+	if !reflect.DeepEqual(users.revoked, []uint{user.ID}) {
+		t.Fatalf("expected refresh tokens to be revoked, got %v", users.revoked)
+	}
+
+	// 已停用的账号再次修改其它属性时不重复撤销
+	if _, err := svc.PatchUser(user.ID, []SCIMPatchOperation{scimOperation("replace", "", map[string]interface{}{
+		"emails":     []SCIMMultiValue{{Value: "old@example.com"}, {Value: "alice@corp.example.com", Primary: true}},
+		"externalId": "ignored",
+	})}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "alice@corp.example.com" || len(users.revoked) != 1 {
+		t.Fatalf("unexpected state: email=%s revoked=%v", user.Email, users.revoked)
+	}
+}
+
+func TestSCIMPatchUserErrors(t *testing.T) {
+	svc, _, _ := newTestSCIMService(t)
+
+	user, err := svc.CreateUser(&SCIMUserInput{UserName: "alice", Email: "alice@example.com", Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.CreateUser(&SCIMUserInput{UserName: "alice", Email: "other@example.com"}); !errors.Is(err, ErrUsernameTaken) {
+		t.Fatalf("expected username taken, got %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		operation SCIMPatchOperation
+		code      string
+	}{
+		{name: "unknown path", operation: scimOperation("replace", "name.givenName", "Alice"), code: ErrSCIMInvalidPath.Code},
+		{name: "remove required attribute", operation: scimOperation("remove", "userName", nil), code: ErrSCIMInvalidValue.Code},
+		{name: "wrong value type", operation: scimOperation("replace", "active", "maybe"), code: ErrSCIMInvalidValue.Code},
+		{name: "unknown op", operation: scimOperation("move", "userName", "bob"), code: ErrSCIMInvalidValue.Code},
+	}
+	for _, tt := range tests {
+		_, err := svc.PatchUser(user.ID, []SCIMPatchOperation{scimOperation("replace", "userName", "bob"), tt.operation}, 0)
+		var domainErr *Error
+		if !errors.As(err, &domainErr) || domainErr.Code != tt.code {
+			t.Errorf("%s: expected %s, got %v", tt.name, tt.code, err)
+		}
+	}
+	if user.Username != "alice" {
+		t.Fatalf("failed patch must not modify the user, got %s", user.Username)
+	}
+
+	if _, err := svc.PatchUser(user.ID, nil, user.Version+1); !errors.Is(err, ErrPreconditionFailed) {
+		t.Fatalf("expected precondition failed, got %v", err)
+	}
+}
+
+func TestSCIMPatchGroupMembers(t *testing.T) {
+	svc, _, _ := newTestSCIMService(t)
+
+	var ids []uint
+	for _, name := range []string{"alice", "bob", "carol"} {
+		user, err := svc.CreateUser(&SCIMUserInput{UserName: name, Email: name + "@example.com", Active: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, user.ID)
+	}
+
+	group, err := svc.CreateGroup(&SCIMGroupInput{DisplayName: " Engineering ", MemberIDs: []uint{ids[0], ids[0]}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group.Group.Name != "Engineering" || len(group.Members) != 1 {
+		t.Fatalf("unexpected group: %+v", group)
+	}
+	if _, err := svc.CreateGroup(&SCIMGroupInput{DisplayName: "Engineering"}); !errors.Is(err, ErrGroupNameTaken) {
+		t.Fatalf("expected group name taken, got %v", err)
+	}
+
+	member := func(id uint) SCIMMultiValue { return SCIMMultiValue{Value: formatID(id)} }
+	group, err = svc.PatchGroup(group.Group.ID, []SCIMPatchOperation{
+		scimOperation("add", "members", []SCIMMultiValue{member(ids[1]), member(ids[2])}),
+		scimOperation("remove", `members[value eq "`+formatID(ids[0])+`"]`, nil),
+		scimOperation("replace", "", map[string]string{"displayName": "Platform"}),
+	}, group.Group.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := memberIDs(group.Members); group.Group.Name != "Platform" || !reflect.DeepEqual(got, ids[1:]) {
+		t.Fatalf("unexpected group after patch: name=%s members=%v", group.Group.Name, got)
+	}
+
+	group, err = svc.PatchGroup(group.Group.ID, []SCIMPatchOperation{
+		scimOperation("remove", "members", []SCIMMultiValue{member(ids[1])}),
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := memberIDs(group.Members); !reflect.DeepEqual(got, ids[2:]) {
+		t.Fatalf("unexpected members after remove: %v", got)
+	}
+
+	if _, err := svc.PatchGroup(group.Group.ID, []SCIMPatchOperation{
+		scimOperation("add", "members", []SCIMMultiValue{{Value: "999"}}),
+	}, 0); !errors.Is(err, ErrSCIMInvalidValue) {
+		t.Fatalf("expected invalid value for unknown member, got %v", err)
+	}
+	if _, err := svc.PatchGroup(group.Group.ID, nil, 1); !errors.Is(err, ErrPreconditionFailed) {
+		t.Fatalf("expected precondition failed, got %v", err)
+	}
+}
+
+func formatID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+func memberIDs(members []models.User) []uint {
+	ids := make([]uint, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.ID)
+	}
+	return ids
+}
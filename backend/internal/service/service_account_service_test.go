@@ -0,0 +1,150 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+type memoryServiceAccountRepository struct {
+	repository.ServiceAccountRepository
+	accounts map[uint]*models.ServiceAccount
+}
+
+func (r *memoryServiceAccountRepository) Create(account *models.ServiceAccount) error {
+	account.ID = uint(len(r.accounts) + 1)
+	r.accounts[account.ID] = account
+	return nil
+}
+
+func (r *memoryServiceAccountRepository) GetByID(id uint) (*models.ServiceAccount, error) {
+	return r.accounts[id], nil
+}
+
+func (r *memoryServiceAccountRepository) GetByClientID(clientID string) (*models.ServiceAccount, error) {
+	for _, account := range r.accounts {
+		if account.ClientID == clientID {
+			return account, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryServiceAccountRepository) GetByName(name string) (*models.ServiceAccount, error) {
+	for _, account := range r.accounts {
+		if account.Name == name {
+			return account, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryServiceAccountRepository) Delete(id uint) error {
+	delete(r.accounts, id)
+	return nil
+}
+
+func TestServiceAccounts(t *testing.T) {
+	accounts := &memoryServiceAccountRepository{accounts: make(map[uint]*models.ServiceAccount)}
+	svc := NewServiceAccountService(accounts)
+
+	if _, _, err := svc.Create("ci", "", []string{models.ScopeProfileRead}, 1); ErrorCode(err) != "invalid_scope" {
+		t.Fatalf("expected user-only scope to be rejected, got %v", err)
+	}
+
+	account, secret, err := svc.Create("ci", "deploys", []string{models.ScopeUsersRead}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(account.ClientID, ServiceAccountClientIDPrefix) || !strings.HasPrefix(secret, ServiceAccountSecretPrefix) {
+		t.Fatalf("expected prefixed credentials, got %q %q", account.ClientID, secret)
+	}
+	if account.ClientSecretHash == secret || strings.Contains(account.ClientSecretHash, secret) {
+		t.Fatal("expected only the secret hash to be stored")
+	}
+	if _, _, err := svc.Create("ci", "", nil, 1); !errors.Is(err, ErrServiceAccountNameTaken) {
+		t.Fatalf("expected duplicate name to be rejected, got %v", err)
+	}
+
+	if _, err := svc.Delete(99); !errors.Is(err, ErrServiceAccountNotFound) {
+		t.Fatalf("expected unknown account to be not found, got %v", err)
+	}
+	if _, err := svc.Delete(account.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Delete(account.ID); !errors.Is(err, ErrServiceAccountNotFound) {
+		t.Fatalf("expected deleted account to be not found, got %v", err)
+	}
+}
+
+func TestClientCredentials(t *testing.T) {
+	accounts := &memoryServiceAccountRepository{accounts: make(map[uint]*models.ServiceAccount)}
+	accountSvc := NewServiceAccountService(accounts)
+	authSvc := NewAuthService(&memoryUserRepository{}, accounts, &memoryOrganizationRepository{}, nil, nil, nil, nil, newTestPasswordPolicy(t), "test-secret", time.Hour, 30*time.Minute)
+
+	account, secret, err := accountSvc.Create("sync", "", []string{models.ScopeUsersRead, models.ScopeUsersWrite}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := authSvc.ClientCredentials(account.ClientID, secret+"x", nil); !errors.Is(err, ErrInvalidClient) {
+		t.Fatalf("expected wrong secret to be rejected, got %v", err)
+	}
+	if _, err := authSvc.ClientCredentials("sa_unknown", secret, nil); !errors.Is(err, ErrInvalidClient) {
+		t.Fatalf("expected unknown client to be rejected, got %v", err)
+	}
+	if _, err := authSvc.ClientCredentials(account.ClientID, secret, []string{models.ScopeProfileRead}); ErrorCode(err) != "invalid_scope" {
+		t.Fatalf("expected scope outside the grant to be rejected, got %v", err)
+	}
+
+	// 未指定scope时授予全部scope，指定时只授予请求的子集
+	token, err := authSvc.ClientCredentials(account.ClientID, secret, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(token.Scopes, []string{models.ScopeUsersRead, models.ScopeUsersWrite}) || token.ExpiresIn != time.Hour {
+		t.Fatalf("expected all granted scopes, got %v %v", token.Scopes, token.ExpiresIn)
+	}
+	readOnly, err := authSvc.ClientCredentials(account.ClientID, secret, []string{models.ScopeUsersRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(readOnly.AccessToken, claims); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := claims["user_id"]; ok || claims["sub"] != "service_account:1" {
+		t.Fatalf("expected service account subject without user_id, got %v", claims)
+	}
+
+	principal, err := authSvc.ValidateToken(readOnly.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if principal.Type != PrincipalServiceAccount || principal.ID != account.ID || !reflect.DeepEqual(principal.Scopes, []string{models.ScopeUsersRead}) {
+		t.Fatalf("unexpected principal %+v", principal)
+	}
+
+	// 停用或删除账号后已签发的令牌立即失效
+	account.IsActive = false
+	if _, err := authSvc.ValidateToken(token.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected inactive account token to be rejected, got %v", err)
+	}
+	if _, err := authSvc.ClientCredentials(account.ClientID, secret, nil); !errors.Is(err, ErrInvalidClient) {
+		t.Fatalf("expected inactive account to be rejected, got %v", err)
+	}
+	account.IsActive = true
+	if _, err := accountSvc.Delete(account.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authSvc.ValidateToken(token.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected deleted account token to be rejected, got %v", err)
+	}
+}
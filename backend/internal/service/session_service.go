@@ -10,16 +10,25 @@ import (
 )
 
 type SessionService interface {
-	CreateSession(userID uint, token string, expiry time.Duration) error
+	CreateSession(userID uint, sessionID, token string, expiry time.Duration) error
+	// CreateImpersonationSession 创建actorID模拟userID的会话，与用户自己的会话一样随用户的会话一起撤销
+	CreateImpersonationSession(userID, actorID uint, sessionID, token string, expiry time.Duration) error
 	GetSession(token string) (*SessionData, error)
 	DeleteSession(token string) error
 	DeleteUserSessions(userID uint) error
+	// DeleteOtherSessions 删除用户不属于sessionID会话的访问令牌
+	DeleteOtherSessions(userID uint, sessionID string) error
 	RefreshSession(token string, expiry time.Duration) error
+	ListUserSessions(userID uint) ([]SessionData, error)
 }
 
+// SessionData 的ActorID不为0时表示该会话是管理员模拟用户登录
 type SessionData struct {
 	UserID    uint      `json:"user_id"`
+	SessionID string    `json:"session_id,omitempty"`
+	ActorID   uint      `json:"actor_id,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
 }
 
 type sessionService struct {
@@ -34,12 +43,26 @@ func NewSessionService(redisClient *redis.Client) SessionService {
 	}
 }
 
-func (s *sessionService) CreateSession(userID uint, token string, expiry time.Duration) error {
-	sessionData := SessionData{
+func (s *sessionService) CreateSession(userID uint, sessionID, token string, expiry time.Duration) error {
+	return s.saveSession(token, SessionData{
 		UserID:    userID,
+		SessionID: sessionID,
 		CreatedAt: time.Now(),
-	}
+		ExpiresAt: time.Now().Add(expiry),
+	}, expiry)
+}
 
+func (s *sessionService) CreateImpersonationSession(userID, actorID uint, sessionID, token string, expiry time.Duration) error {
+	return s.saveSession(token, SessionData{
+		UserID:    userID,
+		SessionID: sessionID,
+		ActorID:   actorID,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(expiry),
+	}, expiry)
+}
+
+func (s *sessionService) saveSession(token string, sessionData SessionData, expiry time.Duration) error {
 	data, err := json.Marshal(sessionData)
 	if err != nil {
 		return err
@@ -53,7 +76,7 @@ func (s *sessionService) CreateSession(userID uint, token string, expiry time.Du
 	}
 
 	// 将token添加到用户的session集合中
-	userKey := fmt.Sprintf("user:sessions:%d", userID)
+	userKey := fmt.Sprintf("user:sessions:%d", sessionData.UserID)
 	err = s.redis.SAdd(s.ctx, userKey, token).Err()
 	if err != nil {
 		return err
@@ -122,7 +145,55 @@ func (s *sessionService) DeleteUserSessions(userID uint) error {
 	return s.redis.Del(s.ctx, userKey).Err()
 }
 
+func (s *sessionService) DeleteOtherSessions(userID uint, sessionID string) error {
+	userKey := fmt.Sprintf("user:sessions:%d", userID)
+
+	tokens, err := s.redis.SMembers(s.ctx, userKey).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		sessionData, err := s.GetSession(token)
+		if err != nil {
+			return err
+		}
+		if sessionData != nil && sessionData.SessionID == sessionID {
+			continue
+		}
+		// 已过期的session只剩集合中的残留token，一并清理
+		s.redis.Del(s.ctx, fmt.Sprintf("session:%s", token))
+		s.redis.SRem(s.ctx, userKey, token)
+	}
+
+	return nil
+}
+
 func (s *sessionService) RefreshSession(token string, expiry time.Duration) error {
 	key := fmt.Sprintf("session:%s", token)
 	return s.redis.Expire(s.ctx, key, expiry).Err()
-}
\ No newline at end of file
+}
+
+func (s *sessionService) ListUserSessions(userID uint) ([]SessionData, error) {
+	userKey := fmt.Sprintf("user:sessions:%d", userID)
+
+	tokens, err := s.redis.SMembers(s.ctx, userKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]SessionData, 0, len(tokens))
+	for _, token := range tokens {
+		sessionData, err := s.GetSession(token)
+		if err != nil {
+			return nil, err
+		}
+		// 已过期的session只剩集合中的残留token，跳过
+		if sessionData == nil {
+			continue
+		}
+		sessions = append(sessions, *sessionData)
+	}
+
+	return sessions, nil
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"log"
+	"math/big"
+	"os"
+)
+
+// SigningKey 是签发ID Token使用的RSA密钥，ID为公钥指纹，对应JWT头中的kid
+type SigningKey struct {
+	ID         string
+	PrivateKey *rsa.PrivateKey
+}
+
+// JWK 是JWKS中的单个公钥
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+// LoadSigningKey 从PEM文件读取RSA私钥（PKCS#1或PKCS#8），path为空时生成临时密钥
+func LoadSigningKey(path string) (*SigningKey, error) {
+	if path == "" {
+		log.Println("OIDC_SIGNING_KEY_FILE is not set, generating an ephemeral signing key")
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		return newSigningKey(key), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := parseRSAPrivateKey(data)
+	if err != nil {
+		return nil, err
+	}
+	return newSigningKey(key), nil
+}
+
+// parseRSAPrivateKey 解析PEM格式的RSA私钥，支持PKCS#1和PKCS#8
+func parseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("key file does not contain a PEM block")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("key must be an RSA private key")
+	}
+	return key, nil
+}
+
+func newSigningKey(key *rsa.PrivateKey) *SigningKey {
+	der := x509.MarshalPKCS1PublicKey(&key.PublicKey)
+	sum := sha256.Sum256(der)
+	return &SigningKey{
+		ID:         base64.RawURLEncoding.EncodeToString(sum[:8]),
+		PrivateKey: key,
+	}
+}
+
+// JWK 返回公钥的JWK表示
+func (k *SigningKey) JWK() JWK {
+	return JWK{
+		KeyType:   "RSA",
+		Use:       "sig",
+		Algorithm: "RS256",
+		KeyID:     k.ID,
+		Modulus:   base64.RawURLEncoding.EncodeToString(k.PrivateKey.N.Bytes()),
+		Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.PrivateKey.E)).Bytes()),
+	}
+}
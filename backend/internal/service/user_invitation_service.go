@@ -0,0 +1,236 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/user/user-management/internal/config"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+// UserInvitationPage 是注册邀请邮件中链接指向的前端页面，token作为查询参数
+const UserInvitationPage = "/register/invitation"
+
+// UserInvitationService 管理管理员发出的注册邀请。REGISTRATION_MODE=invitation时公开注册关闭，
+// 新用户只能通过邀请链接设置用户名和密码完成注册
+type UserInvitationService interface {
+	// Invite 向email发送注册邀请，同一邮箱之前未接受的邀请作废；organizationID为0时注册后不加入组织
+	Invite(email, role string, organizationID uint, organizationRole string, invitedBy uint) (*models.UserInvitation, error)
+	// List 返回未接受的邀请，包括已过期的
+	List() ([]models.UserInvitation, error)
+	// Resend 为未接受的邀请生成新链接并重新计算有效期，旧链接随之失效
+	Resend(id uint) (*models.UserInvitation, error)
+	Revoke(id uint) (*models.UserInvitation, error)
+	// Lookup 返回令牌对应的有效邀请，供注册页面展示受邀邮箱
+	Lookup(token string) (*models.UserInvitation, error)
+	// Accept 以邀请中的邮箱和角色创建用户并加入邀请中的组织，密码须满足密码策略
+	Accept(token, username, password string) (*models.User, error)
+}
+
+type userInvitationService struct {
+	cfg            config.RegistrationConfig
+	invitationRepo repository.UserInvitationRepository
+	userRepo       repository.UserRepository
+	orgRepo        repository.OrganizationRepository
+	passwordPolicy PasswordPolicy
+	mailer         Mailer
+	baseURL        string
+}
+
+// NewUserInvitationService 的baseURL是前端的对外地址，用于拼接邀请邮件中的链接
+func NewUserInvitationService(cfg config.RegistrationConfig, invitationRepo repository.UserInvitationRepository, userRepo repository.UserRepository, orgRepo repository.OrganizationRepository, passwordPolicy PasswordPolicy, mailer Mailer, baseURL string) UserInvitationService {
+	return &userInvitationService{
+		cfg:            cfg,
+		invitationRepo: invitationRepo,
+		userRepo:       userRepo,
+		orgRepo:        orgRepo,
+		passwordPolicy: passwordPolicy,
+		mailer:         mailer,
+		baseURL:        strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+func (s *userInvitationService) Invite(email, role string, organizationID uint, organizationRole string, invitedBy uint) (*models.UserInvitation, error) {
+	if role != models.RoleUser && role != models.RoleAdmin {
+		return nil, ErrInvalidRole
+	}
+
+	invitation := &models.UserInvitation{Role: role, InvitedBy: invitedBy}
+	if organizationID != 0 {
+		if organizationRole == "" {
+			organizationRole = models.OrgRoleMember
+		}
+		if !validOrgRole(organizationRole) {
+			return nil, ErrInvalidOrganizationRole
+		}
+		org, err := s.orgRepo.GetByID(organizationID)
+		if err != nil {
+			return nil, err
+		}
+		if org == nil {
+			return nil, ErrOrganizationNotFound
+		}
+		invitation.OrganizationID = &organizationID
+		invitation.OrganizationRole = organizationRole
+		invitation.Organization = org
+	}
+
+	invitation.Email = strings.ToLower(strings.TrimSpace(email))
+	existing, err := s.userRepo.GetByEmail(invitation.Email)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrEmailTaken
+	}
+	if err := s.invitationRepo.DeletePending(invitation.Email); err != nil {
+		return nil, err
+	}
+
+	token, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	invitation.TokenHash = hashAccessToken(token)
+	invitation.ExpiresAt = time.Now().Add(s.cfg.InvitationTTL)
+	if err := s.invitationRepo.Create(invitation); err != nil {
+		return nil, err
+	}
+
+	s.send(invitation, token)
+	return invitation, nil
+}
+
+func (s *userInvitationService) List() ([]models.UserInvitation, error) {
+	return s.invitationRepo.ListPending()
+}
+
+func (s *userInvitationService) Resend(id uint) (*models.UserInvitation, error) {
+	invitation, err := s.getPending(id)
+	if err != nil {
+		return nil, err
+	}
+	if invitation.OrganizationID != nil {
+		if invitation.Organization, err = s.orgRepo.GetByID(*invitation.OrganizationID); err != nil {
+			return nil, err
+		}
+	}
+
+	token, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	invitation.TokenHash = hashAccessToken(token)
+	invitation.ExpiresAt = time.Now().Add(s.cfg.InvitationTTL)
+	if err := s.invitationRepo.UpdateToken(invitation.ID, invitation.TokenHash, invitation.ExpiresAt); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			return nil, ErrInvitationNotFound
+		}
+		return nil, err
+	}
+
+	s.send(invitation, token)
+	return invitation, nil
+}
+
+func (s *userInvitationService) Revoke(id uint) (*models.UserInvitation, error) {
+	invitation, err := s.getPending(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.invitationRepo.Delete(id); err != nil {
+		return nil, err
+	}
+	return invitation, nil
+}
+
+func (s *userInvitationService) Lookup(token string) (*models.UserInvitation, error) {
+	invitation, err := s.invitationRepo.GetByHash(hashAccessToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if invitation == nil || invitation.AcceptedAt != nil || time.Now().After(invitation.ExpiresAt) {
+		return nil, ErrInvalidInvitation
+	}
+	return invitation, nil
+}
+
+func (s *userInvitationService) Accept(token, username, password string) (*models.User, error) {
+	invitation, err := s.Lookup(token)
+	if err != nil {
+		return nil, err
+	}
+
+	// 邀请发出后该邮箱可能已通过LDAP或SCIM创建了用户
+	existing, err := s.userRepo.GetByEmail(invitation.Email)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrEmailTaken
+	}
+	if existing, err = s.userRepo.GetByUsername(username); err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrUsernameTaken
+	}
+
+	user := &models.User{
+		Username: username,
+		Email:    invitation.Email,
+		IsActive: true,
+		Role:     invitation.Role,
+	}
+	if err := s.passwordPolicy.SetPassword(user, password); err != nil {
+		return nil, err
+	}
+
+	var member *models.OrganizationMember
+	if invitation.OrganizationID != nil {
+		member = &models.OrganizationMember{OrganizationID: *invitation.OrganizationID, Role: invitation.OrganizationRole}
+	}
+	if err := s.invitationRepo.Accept(invitation, user, member); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			return nil, ErrInvalidInvitation
+		}
+		return nil, err
+	}
+	s.passwordPolicy.Remember(user)
+	return user, nil
+}
+
+func (s *userInvitationService) getPending(id uint) (*models.UserInvitation, error) {
+	invitation, err := s.invitationRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if invitation == nil || invitation.AcceptedAt != nil {
+		return nil, ErrInvitationNotFound
+	}
+	return invitation, nil
+}
+
+// send 异步发送邀请邮件，发送失败只记录日志，管理员可以重新发送
+func (s *userInvitationService) send(invitation *models.UserInvitation, token string) {
+	link := s.baseURL + UserInvitationPage + "?token=" + url.QueryEscape(token)
+	joinEN, joinZH := "", ""
+	if invitation.Organization != nil {
+		joinEN, joinZH = " and join "+invitation.Organization.Name, "并加入"+invitation.Organization.Name
+	}
+	email := invitation.Email
+	go func() {
+		if err := s.mailer.Send(email, "You are invited to create an account / 账号注册邀请", fmt.Sprintf(
+			"You have been invited to create an account%s. Open the link below within %s to choose your username and password.\n"+
+				"您被邀请注册账号%s。请在%s内打开以下链接设置用户名和密码。\n\n%s\n\n"+
+				"If you were not expecting this invitation, you can ignore this email.\n如果您不认识邀请方，请忽略此邮件。\n",
+			joinEN, s.cfg.InvitationTTL, joinZH, s.cfg.InvitationTTL, link)); err != nil {
+			log.Printf("Failed to send registration invitation: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/user/user-management/internal/config"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+type memoryUserInvitationRepository struct {
+	repository.UserInvitationRepository
+	users       *memoryUserRepository
+	orgs        *memoryOrganizationRepository
+	invitations []*models.UserInvitation
+}
+
+func (r *memoryUserInvitationRepository) Create(invitation *models.UserInvitation) error {
+	invitation.ID = uint(len(r.invitations) + 1)
+	r.invitations = append(r.invitations, invitation)
+	return nil
+}
+
+func (r *memoryUserInvitationRepository) GetByID(id uint) (*models.UserInvitation, error) {
+	for _, invitation := range r.invitations {
+		if invitation.ID == id {
+			copied := *invitation
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryUserInvitationRepository) GetByHash(tokenHash string) (*models.UserInvitation, error) {
+	for _, invitation := range r.invitations {
+		if invitation.TokenHash == tokenHash {
+			copied := *invitation
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryUserInvitationRepository) UpdateToken(id uint, tokenHash string, expiresAt time.Time) error {
+	for _, invitation := range r.invitations {
+		if invitation.ID == id && invitation.AcceptedAt == nil {
+			invitation.TokenHash = tokenHash
+			invitation.ExpiresAt = expiresAt
+			return nil
+		}
+	}
+	return repository.ErrVersionConflict
+}
+
+func (r *memoryUserInvitationRepository) DeletePending(email string) error {
+	kept := r.invitations[:0]
+	for _, invitation := range r.invitations {
+		if invitation.Email != email || invitation.AcceptedAt != nil {
+			kept = append(kept, invitation)
+		}
+	}
+	r.invitations = kept
+	return nil
+}
+
+func (r *memoryUserInvitationRepository) Accept(invitation *models.UserInvitation, user *models.User, member *models.OrganizationMember) error {
+	for _, stored := range r.invitations {
+		if stored.ID == invitation.ID {
+			if stored.AcceptedAt != nil {
+				return repository.ErrVersionConflict
+			}
+			r.users.Create(user)
+			now := time.Now()
+			stored.AcceptedAt = &now
+			stored.UserID = &user.ID
+			if member != nil {
+				member.UserID = user.ID
+				r.orgs.members = append(r.orgs.members, member)
+			}
+			return nil
+		}
+	}
+	return repository.ErrVersionConflict
+}
+
+func TestUserInvitations(t *testing.T) {
+	users := &memoryUserRepository{}
+	admin := &models.User{Username: "admin", Email: "admin@example.com", IsActive: true, Role: models.RoleAdmin}
+	users.Create(admin)
+	orgs := &memoryOrganizationRepository{}
+	org := &models.Organization{Slug: "acme", Name: "Acme"}
+	orgs.Create(org, &models.OrganizationMember{UserID: admin.ID, Role: models.OrgRoleOwner})
+	invitations := &memoryUserInvitationRepository{users: users, orgs: orgs}
+	mailer := make(channelMailer, 10)
+	svc := NewUserInvitationService(config.RegistrationConfig{Mode: config.RegistrationInvitation, InvitationTTL: time.Hour}, invitations, users, orgs, newTestPasswordPolicy(t), mailer, "https://example.com/")
+
+	if _, err := svc.Invite("admin@example.com", models.RoleUser, 0, "", admin.ID); !errors.Is(err, ErrEmailTaken) {
+		t.Fatalf("expected registered email to be rejected, got %v", err)
+	}
+	if _, err := svc.Invite("alice@example.com", models.RoleUser, 99, "", admin.ID); !errors.Is(err, ErrOrganizationNotFound) {
+		t.Fatalf("expected unknown organization to be rejected, got %v", err)
+	}
+
+	invitation, err := svc.Invite(" Alice@example.com ", models.RoleUser, org.ID, "", admin.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invitation.Email != "alice@example.com" || invitation.OrganizationRole != models.OrgRoleMember {
+		t.Fatalf("unexpected invitation %+v", invitation)
+	}
+	superseded := receiveInvitationToken(t, mailer, UserInvitationPage)
+
+	// 重新发送后旧链接失效
+	if _, err := svc.Resend(invitation.ID); err != nil {
+		t.Fatal(err)
+	}
+	token := receiveInvitationToken(t, mailer, UserInvitationPage)
+	if _, err := svc.Lookup(superseded); !errors.Is(err, ErrInvalidInvitation) {
+		t.Fatalf("expected superseded link to be rejected, got %v", err)
+	}
+	if found, err := svc.Lookup(token); err != nil || found.Email != "alice@example.com" {
+		t.Fatalf("unexpected lookup %+v, %v", found, err)
+	}
+
+	if _, err := svc.Accept(token, "admin", "correct-horse-battery"); !errors.Is(err, ErrUsernameTaken) {
+		t.Fatalf("expected taken username to be rejected, got %v", err)
+	}
+	user, err := svc.Accept(token, "alice", "correct-horse-battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "alice@example.com" || user.Role != models.RoleUser || user.PasswordHash == "" {
+		t.Fatalf("unexpected user %+v", user)
+	}
+	if member, _ := orgs.GetMember(org.ID, user.ID); member == nil || member.Role != models.OrgRoleMember {
+		t.Fatalf("expected alice to join the organization, got %+v", member)
+	}
+	if _, err := svc.Accept(token, "alice2", "correct-horse-battery"); !errors.Is(err, ErrInvalidInvitation) {
+		t.Fatalf("expected used invitation to be rejected, got %v", err)
+	}
+	if _, err := svc.Revoke(invitation.ID); !errors.Is(err, ErrInvitationNotFound) {
+		t.Fatalf("expected accepted invitation to be unrevocable, got %v", err)
+	}
+}
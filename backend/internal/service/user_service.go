@@ -1,101 +1,168 @@
 package service
 
 import (
+	"context"
 	"errors"
 
 	"github.com/user/user-management/internal/models"
 	"github.com/user/user-management/internal/repository"
-	"golang.org/x/crypto/bcrypt"
 )
 
+// UserService 的所有方法都按ctx中的租户（repository.WithTenant）限定可以访问的用户，
+// 认证中间件为每个请求设置租户；ctx没有租户时查不到任何用户
 type UserService interface {
-	GetByID(id uint) (*models.User, error)
-	UpdateUser(id uint, updates map[string]interface{}) (*models.User, error)
-	DeleteUser(id uint) error
-	ListUsers(page, limit int) ([]models.User, int64, error)
+	GetByID(ctx context.Context, id uint) (*models.User, error)
+	// UpdateUser 的updates["attributes"]是要修改的扩展属性，值为nil时移除该属性；
+	// 扩展属性按定义校验，可见性由调用方通过AttributeService.CheckEditable检查
+	UpdateUser(ctx context.Context, id uint, updates map[string]interface{}, expectedVersion uint) (*models.User, error)
+	DeleteUser(ctx context.Context, id uint) error
+	// ListUsers 按attributes中的扩展属性值过滤（相等比较），非管理员不能按private属性过滤
+	ListUsers(ctx context.Context, page, limit int, attributes map[string]string, admin bool) ([]models.User, int64, error)
 }
 
 type userService struct {
-	userRepo repository.UserRepository
+	userRepo       repository.UserRepository
+	attributeRepo  repository.AttributeRepository
+	passwordPolicy PasswordPolicy
 }
 
-func NewUserService(userRepo repository.UserRepository) UserService {
+func NewUserService(userRepo repository.UserRepository, attributeRepo repository.AttributeRepository, passwordPolicy PasswordPolicy) UserService {
 	return &userService{
-		userRepo: userRepo,
+		userRepo:       userRepo,
+		attributeRepo:  attributeRepo,
+		passwordPolicy: passwordPolicy,
 	}
 }
 
-func (s *userService) GetByID(id uint) (*models.User, error) {
-	user, err := s.userRepo.GetByID(id)
+func (s *userService) GetByID(ctx context.Context, id uint) (*models.User, error) {
+	users := s.userRepo.WithContext(ctx)
+	user, err := users.GetByID(id)
 	if err != nil {
 		return nil, err
 	}
 	if user == nil {
-		return nil, errors.New("user not found")
+		return nil, ErrUserNotFound
 	}
 	return user, nil
 }
 
-func (s *userService) UpdateUser(id uint, updates map[string]interface{}) (*models.User, error) {
-	user, err := s.userRepo.GetByID(id)
+// UpdateUser expectedVersion为0时不校验客户端版本，但仍以读取时的版本防止并发覆盖
+func (s *userService) UpdateUser(ctx context.Context, id uint, updates map[string]interface{}, expectedVersion uint) (*models.User, error) {
+	users := s.userRepo.WithContext(ctx)
+	user, err := users.GetByID(id)
 	if err != nil {
 		return nil, err
 	}
 	if user == nil {
-		return nil, errors.New("user not found")
+		return nil, ErrUserNotFound
+	}
+	if expectedVersion != 0 && user.Version != expectedVersion {
+		return nil, ErrPreconditionFailed
 	}
 
 	// 更新字段
 	if username, ok := updates["username"].(string); ok && username != "" {
 		// 检查用户名是否已被占用
-		existingUser, _ := s.userRepo.GetByUsername(username)
+		existingUser, _ := users.GetByUsername(username)
 		if existingUser != nil && existingUser.ID != id {
-			return nil, errors.New("username already exists")
+			return nil, ErrUsernameTaken
 		}
 		user.Username = username
 	}
 
 	if email, ok := updates["email"].(string); ok && email != "" {
 		// 检查邮箱是否已被占用
-		existingUser, _ := s.userRepo.GetByEmail(email)
+		existingUser, _ := users.GetByEmail(email)
 		if existingUser != nil && existingUser.ID != id {
-			return nil, errors.New("email already exists")
+			return nil, ErrEmailTaken
 		}
 		user.Email = email
 	}
 
-	if password, ok := updates["password"].(string); ok && password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-		if err != nil {
+	// 在用户名和邮箱更新之后校验，禁用词使用新的值
+	password, passwordChanged := updates["password"].(string)
+	passwordChanged = passwordChanged && password != ""
+	if passwordChanged {
+		if err := s.passwordPolicy.SetPassword(user, password); err != nil {
 			return nil, err
 		}
-		user.PasswordHash = string(hashedPassword)
 	}
 
 	if isActive, ok := updates["is_active"].(bool); ok {
 		user.IsActive = isActive
 	}
 
-	if err := s.userRepo.Update(user); err != nil {
+	if role, ok := updates["role"].(string); ok && role != "" {
+		if role != models.RoleUser && role != models.RoleAdmin {
+			return nil, ErrInvalidRole
+		}
+		user.Role = role
+	}
+
+	if changes, ok := updates["attributes"].(map[string]interface{}); ok && len(changes) > 0 {
+		definitions, err := s.attributeRepo.List()
+		if err != nil {
+			return nil, err
+		}
+		if user.Attributes, err = applyAttributes(definitions, user.Attributes, changes); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := users.Update(user); err != nil {
+		if errors.Is(err, repository.ErrVersionConflict) {
+			return nil, ErrPreconditionFailed
+		}
 		return nil, err
 	}
+	if passwordChanged {
+		s.passwordPolicy.Remember(user)
+	}
 
 	return user, nil
 }
 
-func (s *userService) DeleteUser(id uint) error {
-	user, err := s.userRepo.GetByID(id)
+func (s *userService) DeleteUser(ctx context.Context, id uint) error {
+	users := s.userRepo.WithContext(ctx)
+	user, err := users.GetByID(id)
 	if err != nil {
 		return err
 	}
 	if user == nil {
-		return errors.New("user not found")
+		return ErrUserNotFound
 	}
 
-	return s.userRepo.Delete(id)
+	return users.Delete(id)
 }
 
-func (s *userService) ListUsers(page, limit int) ([]models.User, int64, error) {
+func (s *userService) ListUsers(ctx context.Context, page, limit int, attributes map[string]string, admin bool) ([]models.User, int64, error) {
+	users := s.userRepo.WithContext(ctx)
 	offset := (page - 1) * limit
-	return s.userRepo.List(offset, limit)
+	if len(attributes) == 0 {
+		return users.List(offset, limit)
+	}
+
+	definitions, err := s.attributeRepo.List()
+	if err != nil {
+		return nil, 0, err
+	}
+	conditions := make([]repository.Condition, 0, len(attributes))
+	for name, value := range attributes {
+		var definition *models.AttributeDefinition
+		for i := range definitions {
+			if definitions[i].Name == name {
+				definition = &definitions[i]
+			}
+		}
+		if definition == nil || !definition.ReadableBy(false, admin) {
+			return nil, 0, invalidAttributeFilter(name)
+		}
+		conditions = append(conditions, repository.Condition{
+			Column:   "attributes",
+			JSONPath: "$." + name,
+			Operator: repository.OpEqual,
+			Value:    value,
+		})
+	}
+	return users.Search(conditions, offset, limit)
 }
\ No newline at end of file
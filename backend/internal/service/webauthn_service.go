@@ -0,0 +1,397 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/redis/go-redis/v9"
+	"github.com/user/user-management/internal/config"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+// WebAuthnCeremonyTTL 是注册或登录时用户操作认证器的时限，同时也是第二因素票据的有效期
+const WebAuthnCeremonyTTL = 5 * time.Minute
+
+// WebAuthnService 管理用户的WebAuthn凭据，并支持无密码登录和作为密码登录的第二因素。
+// 挑战保存在Redis中，每次仪式只能完成一次
+type WebAuthnService interface {
+	SecondFactor
+	BeginRegistration(userID uint) (string, *protocol.CredentialCreation, error)
+	FinishRegistration(userID uint, ceremonyID, name string, response []byte) (*models.WebAuthnCredential, error)
+	ListCredentials(userID uint) ([]models.WebAuthnCredential, error)
+	DeleteCredential(userID, id uint) (*models.WebAuthnCredential, error)
+	// BeginLogin 的ticket为空时发起无密码登录（由认证器选择凭据），否则为密码登录后的第二步
+	BeginLogin(ticket string) (string, *protocol.CredentialAssertion, error)
+	FinishLogin(ceremonyID string, response []byte) (*models.User, error)
+}
+
+// webAuthnCeremony 在发起注册或登录时保存，完成时一次性取出
+type webAuthnCeremony struct {
+	Registration bool                 `json:"registration"`
+	UserID       uint                 `json:"user_id"`
+	Ticket       string               `json:"ticket,omitempty"`
+	Session      webauthn.SessionData `json:"session"`
+}
+
+type webAuthnService struct {
+	webAuthn       *webauthn.WebAuthn
+	credentialRepo repository.WebAuthnCredentialRepository
+	userRepo       repository.UserRepository
+	redis          *redis.Client
+	ctx            context.Context
+}
+
+func NewWebAuthnService(cfg config.WebAuthnConfig, credentialRepo repository.WebAuthnCredentialRepository, userRepo repository.UserRepository, redisClient *redis.Client) (WebAuthnService, error) {
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPName,
+		RPOrigins:     cfg.Origins,
+		// 优先创建可发现凭据（通行密钥），不支持的安全密钥仍可作为第二因素
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: protocol.VerificationPreferred,
+		},
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: WebAuthnCeremonyTTL, TimeoutUVD: WebAuthnCeremonyTTL},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: WebAuthnCeremonyTTL, TimeoutUVD: WebAuthnCeremonyTTL},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("configure webauthn: %w", err)
+	}
+
+	return &webAuthnService{
+		webAuthn:       webAuthn,
+		credentialRepo: credentialRepo,
+		userRepo:       userRepo,
+		redis:          redisClient,
+		ctx:            context.Background(),
+	}, nil
+}
+
+// Challenge 注册了WebAuthn凭据的账号在密码登录后还需要完成断言
+func (s *webAuthnService) Challenge(user *models.User) (string, error) {
+	count, err := s.credentialRepo.CountByUser(user.ID)
+	if err != nil || count == 0 {
+		return "", err
+	}
+
+	ticket, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	if err := s.redis.Set(s.ctx, webAuthnTicketKey(ticket), user.ID, WebAuthnCeremonyTTL).Err(); err != nil {
+		return "", err
+	}
+	return ticket, nil
+}
+
+func (s *webAuthnService) BeginRegistration(userID uint) (string, *protocol.CredentialCreation, error) {
+	user, err := s.loadUser(userID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// 已注册的认证器不能重复注册
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(user.credentials))
+	for _, credential := range user.WebAuthnCredentials() {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+	creation, session, err := s.webAuthn.BeginRegistration(user, webauthn.WithExclusions(exclusions))
+	if err != nil {
+		return "", nil, err
+	}
+
+	ceremonyID, err := s.saveCeremony(&webAuthnCeremony{Registration: true, UserID: userID, Session: *session})
+	if err != nil {
+		return "", nil, err
+	}
+	return ceremonyID, creation, nil
+}
+
+func (s *webAuthnService) FinishRegistration(userID uint, ceremonyID, name string, response []byte) (*models.WebAuthnCredential, error) {
+	ceremony, err := s.takeCeremony(ceremonyID)
+	if err != nil {
+		return nil, err
+	}
+	if !ceremony.Registration || ceremony.UserID != userID {
+		return nil, ErrWebAuthnCeremony
+	}
+
+	user, err := s.loadUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return nil, webAuthnError(err)
+	}
+	credential, err := s.webAuthn.CreateCredential(user, ceremony.Session, parsed)
+	if err != nil {
+		return nil, webAuthnError(err)
+	}
+
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+	stored := &models.WebAuthnCredential{
+		UserID:          userID,
+		Name:            name,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		Transports:      strings.Join(transports, ","),
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	}
+	if err := s.credentialRepo.Create(stored); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+func (s *webAuthnService) ListCredentials(userID uint) ([]models.WebAuthnCredential, error) {
+	return s.credentialRepo.ListByUser(userID)
+}
+
+func (s *webAuthnService) DeleteCredential(userID, id uint) (*models.WebAuthnCredential, error) {
+	credential, err := s.credentialRepo.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if credential == nil {
+		return nil, ErrWebAuthnKeyNotFound
+	}
+	if err := s.credentialRepo.Delete(credential.ID); err != nil {
+		return nil, err
+	}
+	return credential, nil
+}
+
+func (s *webAuthnService) BeginLogin(ticket string) (string, *protocol.CredentialAssertion, error) {
+	if ticket == "" {
+		// 无密码登录时认证器必须验证用户（PIN或生物识别），凭据本身即满足两个因素
+		assertion, session, err := s.webAuthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+		if err != nil {
+			return "", nil, err
+		}
+		ceremonyID, err := s.saveCeremony(&webAuthnCeremony{Session: *session})
+		if err != nil {
+			return "", nil, err
+		}
+		return ceremonyID, assertion, nil
+	}
+
+	userID, err := s.redis.Get(s.ctx, webAuthnTicketKey(ticket)).Uint64()
+	if errors.Is(err, redis.Nil) {
+		return "", nil, ErrSecondFactorTicket
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	user, err := s.loadUser(uint(userID))
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return "", nil, ErrSecondFactorTicket
+		}
+		return "", nil, err
+	}
+
+	assertion, session, err := s.webAuthn.BeginLogin(user)
+	if err != nil {
+		return "", nil, webAuthnError(err)
+	}
+	ceremonyID, err := s.saveCeremony(&webAuthnCeremony{UserID: user.user.ID, Ticket: ticket, Session: *session})
+	if err != nil {
+		return "", nil, err
+	}
+	return ceremonyID, assertion, nil
+}
+
+func (s *webAuthnService) FinishLogin(ceremonyID string, response []byte) (*models.User, error) {
+	ceremony, err := s.takeCeremony(ceremonyID)
+	if err != nil {
+		return nil, err
+	}
+	if ceremony.Registration {
+		return nil, ErrWebAuthnCeremony
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(response))
+	if err != nil {
+		return nil, webAuthnError(err)
+	}
+
+	var user *webAuthnUser
+	var credential *webauthn.Credential
+	if ceremony.UserID == 0 {
+		credential, err = s.webAuthn.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+			id, err := strconv.ParseUint(string(userHandle), 10, 32)
+			if err != nil {
+				return nil, ErrWebAuthnVerification
+			}
+			user, err = s.loadUser(uint(id))
+			return user, err
+		}, ceremony.Session, parsed)
+	} else {
+		if user, err = s.loadUser(ceremony.UserID); err != nil {
+			return nil, err
+		}
+		credential, err = s.webAuthn.ValidateLogin(user, ceremony.Session, parsed)
+	}
+	if err != nil {
+		return nil, webAuthnError(err)
+	}
+
+	stored := user.credential(credential.ID)
+	if stored == nil {
+		return nil, ErrWebAuthnVerification
+	}
+	// 签名计数没有增加说明私钥可能被复制，拒绝登录
+	if credential.Authenticator.CloneWarning {
+		log.Printf("WebAuthn credential %d of user %d reported a non-increasing sign count", stored.ID, user.user.ID)
+		return nil, ErrWebAuthnVerification
+	}
+	if err := s.credentialRepo.UpdateSignCount(stored.ID, credential.Authenticator.SignCount, credential.Flags.BackupState, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if ceremony.Ticket != "" {
+		s.redis.Del(s.ctx, webAuthnTicketKey(ceremony.Ticket))
+	}
+	return user.user, nil
+}
+
+func (s *webAuthnService) loadUser(userID uint) (*webAuthnUser, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	credentials, err := s.credentialRepo.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &webAuthnUser{user: user, credentials: credentials}, nil
+}
+
+func (s *webAuthnService) saveCeremony(ceremony *webAuthnCeremony) (string, error) {
+	id, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(ceremony)
+	if err != nil {
+		return "", err
+	}
+	if err := s.redis.Set(s.ctx, webAuthnCeremonyKey(id), data, WebAuthnCeremonyTTL).Err(); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (s *webAuthnService) takeCeremony(id string) (*webAuthnCeremony, error) {
+	data, err := s.redis.GetDel(s.ctx, webAuthnCeremonyKey(id)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrWebAuthnCeremony
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ceremony webAuthnCeremony
+	if err := json.Unmarshal([]byte(data), &ceremony); err != nil {
+		return nil, err
+	}
+	return &ceremony, nil
+}
+
+// webAuthnUser 把本地用户和已注册的凭据适配为webauthn.User，用户句柄为十进制的用户ID
+type webAuthnUser struct {
+	user        *models.User
+	credentials []models.WebAuthnCredential
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return []byte(strconv.FormatUint(uint64(u.user.ID), 10))
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	return u.user.Username
+}
+
+func (u *webAuthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.credentials))
+	for _, stored := range u.credentials {
+		var transports []protocol.AuthenticatorTransport
+		for _, transport := range strings.Split(stored.Transports, ",") {
+			if transport != "" {
+				transports = append(transports, protocol.AuthenticatorTransport(transport))
+			}
+		}
+		credentials = append(credentials, webauthn.Credential{
+			ID:              stored.CredentialID,
+			PublicKey:       stored.PublicKey,
+			AttestationType: stored.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: stored.BackupEligible,
+				BackupState:    stored.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    stored.AAGUID,
+				SignCount: stored.SignCount,
+			},
+		})
+	}
+	return credentials
+}
+
+func (u *webAuthnUser) credential(id []byte) *models.WebAuthnCredential {
+	for i := range u.credentials {
+		if bytes.Equal(u.credentials[i].CredentialID, id) {
+			return &u.credentials[i]
+		}
+	}
+	return nil
+}
+
+// webAuthnError 把认证器响应解析或校验失败转换为领域错误，其余错误原样返回
+func webAuthnError(err error) error {
+	var protocolErr *protocol.Error
+	if errors.As(err, &protocolErr) {
+		return ErrWebAuthnVerification
+	}
+	return err
+}
+
+func webAuthnCeremonyKey(id string) string {
+	return fmt.Sprintf("webauthn:ceremony:%s", hashAccessToken(id))
+}
+
+func webAuthnTicketKey(ticket string) string {
+	return fmt.Sprintf("webauthn:ticket:%s", hashAccessToken(ticket))
+}
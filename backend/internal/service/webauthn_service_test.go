@@ -0,0 +1,277 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/redis/go-redis/v9"
+	"github.com/user/user-management/internal/config"
+	"github.com/user/user-management/internal/models"
+	"github.com/user/user-management/internal/repository"
+)
+
+const (
+	testRPID     = "example.com"
+	testRPOrigin = "https://example.com"
+)
+
+type memoryWebAuthnCredentialRepository struct {
+	repository.WebAuthnCredentialRepository
+	credentials []models.WebAuthnCredential
+}
+
+func (r *memoryWebAuthnCredentialRepository) Create(credential *models.WebAuthnCredential) error {
+	credential.ID = uint(len(r.credentials) + 1)
+	r.credentials = append(r.credentials, *credential)
+	return nil
+}
+
+func (r *memoryWebAuthnCredentialRepository) ListByUser(userID uint) ([]models.WebAuthnCredential, error) {
+	var credentials []models.WebAuthnCredential
+	for _, credential := range r.credentials {
+		if credential.UserID == userID {
+			credentials = append(credentials, credential)
+		}
+	}
+	return credentials, nil
+}
+
+func (r *memoryWebAuthnCredentialRepository) CountByUser(userID uint) (int64, error) {
+	credentials, _ := r.ListByUser(userID)
+	return int64(len(credentials)), nil
+}
+
+func (r *memoryWebAuthnCredentialRepository) UpdateSignCount(id uint, signCount uint32, backupState bool, usedAt time.Time) error {
+	credential := &r.credentials[id-1]
+	credential.SignCount = signCount
+	credential.BackupState = backupState
+	credential.LastUsedAt = &usedAt
+	return nil
+}
+
+// softAuthenticator 是只支持ES256和none证明的软件认证器
+type softAuthenticator struct {
+	t         *testing.T
+	key       *ecdsa.PrivateKey
+	id        []byte
+	userID    []byte
+	signCount uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	return &softAuthenticator{t: t, key: key, id: id}
+}
+
+func (a *softAuthenticator) clientData(ceremony string, challenge protocol.URLEncodedBase64) []byte {
+	data, _ := json.Marshal(map[string]string{"type": ceremony, "challenge": challenge.String(), "origin": testRPOrigin})
+	return data
+}
+
+func (a *softAuthenticator) authData(attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	// UP和UV
+	flags := byte(0x05)
+	if attested {
+		flags |= 0x40
+	}
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if !attested {
+		return data
+	}
+
+	publicKey, err := webauthncbor.Marshal(map[int]interface{}{
+		1: 2, 3: -7, -1: 1,
+		-2: a.key.X.FillBytes(make([]byte, 32)),
+		-3: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	data = append(data, make([]byte, 16)...)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(a.id)))
+	data = append(data, a.id...)
+	return append(data, publicKey...)
+}
+
+func (a *softAuthenticator) create(creation *protocol.CredentialCreation) []byte {
+	a.userID = creation.Response.User.ID.(protocol.URLEncodedBase64)
+	attestation, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": a.authData(true),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return a.response(map[string]interface{}{
+		"clientDataJSON":    encode(a.clientData("webauthn.create", creation.Response.Challenge)),
+		"attestationObject": encode(attestation),
+		"transports":        []string{"usb"},
+	})
+}
+
+func (a *softAuthenticator) get(assertion *protocol.CredentialAssertion) []byte {
+	a.signCount++
+	clientData := a.clientData("webauthn.get", assertion.Response.Challenge)
+	authData := a.authData(false)
+	digest := sha256.Sum256(append(authData, sha256Sum(clientData)...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return a.response(map[string]interface{}{
+		"clientDataJSON":    encode(clientData),
+		"authenticatorData": encode(authData),
+		"signature":         encode(signature),
+		"userHandle":        encode(a.userID),
+	})
+}
+
+func (a *softAuthenticator) response(response map[string]interface{}) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"id":       encode(a.id),
+		"rawId":    encode(a.id),
+		"type":     "public-key",
+		"response": response,
+	})
+	return data
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func sha256Sum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+func newTestWebAuthnService(t *testing.T) (WebAuthnService, *memoryWebAuthnCredentialRepository, *models.User) {
+	t.Helper()
+
+	users := &memoryUserRepository{}
+	user := &models.User{Username: "alice", Email: "alice@example.com", IsActive: true}
+	users.Create(user)
+	credentials := &memoryWebAuthnCredentialRepository{}
+	redisClient := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+
+	svc, err := NewWebAuthnService(config.WebAuthnConfig{RPID: testRPID, RPName: "Test", Origins: []string{testRPOrigin}}, credentials, users, redisClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc, credentials, user
+}
+
+func registerSoftAuthenticator(t *testing.T, svc WebAuthnService, userID uint) *softAuthenticator {
+	t.Helper()
+
+	ceremonyID, creation, err := svc.BeginRegistration(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator := newSoftAuthenticator(t)
+	if _, err := svc.FinishRegistration(userID, ceremonyID, "laptop", authenticator.create(creation)); err != nil {
+		t.Fatal(err)
+	}
+	return authenticator
+}
+
+func TestWebAuthnSecondFactor(t *testing.T) {
+	svc, credentials, user := newTestWebAuthnService(t)
+
+	if ticket, err := svc.Challenge(user); err != nil || ticket != "" {
+		t.Fatalf("users without security keys must not be challenged, got %q %v", ticket, err)
+	}
+
+	authenticator := registerSoftAuthenticator(t, svc, user.ID)
+	stored := credentials.credentials[0]
+	if stored.Name != "laptop" || stored.Transports != "usb" || len(stored.PublicKey) == 0 {
+		t.Fatalf("unexpected stored credential: %+v", stored)
+	}
+
+	ticket, err := svc.Challenge(user)
+	if err != nil || ticket == "" {
+		t.Fatalf("expected a second factor ticket, got %q %v", ticket, err)
+	}
+	ceremonyID, assertion, err := svc.BeginLogin(ticket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assertion.Response.AllowedCredentials) != 1 {
+		t.Fatalf("expected the registered key to be allowed, got %+v", assertion.Response.AllowedCredentials)
+	}
+	response := authenticator.get(assertion)
+	loggedIn, err := svc.FinishLogin(ceremonyID, response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loggedIn.ID != user.ID || credentials.credentials[0].SignCount != 1 || credentials.credentials[0].LastUsedAt == nil {
+		t.Fatalf("unexpected login result: user=%d credential=%+v", loggedIn.ID, credentials.credentials[0])
+	}
+
+	// 仪式和票据都只能使用一次
+	if _, err := svc.FinishLogin(ceremonyID, response); !errors.Is(err, ErrWebAuthnCeremony) {
+		t.Fatalf("expected replayed ceremony to be rejected, got %v", err)
+	}
+	if _, _, err := svc.BeginLogin(ticket); !errors.Is(err, ErrSecondFactorTicket) {
+		t.Fatalf("expected used ticket to be rejected, got %v", err)
+	}
+}
+
+func TestWebAuthnPasswordlessLogin(t *testing.T) {
+	svc, _, user := newTestWebAuthnService(t)
+	authenticator := registerSoftAuthenticator(t, svc, user.ID)
+
+	ceremonyID, assertion, err := svc.BeginLogin("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assertion.Response.UserVerification != protocol.VerificationRequired || len(assertion.Response.AllowedCredentials) != 0 {
+		t.Fatalf("unexpected discoverable login options: %+v", assertion.Response)
+	}
+	loggedIn, err := svc.FinishLogin(ceremonyID, authenticator.get(assertion))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loggedIn.ID != user.ID {
+		t.Fatalf("expected user %d, got %d", user.ID, loggedIn.ID)
+	}
+
+	// 签名计数没有增加时视为被复制的认证器
+	authenticator.signCount--
+	ceremonyID, assertion, err = svc.BeginLogin("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.FinishLogin(ceremonyID, authenticator.get(assertion)); !errors.Is(err, ErrWebAuthnVerification) {
+		t.Fatalf("expected clone warning to reject the login, got %v", err)
+	}
+
+	// 签名错误的响应
+	ceremonyID, assertion, err = svc.BeginLogin("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator.key, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if _, err := svc.FinishLogin(ceremonyID, authenticator.get(assertion)); !errors.Is(err, ErrWebAuthnVerification) {
+		t.Fatalf("expected invalid signature to be rejected, got %v", err)
+	}
+}
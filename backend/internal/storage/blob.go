@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/user/user-management/internal/config"
+)
+
+// BlobStore 保存可以公开访问的文件（如头像），key是以/分隔的相对路径，由调用方生成
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Delete 删除文件，文件不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+	// URL 返回文件的公开访问地址
+	URL(key string) string
+}
+
+// ErrInvalidKey 表示key为空、以/开头或包含.和..路径段
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// New 按BLOB_STORE选择存储驱动
+func New(cfg config.BlobConfig) (BlobStore, error) {
+	switch cfg.Driver {
+	case config.BlobStoreLocal:
+		publicURL := cfg.PublicURL
+		if publicURL == "" {
+			publicURL = LocalPublicPath
+		}
+		return NewLocalStore(cfg.LocalDir, publicURL)
+	case config.BlobStoreS3:
+		return NewS3Store(cfg.S3, cfg.PublicURL)
+	default:
+		return nil, fmt.Errorf("unsupported BLOB_STORE %q", cfg.Driver)
+	}
+}
+
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." || strings.Contains(segment, `\`) {
+			return false
+		}
+	}
+	return true
+}
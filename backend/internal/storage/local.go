@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalPublicPath 是本地存储的文件由API服务提供访问的路径
+const LocalPublicPath = "/api/v1/blobs"
+
+type localStore struct {
+	dir       string
+	publicURL string
+}
+
+// NewLocalStore 把文件保存在dir下，publicURL是dir对外的访问地址
+func NewLocalStore(dir, publicURL string) (BlobStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &localStore{dir: dir, publicURL: strings.TrimSuffix(publicURL, "/")}, nil
+}
+
+// Put 先写入临时文件再重命名，读取方不会看到写了一半的文件
+func (s *localStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(file.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+func (s *localStore) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+	err := os.Remove(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *localStore) URL(key string) string {
+	return s.publicURL + "/" + key
+}
@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/user/user-management/internal/config"
+)
+
+const (
+	s3Algorithm     = "AWS4-HMAC-SHA256"
+	s3SignedHeaders = "host;x-amz-content-sha256;x-amz-date"
+)
+
+// s3Store 通过REST接口访问S3兼容的对象存储，请求使用Signature Version 4签名
+type s3Store struct {
+	cfg       config.S3Config
+	endpoint  *url.URL
+	publicURL string
+	client    *http.Client
+	now       func() time.Time
+}
+
+// NewS3Store 创建S3存储，publicURL为空时使用对象在Endpoint下的地址
+func NewS3Store(cfg config.S3Config, publicURL string) (BlobStore, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("S3_ENDPOINT and S3_BUCKET are required for the s3 blob store")
+	}
+	endpoint, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid S3_ENDPOINT %q", cfg.Endpoint)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	s := &s3Store{
+		cfg:       cfg,
+		endpoint:  endpoint,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		client:    &http.Client{Timeout: 30 * time.Second},
+		now:       time.Now,
+	}
+	if s.publicURL == "" {
+		s.publicURL = s.bucketURL().String()
+	}
+	return s, nil
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+	req, err := s.newRequest(ctx, http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	return s.do(req, http.StatusOK)
+}
+
+func (s *s3Store) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	// S3删除不存在的对象同样返回204，部分兼容实现返回404
+	return s.do(req, http.StatusNoContent, http.StatusOK, http.StatusNotFound)
+}
+
+func (s *s3Store) URL(key string) string {
+	return s.publicURL + "/" + escapePath(key)
+}
+
+// bucketURL 返回存储桶的地址，PathStyle时为Endpoint/Bucket，否则为Bucket.Endpoint
+func (s *s3Store) bucketURL() *url.URL {
+	u := *s.endpoint
+	if s.cfg.PathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.cfg.Bucket
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+	}
+	return &u
+}
+
+func (s *s3Store) newRequest(ctx context.Context, method, key string, body []byte) (*http.Request, error) {
+	u := s.bucketURL()
+	u.Path += "/" + key
+	u.RawPath = escapePath(u.Path)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	s.sign(req, body)
+	return req, nil
+}
+
+// sign 按Signature Version 4签名，签名覆盖host、x-amz-date和请求体的SHA-256
+func (s *s3Store) sign(req *http.Request, body []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + payloadHash + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		s3SignedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{s3Algorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, s.cfg.AccessKeyID, scope, s3SignedHeaders, signature))
+}
+
+func (s *s3Store) do(req *http.Request, expected ...int) error {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	for _, status := range expected {
+		if resp.StatusCode == status {
+			return nil
+		}
+	}
+	// S3的错误响应是XML，只截取开头部分用于日志
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("s3 %s %s: %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(detail)))
+}
+
+// escapePath 按S3的规则编码路径，除非保留字符和/以外都使用%XX
+func escapePath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c == '/' || c == '-' || c == '_' || c == '.' || c == '~' ||
+			('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/user-management/internal/config"
+)
+
+func TestLocalStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalStore(dir, "/api/v1/blobs/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "avatars/1/a.jpg", []byte("jpeg"), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "avatars", "1", "a.jpg"))
+	if err != nil || string(data) != "jpeg" {
+		t.Fatalf("expected blob to be written, got %q, %v", data, err)
+	}
+	if url := store.URL("avatars/1/a.jpg"); url != "/api/v1/blobs/avatars/1/a.jpg" {
+		t.Fatalf("unexpected URL %s", url)
+	}
+
+	for _, key := range []string{"", "/etc/passwd", "../escape.jpg", "avatars/../../escape.jpg", "avatars//a.jpg"} {
+		if err := store.Put(ctx, key, []byte("x"), "image/jpeg"); err != ErrInvalidKey {
+			t.Fatalf("expected key %q to be rejected, got %v", key, err)
+		}
+	}
+
+	if err := store.Delete(ctx, "avatars/1/a.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "avatars", "1", "a.jpg")); !os.IsNotExist(err) {
+		t.Fatalf("expected blob to be deleted, got %v", err)
+	}
+	// 删除不存在的文件不报错
+	if err := store.Delete(ctx, "avatars/1/a.jpg"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestS3StoreSignsRequests(t *testing.T) {
+	type received struct {
+		method, path, contentType, authorization, date, payloadHash, body string
+	}
+	var requests []received
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, received{
+			method:        r.Method,
+			path:          r.URL.EscapedPath(),
+			contentType:   r.Header.Get("Content-Type"),
+			authorization: r.Header.Get("Authorization"),
+			date:          r.Header.Get("X-Amz-Date"),
+			payloadHash:   r.Header.Get("X-Amz-Content-Sha256"),
+			body:          string(body),
+		})
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	store, err := NewS3Store(config.S3Config{
+		Endpoint:        server.URL,
+		Region:          "eu-west-1",
+		Bucket:          "avatars",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "secret",
+		PathStyle:       true,
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.(*s3Store).now = func() time.Time { return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) }
+
+	ctx := context.Background()
+	if err := store.Put(ctx, "avatars/1/a b.jpg", []byte("jpeg"), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, "avatars/1/a b.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+
+	put := requests[0]
+	if put.method != http.MethodPut || put.path != "/avatars/avatars/1/a%20b.jpg" || put.body != "jpeg" || put.contentType != "image/jpeg" {
+		t.Fatalf("unexpected put request %+v", put)
+	}
+	if put.date != "20240301T120000Z" || put.payloadHash != sha256Hex([]byte("jpeg")) {
+		t.Fatalf("unexpected signing headers %+v", put)
+	}
+	prefix := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20240301/eu-west-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature="
+	if !strings.HasPrefix(put.authorization, prefix) || len(put.authorization) != len(prefix)+64 {
+		t.Fatalf("unexpected authorization %s", put.authorization)
+	}
+	if requests[1].method != http.MethodDelete || requests[1].authorization == put.authorization {
+		t.Fatalf("unexpected delete request %+v", requests[1])
+	}
+
+	if url := store.URL("avatars/1/a b.jpg"); url != server.URL+"/avatars/avatars/1/a%20b.jpg" {
+		t.Fatalf("unexpected URL %s", url)
+	}
+}
+
+func TestS3StoreReportsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, "<Error><Code>SignatureDoesNotMatch</Code></Error>")
+	}))
+	defer server.Close()
+
+	store, err := NewS3Store(config.S3Config{Endpoint: server.URL, Bucket: "avatars", PathStyle: true}, "https://cdn.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Put(context.Background(), "avatars/1/a.jpg", []byte("jpeg"), "image/jpeg")
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Fatalf("expected S3 error to be reported, got %v", err)
+	}
+	if url := store.URL("avatars/1/a.jpg"); url != "https://cdn.example.com/avatars/1/a.jpg" {
+		t.Fatalf("unexpected URL %s", url)
+	}
+}
+
+// TestS3StoreMinIO 在设置S3_TEST_ENDPOINT等环境变量时对真实的MinIO运行，存储桶需预先创建并允许匿名读取，如：
+// S3_TEST_ENDPOINT=http://localhost:9000 S3_TEST_BUCKET=avatars S3_TEST_ACCESS_KEY_ID=minioadmin S3_TEST_SECRET_ACCESS_KEY=minioadmin
+func TestS3StoreMinIO(t *testing.T) {
+	endpoint := os.Getenv("S3_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_TEST_ENDPOINT is not set")
+	}
+	store, err := NewS3Store(config.S3Config{
+		Endpoint:        endpoint,
+		Region:          os.Getenv("S3_TEST_REGION"),
+		Bucket:          os.Getenv("S3_TEST_BUCKET"),
+		AccessKeyID:     os.Getenv("S3_TEST_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("S3_TEST_SECRET_ACCESS_KEY"),
+		PathStyle:       true,
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	key := "test/" + time.Now().Format("20060102150405.000000000") + ".txt"
+	if err := store.Put(ctx, key, []byte("hello"), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(store.URL(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "hello" {
+		t.Fatalf("expected object to be readable, got %s %q", resp.Status, body)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("expected deleting a missing object to succeed, got %v", err)
+	}
+}
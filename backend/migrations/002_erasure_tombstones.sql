@@ -0,0 +1,9 @@
+-- 数据擦除记录表（GDPR）
+CREATE TABLE IF NOT EXISTS `erasure_tombstones` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  `requested_by` bigint unsigned NOT NULL,
+  `erased_at` timestamp NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_erasure_tombstones_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
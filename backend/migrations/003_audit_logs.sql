@@ -0,0 +1,41 @@
+-- 用户角色
+ALTER TABLE `users` ADD COLUMN `role` varchar(20) NOT NULL DEFAULT 'user' AFTER `is_active`;
+
+-- 审计日志表（只允许追加，哈希链防篡改）
+CREATE TABLE IF NOT EXISTS `audit_logs` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `actor_id` bigint unsigned NULL,
+  `target_id` bigint unsigned NULL,
+  `action` varchar(50) NOT NULL,
+  `ip_address` varchar(45),
+  `user_agent` varchar(255),
+  `request_id` varchar(64),
+  `changes` text,
+  `metadata` text,
+  `prev_hash` varchar(64) NOT NULL,
+  `hash` varchar(64) NOT NULL,
+  `created_at` timestamp NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_audit_logs_hash` (`hash`),
+  KEY `idx_audit_logs_actor_id` (`actor_id`),
+  KEY `idx_audit_logs_target_id` (`target_id`),
+  KEY `idx_audit_logs_action` (`action`),
+  KEY `idx_audit_logs_created_at` (`created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- 哈希链链头，追加审计日志时加行锁
+CREATE TABLE IF NOT EXISTS `audit_chain_heads` (
+  `id` bigint unsigned NOT NULL,
+  `last_id` bigint unsigned NOT NULL DEFAULT 0,
+  `last_hash` varchar(64) NOT NULL DEFAULT '',
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+INSERT INTO `audit_chain_heads` (`id`, `last_id`, `last_hash`) VALUES (1, 0, '');
+
+-- 禁止修改和删除审计日志
+CREATE TRIGGER `audit_logs_no_update` BEFORE UPDATE ON `audit_logs`
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_logs is append-only';
+
+CREATE TRIGGER `audit_logs_no_delete` BEFORE DELETE ON `audit_logs`
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_logs is append-only';
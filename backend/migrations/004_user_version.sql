@@ -0,0 +1,2 @@
+-- 用户版本号（乐观锁，用于ETag/If-Match）
+ALTER TABLE `users` ADD COLUMN `version` int unsigned NOT NULL DEFAULT 1 AFTER `role`;
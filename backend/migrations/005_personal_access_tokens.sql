@@ -0,0 +1,18 @@
+-- 个人访问令牌表，只保存令牌哈希
+CREATE TABLE IF NOT EXISTS `personal_access_tokens` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  `name` varchar(100) NOT NULL,
+  `token_prefix` varchar(16) NOT NULL,
+  `token_hash` varchar(64) NOT NULL,
+  `scopes` varchar(255) NOT NULL,
+  `expires_at` timestamp NULL DEFAULT NULL,
+  `last_used_at` timestamp NULL DEFAULT NULL,
+  `revoked_at` timestamp NULL DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_personal_access_tokens_token_hash` (`token_hash`),
+  KEY `idx_personal_access_tokens_user_id` (`user_id`),
+  KEY `idx_personal_access_tokens_revoked_at` (`revoked_at`),
+  CONSTRAINT `fk_personal_access_tokens_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
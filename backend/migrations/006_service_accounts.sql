@@ -0,0 +1,18 @@
+-- 服务账号表（机器对机器调用，OAuth2 client_credentials）
+CREATE TABLE IF NOT EXISTS `service_accounts` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `name` varchar(100) NOT NULL,
+  `description` varchar(255),
+  `client_id` varchar(64) NOT NULL,
+  `client_secret_hash` varchar(64) NOT NULL,
+  `scopes` varchar(255) NOT NULL,
+  `is_active` boolean DEFAULT true,
+  `created_by` bigint unsigned NOT NULL,
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` timestamp DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  `deleted_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_service_accounts_name` (`name`),
+  UNIQUE KEY `idx_service_accounts_client_id` (`client_id`),
+  KEY `idx_service_accounts_deleted_at` (`deleted_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
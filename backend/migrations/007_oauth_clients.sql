@@ -0,0 +1,30 @@
+-- OpenID Connect客户端表
+CREATE TABLE IF NOT EXISTS `oauth_clients` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `name` varchar(100) NOT NULL,
+  `client_id` varchar(64) NOT NULL,
+  `client_secret_hash` varchar(64),
+  `redirect_uris` text NOT NULL,
+  `scopes` varchar(255) NOT NULL,
+  `public` boolean NOT NULL DEFAULT false,
+  `created_by` bigint unsigned NOT NULL,
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` timestamp DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  `deleted_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_oauth_clients_client_id` (`client_id`),
+  KEY `idx_oauth_clients_deleted_at` (`deleted_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- 用户对客户端的授权记录
+CREATE TABLE IF NOT EXISTS `oauth_consents` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  `client_id` varchar(64) NOT NULL,
+  `scopes` varchar(255) NOT NULL,
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` timestamp DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_oauth_consents_user_client` (`user_id`, `client_id`),
+  CONSTRAINT `fk_oauth_consents_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,14 @@
+-- 外部身份提供方账号与本地用户的关联表
+CREATE TABLE IF NOT EXISTS `linked_identities` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  `issuer` varchar(255) NOT NULL,
+  `subject` varchar(255) NOT NULL,
+  `email` varchar(100),
+  `last_login_at` timestamp NULL DEFAULT NULL,
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_linked_identities_issuer_subject` (`issuer`, `subject`),
+  KEY `idx_linked_identities_user_id` (`user_id`),
+  CONSTRAINT `fk_linked_identities_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
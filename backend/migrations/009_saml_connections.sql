@@ -0,0 +1,19 @@
+-- 组织的SAML身份提供方配置表
+CREATE TABLE IF NOT EXISTS `saml_connections` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `slug` varchar(64) NOT NULL,
+  `name` varchar(100) NOT NULL,
+  `idp_entity_id` varchar(255) NOT NULL,
+  `idp_metadata` text NOT NULL,
+  `email_domains` varchar(255) NOT NULL,
+  `email_attribute` varchar(255),
+  `username_attribute` varchar(255),
+  `is_active` boolean DEFAULT true,
+  `created_by` bigint unsigned NOT NULL,
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` timestamp DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  `deleted_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_saml_connections_slug` (`slug`),
+  KEY `idx_saml_connections_deleted_at` (`deleted_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
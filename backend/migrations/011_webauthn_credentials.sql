@@ -0,0 +1,20 @@
+-- 用户注册的WebAuthn凭据表
+CREATE TABLE IF NOT EXISTS `webauthn_credentials` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  `name` varchar(100) NOT NULL,
+  `credential_id` varbinary(255) NOT NULL,
+  `public_key` blob NOT NULL,
+  `attestation_type` varchar(32),
+  `aaguid` binary(16),
+  `sign_count` int unsigned NOT NULL DEFAULT 0,
+  `transports` varchar(255),
+  `backup_eligible` boolean NOT NULL DEFAULT false,
+  `backup_state` boolean NOT NULL DEFAULT false,
+  `last_used_at` timestamp NULL DEFAULT NULL,
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_webauthn_credentials_credential_id` (`credential_id`),
+  KEY `idx_webauthn_credentials_user_id` (`user_id`),
+  CONSTRAINT `fk_webauthn_credentials_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,10 @@
+-- 用户先前使用过的密码哈希，只保留策略要求的最近几个
+CREATE TABLE IF NOT EXISTS `password_histories` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  `password_hash` varchar(255) NOT NULL,
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_password_histories_user_id` (`user_id`),
+  CONSTRAINT `fk_password_histories_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
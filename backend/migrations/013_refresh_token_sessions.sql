@@ -0,0 +1,5 @@
+-- 刷新令牌记录所属的登录会话和该次登录的认证时间，用于再次认证和撤销其他会话
+ALTER TABLE `refresh_tokens`
+  ADD COLUMN `session_id` varchar(32) NOT NULL DEFAULT '' AFTER `token`,
+  ADD COLUMN `auth_time` timestamp NULL DEFAULT NULL AFTER `session_id`,
+  ADD KEY `idx_refresh_tokens_session_id` (`session_id`);
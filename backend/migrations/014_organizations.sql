@@ -0,0 +1,47 @@
+-- 组织（租户）、成员关系和邀请表
+CREATE TABLE IF NOT EXISTS `organizations` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `slug` varchar(64) NOT NULL,
+  `name` varchar(100) NOT NULL,
+  `created_by` bigint unsigned NOT NULL,
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` timestamp DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  `deleted_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_organizations_slug` (`slug`),
+  KEY `idx_organizations_deleted_at` (`deleted_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `organization_members` (
+  `organization_id` bigint unsigned NOT NULL,
+  `user_id` bigint unsigned NOT NULL,
+  `role` varchar(20) NOT NULL DEFAULT 'member',
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`organization_id`, `user_id`),
+  KEY `idx_organization_members_user_id` (`user_id`),
+  CONSTRAINT `fk_organization_members_organization` FOREIGN KEY (`organization_id`) REFERENCES `organizations` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `fk_organization_members_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `organization_invitations` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `organization_id` bigint unsigned NOT NULL,
+  `email` varchar(100) NOT NULL,
+  `role` varchar(20) NOT NULL,
+  `token_hash` varchar(64) NOT NULL,
+  `invited_by` bigint unsigned NOT NULL,
+  `expires_at` timestamp NOT NULL,
+  `accepted_at` timestamp NULL DEFAULT NULL,
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_organization_invitations_token_hash` (`token_hash`),
+  KEY `idx_organization_invitations_organization_id` (`organization_id`),
+  CONSTRAINT `fk_organization_invitations_organization` FOREIGN KEY (`organization_id`) REFERENCES `organizations` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- 刷新令牌和个人访问令牌记录签发时所在的组织，0表示不属于任何组织
+ALTER TABLE `refresh_tokens`
+  ADD COLUMN `organization_id` bigint unsigned NOT NULL DEFAULT 0 AFTER `auth_time`;
+
+ALTER TABLE `personal_access_tokens`
+  ADD COLUMN `organization_id` bigint unsigned NOT NULL DEFAULT 0 AFTER `user_id`;
@@ -0,0 +1,10 @@
+-- 组的嵌套关系，子组的成员同时属于父组
+CREATE TABLE IF NOT EXISTS `subgroups` (
+  `parent_id` bigint unsigned NOT NULL,
+  `child_id` bigint unsigned NOT NULL,
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`parent_id`, `child_id`),
+  KEY `idx_subgroups_child_id` (`child_id`),
+  CONSTRAINT `fk_subgroups_parent` FOREIGN KEY (`parent_id`) REFERENCES `groups` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `fk_subgroups_child` FOREIGN KEY (`child_id`) REFERENCES `groups` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,20 @@
+-- 注册邀请表，REGISTRATION_MODE=invitation时只能通过邀请注册
+CREATE TABLE IF NOT EXISTS `user_invitations` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `email` varchar(100) NOT NULL,
+  `role` varchar(20) NOT NULL DEFAULT 'user',
+  `organization_id` bigint unsigned DEFAULT NULL,
+  `organization_role` varchar(20) DEFAULT NULL,
+  `token_hash` varchar(64) NOT NULL,
+  `invited_by` bigint unsigned NOT NULL,
+  `expires_at` timestamp NOT NULL,
+  `accepted_at` timestamp NULL DEFAULT NULL,
+  `user_id` bigint unsigned DEFAULT NULL,
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` timestamp DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_user_invitations_token_hash` (`token_hash`),
+  KEY `idx_user_invitations_email` (`email`),
+  KEY `idx_user_invitations_organization_id` (`organization_id`),
+  CONSTRAINT `fk_user_invitations_organization` FOREIGN KEY (`organization_id`) REFERENCES `organizations` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
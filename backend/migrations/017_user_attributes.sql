@@ -0,0 +1,18 @@
+-- 管理员定义的扩展资料属性，属性值以JSON保存在users.attributes
+CREATE TABLE IF NOT EXISTS `attribute_definitions` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `name` varchar(50) NOT NULL,
+  `label` varchar(100) NOT NULL,
+  `type` varchar(20) NOT NULL,
+  `required` tinyint(1) NOT NULL DEFAULT 0,
+  `visibility` varchar(20) NOT NULL DEFAULT 'self_editable',
+  `pattern` varchar(255) DEFAULT NULL,
+  `max_length` int NOT NULL DEFAULT 0,
+  `options` text,
+  `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` timestamp DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `idx_attribute_definitions_name` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+ALTER TABLE `users` ADD COLUMN `attributes` json NULL AFTER `role`;
@@ -0,0 +1,5 @@
+-- 头像保存在BlobStore中，avatar_key是头像的key，缩略图的key由其派生
+ALTER TABLE `users`
+  ADD COLUMN `avatar_key` varchar(255) NOT NULL DEFAULT '' AFTER `attributes`,
+  ADD COLUMN `avatar_url` varchar(500) NOT NULL DEFAULT '' AFTER `avatar_key`,
+  ADD COLUMN `avatar_thumbnail_url` varchar(500) NOT NULL DEFAULT '' AFTER `avatar_url`;
@@ -9,7 +9,23 @@ import type {
   RefreshTokenResponse,
   UpdateUserRequest,
   UsersListResponse,
-  User
+  User,
+  SecondFactorResponse,
+  WebAuthnCeremony,
+  WebAuthnCredential,
+  EmailChangeConfirmResponse,
+  ImpersonationResponse,
+  OrganizationRole,
+  OrganizationListResponse,
+  OrganizationSessionResponse,
+  UserInvitation,
+  CreateUserInvitationRequest,
+  UserInvitationLookup,
+  AttributeDefinition,
+  AttributeDefinitionRequest,
+  OrganizationMember,
+  OrganizationInvitation,
+  UserGroup
 } from '@/types/user'
 
 // 创建axios实例
@@ -17,7 +33,9 @@ const api: AxiosInstance = axios.create({
   baseURL: '/api/v1',
   timeout: 10000,
   headers: {
-    'Content-Type': 'application/json'
+    'Content-Type': 'application/json',
+    // 后端按 Accept-Language 返回本地化的错误信息
+    'Accept-Language': 'zh-CN'
   }
 })
 
@@ -42,10 +60,17 @@ api.interceptors.response.use(
   },
   async error => {
     const userStore = useUserStore()
+    // 后端错误响应为 RFC 7807 problem+json：detail 为提示信息，errors 为字段校验详情
+    const problem = error.response?.data
     
     if (error.response?.status === 401) {
-      // Token过期，尝试刷新
-      if (userStore.refreshToken) {
+      // 模拟令牌不能刷新，过期后回到管理员自己的登录
+      if (userStore.isImpersonating) {
+        userStore.restoreImpersonator()
+        router.push('/users')
+        ElMessage.warning('模拟用户已过期')
+      } else if (userStore.refreshToken) {
+        // Token过期，尝试刷新
         try {
           await userStore.refreshTokens()
           // 重新发送原请求
@@ -62,8 +87,10 @@ api.interceptors.response.use(
       }
     } else if (error.response?.status >= 500) {
       ElMessage.error('服务器错误，请稍后重试')
-    } else if (error.response?.data?.message) {
-      ElMessage.error(error.response.data.message)
+    } else if (problem?.errors?.length) {
+      ElMessage.error(problem.errors.map((fieldError: { message: string }) => fieldError.message).join('; '))
+    } else if (problem?.detail) {
+      ElMessage.error(problem.detail)
     }
     
     return Promise.reject(error)
@@ -73,19 +100,106 @@ api.interceptors.response.use(
 // 认证相关API
 export const authAPI = {
   register: (data: RegisterRequest) => api.post<User>('/auth/register', data),
-  login: (data: LoginRequest) => api.post<LoginResponse>('/auth/login', data),
+  // mode 为 invitation 时只能通过邀请链接注册
+  registration: () => api.get<{ mode: 'open' | 'invitation' }>('/auth/registration'),
+  lookupInvitation: (token: string) => api.post<UserInvitationLookup>('/auth/invitations/lookup', { token }),
+  acceptInvitation: (token: string, username: string, password: string) =>
+    api.post<User>('/auth/invitations/accept', { token, username, password }),
+  // 已注册安全密钥的账号返回 202 和第二因素票据
+  login: (data: LoginRequest) => api.post<LoginResponse | SecondFactorResponse>('/auth/login', data),
   logout: () => api.post<{ message: string }>('/auth/logout'),
-  refreshToken: (refreshToken: string) => api.post<RefreshTokenResponse>('/auth/refresh', { refresh_token: refreshToken })
+  refreshToken: (refreshToken: string) => api.post<RefreshTokenResponse>('/auth/refresh', { refresh_token: refreshToken }),
+  // 邮件登录链接，Cookie 把链接绑定到当前浏览器
+  requestMagicLink: (email: string) => api.post<{ message: string }>('/auth/magic-link', { email }),
+  consumeMagicLink: (token: string) => api.post<LoginResponse | SecondFactorResponse>('/auth/magic-link/consume', { token }),
+  // 外部身份提供方登录
+  federationProvider: () => api.get<{ enabled: boolean; name: string }>('/auth/oidc'),
+  federationExchange: (code: string) => api.post<LoginResponse>('/auth/oidc/exchange', { code }),
+  // 修改邮箱的确认链接，新旧地址都确认后才生效
+  confirmEmailChange: (token: string) => api.post<EmailChangeConfirmResponse>('/auth/email-change/confirm', { token }),
+  // 使用模拟令牌调用，结束管理员模拟用户
+  endImpersonation: () => api.post<{ message: string }>('/auth/impersonation/end')
 }
 
 // 用户相关API
 export const userAPI = {
-  getUsers: (params?: { page?: number; limit?: number }) => api.get<UsersListResponse>('/users', { params }),
+  // attributes按扩展属性值过滤，例如 { department: 'eng' }
+  getUsers: (params?: { page?: number; limit?: number; attributes?: Record<string, string> }) =>
+    api.get<UsersListResponse>('/users', {
+      params: {
+        page: params?.page,
+        limit: params?.limit,
+        ...Object.fromEntries(Object.entries(params?.attributes || {}).map(([name, value]) => [`attributes[${name}]`, value]))
+      }
+    }),
   getUser: (id: number) => api.get<User>(`/users/${id}`),
-  updateUser: (id: number, data: UpdateUserRequest) => api.put<User>(`/users/${id}`, data),
+  // 有效组，包括通过子组间接所属的组
+  getGroups: (id: number) => api.get<{ groups: UserGroup[] }>(`/users/${id}/groups`),
+  updateUser: (id: number, data: UpdateUserRequest, version?: number) =>
+    api.put<User>(`/users/${id}`, data, version ? { headers: { 'If-Match': `"${version}"` } } : undefined),
   deleteUser: (id: number) => api.delete<{ message: string }>(`/users/${id}`),
   getProfile: () => api.get<User>('/users/profile'),
-  updateProfile: (data: UpdateUserRequest) => api.put<User>('/users/profile', data)
+  updateProfile: (data: UpdateUserRequest) => api.put<User>('/users/profile', data),
+  // 以multipart表单上传头像，Content-Type由浏览器补充boundary
+  uploadAvatar: (file: File) => {
+    const form = new FormData()
+    form.append('avatar', file)
+    return api.put<User>('/users/profile/avatar', form, {
+      headers: { 'Content-Type': 'multipart/form-data' }
+    })
+  },
+  deleteAvatar: () => api.delete<User>('/users/profile/avatar'),
+  listAttributes: () => api.get<{ attributes: AttributeDefinition[] }>('/users/attributes')
+}
+
+// 管理员相关API
+export const adminAPI = {
+  impersonate: (id: number) => api.post<ImpersonationResponse>(`/admin/users/${id}/impersonate`),
+  // 注册邀请，重新发送后之前的链接失效
+  listInvitations: () => api.get<{ invitations: UserInvitation[] }>('/admin/invitations'),
+  invite: (data: CreateUserInvitationRequest) => api.post<UserInvitation>('/admin/invitations', data),
+  resendInvitation: (id: number) => api.post<UserInvitation>(`/admin/invitations/${id}/resend`),
+  revokeInvitation: (id: number) => api.delete<{ message: string }>(`/admin/invitations/${id}`),
+  // 扩展属性定义，删除时同时移除所有用户的该属性值
+  createAttribute: (data: AttributeDefinitionRequest) => api.post<AttributeDefinition>('/admin/attributes', data),
+  updateAttribute: (id: number, data: AttributeDefinitionRequest) => api.put<AttributeDefinition>(`/admin/attributes/${id}`, data),
+  deleteAttribute: (id: number) => api.delete<{ message: string }>(`/admin/attributes/${id}`)
+}
+
+// 组织相关API，创建、加入和切换组织返回新令牌
+export const organizationAPI = {
+  list: () => api.get<OrganizationListResponse>('/organizations'),
+  create: (slug: string, name: string) => api.post<OrganizationSessionResponse>('/organizations', { slug, name }),
+  switch: (id: number) => api.post<OrganizationSessionResponse>(`/organizations/${id}/switch`),
+  remove: (id: number) => api.delete<{ message: string }>(`/organizations/${id}`),
+  listMembers: (id: number) => api.get<{ members: OrganizationMember[] }>(`/organizations/${id}/members`),
+  updateMemberRole: (id: number, userId: number, role: OrganizationRole) =>
+    api.patch<{ message: string }>(`/organizations/${id}/members/${userId}`, { role }),
+  removeMember: (id: number, userId: number) => api.delete<{ message: string }>(`/organizations/${id}/members/${userId}`),
+  listInvitations: (id: number) => api.get<{ invitations: OrganizationInvitation[] }>(`/organizations/${id}/invitations`),
+  invite: (id: number, email: string, role: OrganizationRole) =>
+    api.post<OrganizationInvitation>(`/organizations/${id}/invitations`, { email, role }),
+  revokeInvitation: (id: number, invitationId: number) =>
+    api.delete<{ message: string }>(`/organizations/${id}/invitations/${invitationId}`),
+  acceptInvitation: (token: string) => api.post<OrganizationSessionResponse>('/organizations/invitations/accept', { token })
+}
+
+// 安全密钥（WebAuthn）相关API，credential 为 navigator.credentials 返回值转换后的 JSON
+export const webauthnAPI = {
+  beginLogin: (ticket?: string) => api.post<WebAuthnCeremony>('/auth/webauthn/login/begin', { ticket }),
+  finishLogin: (ceremonyId: string, credential: object) =>
+    api.post<LoginResponse>('/auth/webauthn/login/finish', { ceremony_id: ceremonyId, credential }),
+  listCredentials: () => api.get<{ credentials: WebAuthnCredential[] }>('/users/profile/webauthn'),
+  beginRegistration: () => api.post<WebAuthnCeremony>('/users/profile/webauthn/register/begin'),
+  finishRegistration: (ceremonyId: string, name: string, credential: object) =>
+    api.post<WebAuthnCredential>('/users/profile/webauthn/register/finish', { ceremony_id: ceremonyId, name, credential }),
+  deleteCredential: (id: number) => api.delete<{ message: string }>(`/users/profile/webauthn/${id}`)
+}
+
+// OpenID Connect 授权同意相关API，参数为客户端发起授权时的原始查询参数
+export const oauthAPI = {
+  getAuthorization: (params: Record<string, string>) => api.get('/oauth/authorize', { params }),
+  decide: (params: Record<string, string>, approve: boolean) => api.post('/oauth/authorize', { ...params, approve })
 }
 
 export default api
\ No newline at end of file
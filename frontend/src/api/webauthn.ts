@@ -0,0 +1,62 @@
+// WebAuthn 的二进制字段在后端 JSON 中为 base64url，调用浏览器 API 前后需要转换
+
+const toBuffer = (value: string): ArrayBuffer => {
+  const base64 = value.replace(/-/g, '+').replace(/_/g, '/')
+  const binary = atob(base64 + '='.repeat((4 - (base64.length % 4)) % 4))
+  return Uint8Array.from(binary, c => c.charCodeAt(0)).buffer
+}
+
+const toBase64URL = (buffer: ArrayBuffer | null): string | null => {
+  if (!buffer) return null
+  const binary = String.fromCharCode(...new Uint8Array(buffer))
+  return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '')
+}
+
+const withCredentialIDs = (credentials?: any[]) =>
+  credentials?.map(credential => ({ ...credential, id: toBuffer(credential.id) }))
+
+// 调用 navigator.credentials.create() 注册新的安全密钥
+export const createCredential = async (options: any) => {
+  const credential = await navigator.credentials.create({
+    publicKey: {
+      ...options,
+      challenge: toBuffer(options.challenge),
+      user: { ...options.user, id: toBuffer(options.user.id) },
+      excludeCredentials: withCredentialIDs(options.excludeCredentials)
+    }
+  }) as PublicKeyCredential
+  const response = credential.response as AuthenticatorAttestationResponse
+  return {
+    id: credential.id,
+    rawId: toBase64URL(credential.rawId),
+    type: credential.type,
+    response: {
+      clientDataJSON: toBase64URL(response.clientDataJSON),
+      attestationObject: toBase64URL(response.attestationObject),
+      transports: response.getTransports?.() ?? []
+    }
+  }
+}
+
+// 调用 navigator.credentials.get() 生成登录断言
+export const getAssertion = async (options: any) => {
+  const credential = await navigator.credentials.get({
+    publicKey: {
+      ...options,
+      challenge: toBuffer(options.challenge),
+      allowCredentials: withCredentialIDs(options.allowCredentials)
+    }
+  }) as PublicKeyCredential
+  const response = credential.response as AuthenticatorAssertionResponse
+  return {
+    id: credential.id,
+    rawId: toBase64URL(credential.rawId),
+    type: credential.type,
+    response: {
+      clientDataJSON: toBase64URL(response.clientDataJSON),
+      authenticatorData: toBase64URL(response.authenticatorData),
+      signature: toBase64URL(response.signature),
+      userHandle: toBase64URL(response.userHandle)
+    }
+  }
+}
@@ -18,12 +18,40 @@ const routes: RouteRecordRaw[] = [
     component: () => import('@/views/LoginView.vue'),
     meta: { requiresAuth: false }
   },
+  {
+    // 外部身份提供方登录完成后由后端跳转回来
+    path: '/login/callback',
+    name: 'login-callback',
+    component: () => import('@/views/LoginCallbackView.vue'),
+    meta: { requiresAuth: false }
+  },
+  {
+    // 邮件中的一次性登录链接
+    path: '/login/magic',
+    name: 'login-magic',
+    component: () => import('@/views/MagicLinkView.vue'),
+    meta: { requiresAuth: false }
+  },
+  {
+    // 邮件中修改邮箱的确认链接，无需登录
+    path: '/email-change',
+    name: 'email-change',
+    component: () => import('@/views/EmailChangeView.vue'),
+    meta: { requiresAuth: false }
+  },
   {
     path: '/register',
     name: 'register',
     component: () => import('@/views/RegisterView.vue'),
     meta: { requiresAuth: false }
   },
+  {
+    // 邮件中的注册邀请链接，设置用户名和密码完成注册
+    path: '/register/invitation',
+    name: 'register-invitation',
+    component: () => import('@/views/RegisterInvitationView.vue'),
+    meta: { requiresAuth: false }
+  },
   {
     path: '/users',
     name: 'users',
@@ -35,6 +63,26 @@ const routes: RouteRecordRaw[] = [
     name: 'profile',
     component: () => import('@/views/ProfileView.vue'),
     meta: { requiresAuth: true }
+  },
+  {
+    path: '/organization',
+    name: 'organization',
+    component: () => import('@/views/OrganizationView.vue'),
+    meta: { requiresAuth: true }
+  },
+  {
+    // 邮件中的组织邀请链接，未登录时先登录再回到此页
+    path: '/invitations/accept',
+    name: 'invitation-accept',
+    component: () => import('@/views/InvitationAcceptView.vue'),
+    meta: { requiresAuth: true }
+  },
+  {
+    // OpenID Connect 授权端点，未登录时先登录再回到此页
+    path: '/oauth/authorize',
+    name: 'oauth-authorize',
+    component: () => import('@/views/OAuthConsentView.vue'),
+    meta: { requiresAuth: true }
   }
 ]
 
@@ -48,7 +96,7 @@ router.beforeEach((to, from, next) => {
   const userStore = useUserStore()
   
   if (to.meta.requiresAuth && !userStore.isAuthenticated) {
-    next('/login')
+    next({ path: '/login', query: { redirect: to.fullPath } })
   } else if (!to.meta.requiresAuth && userStore.isAuthenticated && (to.path === '/login' || to.path === '/register')) {
     next('/users')
   } else {
@@ -1,18 +1,60 @@
 import { defineStore } from 'pinia'
 import { ref, computed } from 'vue'
-import { authAPI, userAPI } from '@/api'
-import type { User, LoginRequest, RegisterRequest, UpdateUserRequest } from '@/types/user'
+import { authAPI, userAPI, webauthnAPI, adminAPI, organizationAPI } from '@/api'
+import { getAssertion } from '@/api/webauthn'
+import type { User, LoginRequest, LoginResponse, RegisterRequest, UpdateUserRequest, SecondFactorResponse, Organization, OrganizationSessionResponse } from '@/types/user'
 
 export const useUserStore = defineStore('user', () => {
   const user = ref<User | null>(null)
   const token = ref<string>(localStorage.getItem('token') || '')
   const refreshToken = ref<string>(localStorage.getItem('refreshToken') || '')
+  // 模拟用户期间保存管理员自己的令牌，结束模拟后恢复
+  const impersonatorToken = ref<string>(localStorage.getItem('impersonatorToken') || '')
+  // 用户加入的组织，当前组织决定用户列表中能看到哪些用户
+  const organizations = ref<Organization[]>([])
+  const currentOrganizationId = ref<number>(0)
 
   const isAuthenticated = computed(() => !!token.value)
+  const isImpersonating = computed(() => !!impersonatorToken.value)
+  const currentOrganization = computed(() =>
+    organizations.value.find(organization => organization.id === currentOrganizationId.value) || null
+  )
 
   const login = async (credentials: LoginRequest) => {
     const response = await authAPI.login(credentials)
-    const { token: newToken, refresh_token, user: userData } = response.data
+    // 密码正确但还需要安全密钥验证
+    if (response.status === 202) {
+      await loginWithPasskey((response.data as SecondFactorResponse).ticket)
+      return
+    }
+    setSession(response.data as LoginResponse)
+  }
+
+  const loginWithMagicLink = async (token: string) => {
+    const response = await authAPI.consumeMagicLink(token)
+    if (response.status === 202) {
+      await loginWithPasskey((response.data as SecondFactorResponse).ticket)
+      return
+    }
+    setSession(response.data as LoginResponse)
+  }
+
+  // 不带票据时为通行密钥无密码登录，带票据时完成密码登录的第二步
+  const loginWithPasskey = async (ticket?: string) => {
+    const ceremony = await webauthnAPI.beginLogin(ticket)
+    const credential = await getAssertion(ceremony.data.publicKey)
+    const response = await webauthnAPI.finishLogin(ceremony.data.ceremony_id, credential)
+    setSession(response.data)
+  }
+
+  // 外部身份提供方登录回调后，用一次性登录码换取令牌
+  const loginWithCode = async (code: string) => {
+    const response = await authAPI.federationExchange(code)
+    setSession(response.data)
+  }
+
+  const setSession = (data: LoginResponse) => {
+    const { token: newToken, refresh_token, user: userData } = data
     
     token.value = newToken
     refreshToken.value = refresh_token
@@ -27,13 +69,52 @@ export const useUserStore = defineStore('user', () => {
     return response.data
   }
 
+  const startImpersonation = async (userId: number) => {
+    const response = await adminAPI.impersonate(userId)
+    impersonatorToken.value = token.value
+    localStorage.setItem('impersonatorToken', token.value)
+    localStorage.setItem('impersonatorRefreshToken', refreshToken.value)
+
+    token.value = response.data.token
+    refreshToken.value = ''
+    user.value = response.data.user
+    localStorage.setItem('token', response.data.token)
+    localStorage.removeItem('refreshToken')
+  }
+
+  const restoreImpersonator = () => {
+    token.value = impersonatorToken.value
+    refreshToken.value = localStorage.getItem('impersonatorRefreshToken') || ''
+    user.value = null
+    impersonatorToken.value = ''
+    localStorage.setItem('token', token.value)
+    localStorage.setItem('refreshToken', refreshToken.value)
+    localStorage.removeItem('impersonatorToken')
+    localStorage.removeItem('impersonatorRefreshToken')
+  }
+
+  const endImpersonation = async () => {
+    try {
+      await authAPI.endImpersonation()
+    } finally {
+      restoreImpersonator()
+    }
+    await fetchProfile()
+  }
+
   const logout = async () => {
+    // 模拟令牌不能退出登录，先回到管理员自己的会话
+    if (isImpersonating.value) {
+      await endImpersonation().catch(() => {})
+    }
     try {
       await authAPI.logout()
     } finally {
       token.value = ''
       refreshToken.value = ''
       user.value = null
+      organizations.value = []
+      currentOrganizationId.value = 0
       localStorage.removeItem('token')
       localStorage.removeItem('refreshToken')
     }
@@ -44,9 +125,52 @@ export const useUserStore = defineStore('user', () => {
     user.value = response.data
   }
 
+  // 修改邮箱时返回 202，新邮箱要等新旧地址都确认后才生效，返回值表示是否在等待确认
   const updateProfile = async (updates: UpdateUserRequest) => {
     const response = await userAPI.updateProfile(updates)
     user.value = response.data
+    return response.status === 202
+  }
+
+  const uploadAvatar = async (file: File) => {
+    const response = await userAPI.uploadAvatar(file)
+    user.value = response.data
+  }
+
+  const deleteAvatar = async () => {
+    const response = await userAPI.deleteAvatar()
+    user.value = response.data
+  }
+
+  const fetchOrganizations = async () => {
+    const response = await organizationAPI.list()
+    organizations.value = response.data.organizations
+    currentOrganizationId.value = response.data.current_organization_id
+  }
+
+  // 创建、加入或切换组织后旧的访问令牌失效，换用响应中的新令牌
+  const enterOrganization = async (data: OrganizationSessionResponse) => {
+    token.value = data.token
+    refreshToken.value = data.refresh_token
+    localStorage.setItem('token', data.token)
+    localStorage.setItem('refreshToken', data.refresh_token)
+    await fetchOrganizations()
+  }
+
+  const createOrganization = async (slug: string, name: string) => {
+    const response = await organizationAPI.create(slug, name)
+    await enterOrganization(response.data)
+  }
+
+  const switchOrganization = async (id: number) => {
+    const response = await organizationAPI.switch(id)
+    await enterOrganization(response.data)
+  }
+
+  const acceptInvitation = async (invitationToken: string) => {
+    const response = await organizationAPI.acceptInvitation(invitationToken)
+    await enterOrganization(response.data)
+    return response.data.organization
   }
 
   const refreshTokens = async () => {
@@ -64,11 +188,27 @@ export const useUserStore = defineStore('user', () => {
     user,
     token,
     isAuthenticated,
+    isImpersonating,
+    organizations,
+    currentOrganizationId,
+    currentOrganization,
     login,
+    loginWithPasskey,
+    loginWithMagicLink,
+    loginWithCode,
     register,
     logout,
     fetchProfile,
     updateProfile,
-    refreshTokens
+    uploadAvatar,
+    deleteAvatar,
+    refreshTokens,
+    fetchOrganizations,
+    createOrganization,
+    switchOrganization,
+    acceptInvitation,
+    startImpersonation,
+    endImpersonation,
+    restoreImpersonator
   }
 })
\ No newline at end of file
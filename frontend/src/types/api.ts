@@ -5,9 +5,23 @@ export interface ApiResponse<T = any> {
   message?: string
 }
 
+export interface ApiFieldError {
+  field: string
+  code: string
+  message: string
+  param?: string
+}
+
+// 后端统一返回 RFC 7807 application/problem+json
 export interface ApiError {
-  error: string
-  message?: string
+  type: string
+  title: string
+  status: number
+  detail?: string
+  instance?: string
+  code: string
+  request_id?: string
+  errors?: ApiFieldError[]
 }
 
 export type ApiErrorResponse = AxiosError<ApiError>
\ No newline at end of file
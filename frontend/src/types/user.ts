@@ -3,6 +3,13 @@ export interface User {
   username: string
   email: string
   is_active: boolean
+  role: string
+  // 扩展资料属性，其他用户的private属性只对管理员返回
+  attributes?: Record<string, AttributeValue>
+  // 头像和缩略图的地址，未上传头像时不返回
+  avatar_url?: string
+  avatar_thumbnail_url?: string
+  version: number
   created_at: string
   updated_at: string
   deleted_at?: string | null
@@ -39,6 +46,10 @@ export interface UpdateUserRequest {
   email?: string
   password?: string
   is_active?: boolean
+  // 修改本人邮箱或密码时需要，最近登录过的会话可以省略
+  current_password?: string
+  // 值为null时移除该属性
+  attributes?: Record<string, AttributeValue | null>
 }
 
 export interface UsersListResponse {
@@ -46,4 +57,146 @@ export interface UsersListResponse {
   total: number
   page: number
   limit: number
+}
+
+// 已注册安全密钥的账号密码正确后返回，需继续完成安全密钥验证
+export interface SecondFactorResponse {
+  method: string
+  ticket: string
+  expires_in: number
+}
+
+export interface WebAuthnCeremony {
+  ceremony_id: string
+  publicKey: any
+}
+
+export interface WebAuthnCredential {
+  id: number
+  name: string
+  transports: string[]
+  backup_eligible: boolean
+  backup_state: boolean
+  last_used_at: string | null
+  created_at: string
+}
+
+export interface EmailChangeConfirmResponse {
+  status: 'pending' | 'completed'
+  email: string
+}
+
+// 管理员模拟用户时签发的令牌，不能刷新
+export interface ImpersonationResponse {
+  token: string
+  expires_in: number
+  user: User
+}
+
+// 组织（租户），role 为当前用户在组织中的角色
+export type OrganizationRole = 'owner' | 'admin' | 'member'
+
+export interface Organization {
+  id: number
+  slug: string
+  name: string
+  role: OrganizationRole
+  created_at: string
+}
+
+export interface OrganizationListResponse {
+  organizations: Organization[]
+  // 当前令牌所在的组织，0 表示不属于任何组织
+  current_organization_id: number
+}
+
+// 创建、加入或切换组织后签发的新令牌，之前的访问令牌已失效
+export interface OrganizationSessionResponse {
+  token: string
+  refresh_token: string
+  organization: Organization
+}
+
+export interface OrganizationMember {
+  user_id: number
+  username: string
+  email: string
+  role: OrganizationRole
+  joined_at: string
+}
+
+export interface OrganizationInvitation {
+  id: number
+  email: string
+  role: OrganizationRole
+  invited_by: number
+  expires_at: string
+  created_at: string
+}
+
+// 用户的有效组，direct 为 false 表示通过子组间接所属
+export interface UserGroup {
+  id: number
+  name: string
+  direct: boolean
+}
+
+// 管理员发出的注册邀请，expired 的邀请可以重新发送
+export interface UserInvitation {
+  id: number
+  email: string
+  role: 'user' | 'admin'
+  organization_id: number | null
+  organization_name?: string
+  organization_role?: OrganizationRole
+  invited_by: number
+  expires_at: string
+  expired: boolean
+  created_at: string
+}
+
+export interface CreateUserInvitationRequest {
+  email: string
+  role: 'user' | 'admin'
+  organization_id?: number
+  organization_role?: OrganizationRole
+}
+
+// 注册页面展示的邀请信息
+export interface UserInvitationLookup {
+  email: string
+  organization_name?: string
+  expires_at: string
+}
+
+export type AttributeValue = string | number | boolean
+
+export type AttributeType = 'string' | 'number' | 'boolean' | 'date' | 'enum'
+
+// self_editable本人可以修改；admin_only只有管理员可以修改；private只有本人和管理员可见
+export type AttributeVisibility = 'self_editable' | 'admin_only' | 'private'
+
+export interface AttributeDefinition {
+  id: number
+  name: string
+  label: string
+  type: AttributeType
+  required: boolean
+  visibility: AttributeVisibility
+  pattern?: string
+  max_length?: number
+  options?: string[]
+  created_at: string
+}
+
+// name和type创建后不能修改
+export interface AttributeDefinitionRequest {
+  name?: string
+  type?: AttributeType
+  label: string
+  required: boolean
+  visibility: AttributeVisibility
+  pattern?: string
+  max_length?: number
+  options?: string[]
 }
\ No newline at end of file